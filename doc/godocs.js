@@ -6,6 +6,9 @@
  * On window load we:
  *  + Generate a table of contents (godocs_generateTOC)
  *  + Add links up to the top of the doc from each section (godocs_addTopLinks)
+ *  + Make the folding regions of a source view collapsible (godocs_addFolds)
+ *  + Show tooltips over the linked identifiers of a source view (godocs_addHovers)
+ *  + Suggest completions as a query is typed into the search box (godocs_addSuggest)
  */
 
 /* We want to do some stuff on page load (after the HTML is rendered).
@@ -20,6 +23,9 @@ if (window.addEventListener){
 function godocs_onload() {
   godocs_generateTOC();
   godocs_addTopLinks();
+  godocs_addFolds();
+  godocs_addHovers();
+  godocs_addSuggest();
 }
 
 /* Generates a table of contents: looks for h2 and h3 elements and generates
@@ -28,6 +34,8 @@ function godocs_onload() {
 function godocs_generateTOC() {
   var navbar = document.getElementById('nav');
   if (!navbar) { return; }
+  // Package pages come with a table of contents generated by godoc.
+  if (document.getElementById('pkg-index')) { return; }
 
   var toc_items = [];
 
@@ -158,3 +166,172 @@ function godocs_addTopLinks() {
     headers[i].appendChild(span);
   }
 }
+
+/* Makes the folding regions of a source view collapsible.  godoc
+ * computes the regions from the syntax tree and lists them in the
+ * array godocs_folds, as {kind, start, end} objects whose start and
+ * end are the lines of the line anchors (id="L<line>") in the source.
+ * The lines between start and end are wrapped into a span, and a
+ * marker at the beginning of the region hides and shows the span.
+ */
+function godocs_addFolds() {
+  var pre = document.getElementById('source');
+  if (!pre || typeof godocs_folds == 'undefined') { return; }
+  // Wrap the regions back to front, so that a nested region is
+  // wrapped before the region containing it.
+  for (var i = godocs_folds.length - 1; i >= 0; i--) {
+    godocs_fold(pre, godocs_folds[i]);
+  }
+}
+
+/* Returns the line of the first line anchor in node, or 0 if
+ * there is none.
+ */
+function godocs_nodeLine(node) {
+  var ELEMENT_NODE = 1;
+  if (node.nodeType != ELEMENT_NODE) { return 0; }
+  var anchors = [node];
+  if (!node.id) {
+    anchors = node.getElementsByTagName('a');
+  }
+  for (var j = 0; j < anchors.length; j++) {
+    var id = anchors[j].id;
+    if (id && id.charAt(0) == 'L') {
+      return parseInt(id.substring(1), 10);
+    }
+  }
+  return 0;
+}
+
+function godocs_fold(pre, fold) {
+  var nodes = [];
+  for (var node = pre.firstChild; node; node = node.nextSibling) {
+    var line = godocs_nodeLine(node);
+    if (line >= fold.end) { break; }
+    if (line > fold.start || nodes.length > 0) {
+      nodes.push(node);
+    }
+  }
+  if (!nodes.length) { return; }
+
+  var body = document.createElement('span');
+  pre.insertBefore(body, nodes[0]);
+  for (var j = 0; j < nodes.length; j++) {
+    body.appendChild(nodes[j]);
+  }
+
+  var marker = document.createElement('span');
+  marker.className = 'fold';
+  marker.title = 'Collapse ' + fold.kind;
+  marker.appendChild(document.createTextNode('[-]'));
+  marker.onclick = function() {
+    var collapse = body.style.display != 'none';
+    body.style.display = collapse ? 'none' : '';
+    marker.firstChild.nodeValue = collapse ? '[+]' : '[-]';
+    marker.title = (collapse ? 'Expand ' : 'Collapse ') + fold.kind;
+  };
+  pre.insertBefore(marker, body);
+}
+
+/* Gives each linked identifier of a source view a tooltip with its
+ * declaration and documentation, fetched from /hover the first time
+ * the mouse is over the identifier.
+ */
+function godocs_addHovers() {
+  var pre = document.getElementById('source');
+  if (!pre || !window.XMLHttpRequest) { return; }
+  var anchors = pre.getElementsByTagName('a');
+  for (var i = 0; i < anchors.length; i++) {
+    var offset = anchors[i].getAttribute('data-offset');
+    if (offset) {
+      anchors[i].onmouseover = godocs_hoverFunc(anchors[i], offset);
+    }
+  }
+}
+
+function godocs_hoverFunc(anchor, offset) {
+  var requested = false;
+  return function() {
+    if (requested) { return; }
+    requested = true;
+    var req = new XMLHttpRequest();
+    req.onreadystatechange = function() {
+      if (req.readyState != 4 || req.status != 200) { return; }
+      var info = window.JSON ? JSON.parse(req.responseText) : eval('(' + req.responseText + ')');
+      var title = info.decl;
+      if (info.doc) {
+        title += '\n\n' + info.doc;
+      }
+      anchor.title = title;
+    };
+    // the page sets godocs_urlPrefix if the server runs with -urlprefix
+    var prefix = window.godocs_urlPrefix || '';
+    var path = window.location.pathname;
+    if (prefix && path.indexOf(prefix + '/') == 0) {
+      path = path.substring(prefix.length);
+    }
+    req.open('GET', prefix + '/hover?file=' + encodeURIComponent(path) +
+      '&offset=' + offset, true);
+    req.send(null);
+  };
+}
+
+/* Shows a list of completions, fetched from /suggest, below the search
+ * box as a query is typed.  Each completion links to the page of a
+ * package or to the search for an identifier.
+ */
+function godocs_addSuggest() {
+  var input = document.getElementById('search');
+  if (!input || !window.XMLHttpRequest) { return; }
+  var list = document.createElement('ul');
+  list.className = 'suggest';
+  list.style.display = 'none';
+  input.parentNode.insertBefore(list, input.nextSibling);
+
+  var req = null;
+  var typed = '';
+  input.onkeyup = function() {
+    var prefix = input.value;
+    if (prefix == typed) { return; }
+    typed = prefix;
+    if (req) { req.abort(); }
+    if (!prefix) {
+      list.style.display = 'none';
+      return;
+    }
+    req = new XMLHttpRequest();
+    req.onreadystatechange = function() {
+      if (req.readyState != 4 || req.status != 200) { return; }
+      var result = window.JSON ? JSON.parse(req.responseText) : eval('(' + req.responseText + ')');
+      if (result.prefix != input.value) { return; }  // outdated
+      godocs_showSuggestions(list, result.suggestions);
+    };
+    var prefixURL = window.godocs_urlPrefix || '';
+    req.open('GET', prefixURL + '/suggest?q=' + encodeURIComponent(prefix), true);
+    req.send(null);
+  };
+  input.onblur = function() {
+    // hide the list after a click on a completion has been handled
+    setTimeout(function() { list.style.display = 'none'; }, 200);
+  };
+}
+
+function godocs_showSuggestions(list, suggestions) {
+  while (list.firstChild) {
+    list.removeChild(list.firstChild);
+  }
+  for (var i = 0; i < suggestions.length; i++) {
+    var s = suggestions[i];
+    var a = document.createElement('a');
+    a.href = s.url;
+    a.appendChild(document.createTextNode(s.text));
+    var kind = document.createElement('span');
+    kind.className = 'kind';
+    kind.appendChild(document.createTextNode(' ' + s.kind));
+    var item = document.createElement('li');
+    item.appendChild(a);
+    item.appendChild(kind);
+    list.appendChild(item);
+  }
+  list.style.display = suggestions.length > 0 ? 'block' : 'none';
+}
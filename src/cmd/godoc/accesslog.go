@@ -0,0 +1,85 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the access log of the web server. With
+//
+//	-log=file
+//
+// each request is logged, with the remote address, method, URL,
+// status code, and duration, by appending a line to file, or by
+// writing it to standard error if file is "-":
+//
+//	2009/11/10 23:00:00 127.0.0.1:4711 GET /pkg/fmt/ 200 3.2ms
+//
+// Index searches that take longer than -slow_query_ms are logged
+// in addition, so that expensive queries can be spotted.
+
+package main
+
+import (
+	"fmt";
+	"http";
+	"io";
+	"log";
+	"os";
+	"time";
+)
+
+
+// accessLog is the access log; nil if there is none.
+var accessLog *log.Logger
+
+
+// openAccessLog sets up the access log for the -log flag value name.
+func openAccessLog(name string) os.Error {
+	var w io.Writer;
+	switch name {
+	case "":
+		return nil
+	case "-":
+		w = os.Stderr
+	default:
+		f, err := os.Open(name, os.O_WRONLY|os.O_CREAT|os.O_APPEND, 0644);
+		if err != nil {
+			return err
+		}
+		w = f;
+	}
+	accessLog = log.New(w, nil, "", log.Ldate|log.Ltime);
+	return nil;
+}
+
+
+// msec formats a duration in nanoseconds as milliseconds.
+func msec(ns int64) string	{ return fmt.Sprintf("%.1fms", float64(ns)/1e6) }
+
+
+// accessLogHandler returns a handler that serves requests with h
+// and logs them to the access log; it returns h if there is none.
+func accessLogHandler(h http.Handler) http.Handler {
+	if accessLog == nil {
+		return h
+	}
+	return http.HandlerFunc(func(c *http.Conn, r *http.Request) {
+		t0 := time.Nanoseconds();
+		// h may change the URL, e.g. by removing the URL path prefix
+		url := r.URL.String();
+		h.ServeHTTP(c, r);
+		status := c.Status();
+		if status == 0 {
+			// nothing written; the server replies with an empty page
+			status = http.StatusOK
+		}
+		accessLog.Logf("%s %s %s %d %s", c.RemoteAddr, r.Method, url, status, msec(time.Nanoseconds()-t0));
+	})
+}
+
+
+// logSearch logs the index search for query, which took ns nanoseconds,
+// to the access log if it took longer than -slow_query_ms.
+func logSearch(query string, ns int64) {
+	if accessLog != nil && *slowQueryMs > 0 && ns > int64(*slowQueryMs)*1e6 {
+		accessLog.Logf("slow query %q: %s", query, msec(ns))
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the JSON API for editors and other tools.
+// A request for
+//
+//	/api/pkg/importpath	(or /api/cmd/name)
+//
+// returns the documentation of the respective package as a JSON
+// object of the form
+//
+//	{
+//		"version": 1,
+//		"path": "importpath",
+//		"package": package or null,
+//		"dirs": [ { "path": ..., "name": ..., "synopsis": ... }, ... ]
+//	}
+//
+// where package is
+//
+//	{
+//		"name": ..., "importPath": ..., "doc": ...,
+//		"files": [ filename, ... ],
+//		"consts": [ value, ... ], "vars": [ value, ... ],
+//		"funcs": [ func, ... ], "types": [ type, ... ],
+//		"bugs": [ text, ... ]
+//	}
+//
+//	value:	{ "names": [ ... ], "doc": ..., "decl": ..., "pos": pos }
+//	func:	{ "name": ..., "recv": ..., "doc": ..., "decl": ..., "pos": pos }
+//	type:	{ "name": ..., "doc": ..., "decl": ..., "pos": pos,
+//		  "consts": [ ... ], "vars": [ ... ], "factories": [ ... ], "methods": [ ... ] }
+//	pos:	{ "file": ..., "line": ... }
+//
+// Fields are only ever added to this schema; the version number
+// changes if existing fields change meaning. Responses carry an
+// ETag computed from their contents and honor If-None-Match.
+
+package main
+
+import (
+	"bytes";
+	"container/vector";
+	"fmt";
+	"go/ast";
+	"go/doc";
+	"go/token";
+	"hash/crc32";
+	"http";
+	"utf8";
+)
+
+
+const apiVersion = 1
+
+
+// ----------------------------------------------------------------------------
+// JSON encoding
+
+type jsonWriter struct {
+	bytes.Buffer;
+}
+
+
+func (w *jsonWriter) quote(s string) {
+	w.WriteByte('"');
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '\\':
+			w.WriteByte('\\');
+			w.WriteByte(byte(c));
+		case c == '\n':
+			w.WriteString(`\n`)
+		case c == '\t':
+			w.WriteString(`\t`)
+		case c < ' ' || c == utf8.RuneError:
+			fmt.Fprintf(w, `\u%04x`, c)
+		default:
+			var buf [utf8.UTFMax]byte;
+			n := utf8.EncodeRune(c, &buf);
+			w.Write(buf[0:n]);
+		}
+	}
+	w.WriteByte('"');
+}
+
+
+// key writes the name of the next field of an object;
+// first must be set for the first field.
+func (w *jsonWriter) key(name string, first bool) {
+	if !first {
+		w.WriteString(", ")
+	}
+	w.quote(name);
+	w.WriteString(": ");
+}
+
+
+func (w *jsonWriter) stringList(list []string) {
+	w.WriteByte('[');
+	for i, s := range list {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.quote(s);
+	}
+	w.WriteByte(']');
+}
+
+
+// node writes the source text of an AST node.
+func (w *jsonWriter) node(node interface{}) {
+	var buf bytes.Buffer;
+	writeNode(&buf, node, false, nil);
+	w.quote(buf.String());
+}
+
+
+func (w *jsonWriter) pos(pos token.Position) {
+	w.WriteByte('{');
+	w.key("file", true);
+	w.quote(pos.Filename);
+	w.key("line", false);
+	fmt.Fprint(w, pos.Line);
+	w.WriteByte('}');
+}
+
+
+func (w *jsonWriter) values(list []*doc.ValueDoc) {
+	w.WriteByte('[');
+	for i, v := range list {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		var names vector.StringVector;
+		names.Init(0);
+		for _, s := range v.Decl.Specs {
+			if s, ok := s.(*ast.ValueSpec); ok {
+				for _, name := range s.Names {
+					names.Push(name.Value)
+				}
+			}
+		}
+		w.WriteByte('{');
+		w.key("names", true);
+		w.stringList(names.Data());
+		w.key("doc", false);
+		w.quote(v.Doc);
+		w.key("decl", false);
+		w.node(v.Decl);
+		w.key("pos", false);
+		w.pos(v.Decl.Pos());
+		w.WriteByte('}');
+	}
+	w.WriteByte(']');
+}
+
+
+func (w *jsonWriter) funcs(list []*doc.FuncDoc) {
+	w.WriteByte('[');
+	for i, f := range list {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.WriteByte('{');
+		w.key("name", true);
+		w.quote(f.Name);
+		w.key("recv", false);
+		if f.Recv != nil {
+			w.node(f.Recv)
+		} else {
+			w.quote("")
+		}
+		w.key("doc", false);
+		w.quote(f.Doc);
+		w.key("decl", false);
+		w.node(f.Decl);
+		w.key("pos", false);
+		w.pos(f.Decl.Pos());
+		w.WriteByte('}');
+	}
+	w.WriteByte(']');
+}
+
+
+func (w *jsonWriter) types(list []*doc.TypeDoc) {
+	w.WriteByte('[');
+	for i, t := range list {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.WriteByte('{');
+		w.key("name", true);
+		w.quote(t.Type.Name.Value);
+		w.key("doc", false);
+		w.quote(t.Doc);
+		w.key("decl", false);
+		w.node(t.Decl);
+		w.key("pos", false);
+		w.pos(t.Decl.Pos());
+		w.key("consts", false);
+		w.values(t.Consts);
+		w.key("vars", false);
+		w.values(t.Vars);
+		w.key("factories", false);
+		w.funcs(t.Factories);
+		w.key("methods", false);
+		w.funcs(t.Methods);
+		w.WriteByte('}');
+	}
+	w.WriteByte(']');
+}
+
+
+func (w *jsonWriter) packageDoc(p *doc.PackageDoc) {
+	if p == nil {
+		w.WriteString("null");
+		return;
+	}
+	w.WriteByte('{');
+	w.key("name", true);
+	w.quote(p.PackageName);
+	w.key("importPath", false);
+	w.quote(p.ImportPath);
+	w.key("doc", false);
+	w.quote(p.Doc);
+	w.key("files", false);
+	w.stringList(p.Filenames);
+	w.key("consts", false);
+	w.values(p.Consts);
+	w.key("vars", false);
+	w.values(p.Vars);
+	w.key("funcs", false);
+	w.funcs(p.Funcs);
+	w.key("types", false);
+	w.types(p.Types);
+	w.key("bugs", false);
+	w.stringList(p.Bugs);
+	w.WriteByte('}');
+}
+
+
+func (w *jsonWriter) dirList(dirs *DirList) {
+	w.WriteByte('[');
+	if dirs != nil {
+		for i, d := range dirs.List {
+			if i > 0 {
+				w.WriteString(", ")
+			}
+			w.WriteByte('{');
+			w.key("path", true);
+			w.quote(d.Path);
+			w.key("name", false);
+			w.quote(d.Name);
+			w.key("synopsis", false);
+			w.quote(d.Synopsis);
+			w.WriteByte('}');
+		}
+	}
+	w.WriteByte(']');
+}
+
+
+// ----------------------------------------------------------------------------
+// HTTP handlers
+
+type apiHandler struct {
+	pattern	string;		// url pattern; e.g. "/api/pkg/"
+	h	*httpHandler;	// handler for the corresponding documentation pages
+}
+
+
+var (
+	cmdAPIHandler	= apiHandler{"/api/cmd/", &cmdHandler};
+	pkgAPIHandler	= apiHandler{"/api/pkg/", &pkgHandler};
+)
+
+
+func (a *apiHandler) ServeHTTP(c *http.Conn, r *http.Request) {
+	path := r.URL.Path;
+	path = path[len(a.pattern):len(path)];
+	info := a.h.getPageInfo(path, pageInfoMode(r));
+	if info.PDoc == nil && info.Dirs == nil {
+		http.NotFound(c, r);
+		return;
+	}
+
+	var w jsonWriter;
+	w.WriteByte('{');
+	w.key("version", true);
+	fmt.Fprint(&w, apiVersion);
+	w.key("path", false);
+	w.quote(path);
+	w.key("package", false);
+	w.packageDoc(info.PDoc);
+	w.key("dirs", false);
+	w.dirList(info.Dirs);
+	w.WriteString("}\n");
+
+	etag := fmt.Sprintf(`"%08x"`, crc32.ChecksumIEEE(w.Bytes()));
+	c.SetHeader("content-type", "application/json; charset=utf-8");
+	c.SetHeader("etag", etag);
+	if r.Header["If-None-Match"] == etag {
+		c.WriteHeader(http.StatusNotModified);
+		return;
+	}
+	c.Write(w.Bytes());
+}
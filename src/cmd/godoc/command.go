@@ -0,0 +1,252 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the command documentation under /cmd/. A command
+// is documented by the package comment of the doc.go file in its
+// directory, which by convention has a usage section
+//
+//	Usage:
+//		gofmt [flags] [path ...]
+//
+// and one or more flag sections introduced by a line ending in a colon,
+// with a flag per line indented by one tab and its description indented
+// by two tabs:
+//
+//	The flags are:
+//		-l
+//			just list files whose formatting differs from gofmt's
+//
+// The sections are shown separately from the remaining text, with the
+// command.html template. Directories without a doc.go file are shown
+// like package directories.
+
+package main
+
+import (
+	"bytes";
+	"container/vector";
+	"go/ast";
+	"go/doc";
+	"go/parser";
+	"http";
+	"log";
+	"os";
+	pathutil "path";
+	"strings";
+)
+
+
+// A CommandFlag is a flag documented in a flag section.
+type CommandFlag struct {
+	Name	string;	// as written, e.g. -tabwidth=8
+	Doc	string;
+}
+
+
+// A FlagSection is a flag section of a command comment.
+type FlagSection struct {
+	Title	string;
+	Flags	[]CommandFlag;
+}
+
+
+// A CommandInfo is the documentation of a command, or of the
+// directory of all commands.
+type CommandInfo struct {
+	Name	string;		// command name; "" for the directory of all commands
+	Doc	string;		// comment text without the usage and flag sections
+	Usage	[]string;	// lines of the usage section, or nil
+	Flags	[]FlagSection;	// flag sections, or nil
+	Dirs	*DirList;	// nil if no directory information found
+}
+
+
+// indentDepth returns the number of leading tabs of line.
+func indentDepth(line string) int {
+	n := 0;
+	for n < len(line) && line[n] == '\t' {
+		n++
+	}
+	return n;
+}
+
+
+// nextLine returns the index of the first non-blank line
+// at or after i, or len(lines) if there is none.
+func nextLine(lines []string, i int) int {
+	for i < len(lines) && lines[i] == "" {
+		i++
+	}
+	return i;
+}
+
+
+// flagTitle returns the title of the flag section introduced by
+// line, such as "Debugging flags" for "Debugging flags:".
+func flagTitle(line string) string {
+	title := line[0 : len(line)-1];
+	if strings.HasSuffix(strings.ToLower(title), "flags are") {
+		return "Flags"
+	}
+	return title;
+}
+
+
+// parseFlags parses the flags of a flag section, starting at the
+// first flag in lines[i], and returns them and the index of the
+// line after the section.
+func parseFlags(lines []string, i int) ([]CommandFlag, int) {
+	list := vector.New(0);
+	depth := indentDepth(lines[i]);
+	for ; i < len(lines) && (lines[i] == "" || indentDepth(lines[i]) > 0); i++ {
+		line := strings.TrimSpace(lines[i]);
+		switch {
+		case line == "":
+			// ignore
+		case indentDepth(lines[i]) == depth && strings.HasPrefix(line, "-"):
+			list.Push(&CommandFlag{line, ""})
+		case list.Len() > 0:
+			f := list.Last().(*CommandFlag);
+			if f.Doc != "" {
+				f.Doc += " "
+			}
+			f.Doc += line;
+		}
+	}
+	flags := make([]CommandFlag, list.Len());
+	for j := range flags {
+		flags[j] = *list.At(j).(*CommandFlag)
+	}
+	return flags, i;
+}
+
+
+// parseCommandDoc splits the text of a command comment into
+// its usage section, its flag sections, and the remaining text.
+func parseCommandDoc(text string) (rest string, usage []string, flags []FlagSection) {
+	lines := strings.Split(text, "\n", 0);
+	var buf bytes.Buffer;
+	usageList := vector.NewStringVector(0);
+	flagList := vector.New(0);
+	for i := 0; i < len(lines); {
+		line := lines[i];
+		j := nextLine(lines, i+1);
+		indented := j < len(lines) && indentDepth(lines[j]) > 0;
+		switch {
+		case line == "Usage:" && indented:
+			// usage section: the indented lines that follow
+			for ; j < len(lines) && (lines[j] == "" || indentDepth(lines[j]) > 0); j++ {
+				if lines[j] != "" {
+					usageList.Push(strings.TrimSpace(lines[j]))
+				}
+			}
+
+		case strings.HasSuffix(line, ":") && indentDepth(line) == 0 &&
+			indented && strings.HasPrefix(strings.TrimSpace(lines[j]), "-"):
+			// flag section
+			s := FlagSection{Title: flagTitle(line)};
+			s.Flags, j = parseFlags(lines, j);
+			flagList.Push(s);
+
+		default:
+			buf.WriteString(line);
+			buf.WriteByte('\n');
+			j = i + 1;
+		}
+		i = j;
+	}
+
+	if usageList.Len() > 0 {
+		usage = usageList.Data()
+	}
+	if flagList.Len() > 0 {
+		flags = make([]FlagSection, flagList.Len());
+		for i := range flags {
+			flags[i] = flagList.At(i).(FlagSection)
+		}
+	}
+	return strings.TrimSpace(buf.String()) + "\n", usage, flags;
+}
+
+
+// commandComment returns the package comment of the doc.go file
+// in dirname, or nil if there is none.
+func commandComment(dirname string) *ast.CommentGroup {
+	filename := pathutil.Join(dirname, "doc.go");
+	if d, err := os.Stat(filename); err != nil || !d.IsRegular() {
+		return nil
+	}
+	file, _ := parse(filename, parser.PackageClauseOnly|parser.ParseComments);
+	if file == nil {
+		return nil
+	}
+	return file.Doc;
+}
+
+
+// getCommandInfo returns the CommandInfo for the command directory
+// path, relative to -cmdroot, and whether the directory is the
+// directory of all commands or has a doc.go file with a package
+// comment.
+func getCommandInfo(path string) (info CommandInfo, ok bool) {
+	dirname := pathutil.Join(*cmdroot, path);
+	info.Dirs = dirListing(dirname);
+	if pathutil.Clean(dirname) == pathutil.Clean(*cmdroot) {
+		return info, true
+	}
+	comment := commandComment(dirname);
+	if comment == nil {
+		return info, false
+	}
+	_, info.Name = pathutil.Split(dirname);
+	info.Doc, info.Usage, info.Flags = parseCommandDoc(doc.CommentText(comment));
+	return info, true;
+}
+
+
+// commandHandler serves the command documentation; it falls back to
+// the package documentation handler h for text output and for
+// directories without command documentation.
+type commandHandler struct {
+	h *httpHandler;
+}
+
+
+func (ch commandHandler) ServeHTTP(c *http.Conn, r *http.Request) {
+	if r.FormValue("f") == "text" {
+		ch.h.ServeHTTP(c, r);
+		return;
+	}
+	if redirect(c, r) {
+		return
+	}
+
+	path := r.URL.Path;
+	path = path[len(ch.h.pattern):len(path)];
+	info, ok := getCommandInfo(path);
+	if !ok {
+		ch.h.ServeHTTP(c, r);
+		return;
+	}
+
+	title, sibs, content := commandPage(path, info);
+	servePage(c, title, "", breadcrumbs(r.URL.Path), sibs, content);
+}
+
+
+// commandPage returns the title, the sibling links, and the content
+// of the page for the command directory path with the given info.
+func commandPage(path string, info CommandInfo) (title string, sibs []Link, content []byte) {
+	var buf bytes.Buffer;
+	if err := getTemplate(&commandHTML).Execute(info, &buf); err != nil {
+		log.Stderrf("commandHTML.Execute: %s", err)
+	}
+
+	title = "Commands";
+	if info.Name != "" {
+		title = "Command " + info.Name;
+		sibs = siblings(pathutil.Join(*cmdroot, path));
+	}
+	return title, sibs, buf.Bytes();
+}
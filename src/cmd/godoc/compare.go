@@ -0,0 +1,205 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the comparison of the exported declarations
+// of two packages, such as a copy of a package and its original.
+// A request for
+//
+//	/compare?a=path1&b=path2
+//
+// shows the declarations that were added to, removed from, or changed
+// between the packages path1 and path2 side by side. The same report is
+// printed in command-line mode by
+//
+//	godoc -compare path1 path2
+
+package main
+
+import (
+	"bytes";
+	"go/ast";
+	"go/doc";
+	"go/token";
+	"http";
+	"log";
+	"sort";
+)
+
+
+var noPos token.Position
+
+
+// A DeclDiff describes an exported declaration in either package.
+// Old and OldDoc are empty if the declaration was added; New and
+// NewDoc are empty if it was removed.
+type DeclDiff struct {
+	Kind		string;	// "const", "var", "func", "type", or "method"
+	Name		string;	// methods are named "T.M"
+	Old, New	string;	// declaration source text
+	OldDoc, NewDoc	string;
+}
+
+
+type CompareInfo struct {
+	A, B		string;	// package paths
+	ADoc, BDoc	string;	// package documentation
+	DocChanged	bool;
+	Added		[]DeclDiff;
+	Removed		[]DeclDiff;
+	Changed		[]DeclDiff;	// changed declaration or documentation
+	Same		int;		// number of unchanged declarations
+}
+
+
+type declText struct {
+	kind	string;
+	text	string;
+	doc	string;
+}
+
+
+func nodeText(node interface{}) string {
+	var buf bytes.Buffer;
+	writeNode(&buf, node, false, nil);
+	return buf.String();
+}
+
+
+func addValues(m map[string]declText, list []*doc.ValueDoc) {
+	for _, v := range list {
+		kind := "var";
+		if v.Decl.Tok == token.CONST {
+			kind = "const"
+		}
+		// describe each name by its own spec so that changes
+		// to one value in a group don't affect the others
+		for _, s := range v.Decl.Specs {
+			if s, ok := s.(*ast.ValueSpec); ok {
+				text := nodeText(&ast.GenDecl{nil, noPos, v.Decl.Tok, noPos, []ast.Spec{s}, noPos});
+				for _, name := range s.Names {
+					m[name.Value] = declText{kind, text, v.Doc}
+				}
+			}
+		}
+	}
+}
+
+
+func addFuncs(m map[string]declText, list []*doc.FuncDoc, kind, prefix string) {
+	for _, f := range list {
+		m[prefix+f.Name] = declText{kind, nodeText(f.Decl), f.Doc}
+	}
+}
+
+
+// declTexts returns the exported declarations of pdoc by name.
+func declTexts(pdoc *doc.PackageDoc) map[string]declText {
+	m := make(map[string]declText);
+	addValues(m, pdoc.Consts);
+	addValues(m, pdoc.Vars);
+	addFuncs(m, pdoc.Funcs, "func", "");
+	for _, t := range pdoc.Types {
+		name := t.Type.Name.Value;
+		m[name] = declText{"type", nodeText(t.Decl), t.Doc};
+		addValues(m, t.Consts);
+		addValues(m, t.Vars);
+		addFuncs(m, t.Factories, "func", "");
+		addFuncs(m, t.Methods, "method", name+".");
+	}
+	return m;
+}
+
+
+func sortedNames(a, b map[string]declText) []string {
+	n := len(a);
+	for name, _ := range b {
+		if _, found := a[name]; !found {
+			n++
+		}
+	}
+	names := make([]string, n);
+	i := 0;
+	for name, _ := range a {
+		names[i] = name;
+		i++;
+	}
+	for name, _ := range b {
+		if _, found := a[name]; !found {
+			names[i] = name;
+			i++;
+		}
+	}
+	sort.SortStrings(names);
+	return names;
+}
+
+
+func appendDiff(list []DeclDiff, d DeclDiff) []DeclDiff {
+	n := len(list);
+	if n == cap(list) {
+		l := make([]DeclDiff, n, 2*n+1);
+		copy(l, list);
+		list = l;
+	}
+	list = list[0 : n+1];
+	list[n] = d;
+	return list;
+}
+
+
+// comparePackages compares the exported declarations of the
+// packages documented by a and b.
+//
+func comparePackages(pathA, pathB string, a, b *doc.PackageDoc) CompareInfo {
+	info := CompareInfo{A: pathA, B: pathB, ADoc: a.Doc, BDoc: b.Doc};
+	info.DocChanged = a.Doc != b.Doc;
+
+	da := declTexts(a);
+	db := declTexts(b);
+	for _, name := range sortedNames(da, db) {
+		x, inA := da[name];
+		y, inB := db[name];
+		switch {
+		case !inB:
+			info.Removed = appendDiff(info.Removed, DeclDiff{x.kind, name, x.text, "", x.doc, ""})
+		case !inA:
+			info.Added = appendDiff(info.Added, DeclDiff{y.kind, name, "", y.text, "", y.doc})
+		case x.text != y.text || x.doc != y.doc:
+			info.Changed = appendDiff(info.Changed, DeclDiff{y.kind, name, x.text, y.text, x.doc, y.doc})
+		default:
+			info.Same++
+		}
+	}
+	return info;
+}
+
+
+// packageDoc returns the documentation for the package or
+// command with the given path, or nil if there is none.
+//
+func packageDoc(path string) *doc.PackageDoc {
+	info := pkgHandler.getPageInfo(path, exportsOnly);
+	if info.PDoc == nil {
+		info = cmdHandler.getPageInfo(path, exportsOnly)
+	}
+	return info.PDoc;
+}
+
+
+func compare(c *http.Conn, r *http.Request) {
+	pathA := r.FormValue("a");
+	pathB := r.FormValue("b");
+	a := packageDoc(pathA);
+	b := packageDoc(pathB);
+	if pathA == "" || pathB == "" || a == nil || b == nil {
+		http.NotFound(c, r);
+		return;
+	}
+
+	var buf bytes.Buffer;
+	if err := getTemplate(&compareHTML).Execute(comparePackages(pathA, pathB, a, b), &buf); err != nil {
+		log.Stderrf("compareHTML.Execute: %s", err)
+	}
+	servePage(c, "Compare "+pathA+" and "+pathB, "", nil, nil, buf.Bytes());
+}
@@ -0,0 +1,205 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the control of a running godoc server, for use
+// as a long-lived service behind a proxy: reloading the templates and
+// the saved index, and shutting down after the requests in progress
+// have been served.
+//
+// The runtime does not deliver signals to Go programs yet, so godoc
+// cannot catch SIGHUP and SIGTERM; both terminate it at once. Until
+// it can, the operations are requested with
+//
+//	/debug/reload
+//	/debug/shutdown
+//
+// which, like the other control pages, are served only at the separate
+// -admin address. The address of a client cannot tell who may control
+// the server: behind a reverse proxy on the same host, every client
+// seems to be local.
+
+package main
+
+import (
+	"http";
+	"io";
+	"log";
+	"net";
+	"os";
+	"sync";
+)
+
+
+// A requestTracker counts the requests in progress, so that
+// the server can wait for them before it exits.
+type requestTracker struct {
+	mu		sync.Mutex;
+	active		int;		// number of requests in progress
+	draining	bool;		// set by drain
+	idle		chan bool;	// signaled when the last request completes while draining
+}
+
+
+var tracker = requestTracker{idle: make(chan bool, 1)}
+
+
+// handler returns a handler that serves requests with h
+// and counts them while they are in progress.
+func (t *requestTracker) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(c *http.Conn, r *http.Request) {
+		t.mu.Lock();
+		t.active++;
+		draining := t.draining;
+		t.mu.Unlock();
+
+		if draining {
+			// don't keep the connection alive for more requests
+			c.SetHeader("connection", "close")
+		}
+		h.ServeHTTP(c, r);
+
+		t.mu.Lock();
+		t.active--;
+		if t.active == 0 && t.draining {
+			select {
+			case t.idle <- true:
+			default:	// signaled before
+			}
+		}
+		t.mu.Unlock();
+	})
+}
+
+
+// stop makes the replies from now on close their connections, so
+// that clients do not send more requests on kept-alive connections.
+func (t *requestTracker) stop() {
+	t.mu.Lock();
+	t.draining = true;
+	t.mu.Unlock();
+}
+
+
+// drain waits until no request is in progress.
+func (t *requestTracker) drain() {
+	t.stop();
+	t.mu.Lock();
+	n := t.active;
+	t.mu.Unlock();
+	if n > 0 {
+		if *verbose {
+			log.Stderrf("waiting for %d requests in progress", n)
+		}
+		<-t.idle;
+	}
+}
+
+
+// The listener of the HTTP server; closing it stops the server.
+var server struct {
+	sync.Mutex;
+	listener	net.Listener;
+	shutdown	bool;	// listener closed by shutdown
+}
+
+
+// serve runs the HTTP server on addr. After a shutdown has closed
+// the listener, it waits for the requests in progress and returns
+// nil; otherwise it returns the error that stopped the server.
+func serve(addr string, handler http.Handler) os.Error {
+	l, err := net.Listen("tcp", addr);
+	if err != nil {
+		return err
+	}
+	server.Lock();
+	server.listener = l;
+	server.Unlock();
+
+	err = http.Serve(l, tracker.handler(handler));
+
+	server.Lock();
+	stopped := server.shutdown;
+	server.Unlock();
+	if !stopped {
+		return err
+	}
+	tracker.drain();
+	return nil;
+}
+
+
+// shutdown stops the HTTP server from accepting connections;
+// the requests in progress are completed.
+func shutdown() {
+	server.Lock();
+	defer server.Unlock();
+	if server.listener != nil && !server.shutdown {
+		server.shutdown = true;
+		tracker.stop();
+		server.listener.Close();
+	}
+}
+
+
+// serveAdmin runs the HTTP server of the control pages on addr.
+func serveAdmin(addr string, handler http.Handler) {
+	if err := http.ListenAndServe(addr, handler); err != nil {
+		log.Exitf("ListenAndServe %s: %v", addr, err)
+	}
+}
+
+
+// reload reads the templates and, if there is one, the saved index
+// again. The index replaces the current one until the indexer builds
+// a new one; it is not used if a file of the tree changed since it
+// was written.
+func reload() os.Error {
+	var index *Index;
+	if *indexFileName != "" {
+		tree, _ := fsTree.get();
+		dir, _ := tree.(*Directory);
+		if !isCurrentIndex(*indexFileName, dir) {
+			return os.NewError("saved index " + *indexFileName + " is out of date")
+		}
+		var err os.Error;
+		if index, err = OpenIndex(*indexFileName); err != nil {
+			return err
+		}
+	}
+	if err := reloadTemplates(); err != nil {
+		return err
+	}
+	if index != nil {
+		searchIndex.set(index)
+	}
+	return nil;
+}
+
+
+func serveControl(c *http.Conn, r *http.Request, op func() os.Error) {
+	c.SetHeader("content-type", "text/plain; charset=utf-8");
+	if err := op(); err != nil {
+		log.Stderrf("%s: %v", r.URL.Path, err);
+		c.WriteHeader(http.StatusInternalServerError);
+		io.WriteString(c, err.String()+"\n");
+		return;
+	}
+	if *verbose {
+		log.Stderrf("%s done", r.URL.Path)
+	}
+	io.WriteString(c, "ok\n");
+}
+
+
+func serveReload(c *http.Conn, r *http.Request) {
+	serveControl(c, r, reload)
+}
+
+
+func serveShutdown(c *http.Conn, r *http.Request) {
+	serveControl(c, r, func() os.Error {
+		shutdown();
+		return nil;
+	})
+}
@@ -0,0 +1,160 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains godoc -src, which prints the complete source
+// of declarations - function bodies, unexported fields, and comments
+// included - instead of their documentation.
+
+package main
+
+import (
+	"fmt";
+	"go/ast";
+	"go/parser";
+	"go/printer";
+	"io";
+	"log";
+	"os";
+	pathutil "path";
+)
+
+
+// A sourceFile is a parsed source file with its source text.
+type sourceFile struct {
+	path	string;
+	src	[]byte;
+	file	*ast.File;
+}
+
+
+// parsePackageFiles parses the package files in the directory dirname.
+// Files that cannot be parsed are skipped.
+func parsePackageFiles(dirname string) []sourceFile {
+	list, err := io.ReadDir(dirname);
+	if err != nil {
+		return nil
+	}
+	files := make([]sourceFile, len(list));
+	n := 0;
+	for _, d := range list {
+		if !isPkgFile(d) {
+			continue
+		}
+		path := pathutil.Join(dirname, d.Name);
+		src, err := io.ReadFile(path);
+		if err != nil {
+			continue
+		}
+		file, err := parser.ParseFile(path, src, parser.ParseComments);
+		if err != nil {
+			if *verbose {
+				log.Stderrf("%v", err)
+			}
+			continue;
+		}
+		files[n] = sourceFile{path, src, file};
+		n++;
+	}
+	return files[0:n];
+}
+
+
+// recvTypeName returns the name of the type of a method receiver.
+func recvTypeName(recv *ast.Field) string {
+	typ := recv.Type;
+	if p, ok := typ.(*ast.StarExpr); ok {
+		typ = p.X
+	}
+	if id, ok := typ.(*ast.Ident); ok {
+		return id.Value
+	}
+	return "";
+}
+
+
+// declares reports whether the top-level declaration d declares name:
+// a constant, variable, type, or function, or a method T.M.
+func declares(d ast.Decl, name string) bool {
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			return d.Name.Value == name
+		}
+		return recvTypeName(d.Recv) + "." + d.Name.Value == name;
+	case *ast.GenDecl:
+		for _, s := range d.Specs {
+			switch s := s.(type) {
+			case *ast.ValueSpec:
+				for _, id := range s.Names {
+					if id.Value == name {
+						return true
+					}
+				}
+			case *ast.TypeSpec:
+				if s.Name.Value == name {
+					return true
+				}
+			}
+		}
+	}
+	return false;
+}
+
+
+// findPackageDir returns the directory of the package or command path.
+func findPackageDir(path string) string {
+	for _, root := range []string{*pkgroot, *cmdroot} {
+		dirname := pathutil.Join(root, path);
+		if d, err := os.Stat(dirname); err == nil && d.IsDirectory() {
+			return dirname
+		}
+	}
+	return "";
+}
+
+
+// printDeclSource prints to w the complete source of the top-level
+// declarations of the package or command path that declare one of
+// names; a name of the form T.M denotes the method M of type T. A
+// constant, variable, or type declared in a group is printed with its
+// group. Each declaration is preceded by a comment with its location.
+// printDeclSource reports whether every name was found.
+func printDeclSource(w io.Writer, path string, names []string) bool {
+	dirname := findPackageDir(path);
+	if dirname == "" {
+		fmt.Fprintf(w, "no package or command %s\n", path);
+		return false;
+	}
+	files := parsePackageFiles(dirname);
+
+	cfg := printer.Config{printer.UseSpaces, *tabwidth, nil};
+	cfg.Merge(printerProfile, printerProfileMask);
+	found := true;
+	first := true;
+	for _, name := range names {
+		n := 0;
+		for _, f := range files {
+			for _, d := range f.file.Decls {
+				if !declares(d, name) {
+					continue
+				}
+				if !first {
+					fmt.Fprintln(w)
+				}
+				first = false;
+				fmt.Fprintf(w, "// %s:%d\n", f.path, d.Pos().Line);
+				if _, err := cfg.FprintDecl(w, f.src, f.file, d); err != nil {
+					log.Stderrf("%s: %v", f.path, err)
+				}
+				fmt.Fprintln(w);
+				n++;
+			}
+		}
+		if n == 0 {
+			fmt.Fprintf(w, "%s not declared in %s\n", name, path);
+			found = false;
+		}
+	}
+	return found;
+}
@@ -13,36 +13,249 @@ Without the -http flag, it prints plain text documentation to standard output an
 	godoc fmt
 	godoc fmt Printf
 
+Only exported declarations are shown; with the -all flag, the unexported
+declarations are shown as well, which helps when working on the internals
+of a package.
+
+	godoc -all go/printer
+
+The factory functions and methods of a type are listed beneath the type,
+indented; with -methods=false, they are not indented.
+
+With the -compare flag, it prints the exported declarations that differ
+between two packages, such as a copy of a package and its original.
+
+	godoc -compare container/vector mypkg/vector
+
+With the -man flag, it prints the documentation as a man page formatted
+with the man(7) macros instead, so that it can be installed in a man path.
+Packages are documented in section 3go, commands in section 1.
+
+	godoc -man fmt > /usr/local/man/man3/fmt.3go
+
+With the -query flag, it searches the index for an identifier and prints
+the package-level declarations found, with their file:line locations and
+signatures, followed by the locations of all other occurrences. The index
+is read from the -index_file if there is one and no file of the tree changed
+since it was written; otherwise it is built first, which takes a while.
+
+	godoc -query Fprintf
+
+With the -src flag, it prints the complete source of the named declarations,
+including function bodies and comments, instead of their documentation. A
+method is named by its type and method name.
+
+	godoc -src fmt Printf
+	godoc -src bytes Buffer.Write
+
+With the -remote flag, it fetches the plain text documentation from a
+running godoc web server instead of reading the local Go tree, so that no
+GOROOT is needed; the -all and -since flags are passed on to the server.
+
+	godoc -remote=godoc.example.com:6060 fmt Printf
+
 With the -http flag, it runs as a web server and presents the documentation as a web page.
 
 	godoc -http=:6060
 
 Usage:
 	godoc [flag] package [name ...]
+	godoc [flag] -compare package1 package2
+	godoc [flag] -man package [name ...]
+	godoc [flag] -query identifier
+	godoc [flag] -src package name ...
+	godoc [flag] -remote=host:port package [name ...]
 
 The flags are:
 	-v
 		verbose mode
 	-tabwidth=4
 		width of tabs in units of spaces
+	-profile=""
+		printer configuration profile (if unrooted, relative to -goroot);
+		see go/printer.ReadConfig. Settings in the profile take precedence
+		over -tabwidth
+	-banner=""
+		file with an HTML banner shown at the top of every page (if
+		unrooted, relative to -goroot), such as a compliance notice
+	-notices=""
+		file with HTML notices for parts of the tree (if unrooted,
+		relative to -goroot); each line holds a URL path, such as
+		/pkg/internal, and the HTML shown on the pages at and below it
 	-cmdroot="src/cmd"
 		root command source directory (if unrooted, relative to -goroot)
 	-tmplroot="lib/godoc"
 		root template directory (if unrooted, relative to -goroot)
+	-templates=""
+		colon-separated list of template directories (if unrooted,
+		relative to -goroot); a template found in one of them is used
+		instead of the one in -tmplroot, the first directory first
 	-pkgroot="src/pkg"
 		root package source directory (if unrooted, relative to -goroot)
 	-html
 		print HTML in command-line mode
+	-man
+		print a man page in command-line mode
+	-methods=true
+		indent the factories and methods of a type beneath it in
+		plain-text mode
+	-compare
+		compare the two packages given as arguments
+	-query=""
+		search the index for the query and print the results
+	-src
+		print the complete source of the named declarations
+	-all
+		include unexported declarations in the documentation
+	-write_html=""
+		write the documentation of all packages and commands as static
+		HTML pages to this directory, and exit
+	-since=""
+		only document the declarations added in this version or later,
+		such as r60, according to the "Since:" lines of their comments
+	-remote=""
+		address of a godoc web server, such as host:6060, to fetch the
+		documentation from in command-line mode
 	-goroot=$GOROOT
 		Go root directory
 	-http=
 		HTTP service address (e.g., '127.0.0.1:6060' or just ':6060')
+	-admin=""
+		HTTP service address of the control pages under /debug/
+		(e.g., 'localhost:6061'); they are not served if empty
+	-urlprefix=""
+		URL path prefix of the server behind a reverse proxy, such as
+		/docs; the absolute links of the pages and the redirects start
+		with it, and requests are served with or without it
 	-sync="command"
 		if this and -sync_minutes are set, run the argument as a
 		command every sync_minutes; it is intended to update the
 		repository holding the source files.
 	-sync_minutes=0
 		sync interval in minutes; sync is disabled if <= 0
+	-watchdog_minutes=10
+		index integrity check interval in minutes; checks are
+		disabled if <= 0
+	-index_file=""
+		search index file (if unrooted, relative to -goroot); if set,
+		the index is saved to this file whenever it is built, and a
+		saved index is used at startup instead of building a new one,
+		unless a file of the tree changed since it was written
+	-index_bodies=false
+		also index the words in string literals inside function bodies,
+		so that searches find messages and other text used by the code
+	-index_max_literals=1000000
+		maximum number of string literal words indexed with -index_bodies;
+		further words are dropped to bound the index size (unlimited if <= 0)
+	-index_throttle=1.0
+		fraction of the time the index workers spend indexing, between
+		0 and 1; lower values make building the index take longer but
+		leave more CPU time to the server (1.0 = full speed)
+	-index_snapshots=0
+		number of built search indexes kept, with their directory trees,
+		for rolling back with /debug/rollback
+	-fulltext=false
+		also index the words of doc comments and string literals for
+		full-text search
+	-log=""
+		access log file, or "-" for standard error; if set, each request
+		is logged with its method, URL, status code, and duration
+	-slow_query_ms=500
+		index searches taking longer than this many milliseconds are
+		logged to the access log (disabled if <= 0)
+
+The web server shows the unexported declarations of a package, too, if the
+URL has the query parameter m=all, as in /pkg/go/printer/?m=all. With
+f=text, the page is served as plain text, and the query parameter name,
+which may be repeated, limits it to the named declarations, as the names
+following the package do in command-line mode.
+
+A line such as "Since: r60" in the doc comment of a declaration records the
+release that added it. The version is shown with the declaration instead of
+the line, and the URL query parameter since=r60, like the -since flag, limits
+the documentation to the declarations added in r60 or later. Versions are
+compared with their numbers taken as numbers, so r9 is older than r10.
+
+The web server offers the same comparison at /compare?a=package1&b=package2.
+
+A package page lists the files of the package with their build constraints:
+the operating system and architecture in names such as fd_linux.go, and the
+words of "+build" lines in the comments preceding the package clause, so that
+readers can tell which files apply to their system.
+
+Package and command pages carry Last-Modified and ETag headers derived from
+the newest modification time of the package files, the directory, and the page
+templates, and conditional requests for unchanged pages are answered with 304
+Not Modified, so that browsers and proxies need not download them again.
+
+The command pages under /cmd/ are made from the package comment of each
+command's doc.go file: its "Usage:" section and its flag sections, such
+as the one following "The flags are:", are shown apart from the rest of
+the comment. /cmd/ itself lists the commands with their synopses.
+
+Comments of the form "BUG(uid): text" or "TODO(uid): text" are notes. A package
+page lists the notes of its package, and /notes lists the notes of the whole
+tree by package; marker=BUG or marker=TODO and uid=name restrict the list. The
+notes are collected when the index is built, so they are not listed while the
+index read from -index_file is in use.
+
+The search box suggests package paths and exported identifiers as a query is
+typed. The suggestions are served as JSON at /suggest?q=prefix, which returns
+the best ten completions of the prefix, regardless of case; packages are also
+found by their last path element.
+
+In the source view of a .go file, the identifiers linked to their declarations
+show the declaration and the first sentence of its documentation as a tooltip.
+The tooltip data is served as JSON at /hover?file=path&offset=n, where path is
+the URL path of the file and n the byte offset of an identifier in it.
+
+In the web pages, the indented code blocks of doc comments are shown with
+their comments highlighted, and the identifiers qualified by a package name,
+as in fmt.Println, link to the documentation of that package. Blocks that are
+not Go code are shown as plain text.
+
+The files under $GOROOT are also served unformatted at /text/ followed by
+their path, as in /text/src/pkg/fmt/print.go: as text/plain with the UTF-8
+charset if they are valid UTF-8, and as application/octet-stream otherwise.
+
+With -write_html=dir, godoc writes a snapshot of the documentation to dir
+that can be served by any web server for static files: the home page, a page
+for every package and command directory laid out like the server's URLs, as
+in dir/pkg/fmt/index.html, and an alphabetical index of the exported
+identifiers of all packages at dir/search/index.html, which replaces the
+search. Source files are not included; the links to them are removed, and
+the search box is replaced by a link to the identifier index.
+
+A godoc web server running as a service can be controlled through the
+control pages, which are served only at the -admin address, separate from
+the -http address so that they are not reachable through a reverse proxy;
+it should be a loopback address or be protected by a firewall.
+/debug/reload reads the templates and the -index_file again, unless a file
+of the tree changed since the index was written, without interrupting the
+requests in progress, and /debug/shutdown stops accepting connections and
+exits once the requests in progress have been served; the connections kept
+alive are closed after their next reply. (The runtime does not deliver
+signals to Go programs yet, so SIGHUP and SIGTERM cannot be used for these;
+they terminate godoc immediately.)
+
+If -goroot is not set and its default holds no Go tree, godoc tries $GOROOT,
+$HOME/go, and the usual install locations, such as /usr/local/go and
+/cygdrive/c/go, and serves the first Go tree found. Windows paths such as
+C:\go are accepted, path elements are matched regardless of case if there is
+no exact match, and symbolic links, as used by Cygwin mounts, are followed.
+Unless set explicitly, the package, command, and template roots are also
+looked for in alternative layouts, such as pkg instead of src/pkg. A root that
+cannot be found leaves its pages empty, or, for the template root, showing a
+notice, instead of stopping godoc. The roots in use and the locations tried
+are shown at /debug/roots, which, like /debug/reload, is served at the -admin
+address.
+
+With -index_snapshots=k, the last k search indexes built are kept. If a
+sync pulled broken sources, /debug/rollback?n=1 puts the index built before
+the one in use back into service, together with its directory tree, until
+the next sync changes files; n=-1 returns to the newer one. The snapshots
+are listed at /debug/snapshots. Like /debug/reload, these are served at the
+-admin address. The package pages always show the files as they are.
 
 When godoc runs as a web server, it creates a search index from all .go files
 under $GOROOT (excluding files starting with .). The index is created at startup
@@ -56,5 +269,50 @@ In all other cases, sync is assumed to have failed and godoc backs off running
 sync exponentially (up to 1 day). As soon as sync succeeds again (exit status 0
 or 1), the normal sync rhythm is re-established.
 
+Search results list the package-level declarations of the identifier first,
+grouped by kind: types, functions, methods, constants, and variables. They are
+followed by local declarations, other uses, and full-text matches. Packages
+declaring a type or function come before packages declaring only a constant or
+variable; otherwise packages are sorted by name and path. Results are shown in
+pages of 20 packages; the URL of a page, /search?q=query&start=index, can be
+bookmarked and shared. The additional parameter n=count sets the page size
+(up to 200).
+
+A watchdog periodically verifies the integrity of the search index. If the
+index is found to be inconsistent, or if searches repeatedly encounter invalid
+index data, the index is taken out of service and rebuilt; until the new index
+is available, searches report that indexing is in progress.
+
+The index is built by four goroutines, each parsing and indexing one directory
+at a time; unless $GOMAXPROCS is set, godoc lets them run on four CPUs. On a
+busy machine, -index_throttle=0.25, for instance, slows indexing down so that
+the workers use about a quarter of the CPU time they otherwise would.
+
+With -index_file, the index is also saved to disk in a compact form that is
+memory-mapped and searched in place, so that a restarted godoc serves searches
+immediately, without reading the whole index into memory. A saved index is
+used until the next sync changes files; remove the file to force a new index.
+
+Identifiers are indexed everywhere, including the identifiers and selectors in
+function bodies. With -index_bodies, the words of string literals in function
+bodies are indexed as uses, too; this makes the index considerably larger.
+
+With -fulltext, the words of doc comments and of all string literals are
+indexed for full-text search as well, regardless of case. Searching for a
+single word then also lists the lines containing it, with the word
+highlighted. Full-text matches are not saved with -index_file; an index read
+from a file serves identifier searches only until it is rebuilt.
+
+With each index build, godoc also checks the code examples in the package
+documentation: indented blocks of doc comments that look like Go code must
+parse, and their references to the documented package must name exported
+declarations. The broken examples are listed at /debug/examples.
+
+Functions named Example, ExampleF, ExampleT, and ExampleT_M in the _test.go
+files of a package are shown, in both the web pages and the command-line
+output, with the documentation of the package, the function F, the type T,
+and the method M of T, respectively. A lower-case suffix, as in
+ExampleF_second, distinguishes several examples of the same declaration.
+
 */
 package documentation
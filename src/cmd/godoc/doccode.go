@@ -0,0 +1,158 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the formatting of the code blocks of doc comments,
+// their runs of indented lines, in HTML pages. A block is tokenized with
+// go/scanner, and its comments are highlighted as in the source view. If
+// the block parses as an example, the identifiers qualified by the name
+// of a package link to the documentation of that package, as they do in
+// the source view. Blocks that cannot be tokenized are shown as plain
+// text, as before.
+
+package main
+
+import (
+	"bytes";
+	"go/ast";
+	"go/scanner";
+	"go/token";
+	"io";
+	"os";
+	pathutil "path";
+	"strings";
+	"template";
+)
+
+
+// A qualifierFinder collects the names used as the operand
+// of a selector expression, which may name a package.
+type qualifierFinder map[string]bool
+
+
+func (v qualifierFinder) Visit(node interface{}) bool {
+	if x, ok := node.(*ast.SelectorExpr); ok {
+		if id, ok := x.X.(*ast.Ident); ok {
+			v[id.Value] = true
+		}
+	}
+	return true;
+}
+
+
+// packagePath returns the import path of the package named name,
+// or "" if there is no such package or more than one.
+func packagePath(name string) string {
+	tree, _ := fsTree.get();
+	if tree == nil {
+		// no directory tree; try the package directory of that name
+		if d, err := os.Stat(pathutil.Join(*pkgroot, name)); err == nil && d.IsDirectory() {
+			return name
+		}
+		return "";
+	}
+	path := "";
+	n := 0;
+	prefix := *pkgroot + "/";
+	for d := range tree.(*Directory).iter(true) {
+		// consume the entire channel
+		if d.Name == name && strings.HasPrefix(d.Path, prefix) {
+			path = d.Path[len(prefix):len(d.Path)];
+			n++;
+		}
+	}
+	if n != 1 {
+		return ""	// none or ambiguous
+	}
+	return path;
+}
+
+
+// codeLinks returns the links of the identifiers of the example
+// code that refer to the exported declarations of packages, by
+// offset in code. It returns nil if code does not parse.
+func codeLinks(code string) map[int]string {
+	if !looksLikeGo(code) {
+		return nil
+	}
+	file, err := parseExample(code);
+	if err != nil {
+		return nil
+	}
+
+	// the code does not import the packages it uses; assume that
+	// a qualifier naming a package refers to that package
+	r := &resolver{
+		imports: make(map[string]string),
+		files: make(map[*ast.Ident]string),
+		links: make(map[*ast.Ident]string),
+	};
+	names := make(qualifierFinder);
+	ast.Walk(names, file);
+	for name, _ := range names {
+		if path := packagePath(name); path != "" {
+			r.imports[name] = path
+		}
+	}
+	if len(r.imports) == 0 {
+		return nil
+	}
+	ast.Walk(&scopeVisitor{r, ast.NewScope(nil), nil}, file);
+
+	// only the links to packages are kept: the declarations
+	// of the code itself have no anchors in the page
+	offset := len(examplePrefix(code));
+	links := make(map[int]string);
+	for id, link := range r.links {
+		if strings.HasPrefix(link, "/pkg/") {
+			links[id.Pos().Offset-offset] = link
+		}
+	}
+	return links;
+}
+
+
+var (
+	commentStart	= strings.Bytes(`<span class="comment">`);
+	commentEnd	= strings.Bytes("</span>");
+	linkEnd		= strings.Bytes("</a>");
+)
+
+
+// docCode is the doc.CodeFormatter of the doc comments
+// of HTML pages.
+func docCode(w io.Writer, code []byte) bool {
+	links := codeLinks(string(code));
+	var buf bytes.Buffer;
+	last := 0;
+	errors := scanner.Tokenize("", code, nil, scanner.ScanComments, func(pos token.Position, tok token.Token, lit []byte) bool {
+		if tok == token.EOF {
+			return false
+		}
+		// the white space before the token
+		template.HTMLEscape(&buf, code[last:pos.Offset]);
+		last = pos.Offset + len(lit);
+		switch tok {
+		case token.COMMENT:
+			buf.Write(commentStart);
+			template.HTMLEscape(&buf, lit);
+			buf.Write(commentEnd);
+			return true;
+		case token.IDENT:
+			if link, found := links[pos.Offset]; found {
+				buf.WriteString(`<a href="` + htmlEscape(rooted(link)) + `">`);
+				buf.Write(lit);
+				buf.Write(linkEnd);
+				return true;
+			}
+		}
+		template.HTMLEscape(&buf, lit);
+		return true;
+	});
+	if errors > 0 {
+		return false
+	}
+	template.HTMLEscape(&buf, code[last:len(code)]);
+	w.Write(buf.Bytes());
+	return true;
+}
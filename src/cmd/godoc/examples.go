@@ -0,0 +1,391 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the checking of the code examples in package
+// documentation.  Examples are the indented blocks of doc comments;
+// those that look like Go code are parsed, and selectors that refer
+// to the documented package must name one of its exported declarations.
+// The examples are checked with each index build, and the broken ones
+// are listed on the maintenance page
+//
+//	/debug/examples
+//
+// It also contains the extraction of the example functions, named
+// Example*, from the test files of a package. They are shown with the
+// documentation of the declarations they illustrate; see doc.Example.
+
+package main
+
+import (
+	"bytes";
+	"go/ast";
+	"go/doc";
+	"go/parser";
+	"http";
+	"io";
+	"log";
+	"os";
+	pathutil "path";
+	"strings";
+)
+
+
+// A BrokenExample describes an example that does not compile.
+type BrokenExample struct {
+	Pkg	string;	// import path of the package
+	Decl	string;	// declaration documented by the example; "" for the package
+	Code	string;
+	Err	string;
+}
+
+
+type ExampleReport struct {
+	Checked	int;	// number of examples checked
+	Broken	[]BrokenExample;
+}
+
+
+var exampleReport RWValue	// *ExampleReport, updated with each index build
+
+
+// exampleBlocks returns the indented blocks of the comment text,
+// with the common indentation removed.
+func exampleBlocks(text string) []string {
+	var blocks []string;
+	lines := strings.Split(text, "\n", 0);
+	for i := 0; i < len(lines); {
+		if !isIndented(lines[i]) {
+			i++;
+			continue;
+		}
+		// collect the block, including interior blank lines
+		j := i;
+		for j < len(lines) && (isIndented(lines[j]) || isBlankLine(lines[j]) && j+1 < len(lines) && isIndented(lines[j+1])) {
+			j++
+		}
+		blocks = appendString(blocks, unindent(lines[i:j]));
+		i = j;
+	}
+	return blocks;
+}
+
+
+func isIndented(line string) bool {
+	return len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && !isBlankLine(line)
+}
+
+
+func isBlankLine(line string) bool	{ return strings.TrimSpace(line) == "" }
+
+
+func unindent(lines []string) string {
+	prefix := "";
+	for i, line := range lines {
+		if isBlankLine(line) {
+			continue
+		}
+		n := 0;
+		for n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+			n++
+		}
+		if i == 0 || n < len(prefix) {
+			prefix = line[0:n]
+		}
+	}
+	var buf bytes.Buffer;
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			line = line[len(prefix):len(line)]
+		}
+		buf.WriteString(line);
+		buf.WriteByte('\n');
+	}
+	return buf.String();
+}
+
+
+func appendString(list []string, s string) []string {
+	n := len(list);
+	if n == cap(list) {
+		l := make([]string, n, 2*n+1);
+		copy(l, list);
+		list = l;
+	}
+	list = list[0 : n+1];
+	list[n] = s;
+	return list;
+}
+
+
+// looksLikeGo reports whether the example code is meant to be Go
+// source rather than, say, a command line or program output.
+// Examples eliding code with "..." are not checked.
+func looksLikeGo(code string) bool {
+	if strings.Index(code, "...") >= 0 || strings.HasPrefix(code, "$") || strings.HasPrefix(code, "%") {
+		return false
+	}
+	return strings.Index(code, "(") >= 0 || strings.Index(code, ":=") >= 0 || strings.Index(code, "{") >= 0;
+}
+
+
+func isDeclKeyword(code string) bool {
+	for _, kw := range []string{"func ", "type ", "var ", "const ", "import "} {
+		if strings.HasPrefix(code, kw) {
+			return true
+		}
+	}
+	return false;
+}
+
+
+// examplePrefix returns the text that parseExample puts in front of
+// the example code.
+func examplePrefix(code string) string {
+	if isDeclKeyword(code) {
+		return "package p "
+	}
+	return "package p func _() { ";
+}
+
+
+// parseExample parses the example code as a list of declarations or
+// statements.  Statements may be terminated by newlines.  The code is
+// wrapped so that line numbers in errors refer to the example itself.
+func parseExample(code string) (*ast.File, os.Error) {
+	const mode = parser.AutoSemicolons;
+	if isDeclKeyword(code) {
+		return parser.ParseFile("example", examplePrefix(code)+code, mode)
+	}
+	return parser.ParseFile("example", examplePrefix(code)+code+"\n}", mode);
+}
+
+
+// A selectorChecker finds selectors referring to the package pkgname
+// that do not name one of its exported declarations.
+type selectorChecker struct {
+	pkgname	string;
+	exports	map[string]bool;
+	err	string;
+}
+
+
+func (v *selectorChecker) Visit(node interface{}) bool {
+	if v.err != "" {
+		return false
+	}
+	if x, ok := node.(*ast.SelectorExpr); ok {
+		if id, ok := x.X.(*ast.Ident); ok && id.Value == v.pkgname && !v.exports[x.Sel.Value] {
+			v.err = x.Sel.Pos().String() + ": " + v.pkgname + "." + x.Sel.Value + " is not declared by the package";
+			return false;
+		}
+	}
+	return true;
+}
+
+
+func addValueNames(m map[string]bool, list []*doc.ValueDoc) {
+	for _, v := range list {
+		for _, s := range v.Decl.Specs {
+			if s, ok := s.(*ast.ValueSpec); ok {
+				for _, name := range s.Names {
+					m[name.Value] = true
+				}
+			}
+		}
+	}
+}
+
+
+// exportedNames returns the names of the exported package-level
+// declarations of pdoc.
+func exportedNames(pdoc *doc.PackageDoc) map[string]bool {
+	m := make(map[string]bool);
+	addValueNames(m, pdoc.Consts);
+	addValueNames(m, pdoc.Vars);
+	for _, f := range pdoc.Funcs {
+		m[f.Name] = true
+	}
+	for _, t := range pdoc.Types {
+		m[t.Type.Name.Value] = true;
+		addValueNames(m, t.Consts);
+		addValueNames(m, t.Vars);
+		for _, f := range t.Factories {
+			m[f.Name] = true
+		}
+	}
+	return m;
+}
+
+
+// checkExample returns a description of the problem with
+// the example code, or "" if the code compiles.
+func checkExample(code string, v *selectorChecker) string {
+	file, err := parseExample(code);
+	if err != nil {
+		return err.String()
+	}
+	v.err = "";
+	for _, d := range file.Decls {
+		ast.Walk(v, d)
+	}
+	return v.err;
+}
+
+
+// checkDocExamples checks the examples of a single doc comment.
+func (r *ExampleReport) checkDocExamples(path, decl, text string, v *selectorChecker) {
+	for _, code := range exampleBlocks(text) {
+		if !looksLikeGo(code) {
+			continue
+		}
+		r.Checked++;
+		if msg := checkExample(code, v); msg != "" {
+			n := len(r.Broken);
+			if n == cap(r.Broken) {
+				l := make([]BrokenExample, n, 2*n+1);
+				copy(l, r.Broken);
+				r.Broken = l;
+			}
+			r.Broken = r.Broken[0 : n+1];
+			r.Broken[n] = BrokenExample{path, decl, code, msg};
+		}
+	}
+}
+
+
+func (r *ExampleReport) checkFuncExamples(path, prefix string, list []*doc.FuncDoc, v *selectorChecker) {
+	for _, f := range list {
+		r.checkDocExamples(path, prefix+f.Name, f.Doc, v)
+	}
+}
+
+
+func (r *ExampleReport) checkValueExamples(path string, list []*doc.ValueDoc, v *selectorChecker) {
+	for _, c := range list {
+		name := "";
+		if s, ok := c.Decl.Specs[0].(*ast.ValueSpec); ok && len(s.Names) > 0 {
+			name = s.Names[0].Value
+		}
+		r.checkDocExamples(path, name, c.Doc, v)
+	}
+}
+
+
+// checkPackageExamples checks the examples of the package documentation pdoc.
+func (r *ExampleReport) checkPackageExamples(pdoc *doc.PackageDoc) {
+	path := pdoc.ImportPath;
+	v := &selectorChecker{pdoc.PackageName, exportedNames(pdoc), ""};
+	r.checkDocExamples(path, "", pdoc.Doc, v);
+	r.checkValueExamples(path, pdoc.Consts, v);
+	r.checkValueExamples(path, pdoc.Vars, v);
+	r.checkFuncExamples(path, "", pdoc.Funcs, v);
+	for _, t := range pdoc.Types {
+		name := t.Type.Name.Value;
+		r.checkDocExamples(path, name, t.Doc, v);
+		r.checkFuncExamples(path, "", t.Factories, v);
+		r.checkFuncExamples(path, name+".", t.Methods, v);
+	}
+}
+
+
+// checkExamples checks the examples of all packages under pkgroot.
+func checkExamples() *ExampleReport {
+	r := new(ExampleReport);
+	root := newDirectory(*pkgroot, maxDirDepth);
+	for d := range root.iter(false) {
+		filter := func(f *os.Dir) bool {
+			return isPkgFile(f) && pkgName(pathutil.Join(d.Path, f.Name)) == d.Name
+		};
+		pkg, err := parser.ParsePackage(d.Path, filter, parser.ParseComments);
+		if err != nil {
+			continue	// no package or parse errors; nothing to check
+		}
+		ast.PackageExports(pkg);
+		path := d.Path;
+		if strings.HasPrefix(path, *pkgroot+"/") {
+			path = path[len(*pkgroot)+1 : len(path)]
+		}
+		r.checkPackageExamples(doc.NewPackageDoc(pkg, path));
+	}
+	return r;
+}
+
+
+// updateExamples checks the examples and records the report.
+func updateExamples() {
+	r := checkExamples();
+	exampleReport.set(r);
+	if *verbose {
+		log.Stderrf("examples checked (%d examples, %d broken)", r.Checked, len(r.Broken))
+	}
+}
+
+
+func serveExamples(c *http.Conn, r *http.Request) {
+	report, _ := exampleReport.get();
+	if report == nil {
+		http.NotFound(c, r);
+		return;
+	}
+
+	var buf bytes.Buffer;
+	if err := getTemplate(&examplesHTML).Execute(report, &buf); err != nil {
+		log.Stderrf("examplesHTML.Execute: %s", err)
+	}
+	servePage(c, "Broken examples", "", nil, nil, buf.Bytes());
+}
+
+
+// testFiles returns the ASTs of the test files in the directory
+// dirname; files that cannot be parsed are skipped.
+func testFiles(dirname string) []*ast.File {
+	list, err := io.ReadDir(dirname);
+	if err != nil {
+		return nil
+	}
+	files := make([]*ast.File, len(list));
+	n := 0;
+	for _, d := range list {
+		if !isGoFile(d) || !strings.HasSuffix(d.Name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(pathutil.Join(dirname, d.Name), nil, parser.ParseComments);
+		if err == nil {
+			files[n] = file;
+			n++;
+		}
+	}
+	return files[0:n];
+}
+
+
+// exampleCode returns the source of the body of an example function,
+// without the enclosing braces and unindented; optionally html-escaped.
+func exampleCode(body *ast.BlockStmt, html bool) string {
+	var buf bytes.Buffer;
+	writeNode(&buf, body, html, nil);	// no styler: line tags would break the unindentation
+	lines := strings.Split(buf.String(), "\n", 0);
+	if len(lines) < 2 {
+		return ""	// empty body
+	}
+	return unindent(lines[1 : len(lines)-1]);	// lines 0 and n-1 hold the braces
+}
+
+
+// Template formatter for "example-html" format.
+func exampleHTMLFmt(w io.Writer, x interface{}, format string) {
+	io.WriteString(w, exampleCode(x.(*ast.BlockStmt), true))
+}
+
+
+// Template formatter for "example" format; the code is indented.
+func exampleFmt(w io.Writer, x interface{}, format string) {
+	lines := strings.Split(exampleCode(x.(*ast.BlockStmt), false), "\n", 0);
+	for _, line := range lines[0 : len(lines)-1] {	// the code ends in a newline
+		if line != "" {
+			io.WriteString(w, "\t"+line)
+		}
+		io.WriteString(w, "\n");
+	}
+}
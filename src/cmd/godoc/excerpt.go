@@ -0,0 +1,261 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the computation of source excerpts for the
+// local declarations found by a search: instead of a bare line
+// number, a search result shows the declaring statement or the
+// signature of the declaring function, printed from the AST.
+//
+// Excerpts are computed when a result page is rendered rather than
+// when the index is built, since most are never looked at. They are
+// cached until the index changes.
+
+package main
+
+import (
+	"bytes";
+	"go/ast";
+	"go/parser";
+	"go/token";
+	"io";
+	"strings";
+	"sync";
+)
+
+
+// maxExcerpts is the number of excerpts cached; the cache is
+// cleared when it is full.
+const maxExcerpts = 10000
+
+
+type excerptKey struct {
+	path	string;
+	line	int;
+	word	string;
+}
+
+
+var excerpts struct {
+	mu		sync.Mutex;
+	timestamp	int64;	// timestamp of the index the cached excerpts belong to
+	cache		map[excerptKey]string;
+}
+
+
+// cachedExcerpt returns the excerpt for key cached for the index
+// with the given timestamp, if any.
+func cachedExcerpt(key excerptKey, timestamp int64) (text string, found bool) {
+	excerpts.mu.Lock();
+	if excerpts.timestamp == timestamp && excerpts.cache != nil {
+		text, found = excerpts.cache[key]
+	}
+	excerpts.mu.Unlock();
+	return;
+}
+
+
+// cacheExcerpt caches the excerpt text for key and the index
+// with the given timestamp.
+func cacheExcerpt(key excerptKey, timestamp int64, text string) {
+	excerpts.mu.Lock();
+	if excerpts.timestamp != timestamp || len(excerpts.cache) >= maxExcerpts {
+		excerpts.timestamp = timestamp;
+		excerpts.cache = make(map[excerptKey]string);
+	}
+	excerpts.cache[key] = text;
+	excerpts.mu.Unlock();
+}
+
+
+// An excerptFinder finds the node declaring the identifier word
+// at line: the statement, specification, or function signature
+// best suited as an excerpt of the declaration.
+type excerptFinder struct {
+	word	string;
+	line	int;
+	id	*ast.Ident;		// the declared identifier, once found
+	node	interface{};		// the node to print, once found
+	doc	*ast.CommentGroup;	// the doc comment of the declaration, if any
+}
+
+
+func (f *excerptFinder) match(id *ast.Ident) bool {
+	if f.id == nil && id != nil && id.Value == f.word && id.Pos().Line == f.line {
+		f.id = id;
+		return true;
+	}
+	return false;
+}
+
+
+func (f *excerptFinder) matchExpr(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident);
+	return ok && f.match(id);
+}
+
+
+func (f *excerptFinder) matchFields(list []*ast.Field) bool {
+	for _, field := range list {
+		for _, id := range field.Names {
+			if f.match(id) {
+				return true
+			}
+		}
+	}
+	return false;
+}
+
+
+func (f *excerptFinder) matchSignature(t *ast.FuncType) bool {
+	return f.matchFields(t.Params) || f.matchFields(t.Results)
+}
+
+
+func (f *excerptFinder) Visit(node interface{}) bool {
+	if f.node != nil {
+		return false	// found
+	}
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		if f.match(n.Name) || n.Recv != nil && f.matchFields([]*ast.Field{n.Recv}) || f.matchSignature(n.Type) {
+			// only use the function signature
+			f.doc = n.Doc;
+			f.node = &ast.FuncDecl{nil, n.Recv, n.Name, n.Type, nil};
+			return false;
+		}
+	case *ast.FuncLit:
+		if f.matchSignature(n.Type) {
+			f.node = n.Type;
+			return false;
+		}
+	case *ast.GenDecl:
+		for _, s := range n.Specs {
+			switch s := s.(type) {
+			case *ast.ValueSpec:
+				for _, id := range s.Names {
+					f.match(id)
+				}
+				f.doc = s.Doc;
+			case *ast.TypeSpec:
+				f.match(s.Name);
+				f.doc = s.Doc;
+			}
+			if f.id != nil {
+				if f.doc == nil {
+					f.doc = n.Doc
+				}
+				// only use the spec containing the identifier
+				f.node = &ast.GenDecl{nil, n.Position, n.Tok, n.Lparen, []ast.Spec{s}, n.Rparen};
+				return false;
+			}
+		}
+	case *ast.AssignStmt:
+		if n.Tok == token.DEFINE {
+			for _, x := range n.Lhs {
+				if f.matchExpr(x) {
+					f.node = n;
+					return false;
+				}
+			}
+		}
+	case *ast.RangeStmt:
+		if n.Tok == token.DEFINE && (f.matchExpr(n.Key) || n.Value != nil && f.matchExpr(n.Value)) {
+			// don't print the loop body
+			f.node = &ast.RangeStmt{n.Position, n.Key, n.Value, n.TokPos, n.Tok, n.X, &ast.BlockStmt{Position: n.Body.Pos(), Rbrace: n.Body.Pos()}};
+			return false;
+		}
+	}
+	return true;
+}
+
+
+// excerpt returns the HTML excerpt of the declaration of word at
+// line in file, with the declared identifier highlighted. If file
+// is nil or no declaration is found, the excerpt is the source line.
+func excerpt(file *ast.File, src []byte, line int, word string) string {
+	if file != nil {
+		f := excerptFinder{word: word, line: line};
+		ast.Walk(&f, file);
+		if f.node != nil {
+			var buf bytes.Buffer;
+			writeNode(&buf, f.node, true, &snippetStyler{highlight: f.id});
+			return buf.String();
+		}
+	}
+	lines := strings.Split(string(src), "\n", 0);
+	if 0 < line && line <= len(lines) {
+		return highlightWord(lines[line-1], word)
+	}
+	return "";
+}
+
+
+// localResults splits the occurrences in hits into the local
+// declarations and the uses of word. It returns the local declarations
+// with their excerpts, and a copy of hits with the uses only. The index
+// timestamp identifies the cached excerpts that are still valid.
+func localResults(hits HitList, word string, timestamp int64) (decls []TextPak, uses HitList) {
+	decls = make([]TextPak, len(hits));
+	uses = make(HitList, len(hits));
+	nd, nu := 0, 0;
+	for _, p := range hits {
+		dfiles := make([]TextFile, len(p.Files));
+		ufiles := make([]*FileRun, len(p.Files));
+		ndf, nuf := 0, 0;
+		for _, f := range p.Files {
+			n := 0;
+			for _, g := range f.Groups {
+				if g.Kind != Use {
+					n += len(g.Infos)
+				}
+			}
+			lines := make([]TextLine, n);
+			groups := make([]*KindRun, len(f.Groups));
+			n, ng := 0, 0;
+			parsed := false;
+			var file *ast.File;
+			var src []byte;
+			for _, g := range f.Groups {
+				if g.Kind == Use {
+					groups[ng] = g;
+					ng++;
+					continue;
+				}
+				for _, info := range g.Infos {
+					key := excerptKey{f.File.Path, info.Lori(), word};
+					text, found := cachedExcerpt(key, timestamp);
+					if !found {
+						if !parsed {
+							// parse the file only once for all its excerpts
+							src, _ = io.ReadFile(f.File.Path);
+							file, _ = parser.ParseFile(f.File.Path, src, 0);
+							parsed = true;
+						}
+						text = excerpt(file, src, key.line, word);
+						cacheExcerpt(key, timestamp, text);
+					}
+					lines[n] = TextLine{key.line, text};
+					n++;
+				}
+			}
+			if n > 0 {
+				dfiles[ndf] = TextFile{f.File, lines};
+				ndf++;
+			}
+			if ng > 0 {
+				ufiles[nuf] = &FileRun{f.File, groups[0:ng]};
+				nuf++;
+			}
+		}
+		if ndf > 0 {
+			decls[nd] = TextPak{p.Pak, dfiles[0:ndf]};
+			nd++;
+		}
+		if nuf > 0 {
+			uses[nu] = &PakRun{p.Pak, ufiles[0:nuf]};
+			nu++;
+		}
+	}
+	return decls[0:nd], uses[0:nu];
+}
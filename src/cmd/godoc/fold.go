@@ -0,0 +1,93 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the computation of the folding regions
+// of a source file: the parts of the source view that can be
+// collapsed and expanded without parsing the source again in
+// the browser.
+
+package main
+
+import (
+	"bytes";
+	"fmt";
+	"go/ast";
+	"go/token";
+	"io";
+)
+
+
+// A Fold is a region of a source file that can be collapsed.
+// Start and End are the (1-based) source lines of the first and
+// last line of the region; when collapsed, the lines between them
+// are hidden. Start and End are the lines of the line tags written
+// by Styler.LineTag.
+type Fold struct {
+	Kind		string;	// "import", "func", or "comment"
+	Start, End	int;
+}
+
+
+// endLine returns the line of the last character of comment c.
+func endLine(c *ast.Comment) int {
+	return c.Pos().Line + bytes.Count(c.Text, []byte{'\n'})
+}
+
+
+// folds returns the folding regions of file in source order:
+// parenthesized import declarations, function and method bodies,
+// and comment groups spanning more than one line.
+func folds(file *ast.File) []Fold {
+	var list []Fold;
+	add := func(kind string, start, end token.Position) {
+		if end.Line > start.Line {
+			n := len(list);
+			if n == cap(list) {
+				l := make([]Fold, n, 2*n+8);
+				copy(l, list);
+				list = l;
+			}
+			list = list[0 : n+1];
+			list[n] = Fold{kind, start.Line, end.Line};
+		}
+	};
+
+	// comments and declarations are each in source order; merge them
+	g := file.Comments;
+	for _, d := range file.Decls {
+		for ; g != nil && g.List[0].Pos().Offset < d.Pos().Offset; g = g.Next {
+			add("comment", g.List[0].Pos(), token.Position{Line: endLine(g.List[len(g.List)-1])})
+		}
+		switch d := d.(type) {
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT && d.Lparen.IsValid() {
+				add("import", d.Lparen, d.Rparen)
+			}
+		case *ast.FuncDecl:
+			if d.Body != nil {
+				add("func", d.Body.Pos(), d.Body.Rbrace)
+			}
+		}
+	}
+	for ; g != nil; g = g.Next {
+		add("comment", g.List[0].Pos(), token.Position{Line: endLine(g.List[len(g.List)-1])})
+	}
+
+	return list;
+}
+
+
+// writeFolds writes the folding regions of file to w as the
+// JavaScript array godocs_folds, which godocs.js uses to make
+// the regions of the source view collapsible.
+func writeFolds(w io.Writer, file *ast.File) {
+	fmt.Fprint(w, "<script type=\"text/javascript\">\nvar godocs_folds = [");
+	for i, f := range folds(file) {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprintf(w, "\n  {kind: %q, start: %d, end: %d}", f.Kind, f.Start, f.End);
+	}
+	fmt.Fprint(w, "\n];\n</script>\n");
+}
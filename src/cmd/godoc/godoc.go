@@ -6,6 +6,7 @@ package main
 
 import (
 	"bytes";
+	"container/vector";
 	"flag";
 	"fmt";
 	"go/ast";
@@ -19,6 +20,7 @@ import (
 	"log";
 	"os";
 	pathutil "path";
+	"strconv";
 	"strings";
 	"sync";
 	"template";
@@ -82,9 +84,18 @@ var (
 	cmdroot		= flag.String("cmdroot", "src/cmd", "root command source directory (if unrooted, relative to goroot)");
 	pkgroot		= flag.String("pkgroot", "src/pkg", "root package source directory (if unrooted, relative to goroot)");
 	tmplroot	= flag.String("tmplroot", "lib/godoc", "root template directory (if unrooted, relative to goroot)");
+	tmpldirs	= flag.String("templates", "", "colon-separated list of template directories searched before -tmplroot (if unrooted, relative to goroot)");
 
 	// layout control
 	tabwidth	= flag.Int("tabwidth", 4, "tab width");
+	profile		= flag.String("profile", "", "printer configuration profile (if unrooted, relative to goroot)");
+
+	// page decorations
+	bannerFile	= flag.String("banner", "", "file with an HTML banner shown on every page (if unrooted, relative to goroot)");
+	noticeFile	= flag.String("notices", "", "file mapping URL paths to HTML notices shown on their pages (if unrooted, relative to goroot)");
+
+	// links
+	urlPrefix	= flag.String("urlprefix", "", "URL path prefix of the server behind a proxy, such as /docs; prepended to the absolute links of the pages");
 )
 
 
@@ -257,7 +268,8 @@ func newDirTree(path, name string, depth, maxDepth int) *Directory {
 // subdirectories containing package files (transitively).
 //
 func newDirectory(root string, maxDepth int) *Directory {
-	d, err := os.Lstat(root);
+	// follow a symbolic link at the root, such as a mounted pkgroot
+	d, err := os.Stat(root);
 	if err != nil || !isPkgDir(d) {
 		return nil
 	}
@@ -517,13 +529,131 @@ func (s *Styler) Token(tok token.Token) (text []byte, tag printer.HTMLTag) {
 // ----------------------------------------------------------------------------
 // Templates
 
+// printerProfile holds the printer settings read from the -profile
+// file; it is nil if no profile is used.
+var printerProfile *printer.Config
+
+
+// printerProfileMask holds the mode flags set by the -profile file.
+var printerProfileMask uint
+
+
+// readProfile reads the printer configuration profile, if any.
+// Like the templates, it is read after main has chdir'ed to goroot.
+func readProfile() {
+	if *profile == "" {
+		return
+	}
+	f, err := os.Open(*profile, os.O_RDONLY, 0);
+	if err != nil {
+		log.Exitf("%v", err)
+	}
+	defer f.Close();
+	cfg, mask, err := printer.ReadConfig(f);
+	if err != nil {
+		log.Exitf("%s: %v", *profile, err)
+	}
+	printerProfile = cfg;
+	printerProfileMask = mask &^ printer.GenHTML;	// godoc decides what is HTML
+}
+
+
+// pageBanner holds the HTML read from the -banner file;
+// it is nil if no banner is used.
+var pageBanner []byte
+
+
+// A notice is an HTML fragment shown on the pages below a URL path.
+type notice struct {
+	path	string;	// URL path without trailing '/'
+	html	[]byte;
+}
+
+
+// pageNotices holds the notices read from the -notices file.
+var pageNotices []notice
+
+
+// readDecorations reads the page banner and notices, if any.
+// Like the templates, they are read after main has chdir'ed to goroot.
+func readDecorations() {
+	if *bannerFile != "" {
+		data, err := io.ReadFile(*bannerFile);
+		if err != nil {
+			log.Exitf("ReadFile %s: %v", *bannerFile, err)
+		}
+		pageBanner = data;
+	}
+	if *noticeFile != "" {
+		data, err := io.ReadFile(*noticeFile);
+		if err != nil {
+			log.Exitf("ReadFile %s: %v", *noticeFile, err)
+		}
+		pageNotices = parseNotices(string(data));
+	}
+}
+
+
+// parseNotices parses the contents of a notices file. Each line
+// consists of a URL path, such as /pkg/internal, followed by blanks
+// and the HTML of the notice for the pages at and below that path.
+// Empty lines and lines starting with '#' are ignored.
+func parseNotices(text string) []notice {
+	var list []notice;
+	for _, line := range strings.Split(text, "\n", 0) {
+		line = strings.TrimSpace(line);
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		i := 0;
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		if i == len(line) {
+			log.Stderrf("%s: missing notice for %s", *noticeFile, line);
+			continue;
+		}
+		path := pathutil.Clean("/" + line[0:i]);
+		if path == "/" {
+			path = ""
+		}
+		n := len(list);
+		if n == cap(list) {
+			l := make([]notice, n, 2*n+1);
+			copy(l, list);
+			list = l;
+		}
+		list = list[0 : n+1];
+		list[n] = notice{path, strings.Bytes(strings.TrimSpace(line[i:len(line)]))};
+	}
+	return list;
+}
+
+
+// pageNotice returns the notice for the page at the URL path,
+// or nil. If several notices apply, the most specific one wins.
+func pageNotice(path string) []byte {
+	var html []byte;
+	best := -1;
+	for _, n := range pageNotices {
+		if len(n.path) > best && (path == n.path || strings.HasPrefix(path, n.path+"/")) {
+			html = n.html;
+			best = len(n.path);
+		}
+	}
+	return html;
+}
+
+
 // Write an AST-node to w; optionally html-escaped.
 func writeNode(w io.Writer, node interface{}, html bool, styler printer.Styler) {
 	mode := printer.UseSpaces;
 	if html {
 		mode |= printer.GenHTML
 	}
-	(&printer.Config{mode, *tabwidth, styler}).Fprint(w, node);
+	cfg := printer.Config{mode, *tabwidth, styler};
+	cfg.Merge(printerProfile, printerProfileMask);
+	cfg.Fprint(w, node);
 }
 
 
@@ -570,7 +700,7 @@ func htmlFmt(w io.Writer, x interface{}, format string) {
 func htmlCommentFmt(w io.Writer, x interface{}, format string) {
 	var buf bytes.Buffer;
 	writeAny(&buf, x, false);
-	doc.ToHTML(w, buf.Bytes());	// does html-escaping
+	doc.ToHTMLCode(w, buf.Bytes(), docCode);	// does html-escaping
 }
 
 
@@ -580,6 +710,28 @@ func textFmt(w io.Writer, x interface{}, format string) {
 }
 
 
+// Template formatter for "method" format: the declaration or doc
+// comment of a factory function or method, indented beneath its
+// type unless -methods=false.
+func methodFmt(w io.Writer, x interface{}, format string) {
+	if !*methods {
+		writeAny(w, x, false);
+		return;
+	}
+	var buf bytes.Buffer;
+	writeAny(&buf, x, false);
+	lines := strings.Split(buf.String(), "\n", 0);
+	for i, line := range lines {
+		if i > 0 {
+			io.WriteString(w, "\n")
+		}
+		if line != "" {
+			io.WriteString(w, "\t"+line)
+		}
+	}
+}
+
+
 func removePrefix(s, prefix string) string {
 	if strings.HasPrefix(s, prefix) {
 		return s[len(prefix):len(s)]
@@ -593,7 +745,7 @@ func pathFmt(w io.Writer, x interface{}, format string) {
 	// TODO(gri): Need to find a better solution for this.
 	//            This will not work correctly if *cmdroot
 	//            or *pkgroot change.
-	writeAny(w, removePrefix(x.(string), "src"), true)
+	writeAny(w, rooted(removePrefix(x.(string), "src")), true)
 }
 
 
@@ -607,7 +759,7 @@ func linkFmt(w io.Writer, x interface{}, format string) {
 		if pos.IsValid() {
 			// line id's in html-printed source are of the
 			// form "L%d" where %d stands for the line number
-			fmt.Fprintf(w, "/%s#L%d", htmlEscape(pos.Filename), pos.Line)
+			fmt.Fprintf(w, "%s/%s#L%d", htmlEscape(urlRoot()), htmlEscape(pos.Filename), pos.Line)
 		}
 	}
 }
@@ -632,29 +784,22 @@ func infoKindFmt(w io.Writer, x interface{}, format string) {
 }
 
 
-// Template formatter for "infoLine" format.
-func infoLineFmt(w io.Writer, x interface{}, format string) {
-	info := x.(SpotInfo);
-	line := info.Lori();
-	if info.IsIndex() {
-		index, _ := searchIndex.get();
-		line = index.(*Index).Snippet(line).Line;
-	}
-	fmt.Fprintf(w, "%d", line);
+// The strings in kindTitles must be properly html-escaped.
+var kindTitles = [nKinds]string{
+	PackageClause: "Package clauses",
+	ImportDecl: "Imports",
+	ConstDecl: "Constants",
+	TypeDecl: "Types",
+	VarDecl: "Variables",
+	FuncDecl: "Functions",
+	MethodDecl: "Methods",
+	Use: "Uses",
 }
 
 
-// Template formatter for "infoSnippet" format.
-func infoSnippetFmt(w io.Writer, x interface{}, format string) {
-	info := x.(SpotInfo);
-	text := `<span class="alert">no snippet text available</span>`;
-	if info.IsIndex() {
-		index, _ := searchIndex.get();
-		// no escaping of snippet text needed;
-		// snippet text is escaped when generated
-		text = index.(*Index).Snippet(info.Lori()).Text;
-	}
-	fmt.Fprint(w, text);
+// Template formatter for "kindTitle" format.
+func kindTitleFmt(w io.Writer, x interface{}, format string) {
+	fmt.Fprint(w, kindTitles[x.(SpotKind)])	// kindTitles entries are html-escaped
 }
 
 
@@ -677,61 +822,239 @@ var fmap = template.FormatterMap{
 	"": textFmt,
 	"html": htmlFmt,
 	"html-comment": htmlCommentFmt,
+	"example": exampleFmt,
+	"example-html": exampleHTMLFmt,
 	"path": pathFmt,
 	"link": linkFmt,
+	"man": manFmt,
+	"method": methodFmt,
+	"man-comment": manCommentFmt,
+	"man-synopsis": manSynopsisFmt,
 	"infoKind": infoKindFmt,
-	"infoLine": infoLineFmt,
-	"infoSnippet": infoSnippetFmt,
+	"kindTitle": kindTitleFmt,
 	"padding": paddingFmt,
+	"prefix": prefixFmt,
 	"time": timeFmt,
+	"url": urlFmt,
 }
 
 
-func readTemplate(name string) *template.Template {
-	path := pathutil.Join(*tmplroot, name);
+// templatePath returns the path of the template file name: the file
+// in the first of the -templates directories that has one, or else the
+// file in -tmplroot. This way, a site can override individual templates.
+func templatePath(name string) string {
+	if *tmpldirs != "" {
+		for _, dir := range strings.Split(*tmpldirs, ":", 0) {
+			if dir == "" {
+				continue
+			}
+			path := pathutil.Join(dir, name);
+			if d, err := os.Stat(path); err == nil && d.IsRegular() {
+				if *verbose {
+					log.Stderrf("using template %s", path)
+				}
+				return path;
+			}
+		}
+	}
+	return pathutil.Join(*tmplroot, name);
+}
+
+
+// parseTemplate reads and parses the template file name.
+func parseTemplate(name string) (*template.Template, os.Error) {
+	path := templatePath(name);
 	data, err := io.ReadFile(path);
 	if err != nil {
-		log.Exitf("ReadFile %s: %v", path, err)
+		return nil, err
 	}
 	t, err := template.Parse(string(data), fmap);
 	if err != nil {
-		log.Exitf("%s: %v", name, err)
+		return nil, &os.PathError{"parse", path, err}
+	}
+	return t, nil;
+}
+
+
+// readTemplate reads the template file name. A template that cannot be
+// read, as when -tmplroot was not found, is replaced by a notice saying
+// so, so that the rest of the server keeps working.
+func readTemplate(name string) *template.Template {
+	t, err := parseTemplate(name);
+	if err != nil {
+		log.Stderrf("%v; using a placeholder", err);
+		t = template.MustParse("godoc: template "+name+" is missing\n", nil);
 	}
 	return t;
 }
 
 
 var (
-	dirlistHTML,
+	commandHTML,
+		compareHTML,
+		compareText,
+		dirlistHTML,
+		examplesHTML,
 		godocHTML,
+		identifiersHTML,
+		notesHTML,
 		packageHTML,
+		packageMan,
 		packageText,
 		parseerrorHTML,
 		parseerrorText,
 		searchHTML *template.Template;
 )
 
+// A templateVar is a template file and the variable holding it.
+type templateVar struct {
+	name	string;
+	t	**template.Template;
+}
+
+
+var templateVars = []templateVar{
+	templateVar{"command.html", &commandHTML},
+	templateVar{"compare.html", &compareHTML},
+	templateVar{"compare.txt", &compareText},
+	templateVar{"dirlist.html", &dirlistHTML},
+	templateVar{"examples.html", &examplesHTML},
+	templateVar{"godoc.html", &godocHTML},
+	templateVar{"identifiers.html", &identifiersHTML},
+	templateVar{"notes.html", &notesHTML},
+	templateVar{"package.html", &packageHTML},
+	templateVar{"package.man", &packageMan},
+	templateVar{"package.txt", &packageText},
+	templateVar{"parseerror.html", &parseerrorHTML},
+	templateVar{"parseerror.txt", &parseerrorText},
+	templateVar{"search.html", &searchHTML},
+}
+
+
+// Lock of the template variables, which reloadTemplates sets
+// while the server is running.
+var templateMu sync.RWMutex
+
+
 func readTemplates() {
 	// have to delay until after flags processing,
 	// so that main has chdir'ed to goroot.
-	dirlistHTML = readTemplate("dirlist.html");
-	godocHTML = readTemplate("godoc.html");
-	packageHTML = readTemplate("package.html");
-	packageText = readTemplate("package.txt");
-	parseerrorHTML = readTemplate("parseerror.html");
-	parseerrorText = readTemplate("parseerror.txt");
-	searchHTML = readTemplate("search.html");
+	for _, v := range templateVars {
+		*v.t = readTemplate(v.name)
+	}
+}
+
+
+// getTemplate returns the template held by the variable t;
+// handlers must get their templates this way.
+func getTemplate(t **template.Template) *template.Template {
+	templateMu.RLock();
+	defer templateMu.RUnlock();
+	return *t;
+}
+
+
+// reloadTemplates reads the templates again. If one of them cannot
+// be read, it returns the error and all templates remain unchanged.
+// Requests in progress are not interrupted.
+func reloadTemplates() os.Error {
+	list := make([]*template.Template, len(templateVars));
+	for i, v := range templateVars {
+		t, err := parseTemplate(v.name);
+		if err != nil {
+			return err
+		}
+		list[i] = t;
+	}
+	templateMu.Lock();
+	for i, v := range templateVars {
+		*v.t = list[i]
+	}
+	templateMu.Unlock();
+	return nil;
+}
+
+
+// ----------------------------------------------------------------------------
+// Page navigation
+
+// A Link is an entry in the navigation shown in the page header.
+type Link struct {
+	Name	string;
+	URL	string;
+	Current	bool;	// true if the link refers to the page itself
+}
+
+
+// breadcrumbs returns a link for each segment of the URL path.
+// Each link refers to the path prefix ending in that segment.
+//
+func breadcrumbs(path string) []Link {
+	path = pathutil.Clean("/" + path);
+	if path == "/" {
+		return nil
+	}
+
+	elems := strings.Split(path[1:len(path)], "/", 0);
+	links := make([]Link, len(elems));
+	url := "";
+	for i, name := range elems {
+		url += "/" + name;
+		links[i] = Link{name, url + "/", false};
+	}
+
+	// the last segment refers to the page itself; it may be a file
+	links[len(links)-1].URL = path;
+	links[len(links)-1].Current = true;
+	return links;
+}
+
+
+// siblings returns a link for each package directory next to dirname
+// (including dirname itself) in the current directory tree. The URLs
+// are relative to the URL of the package page for dirname. The result
+// is nil if the directory tree is not available or dirname has no
+// siblings.
+//
+func siblings(dirname string) []Link {
+	tree, _ := fsTree.get();
+	if tree == nil {
+		return nil
+	}
+
+	parent, name := pathutil.Split(pathutil.Clean(dirname));
+	dir := tree.(*Directory).lookup(parent);
+	if dir == nil || len(dir.Dirs) < 2 {
+		return nil
+	}
+
+	links := make([]Link, len(dir.Dirs));
+	for i, d := range dir.Dirs {
+		links[i] = Link{d.Name, "../" + d.Name + "/", d.Name == name}
+	}
+	return links;
 }
 
 
 // ----------------------------------------------------------------------------
 // Generic HTML wrapper
 
-func servePage(c *http.Conn, title, query string, content []byte) {
+func servePage(c *http.Conn, title, query string, crumbs, siblings []Link, content []byte) {
+	writePage(c, title, query, crumbs, siblings, content)
+}
+
+
+// writePage writes the page with the given title and content,
+// wrapped in the godoc.html template, to w.
+func writePage(w io.Writer, title, query string, crumbs, siblings []Link, content []byte) {
 	type Data struct {
 		Title		string;
 		Timestamp	uint64;	// int64 to be compatible with os.Dir.Mtime_ns
 		Query		string;
+		Crumbs		[]Link;	// breadcrumbs for the page path, if any
+		Siblings	[]Link;	// sibling package directories, if any
+		Banner		[]byte;	// HTML shown on every page, if any
+		Notice		[]byte;	// HTML notice for the page, if any
 		Content		[]byte;
 	}
 
@@ -740,10 +1063,17 @@ func servePage(c *http.Conn, title, query string, content []byte) {
 		Title: title,
 		Timestamp: uint64(ts) * 1e9,	// timestamp in ns
 		Query: query,
+		Crumbs: crumbs,
+		Siblings: siblings,
+		Banner: pageBanner,
 		Content: content,
 	};
+	if len(crumbs) > 0 {
+		// the last breadcrumb refers to the page itself
+		d.Notice = pageNotice(crumbs[len(crumbs)-1].URL)
+	}
 
-	if err := godocHTML.Execute(&d, c); err != nil {
+	if err := getTemplate(&godocHTML).Execute(&d, w); err != nil {
 		log.Stderrf("godocHTML.Execute: %s", err)
 	}
 }
@@ -791,21 +1121,21 @@ func serveHTMLDoc(c *http.Conn, r *http.Request, path string) {
 	}
 
 	title := commentText(src);
-	servePage(c, title, "", src);
+	servePage(c, title, "", breadcrumbs(r.URL.Path), nil, rootLinks(src));
 }
 
 
 func serveParseErrors(c *http.Conn, errors *parseErrors) {
 	// format errors
 	var buf bytes.Buffer;
-	if err := parseerrorHTML.Execute(errors, &buf); err != nil {
+	if err := getTemplate(&parseerrorHTML).Execute(errors, &buf); err != nil {
 		log.Stderrf("parseerrorHTML.Execute: %s", err)
 	}
-	servePage(c, "Parse errors in source file "+errors.filename, "", buf.Bytes());
+	servePage(c, "Parse errors in source file "+errors.filename, "", nil, nil, buf.Bytes());
 }
 
 
-func serveGoSource(c *http.Conn, r *http.Request, path string, styler printer.Styler) {
+func serveGoSource(c *http.Conn, r *http.Request, path string, styler *Styler) {
 	prog, errors := parse(path, parser.ParseComments);
 	if errors != nil {
 		serveParseErrors(c, errors);
@@ -813,17 +1143,18 @@ func serveGoSource(c *http.Conn, r *http.Request, path string, styler printer.St
 	}
 
 	var buf bytes.Buffer;
-	fmt.Fprintln(&buf, "<pre>");
-	writeNode(&buf, prog, true, styler);
+	fmt.Fprintln(&buf, "<pre id=\"source\">");
+	writeNode(&buf, prog, true, &linkStyler{styler, resolveLinks(path, prog)});
 	fmt.Fprintln(&buf, "</pre>");
+	writeFolds(&buf, prog);
 
-	servePage(c, "Source file "+r.URL.Path, "", buf.Bytes());
+	servePage(c, "Source file "+r.URL.Path, "", breadcrumbs(r.URL.Path), nil, buf.Bytes());
 }
 
 
 func redirect(c *http.Conn, r *http.Request) (redirected bool) {
 	if canonical := pathutil.Clean(r.URL.Path) + "/"; r.URL.Path != canonical {
-		http.Redirect(c, canonical, http.StatusMovedPermanently);
+		http.Redirect(c, rooted(canonical), http.StatusMovedPermanently);
 		redirected = true;
 	}
 	return;
@@ -887,7 +1218,48 @@ func serveTextFile(c *http.Conn, r *http.Request, path string) {
 	template.HTMLEscape(&buf, src);
 	fmt.Fprintln(&buf, "</pre>");
 
-	servePage(c, "Text file "+path, "", buf.Bytes());
+	servePage(c, "Text file "+path, "", breadcrumbs(r.URL.Path), nil, buf.Bytes());
+}
+
+
+// isUTF8 reports whether text is valid UTF-8.
+func isUTF8(text []byte) bool {
+	for len(text) > 0 {
+		rune, size := utf8.DecodeRune(text);
+		if rune == utf8.RuneError && size == 1 {
+			return false
+		}
+		text = text[size:len(text)];
+	}
+	return true;
+}
+
+
+// serveRaw serves the file at the URL path below /text/, such as
+// /text/src/pkg/fmt/print.go, unformatted: as text/plain if it is
+// valid UTF-8, and as application/octet-stream otherwise.
+//
+func serveRaw(c *http.Conn, r *http.Request) {
+	// the path is relative to goroot; cleaning it as an absolute
+	// path keeps it from escaping goroot with ".." elements
+	path := r.URL.Path[len("/text"):len(r.URL.Path)];
+	path = pathutil.Join(".", pathutil.Clean(path));
+	if d, err := os.Stat(path); err != nil || !d.IsRegular() {
+		http.NotFound(c, r);
+		return;
+	}
+	src, err := io.ReadFile(path);
+	if err != nil {
+		log.Stderrf("serveRaw: %s", err);
+		http.NotFound(c, r);
+		return;
+	}
+	if isUTF8(src) {
+		serveText(c, src);
+		return;
+	}
+	c.SetHeader("content-type", "application/octet-stream");
+	c.Write(src);
 }
 
 
@@ -903,11 +1275,11 @@ func serveDirectory(c *http.Conn, r *http.Request, path string) {
 	}
 
 	var buf bytes.Buffer;
-	if err := dirlistHTML.Execute(list, &buf); err != nil {
+	if err := getTemplate(&dirlistHTML).Execute(list, &buf); err != nil {
 		log.Stderrf("dirlistHTML.Execute: %s", err)
 	}
 
-	servePage(c, "Directory "+path, "", buf.Bytes());
+	servePage(c, "Directory "+path, "", breadcrumbs(r.URL.Path), nil, buf.Bytes());
 }
 
 
@@ -967,6 +1339,67 @@ type PageInfo struct {
 	PDoc	*doc.PackageDoc;	// nil if no package found
 	Dirs	*DirList;		// nil if no directory information found
 	IsPkg	bool;			// false if this is not documenting a real package
+	TOC	[]TOCEntry;		// table of contents for PDoc; nil if PDoc is nil
+}
+
+
+// A TOCEntry is an entry in the table of contents of a package page.
+// Anchor is the id of the corresponding heading in package.html.
+type TOCEntry struct {
+	Name	string;
+	Anchor	string;
+	Entries	[]TOCEntry;	// factories and methods of a type, if any
+}
+
+
+func funcEntries(list []*doc.FuncDoc, prefix string) []TOCEntry {
+	if len(list) == 0 {
+		return nil
+	}
+	entries := make([]TOCEntry, len(list));
+	for i, f := range list {
+		entries[i] = TOCEntry{f.Name, prefix + f.Name, nil}
+	}
+	return entries;
+}
+
+
+// makeTOC returns the table of contents for the package page of pdoc.
+// It is computed from the same documentation as the page itself, so
+// the anchors always match the headings.
+//
+func makeTOC(pdoc *doc.PackageDoc) []TOCEntry {
+	var list vector.Vector;
+	if len(pdoc.Consts) > 0 {
+		list.Push(TOCEntry{"Constants", "Constants", nil})
+	}
+	if len(pdoc.Vars) > 0 {
+		list.Push(TOCEntry{"Variables", "Variables", nil})
+	}
+	for _, f := range pdoc.Funcs {
+		list.Push(TOCEntry{"func " + f.Name, f.Name, nil})
+	}
+	for _, t := range pdoc.Types {
+		name := t.Type.Name.Value;
+		entries := funcEntries(t.Factories, "");
+		methods := funcEntries(t.Methods, name+".");
+		if len(methods) > 0 {
+			all := make([]TOCEntry, len(entries)+len(methods));
+			copy(all, entries);
+			copy(all[len(entries):len(all)], methods);
+			entries = all;
+		}
+		list.Push(TOCEntry{"type " + name, name, entries});
+	}
+	if len(pdoc.Notes) > 0 {
+		list.Push(TOCEntry{"Notes", "Notes", nil})
+	}
+
+	toc := make([]TOCEntry, list.Len());
+	for i := range toc {
+		toc[i] = list.At(i).(TOCEntry)
+	}
+	return toc;
 }
 
 
@@ -977,12 +1410,52 @@ type httpHandler struct {
 }
 
 
+// A PageInfoMode controls which declarations getPageInfo documents.
+type PageInfoMode uint
+
+const (
+	exportsOnly	PageInfoMode	= iota;	// only exported declarations
+	noFiltering;			// unexported declarations, too
+)
+
+
+// formValues returns all values of the query parameter key of r.
+func formValues(r *http.Request, key string) []string {
+	r.ParseForm();
+	return r.Form[key];
+}
+
+
+// pageInfoMode returns the PageInfoMode requested by the
+// query parameter m of r; m=all requests noFiltering.
+func pageInfoMode(r *http.Request) PageInfoMode {
+	if r.FormValue("m") == "all" {
+		return noFiltering
+	}
+	return exportsOnly;
+}
+
+
+// stripBodies sets the function bodies in pkg to nil, as
+// ast.PackageExports does for the exported functions.
+func stripBodies(pkg *ast.Package) {
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			if fun, ok := d.(*ast.FuncDecl); ok {
+				fun.Body = nil
+			}
+		}
+	}
+}
+
+
 // getPageInfo returns the PageInfo for a given package directory.
 // If there is no corresponding package in the directory,
 // PageInfo.PDoc is nil. If there are no subdirectories,
-// PageInfo.Dirs is nil.
+// PageInfo.Dirs is nil. With mode noFiltering, the documentation
+// includes the unexported declarations.
 //
-func (h *httpHandler) getPageInfo(path string) PageInfo {
+func (h *httpHandler) getPageInfo(path string, mode PageInfoMode) PageInfo {
 	// the path is relative to h.fsroot
 	dirname := pathutil.Join(h.fsRoot, path);
 
@@ -1014,12 +1487,47 @@ func (h *httpHandler) getPageInfo(path string) PageInfo {
 
 	// compute package documentation
 	var pdoc *doc.PackageDoc;
+	var toc []TOCEntry;
 	if pkg != nil {
-		ast.PackageExports(pkg);
-		pdoc = doc.NewPackageDoc(pkg, pathutil.Clean(path));	// no trailing '/' in importpath
+		var dmode doc.Mode;
+		if mode == noFiltering {
+			stripBodies(pkg);
+			dmode = doc.AllDecls;	// keep the methods of unexported types
+		} else {
+			ast.PackageExports(pkg)
+		}
+		pdoc = doc.NewPackageDocMode(pkg, pathutil.Clean(path), dmode);	// no trailing '/' in importpath
+		pdoc.AddExamples(testFiles(dirname));
+		toc = makeTOC(pdoc);
+	}
+
+	return PageInfo{pdoc, dirListing(dirname), h.isPkg, toc};
+}
+
+
+// filterNames restricts the documentation of info to the declarations
+// with the given names, if there are any; see doc.Filter.
+func (info *PageInfo) filterNames(names []string) {
+	if len(names) > 0 && info.PDoc != nil {
+		info.PDoc.Filter(names);
+		info.TOC = makeTOC(info.PDoc);
 	}
+}
+
+
+// filterSince restricts the documentation of info to the declarations
+// added in version since or later, if since is set; see doc.FilterSince.
+func (info *PageInfo) filterSince(since string) {
+	if since != "" && info.PDoc != nil {
+		info.PDoc.FilterSince(since);
+		info.TOC = makeTOC(info.PDoc);
+	}
+}
+
 
-	// get directory information
+// dirListing returns the listing of the subdirectories of dirname,
+// or nil if there is no directory information.
+func dirListing(dirname string) *DirList {
 	var dir *Directory;
 	if tree, _ := fsTree.get(); tree != nil {
 		// directory tree is present; lookup respective directory
@@ -1031,8 +1539,7 @@ func (h *httpHandler) getPageInfo(path string) PageInfo {
 		// or command-line mode); compute one level for this page
 		dir = newDirectory(dirname, 1)
 	}
-
-	return PageInfo{pdoc, dir.listing(true), h.isPkg};
+	return dir.listing(true);
 }
 
 
@@ -1043,21 +1550,32 @@ func (h *httpHandler) ServeHTTP(c *http.Conn, r *http.Request) {
 
 	path := r.URL.Path;
 	path = path[len(h.pattern):len(path)];
-	info := h.getPageInfo(path);
+	if notModified(c, r, pathutil.Join(h.fsRoot, path)) {
+		return
+	}
+	info := h.getPageInfo(path, pageInfoMode(r));
+	info.filterNames(formValues(r, "name"));
+	info.filterSince(r.FormValue("since"));
 
 	var buf bytes.Buffer;
 	if r.FormValue("f") == "text" {
-		if err := packageText.Execute(info, &buf); err != nil {
+		if err := getTemplate(&packageText).Execute(info, &buf); err != nil {
 			log.Stderrf("packageText.Execute: %s", err)
 		}
 		serveText(c, buf.Bytes());
 		return;
 	}
 
-	if err := packageHTML.Execute(info, &buf); err != nil {
+	if err := getTemplate(&packageHTML).Execute(info, &buf); err != nil {
 		log.Stderrf("packageHTML.Execute: %s", err)
 	}
 
+	servePage(c, h.pageTitle(path, info), "", breadcrumbs(r.URL.Path), h.siblings(path), buf.Bytes());
+}
+
+
+// pageTitle returns the title of the page for the directory path.
+func (h *httpHandler) pageTitle(path string, info PageInfo) string {
 	if path == "" {
 		path = "."	// don't display an empty path
 	}
@@ -1074,8 +1592,17 @@ func (h *httpHandler) ServeHTTP(c *http.Conn, r *http.Request) {
 			title = "Command " + info.PDoc.PackageName
 		}
 	}
+	return title;
+}
+
 
-	servePage(c, title, "", buf.Bytes());
+// siblings returns the other packages in the parent directory of
+// the directory path, but none for the top-level directory itself.
+func (h *httpHandler) siblings(path string) []Link {
+	if path == "" || path == "." {
+		return nil
+	}
+	return siblings(pathutil.Join(h.fsRoot, path));
 }
 
 
@@ -1084,27 +1611,326 @@ func (h *httpHandler) ServeHTTP(c *http.Conn, r *http.Request) {
 
 var searchIndex RWValue
 
+// Search results are shown in pages of at most limit package runs;
+// the runs of the package-level declarations are counted before the
+// runs of all other occurrences, followed by the runs of full-text
+// matches. The query parameters start and n select the first run and
+// the limit, so that every page has a URL.
+const (
+	defaultSearchLimit	= 20;
+	maxSearchLimit		= 200;
+)
+
 type SearchResult struct {
 	Query		string;
 	Hit		*LookupResult;
 	Alt		*AltWords;
 	Illegal		bool;
 	Accurate	bool;
+	DeclGroups	[]SpotGroup;	// package-level declarations on this page, by kind
+	Uses		[]SpotPak;	// other occurrences on this page
+	Locals		[]TextPak;	// local declarations on this page, with excerpts
+	Text		[]TextPak;	// full-text matches on this page, with snippets
+
+	// pagination
+	First	int;	// number of the first package run on this page (1-based)
+	Last	int;	// number of the last package run on this page
+	Total	int;	// total number of package runs
+	Prev	string;	// URL of the previous page, or ""
+	Next	string;	// URL of the next page, or ""
 }
 
+
+// searchURL returns the URL of the search results page for query
+// starting with package run start and showing at most limit runs.
+func searchURL(query string, start, limit int) string {
+	url := "search?q=" + http.URLEscape(query);
+	if start > 0 {
+		url += "&start=" + strconv.Itoa(start)
+	}
+	if limit != defaultSearchLimit {
+		url += "&n=" + strconv.Itoa(limit)
+	}
+	return url;
+}
+
+
+// intValue returns the value of the form field key of r as an
+// integer, or def if the field is missing or not a number.
+func intValue(r *http.Request, key string, def int) int {
+	if n, err := strconv.Atoi(r.FormValue(key)); err == nil {
+		return n
+	}
+	return def;
+}
+
+
+// paginate restricts result.Hit to the page of package runs
+// starting with run start and sets up the pagination fields.
+func (result *SearchResult) paginate(start, limit int) {
+	if result.Hit == nil {
+		return
+	}
+	decls, others, text := result.Hit.Decls, result.Hit.Others, result.Hit.Text;
+	result.Total = len(decls) + len(others) + len(text);
+	if result.Total == 0 {
+		// e.g., a qualified identifier not declared in the package
+		result.Hit = nil;
+		return;
+	}
+	if start >= result.Total {
+		// past the end; show the last page instead
+		start = (result.Total - 1) / limit * limit
+	}
+	end := start + limit;
+	if end > result.Total {
+		end = result.Total
+	}
+	result.Hit = &LookupResult{
+		Decls: decls.slice(0, start, end),
+		Others: others.slice(len(decls), start, end),
+		Text: text.slice(len(decls)+len(others), start, end),
+	};
+	result.First = start + 1;
+	result.Last = end;
+	if start > 0 {
+		prev := start - limit;
+		if prev < 0 {
+			prev = 0
+		}
+		result.Prev = searchURL(result.Query, prev, limit);
+	}
+	if end < result.Total {
+		result.Next = searchURL(result.Query, end, limit)
+	}
+}
+
+
+// A SpotLine is a spot of a search result, resolved against
+// the index the result came from.
+type SpotLine struct {
+	Line	int;	// 0 if not available
+	HTML	string;	// snippet text, HTML-escaped
+}
+
+
+// A SpotRun lists the spots of a given kind within a file.
+type SpotRun struct {
+	Kind	SpotKind;
+	Infos	[]SpotLine;
+}
+
+
+// A SpotFile lists the runs of spots within a file.
+type SpotFile struct {
+	File	*File;
+	Groups	[]SpotRun;
+}
+
+
+// A SpotPak lists the files of a package containing spots.
+type SpotPak struct {
+	Pak	Pak;
+	Files	[]SpotFile;
+}
+
+
+// A SpotGroup lists the packages containing spots of a given kind.
+type SpotGroup struct {
+	Kind	SpotKind;
+	Hits	[]SpotPak;
+}
+
+
+// resolveSpot returns the line and snippet of info. Snippets are
+// looked up in index, the index info came from, rather than in the
+// current index, which may have been rebuilt since.
+func resolveSpot(index *Index, info SpotInfo) SpotLine {
+	if !info.IsIndex() {
+		return SpotLine{info.Lori(), `<span class="alert">no snippet text available</span>`}
+	}
+	s := index.Snippet(info.Lori());
+	if s == nil {
+		reportIndexFault(index, fmt.Sprintf("invalid snippet index %d", info.Lori()));
+		return SpotLine{0, `<span class="alert">no snippet text available</span>`};
+	}
+	// no escaping of snippet text needed;
+	// snippet text is escaped when generated
+	return SpotLine{s.Line, s.Text};
+}
+
+
+// resolveHits resolves the spots of hits against index,
+// the index hits came from.
+func resolveHits(index *Index, hits HitList) []SpotPak {
+	paks := make([]SpotPak, len(hits));
+	for i, p := range hits {
+		files := make([]SpotFile, len(p.Files));
+		for j, f := range p.Files {
+			runs := make([]SpotRun, len(f.Groups));
+			for k, g := range f.Groups {
+				infos := make([]SpotLine, len(g.Infos));
+				for l, info := range g.Infos {
+					infos[l] = resolveSpot(index, info)
+				}
+				runs[k] = SpotRun{g.Kind, infos};
+			}
+			files[j] = SpotFile{f.File, runs};
+		}
+		paks[i] = SpotPak{p.Pak, files};
+	}
+	return paks;
+}
+
+
+// A TextLine is a source line containing a full-text match.
+type TextLine struct {
+	Line	int;
+	HTML	string;	// the line, HTML-escaped, with the matches highlighted
+}
+
+
+// A TextFile lists the lines of a file containing full-text matches.
+type TextFile struct {
+	File	*File;
+	Lines	[]TextLine;
+}
+
+
+// A TextPak lists the files of a package containing full-text matches.
+type TextPak struct {
+	Pak	Pak;
+	Files	[]TextFile;
+}
+
+
+// highlightWord returns line, HTML-escaped and without leading and
+// trailing white space, with the occurrences of the word w highlighted
+// regardless of case.
+func highlightWord(line, w string) string {
+	line = strings.TrimSpace(line);
+	w = canonical(w);
+	var buf bytes.Buffer;
+	i0 := 0;	// start of text not yet written
+	for i := 0; i < len(line); {
+		if isWord, size := wordCharAt(line, i); !isWord {
+			i += size;
+			continue;
+		}
+		j, _ := wordAt(line, i);
+		if canonical(line[i:j]) == w {
+			template.HTMLEscape(&buf, strings.Bytes(line[i0:i]));
+			buf.WriteString(`<span class="highlight">`);
+			template.HTMLEscape(&buf, strings.Bytes(line[i:j]));
+			buf.WriteString(`</span>`);
+			i0 = j;
+		}
+		i = j;
+	}
+	template.HTMLEscape(&buf, strings.Bytes(line[i0:len(line)]));
+	return buf.String();
+}
+
+
+// textLines returns the lines of the file run f containing
+// the full-text matches of w, with w highlighted.
+func textLines(f *FileRun, w string) []TextLine {
+	var lines []string;
+	if src, err := io.ReadFile(f.File.Path); err == nil {
+		lines = strings.Split(string(src), "\n", 0)
+	}
+	n := 0;
+	for _, g := range f.Groups {
+		n += len(g.Infos)
+	}
+	list := make([]TextLine, n);
+	n = 0;
+	for _, g := range f.Groups {
+		for _, info := range g.Infos {
+			line := info.Lori();
+			if n > 0 && list[n-1].Line == line {
+				continue	// several matches in the same line
+			}
+			text := "";
+			if 0 < line && line <= len(lines) {
+				text = highlightWord(lines[line-1], w)
+			}
+			list[n] = TextLine{line, text};
+			n++;
+		}
+	}
+	return list[0:n];
+}
+
+
+// textResults returns the full-text matches of w in hits with
+// their source lines.
+func textResults(hits HitList, w string) []TextPak {
+	paks := make([]TextPak, len(hits));
+	for i, p := range hits {
+		files := make([]TextFile, len(p.Files));
+		for j, f := range p.Files {
+			files[j] = TextFile{f.File, textLines(f, w)}
+		}
+		paks[i] = TextPak{p.Pak, files};
+	}
+	return paks;
+}
+
+
 func search(c *http.Conn, r *http.Request) {
 	query := r.FormValue("q");
+	start := intValue(r, "start", 0);
+	if start < 0 {
+		start = 0
+	}
+	limit := intValue(r, "n", defaultSearchLimit);
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = defaultSearchLimit
+	}
 	var result SearchResult;
 
 	if index, timestamp := searchIndex.get(); index != nil {
 		result.Query = query;
+		t0 := time.Nanoseconds();
 		result.Hit, result.Alt, result.Illegal = index.(*Index).Lookup(query);
+		// don't modify the LookupResult; it belongs to the index
+		if result.Hit != nil {
+			hit := *result.Hit;
+			hit.Decls = hit.Decls.rank();
+			result.Hit = &hit;
+		}
+		if text := index.(*Index).LookupText(query); text != nil {
+			var hit LookupResult;
+			if result.Hit != nil {
+				hit = *result.Hit
+			}
+			hit.Text = text;
+			result.Hit = &hit;
+			result.Illegal = false;
+		}
+		logSearch(query, time.Nanoseconds()-t0);
+		result.paginate(start, limit);
+		if result.Hit != nil {
+			// resolve snippets against the index the hits came from
+			x := index.(*Index);
+			groups := result.Hit.Decls.groupByKind();
+			result.DeclGroups = make([]SpotGroup, len(groups));
+			for i, g := range groups {
+				result.DeclGroups[i] = SpotGroup{g.Kind, resolveHits(x, g.Hits)}
+			}
+			// the last identifier of a qualified identifier is the one declared
+			ss := strings.Split(query, ".", 0);
+			result.Locals, result.Hit.Others = localResults(result.Hit.Others, ss[len(ss)-1], timestamp);
+			result.Uses = resolveHits(x, result.Hit.Others);
+			result.Text = textResults(result.Hit.Text, query);
+		}
 		_, ts := fsTree.get();
 		result.Accurate = timestamp >= ts;
 	}
 
 	var buf bytes.Buffer;
-	if err := searchHTML.Execute(result, &buf); err != nil {
+	if err := getTemplate(&searchHTML).Execute(result, &buf); err != nil {
 		log.Stderrf("searchHTML.Execute: %s", err)
 	}
 
@@ -1115,27 +1941,62 @@ func search(c *http.Conn, r *http.Request) {
 		title = fmt.Sprintf(`No results found for query %q`, query)
 	}
 
-	servePage(c, title, query, buf.Bytes());
+	servePage(c, title, query, nil, nil, buf.Bytes());
 }
 
 
 // ----------------------------------------------------------------------------
 // Server
 
-var (
-	cmdHandler	= httpHandler{"/cmd/", *cmdroot, false};
-	pkgHandler	= httpHandler{"/pkg/", *pkgroot, true};
-)
+// The handlers of the command and package pages; set by initHandlers.
+var cmdHandler, pkgHandler httpHandler
+
+
+// initHandlers sets up the handlers of the command and package pages;
+// it must be called once findRoots has set the roots they serve.
+func initHandlers() {
+	cmdHandler = httpHandler{"/cmd/", *cmdroot, false};
+	pkgHandler = httpHandler{"/pkg/", *pkgroot, true};
+}
 
 
 func registerPublicHandlers(mux *http.ServeMux) {
-	mux.Handle(cmdHandler.pattern, &cmdHandler);
+	mux.Handle(cmdHandler.pattern, commandHandler{&cmdHandler});
 	mux.Handle(pkgHandler.pattern, &pkgHandler);
+	mux.Handle(cmdAPIHandler.pattern, &cmdAPIHandler);
+	mux.Handle(pkgAPIHandler.pattern, &pkgAPIHandler);
+	mux.Handle("/compare", http.HandlerFunc(compare));
 	mux.Handle("/search", http.HandlerFunc(search));
+	mux.Handle("/hover", http.HandlerFunc(serveHover));
+	mux.Handle("/notes", http.HandlerFunc(serveNotes));
+	mux.Handle("/suggest", http.HandlerFunc(serveSuggest));
+	mux.Handle("/text/", http.HandlerFunc(serveRaw));
 	mux.Handle("/", http.HandlerFunc(serveFile));
 }
 
 
+func buildIndex() {
+	tree, _ := fsTree.get();
+	start := time.Nanoseconds();
+	index := NewIndex(".");
+	stop := time.Nanoseconds();
+	searchIndex.set(index);
+	addSnapshot(index, tree);
+	if *verbose {
+		secs := float64((stop-start)/1e6) / 1e3;
+		nwords, nspots := index.Size();
+		log.Stderrf("index updated (%gs, %d unique words, %d spots)", secs, nwords, nspots);
+	}
+	if *indexFileName != "" {
+		if err := WriteIndexFile(index, *indexFileName); err != nil {
+			log.Stderrf("WriteIndexFile: %v", err)
+		}
+	}
+	updateExamples();
+	suggestions();	// build the suggestions now rather than on the first request
+}
+
+
 // Indexing goroutine.
 func indexer() {
 	for {
@@ -1145,16 +2006,82 @@ func indexer() {
 			// (could use a channel to send an explicit signal
 			// from the sync goroutine, but this solution is
 			// more decoupled, trivial, and works well enough)
-			start := time.Nanoseconds();
-			index := NewIndex(".");
-			stop := time.Nanoseconds();
-			searchIndex.set(index);
-			if *verbose {
-				secs := float64((stop-start)/1e6) / 1e3;
-				nwords, nspots := index.Size();
-				log.Stderrf("index updated (%gs, %d unique words, %d spots)", secs, nwords, nspots);
-			}
+			buildIndex()
 		}
 		time.Sleep(1 * 60e9);	// try once a minute
 	}
 }
+
+
+// ----------------------------------------------------------------------------
+// Index watchdog
+//
+// The watchdog periodically checks the integrity of the search index.
+// If the index is inconsistent, or if searches repeatedly encountered
+// invalid index data, the index is quarantined: it is taken out of
+// service, so that searches report that indexing is in progress
+// instead of failing, and a new index is built.
+
+// Number of index faults reported by searches before the
+// index is quarantined.
+const maxIndexFaults = 3
+
+var indexFaults struct {
+	sync.Mutex;
+	index	*Index;	// index the faults were found in
+	n	int;	// number of faults found in index
+}
+
+
+// reportIndexFault records that a search encountered invalid data in
+// index. Faults are counted only against the index they were found in;
+// a fault found in an index that has since been replaced is ignored.
+func reportIndexFault(index *Index, msg string) {
+	log.Stderrf("index fault: %s", msg);
+	indexFaults.Lock();
+	defer indexFaults.Unlock();
+	if x, _ := searchIndex.get(); x == nil || x.(*Index) != index {
+		return	// not the current index
+	}
+	if indexFaults.index != index {
+		indexFaults.index = index;
+		indexFaults.n = 0;
+	}
+	indexFaults.n++;
+}
+
+
+func quarantineIndex(reason string) {
+	log.Stderrf("index quarantined (%s); rebuilding index", reason);
+	searchIndex.set(nil);
+	indexFaults.Lock();
+	indexFaults.index = nil;
+	indexFaults.n = 0;
+	indexFaults.Unlock();
+	buildIndex();
+}
+
+
+// Watchdog goroutine.
+func indexWatchdog(minutes int) {
+	for {
+		time.Sleep(int64(minutes) * 60e9);
+		index, _ := searchIndex.get();
+		if index == nil {
+			continue
+		}
+		if err := index.(*Index).Check(); err != nil {
+			quarantineIndex(err.String());
+			continue;
+		}
+		indexFaults.Lock();
+		n := 0;
+		if indexFaults.index == index.(*Index) {
+			n = indexFaults.n
+		}
+		indexFaults.Unlock();
+		if n >= maxIndexFaults {
+			quarantineIndex(fmt.Sprintf("%d faults", n))
+		}
+	}
+}
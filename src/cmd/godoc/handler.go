@@ -0,0 +1,143 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains NewHandler, the http.Handler serving all of
+// godoc's pages. The godoc web server mounts it at the root of its
+// ServeMux; a server embedding godoc's code mounts it at the root of
+// its own ServeMux, next to its own handlers.
+//
+// The documentation state - the file system tree, the search index,
+// the templates - is global, and handlers expect the working directory
+// to be the root of the Go tree. Hence all handlers of a process serve
+// the same tree.
+
+package main
+
+import (
+	"http";
+	"log";
+	"os";
+	"strings";
+	"sync";
+)
+
+
+var state struct {
+	sync.Mutex;
+	root		string;	// root of the Go tree served; "" if not initialized
+	indexing	bool;	// indexing has been started
+}
+
+
+// initialize makes root, the root of a Go tree, the working directory
+// and reads the templates and decorations of the pages. If root is the
+// default -goroot and holds no Go tree, another location may be used;
+// see roots.go. initialize can
+// be called multiple times with the same root; it fails if root differs
+// from the root of an earlier call.
+func initialize(root string) os.Error {
+	state.Lock();
+	defer state.Unlock();
+	if state.root != "" {
+		if root != state.root {
+			return os.NewError("godoc: already serving " + state.root)
+		}
+		return nil;
+	}
+	dir, tried := findGoroot(root, root == goroot && !gorootFlagSet());
+	if dir == "" {
+		return os.NewError("no Go tree found (tried " + strings.Join(tried, ", ") + ")")
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	state.root = root;
+	findRoots(dir, tried);
+	initHandlers();
+	readTemplates();
+	readProfile();
+	readDecorations();
+	return nil;
+}
+
+
+// startIndexing computes the directory tree and starts the indexer
+// and the index watchdog, unless that happened before. The saved
+// search index, if any, is used until the indexer builds a new one.
+func startIndexing() {
+	state.Lock();
+	defer state.Unlock();
+	if state.indexing {
+		return
+	}
+	state.indexing = true;
+
+	// Initialize directory tree with corresponding timestamp.
+	// Do it in two steps:
+	// 1) set timestamp right away so that the indexer is kicked on
+	fsTree.set(nil);
+
+	// 2) compute initial directory tree in a goroutine so that launch is quick
+	go func() {
+		tree := newDirectory(".", maxDirDepth);
+		fsTree.set(tree);
+		// Use the saved search index, if any and if no file of the
+		// tree changed since it was written, instead of building a
+		// new one at startup. It is considered up-to-date until a
+		// sync changes the files.
+		if index := savedIndex(tree); index != nil {
+			searchIndex.set(index)	// as current as the tree
+		}
+	}();
+
+	// Start indexing goroutine.
+	go indexer();
+
+	// Start index watchdog goroutine, if enabled.
+	if *watchdogMin > 0 {
+		go indexWatchdog(*watchdogMin)
+	}
+}
+
+
+// savedIndex returns the index of the -index_file flag if it
+// can be used for tree, and nil otherwise.
+func savedIndex(tree *Directory) *Index {
+	if *indexFileName == "" {
+		return nil
+	}
+	if !isCurrentIndex(*indexFileName, tree) {
+		if *verbose {
+			log.Stderrf("saved index %s is out of date", *indexFileName)
+		}
+		return nil;
+	}
+	index, err := OpenIndex(*indexFileName);
+	if err != nil {
+		if *verbose {
+			log.Stderrf("no saved index: %v", err)
+		}
+		return nil;
+	}
+	return index;
+}
+
+
+// NewHandler returns an http.Handler serving the documentation of the
+// Go tree rooted at root: the package and command documentation under
+// /pkg/ and /cmd/, its JSON form under /api/, /compare, /search,
+// /hover, and the files of the tree under /, and unformatted under
+// /text/. The first call makes root the working
+// directory and starts indexing the tree; the handlers of a process
+// must all serve the same root. The flags of godoc, such as -index_file
+// and -tabwidth, apply to the handler.
+func NewHandler(root string) (http.Handler, os.Error) {
+	if err := initialize(root); err != nil {
+		return nil, err
+	}
+	startIndexing();
+	mux := http.NewServeMux();
+	registerPublicHandlers(mux);
+	return mux, nil;
+}
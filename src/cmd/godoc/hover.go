@@ -0,0 +1,243 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the /hover handler, which describes the
+// identifier at a position of a source file: its declaration, the
+// summary of its documentation, and the link to its definition.
+// The source view uses it to show a tooltip over each identifier
+// that links to its declaration.
+//
+// The identifier is resolved like the links of the source view, see
+// links.go. Declarations in the package of the file are found in its
+// source; declarations of imported packages in their documentation.
+
+package main
+
+import (
+	"fmt";
+	"go/ast";
+	"go/doc";
+	"go/parser";
+	"http";
+	pathutil "path";
+	"strconv";
+	"strings";
+)
+
+
+// A hoverInfo describes an identifier for a tooltip.
+type hoverInfo struct {
+	name	string;
+	decl	string;	// source text of the declaration
+	doc	string;	// first sentence of the documentation
+	link	string;	// link to the declaration
+}
+
+
+// An identFinder finds the identifier at a file offset.
+type identFinder struct {
+	offset	int;
+	id	*ast.Ident;	// the identifier, once found
+}
+
+
+func (f *identFinder) Visit(node interface{}) bool {
+	if f.id != nil {
+		return false	// found
+	}
+	if id, ok := node.(*ast.Ident); ok {
+		pos := id.Pos();
+		if pos.Offset <= f.offset && f.offset < pos.Offset+len(id.Value) {
+			f.id = id
+		}
+		return false;
+	}
+	return true;
+}
+
+
+// localHover describes the identifier declared by word at line
+// of the source file at path.
+func localHover(path string, line int, word string) *hoverInfo {
+	file, err := parser.ParseFile(path, nil, parser.ParseComments);
+	if err != nil {
+		return nil
+	}
+	f := excerptFinder{word: word, line: line};
+	ast.Walk(&f, file);
+	if f.node == nil {
+		return nil
+	}
+	return &hoverInfo{
+		name: word,
+		decl: nodeText(f.node),
+		doc: firstSentence(doc.CommentText(f.doc)),
+		link: fmt.Sprintf("/%s#L%d", path, line),
+	};
+}
+
+
+// valueSpec returns the declaration of name in the value group decl,
+// reduced to the specification declaring name; or nil.
+func valueSpec(decl *ast.GenDecl, name string) *ast.GenDecl {
+	for _, s := range decl.Specs {
+		if s, ok := s.(*ast.ValueSpec); ok {
+			for _, id := range s.Names {
+				if id.Value == name {
+					return &ast.GenDecl{nil, decl.Position, decl.Tok, decl.Lparen, []ast.Spec{s}, decl.Rparen}
+				}
+			}
+		}
+	}
+	return nil;
+}
+
+
+func valueHover(list []*doc.ValueDoc, name string) *hoverInfo {
+	for _, v := range list {
+		if d := valueSpec(v.Decl, name); d != nil {
+			return &hoverInfo{decl: nodeText(d), doc: firstSentence(v.Doc)}
+		}
+	}
+	return nil;
+}
+
+
+func funcHover(list []*doc.FuncDoc, name string) *hoverInfo {
+	for _, f := range list {
+		if f.Name == name {
+			return &hoverInfo{decl: nodeText(f.Decl), doc: firstSentence(f.Doc)}
+		}
+	}
+	return nil;
+}
+
+
+// packageHover describes the exported name of the package with the
+// given import path, as found in the package documentation; name is
+// empty for the package itself.
+func packageHover(importpath, name string) *hoverInfo {
+	info := pkgHandler.getPageInfo(importpath, exportsOnly);
+	pdoc := info.PDoc;
+	if pdoc == nil {
+		return nil
+	}
+	if name == "" {
+		return &hoverInfo{
+			name: pdoc.PackageName,
+			decl: "import " + strconv.Quote(importpath),
+			doc: firstSentence(pdoc.Doc),
+			link: "/pkg/" + importpath + "/",
+		}
+	}
+
+	h := valueHover(pdoc.Consts, name);
+	if h == nil {
+		h = valueHover(pdoc.Vars, name)
+	}
+	if h == nil {
+		h = funcHover(pdoc.Funcs, name)
+	}
+	for _, t := range pdoc.Types {
+		if h != nil {
+			break
+		}
+		if t.Type.Name.Value == name {
+			d := &ast.GenDecl{nil, t.Decl.Position, t.Decl.Tok, t.Decl.Lparen, []ast.Spec{t.Type}, t.Decl.Rparen};
+			h = &hoverInfo{decl: nodeText(d), doc: firstSentence(t.Doc)};
+			break;
+		}
+		h = valueHover(t.Consts, name);
+		if h == nil {
+			h = valueHover(t.Vars, name)
+		}
+		if h == nil {
+			h = funcHover(t.Factories, name)
+		}
+	}
+	if h != nil {
+		h.name = name;
+		h.link = "/pkg/" + importpath + "/#" + name;
+	}
+	return h;
+}
+
+
+// hover describes the identifier at offset in the source file at path.
+// The result is nil if there is no such identifier or if its
+// declaration cannot be found.
+func hover(path string, offset int) *hoverInfo {
+	file, err := parser.ParseFile(path, nil, parser.ParseComments);
+	if err != nil {
+		return nil
+	}
+	f := identFinder{offset: offset};
+	ast.Walk(&f, file);
+	if f.id == nil {
+		return nil
+	}
+
+	link, found := resolveLinks(path, file)[f.id];
+	if !found {
+		// the identifier may be a declaration itself
+		return localHover(path, f.id.Pos().Line, f.id.Value)
+	}
+
+	// links have the forms of scopeVisitor.use and scopeVisitor.Visit
+	switch {
+	case strings.HasPrefix(link, "/pkg/"):
+		link = link[len("/pkg/"):len(link)];
+		name := "";
+		if i := strings.Index(link, "/#"); i >= 0 {
+			link, name = link[0:i], link[i+2:len(link)]
+		} else {
+			link = link[0 : len(link)-1]	// remove trailing '/'
+		}
+		return packageHover(link, name);
+	case strings.HasPrefix(link, "#L"):
+		link = path + link
+	default:
+		link = link[1:len(link)]	// remove leading '/'
+	}
+	i := strings.Index(link, "#L");
+	line, err := strconv.Atoi(link[i+2 : len(link)]);
+	if err != nil {
+		return nil
+	}
+	return localHover(link[0:i], line, f.id.Value);
+}
+
+
+// serveHover serves the description of the identifier at a position of
+// a Go source file as a JSON object with the fields name, decl, doc, and
+// link. The position is given by the form values file, the URL path of
+// the file, and offset, the byte offset of the identifier in the file.
+func serveHover(c *http.Conn, r *http.Request) {
+	path := pathutil.Join(".", r.FormValue("file"));
+	offset, err := strconv.Atoi(r.FormValue("offset"));
+	if err != nil || !strings.HasSuffix(path, ".go") || strings.HasPrefix(path, "..") {
+		http.NotFound(c, r);
+		return;
+	}
+	h := hover(path, offset);
+	if h == nil {
+		http.NotFound(c, r);
+		return;
+	}
+
+	var w jsonWriter;
+	w.WriteByte('{');
+	w.key("name", true);
+	w.quote(h.name);
+	w.key("decl", false);
+	w.quote(h.decl);
+	w.key("doc", false);
+	w.quote(h.doc);
+	w.key("link", false);
+	w.quote(rooted(h.link));
+	w.WriteString("}\n");
+
+	c.SetHeader("content-type", "application/json; charset=utf-8");
+	c.Write(w.Bytes());
+}
@@ -25,15 +25,23 @@
 package main
 
 import (
+	"bytes";
 	"container/vector";
+	"fmt";
 	"go/ast";
 	"go/parser";
 	"go/token";
 	"go/scanner";
+	"hash/crc32";
+	"log";
 	"os";
 	pathutil "path";
 	"sort";
+	"strconv";
 	"strings";
+	"time";
+	"unicode";
+	"utf8";
 )
 
 
@@ -356,6 +364,125 @@ func (h HitList) filter(pakname string) HitList {
 }
 
 
+// kindRanks orders the kinds of spots by relevance for search results:
+// the declarations of types and functions come before those of constants
+// and variables, and declarations come before uses.
+var kindRanks = [nKinds]int{
+	TypeDecl: 0,
+	FuncDecl: 1,
+	MethodDecl: 2,
+	ConstDecl: 3,
+	VarDecl: 4,
+	PackageClause: 5,
+	ImportDecl: 6,
+	Use: 7,
+}
+
+
+// rank returns the best (smallest) kind rank of the spots in p.
+func (p *PakRun) rank() int {
+	r := len(kindRanks);
+	for _, f := range p.Files {
+		for _, g := range f.Groups {
+			if kindRanks[g.Kind] < r {
+				r = kindRanks[g.Kind]
+			}
+		}
+	}
+	return r;
+}
+
+
+// Sorting support for ranked HitLists.
+type rankedHits HitList
+
+func (h rankedHits) Len() int	{ return len(h) }
+func (h rankedHits) Less(i, j int) bool {
+	ri, rj := h[i].rank(), h[j].rank();
+	return ri < rj || ri == rj && h[i].Pak.less(&h[j].Pak);
+}
+func (h rankedHits) Swap(i, j int)	{ h[i], h[j] = h[j], h[i] }
+
+
+// rank returns a copy of h sorted by relevance: packages with a
+// declaration of a more relevant kind come first, and packages with
+// equally relevant declarations are sorted by package. h itself is
+// not modified; it may belong to the index.
+func (h HitList) rank() HitList {
+	hh := make(HitList, len(h));
+	copy(hh, h);
+	sort.Sort(rankedHits(hh));
+	return hh;
+}
+
+
+// A KindGroup is the list of runs of a HitList for spots of a given kind.
+type KindGroup struct {
+	Kind	SpotKind;
+	Hits	HitList;
+}
+
+
+// groupByKind splits h into a list of KindGroups, one for each kind
+// of spot found in h, in the order of kindRanks. The PakRuns and
+// FileRuns of the groups are new; the KindRuns are shared with h.
+func (h HitList) groupByKind() []KindGroup {
+	var groups [nKinds]vector.Vector;
+	for _, p := range h {
+		var files [nKinds]vector.Vector;
+		for _, f := range p.Files {
+			var runs [nKinds]vector.Vector;
+			for _, g := range f.Groups {
+				runs[g.Kind].Push(g)
+			}
+			for k := range runs {
+				if n := runs[k].Len(); n > 0 {
+					list := make([]*KindRun, n);
+					for i := range list {
+						list[i] = runs[k].At(i).(*KindRun)
+					}
+					files[k].Push(&FileRun{f.File, list});
+				}
+			}
+		}
+		for k := range files {
+			if n := files[k].Len(); n > 0 {
+				list := make([]*FileRun, n);
+				for i := range list {
+					list[i] = files[k].At(i).(*FileRun)
+				}
+				groups[k].Push(&PakRun{p.Pak, list});
+			}
+		}
+	}
+
+	// order the groups by kind rank
+	var byRank [nKinds]SpotKind;
+	for k, r := range kindRanks {
+		byRank[r] = SpotKind(k)
+	}
+	n := 0;
+	for _, k := range byRank {
+		if groups[k].Len() > 0 {
+			n++
+		}
+	}
+	list := make([]KindGroup, n);
+	i := 0;
+	for _, k := range byRank {
+		if m := groups[k].Len(); m > 0 {
+			hits := make(HitList, m);
+			for j := range hits {
+				hits[j] = groups[k].At(j).(*PakRun)
+			}
+			list[i] = KindGroup{k, hits};
+			i++;
+		}
+	}
+	return list;
+}
+
+
 // ----------------------------------------------------------------------------
 // AltWords
 
@@ -433,6 +560,15 @@ type Indexer struct {
 	file		*File;				// current file
 	decl		ast.Decl;			// current decl
 	nspots		int;				// number of spots encountered
+	inBody		bool;				// visiting a function body
+	lits		bool;				// index words of string literals in function bodies
+	maxLits		int;				// maximum number of literal words indexed; unlimited if <= 0
+	nlits		int;				// number of literal words indexed
+	litSpots	vector.Vector;			// vector of litSpots, in the order indexed
+	fulltext	bool;				// index words of doc comments and string literals as text
+	textWords	map[string]*RunList;		// RunLists of text Spots, by canonical word
+	notes		vector.Vector;			// vector of *FileNotes
+	parseStats	vector.Vector;			// vector of *parser.Stats of the files parsed
 }
 
 
@@ -445,18 +581,29 @@ func (x *Indexer) addSnippet(s *Snippet) int {
 
 func (x *Indexer) visitComment(c *ast.CommentGroup) {
 	if c != nil {
-		ast.Walk(x, c)
+		ast.Walk(x, c);
+		if x.fulltext {
+			for _, c := range c.List {
+				x.visitText(string(c.Text), c.Pos().Line, true)
+			}
+		}
 	}
 }
 
 
+func (x *Indexer) lookupWord(w string) *IndexResult {
+	lists, found := x.words[w];
+	if !found {
+		lists = new(IndexResult);
+		x.words[w] = lists;
+	}
+	return lists;
+}
+
+
 func (x *Indexer) visitIdent(kind SpotKind, id *ast.Ident) {
 	if id != nil {
-		lists, found := x.words[id.Value];
-		if !found {
-			lists = new(IndexResult);
-			x.words[id.Value] = lists;
-		}
+		lists := x.lookupWord(id.Value);
 
 		if kind == Use || x.decl == nil {
 			// not a declaration or no snippet required
@@ -474,6 +621,118 @@ func (x *Indexer) visitIdent(kind SpotKind, id *ast.Ident) {
 }
 
 
+func isWordChar(ch int) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || '0' <= ch && ch <= '9' || ch >= 0x80 && unicode.IsLetter(ch)
+}
+
+
+// wordCharAt reports whether s has a word character at byte offset i,
+// and returns the size of the character in bytes.
+func wordCharAt(s string, i int) (bool, int) {
+	if s[i] < utf8.RuneSelf {
+		return isWordChar(int(s[i])), 1
+	}
+	rune, size := utf8.DecodeRuneInString(s[i:len(s)]);
+	return isWordChar(rune), size;
+}
+
+
+// wordAt returns the end of the word starting at byte offset i of s,
+// which is i if there is none, and the end of the non-word characters
+// following it.
+func wordAt(s string, i int) (end, next int) {
+	for i < len(s) {
+		isWord, size := wordCharAt(s, i);
+		if !isWord {
+			break
+		}
+		i += size;
+	}
+	end = i;
+	for i < len(s) {
+		isWord, size := wordCharAt(s, i);
+		if isWord {
+			break
+		}
+		i += size;
+	}
+	return end, i;
+}
+
+
+// visitLit indexes the identifier-like words of the string literal
+// lit as uses. The words are also recorded in litSpots, so that merge
+// can bound their total number across the partial indexes in order.
+func (x *Indexer) visitLit(lit *ast.BasicLit) {
+	s, err := strconv.Unquote(string(lit.Value));
+	if err != nil {
+		return
+	}
+	line := lit.Pos().Line;
+	for i := 0; i < len(s); {
+		// find the next word; it is empty at leading non-word characters
+		j, next := wordAt(s, i);
+		w := s[i:j];
+		i = next;
+		if utf8.RuneCountInString(w) < 2 || !isIdentifier(w) {
+			continue	// ignore numbers and single letters
+		}
+		if x.maxLits > 0 && x.nlits >= x.maxLits {
+			return
+		}
+		lists := x.lookupWord(w);
+		x.litSpots.Push(litSpot{w, lists.Others.Len()});
+		lists.Others.Push(Spot{x.file, makeSpotInfo(Use, line, false)});
+		x.nspots++;
+		x.nlits++;
+	}
+}
+
+
+// visitText indexes the words of text for full-text search. The
+// text starts at the given line; if multiLine is not set, all words
+// are considered to be on that line.
+func (x *Indexer) visitText(text string, line int, multiLine bool) {
+	for i := 0; i < len(text); {
+		// find the next word
+		for i < len(text) {
+			isWord, size := wordCharAt(text, i);
+			if isWord {
+				break
+			}
+			if text[i] == '\n' && multiLine {
+				line++
+			}
+			i += size;
+		}
+		j, _ := wordAt(text, i);
+		if utf8.RuneCountInString(text[i:j]) >= 2 {
+			w := canonical(text[i:j]);
+			h, found := x.textWords[w];
+			if !found {
+				h = new(RunList);
+				x.textWords[w] = h;
+			}
+			h.Push(Spot{x.file, makeSpotInfo(Use, line, false)});
+			x.nspots++;
+		}
+		i = j;
+	}
+}
+
+
+// visitTextLit indexes the words of the string literal lit
+// for full-text search.
+func (x *Indexer) visitTextLit(lit *ast.BasicLit) {
+	s, err := strconv.Unquote(string(lit.Value));
+	if err != nil {
+		return
+	}
+	// only raw strings may span several lines
+	x.visitText(s, lit.Pos().Line, lit.Value[0] == '`');
+}
+
+
 func (x *Indexer) visitSpec(spec ast.Spec, isVarDecl bool) {
 	switch n := spec.(type) {
 	case *ast.ImportSpec:
@@ -514,6 +773,14 @@ func (x *Indexer) Visit(node interface{}) bool {
 	case *ast.Ident:
 		x.visitIdent(Use, n)
 
+	case *ast.BasicLit:
+		if x.inBody && x.lits && n.Kind == token.STRING {
+			x.visitLit(n)
+		}
+		if x.fulltext && n.Kind == token.STRING {
+			x.visitTextLit(n)
+		}
+
 	case *ast.Field:
 		x.decl = nil;	// no snippets for fields
 		x.visitComment(n.Doc);
@@ -557,7 +824,9 @@ func (x *Indexer) Visit(node interface{}) bool {
 		x.visitIdent(kind, n.Name);
 		ast.Walk(x, n.Type);
 		if n.Body != nil {
-			ast.Walk(x, n.Body)
+			x.inBody = true;
+			ast.Walk(x, n.Body);
+			x.inBody = false;
 		}
 
 	case *ast.File:
@@ -597,7 +866,8 @@ func (x *Indexer) VisitFile(path string, d *os.Dir) {
 		return
 	}
 
-	file, err := parser.ParseFile(path, nil, parser.ParseComments);
+	file, stats, err := parser.ParseFileStats(path, nil, parser.ParseComments);
+	x.parseStats.Push(stats);
 	if err != nil {
 		return	// ignore files with (parse) errors
 	}
@@ -606,6 +876,251 @@ func (x *Indexer) VisitFile(path string, d *os.Dir) {
 	pak := Pak{dir, file.Name.Value};
 	x.file = &File{path, pak};
 	ast.Walk(x, file);
+	x.addNotes(file);
+}
+
+
+// ----------------------------------------------------------------------------
+// Concurrent indexing
+//
+// The files of the tree are indexed concurrently by a bounded number of
+// workers. The files are split into runs of consecutive files of the
+// same directory, in walk order, each of which is indexed into a partial
+// Indexer of its own. The partial indexes are merged in walk order as
+// they finish, so that the index is the same as if the files had been
+// indexed one after the other, whatever the scheduling of the workers.
+// With -index_throttle below 1, a worker pauses after each run of files
+// so that it spends only that fraction of its time indexing.
+
+// Number of workers indexing files at the same time.
+const indexWorkers = 4
+
+// Smallest -index_throttle value honored; smaller values are raised to it.
+const minIndexThrottle = 0.05
+
+
+// A litSpot is a Spot of a string literal word: the word and
+// the position of the Spot in the list of uses of the word.
+type litSpot struct {
+	word	string;
+	i	int;
+}
+
+
+// A fileEntry is a file to be indexed.
+type fileEntry struct {
+	path	string;
+	d	*os.Dir;
+}
+
+
+// A fileCollector collects the Go files of a file tree in runs of
+// consecutive files of the same directory. It implements the
+// path.Visitor interface.
+type fileCollector struct {
+	runs	vector.Vector;	// vector of *vector.Vectors of fileEntries, in walk order
+	dir	string;		// directory of the last run
+}
+
+
+func (c *fileCollector) VisitDir(path string, d *os.Dir) bool {
+	return true
+}
+
+
+func (c *fileCollector) VisitFile(path string, d *os.Dir) {
+	if !isGoFile(d) {
+		return
+	}
+	dir, _ := pathutil.Split(path);
+	dir = pathutil.Clean(dir);
+	if c.runs.Len() == 0 || dir != c.dir {
+		c.runs.Push(new(vector.Vector));
+		c.dir = dir;
+	}
+	c.runs.Last().(*vector.Vector).Push(fileEntry{path, d});
+}
+
+
+func newIndexer() *Indexer {
+	x := new(Indexer);
+	x.words = make(map[string]*IndexResult);
+	x.lits = *indexBodies;
+	x.maxLits = *indexMaxLits;
+	x.fulltext = *fulltext;
+	x.textWords = make(map[string]*RunList);
+	return x;
+}
+
+
+// indexFiles returns a partial index of the files.
+func indexFiles(files *vector.Vector) *Indexer {
+	x := newIndexer();
+	for i := 0; i < files.Len(); i++ {
+		f := files.At(i).(fileEntry);
+		x.VisitFile(f.path, f.d);
+	}
+	return x;
+}
+
+
+// throttle pauses the calling worker after it has
+// indexed for ns nanoseconds, as set by -index_throttle.
+func throttle(ns int64) {
+	t := *indexThrottle;
+	if t >= 1 {
+		return
+	}
+	if t < minIndexThrottle {
+		t = minIndexThrottle
+	}
+	time.Sleep(int64(float64(ns) * (1 - t) / t));
+}
+
+
+// appendSpots appends the Spots of src to dst, adding offset to their
+// snippet indices and leaving out those at the positions in skip.
+func appendSpots(dst, src *RunList, offset int, skip map[int]bool) {
+	for i := 0; i < src.Len(); i++ {
+		if skip != nil && skip[i] {
+			continue
+		}
+		s := src.At(i).(Spot);
+		if s.Info.IsIndex() {
+			s.Info = makeSpotInfo(s.Info.Kind(), s.Info.Lori()+offset, true)
+		}
+		dst.Push(s);
+	}
+}
+
+
+// merge adds the partial index y to x.
+func (x *Indexer) merge(y *Indexer) {
+	offset := x.snippets.Len();
+	x.snippets.AppendVector(&y.snippets);
+
+	// the literal words are counted in walk order; those
+	// beyond the total limit are left out
+	var skip map[string]map[int]bool;
+	nlits := y.litSpots.Len();
+	if x.maxLits > 0 && x.nlits+nlits > x.maxLits {
+		skip = make(map[string]map[int]bool);
+		keep := x.maxLits - x.nlits;
+		for i := keep; i < nlits; i++ {
+			l := y.litSpots.At(i).(litSpot);
+			if skip[l.word] == nil {
+				skip[l.word] = make(map[int]bool)
+			}
+			skip[l.word][l.i] = true;
+		}
+		x.nspots -= nlits - keep;
+		nlits = keep;
+	}
+	x.nlits += nlits;
+
+	for w, h := range y.words {
+		lists := x.lookupWord(w);
+		appendSpots(&lists.Decls, &h.Decls, offset, nil);
+		var s map[int]bool;
+		if skip != nil {
+			s = skip[w]
+		}
+		appendSpots(&lists.Others, &h.Others, offset, s);
+	}
+
+	for w, h := range y.textWords {
+		list, found := x.textWords[w];
+		if !found {
+			list = new(RunList);
+			x.textWords[w] = list;
+		}
+		appendSpots(list, h, 0, nil);
+	}
+
+	x.nspots += y.nspots;
+	x.notes.AppendVector(&y.notes);
+	x.parseStats.AppendVector(&y.parseStats);
+}
+
+
+// A partialIndex is the partial index of a run of files.
+type partialIndex struct {
+	i	int;	// number of the run
+	x	*Indexer;
+}
+
+
+// indexTree indexes the Go files in the tree rooted at root
+// concurrently and returns the merged index.
+func indexTree(root string) *Indexer {
+	var c fileCollector;
+	pathutil.Walk(root, &c, nil);
+
+	// hand out the runs of files to the workers
+	n := c.runs.Len();
+	work := make(chan int, n);
+	for i := 0; i < n; i++ {
+		work <- i
+	}
+	close(work);
+
+	results := make(chan partialIndex);
+	for w := 0; w < indexWorkers; w++ {
+		go func() {
+			for {
+				i := <-work;
+				if closed(work) {
+					break
+				}
+				t0 := time.Nanoseconds();
+				p := indexFiles(c.runs.At(i).(*vector.Vector));
+				throttle(time.Nanoseconds() - t0);
+				results <- partialIndex{i, p};
+			}
+		}()
+	}
+
+	// merge the partial indexes in order as they finish; those
+	// finishing ahead of their turn wait in pending
+	x := newIndexer();
+	pending := make(map[int]*Indexer);
+	next := 0;
+	for k := 0; k < n; k++ {
+		r := <-results;
+		pending[r.i] = r.x;
+		for {
+			p, found := pending[next];
+			if !found {
+				break
+			}
+			x.merge(p);
+			pending[next] = nil, false;	// release the partial index
+			next++;
+		}
+	}
+	return x;
+}
+
+
+// slice returns the runs of h that fall into the range [start, end)
+// of a list of runs in which h begins at index offset.
+func (h HitList) slice(offset, start, end int) HitList {
+	i, j := clamp(start-offset, len(h)), clamp(end-offset, len(h));
+	if i > j {
+		i = j
+	}
+	return h[i:j];
+}
+
+
+func clamp(i, n int) int {
+	switch {
+	case i < 0:
+		return 0
+	case i > n:
+		return n
+	}
+	return i;
 }
 
 
@@ -615,6 +1130,7 @@ func (x *Indexer) VisitFile(path string, d *os.Dir) {
 type LookupResult struct {
 	Decls	HitList;	// package-level declarations (with snippets)
 	Others	HitList;	// all other occurences
+	Text	HitList;	// full-text matches in comments and string literals
 }
 
 
@@ -623,21 +1139,51 @@ type Index struct {
 	alts		map[string]*AltWords;		// maps canonical(words) to lists of alternative spellings
 	snippets	[]*Snippet;			// all snippets, indexed by snippet index
 	nspots		int;				// number of spots indexed (a measure of the index size)
+	summary		indexSummary;			// summary at creation time, for integrity checks
+	file		*indexFile;			// if set, the index is read from this file instead
+	text		map[string]HitList;		// maps canonical(words) to full-text hit lists
+	notes		[]*FileNote;			// BUG and TODO notes; nil if the index was read from a file
 }
 
 
 func canonical(w string) string	{ return strings.ToLower(w) }
 
 
-// NewIndex creates a new index for the file tree rooted at root.
-func NewIndex(root string) *Index {
-	var x Indexer;
+// Number of the slowest files to parse logged by logParseStats.
+const maxParseHotspots = 5
 
-	// initialize Indexer
-	x.words = make(map[string]*IndexResult);
 
+type parseStatsList []*parser.Stats
+
+func (p parseStatsList) Len() int		{ return len(p) }
+func (p parseStatsList) Less(i, j int) bool	{ return p[i].Ns > p[j].Ns }
+func (p parseStatsList) Swap(i, j int)		{ p[i], p[j] = p[j], p[i] }
+
+
+// logParseStats logs the total cost of parsing the files
+// described by v and the files that took longest to parse.
+func logParseStats(v *vector.Vector) {
+	list := make(parseStatsList, v.Len());
+	total := parser.Stats{Filename: "total"};
+	for i := range list {
+		list[i] = v.At(i).(*parser.Stats);
+		total.Add(list[i]);
+	}
+	sort.Sort(list);
+	log.Stderrf("parsed %d files; %s", len(list), &total);
+	for i := 0; i < len(list) && i < maxParseHotspots; i++ {
+		log.Stderrf("slow parse: %s", list[i])
+	}
+}
+
+
+// NewIndex creates a new index for the file tree rooted at root.
+func NewIndex(root string) *Index {
 	// collect all Spots
-	pathutil.Walk(root, &x, nil);
+	x := indexTree(root);
+	if *verbose {
+		logParseStats(&x.parseStats)
+	}
 
 	// for each word, reduce the RunLists into a LookupResult;
 	// also collect the word with its canonical spelling in a
@@ -671,20 +1217,42 @@ func NewIndex(root string) *Index {
 		snippets[i] = x.snippets.At(i).(*Snippet)
 	}
 
-	return &Index{words, alts, snippets, x.nspots};
+	// reduce the text Spots of each word into a HitList
+	text := make(map[string]HitList);
+	for w, h := range x.textWords {
+		text[w] = reduce(h)
+	}
+
+	// convert the notes vector into a list
+	notes := make([]*FileNote, x.notes.Len());
+	for i := range notes {
+		notes[i] = x.notes.At(i).(*FileNote)
+	}
+
+	index := &Index{words, alts, snippets, x.nspots, indexSummary{}, nil, text, notes};
+	index.summary, _ = index.summarize();
+	return index;
 }
 
 
 // Size returns the number of different words and
 // spots indexed as a measure for the index size.
 func (x *Index) Size() (nwords int, nspots int) {
-	return len(x.words), x.nspots
+	if x.file != nil {
+		return x.file.nwords, x.file.nspots
+	}
+	return len(x.words), x.nspots;
 }
 
 
 func (x *Index) LookupWord(w string) (match *LookupResult, alt *AltWords) {
-	match, _ = x.words[w];
-	alt, _ = x.alts[canonical(w)];
+	if x.file != nil {
+		match = x.file.lookupWord(w);
+		alt = x.file.lookupAlts(canonical(w));
+	} else {
+		match, _ = x.words[w];
+		alt, _ = x.alts[canonical(w)];
+	}
 	// remove current spelling from alternatives
 	// (if there is no match, the alternatives do
 	// not contain the current spelling)
@@ -695,6 +1263,22 @@ func (x *Index) LookupWord(w string) (match *LookupResult, alt *AltWords) {
 }
 
 
+// LookupText returns the full-text matches of the word w regardless
+// of case, or nil if there are none. Full-text matches are only found
+// if the index was created with -fulltext; they are not saved in an
+// index file.
+func (x *Index) LookupText(w string) HitList {
+	if end, _ := wordAt(w, 0); end != len(w) {
+		return nil	// not a single word
+	}
+	if x.text == nil {
+		return nil
+	}
+	hits, _ := x.text[canonical(w)];
+	return hits;
+}
+
+
 func isIdentifier(s string) bool {
 	var S scanner.Scanner;
 	S.Init("", strings.Bytes(s), nil, 0);
@@ -731,7 +1315,7 @@ func (x *Index) Lookup(query string) (match *LookupResult, alt *AltWords, illega
 			// found a match - filter by package name
 			decls := match.Decls.filter(pakname);
 			others := match.Others.filter(pakname);
-			match = &LookupResult{decls, others};
+			match = &LookupResult{Decls: decls, Others: others};
 		}
 
 	default:
@@ -743,9 +1327,125 @@ func (x *Index) Lookup(query string) (match *LookupResult, alt *AltWords, illega
 
 
 func (x *Index) Snippet(i int) *Snippet {
+	if x.file != nil {
+		return x.file.snippet(i)
+	}
 	// handle illegal snippet indices gracefully
 	if 0 <= i && i < len(x.snippets) {
 		return x.snippets[i]
 	}
 	return nil;
 }
+
+
+// ----------------------------------------------------------------------------
+// Integrity checks
+//
+// A long-running server keeps the same index in memory for a long time.
+// The summary of an index (counts and a checksum over all its entries)
+// is recorded when the index is created; Check recomputes the summary
+// and verifies the structure of the index to detect corruption.
+
+type indexSummary struct {
+	nwords		int;	// number of words
+	ninfos		int;	// number of SpotInfos in all hit lists
+	nsnippets	int;	// number of snippets
+	checksum	uint32;	// sum of the checksums of all words and their hit lists
+}
+
+
+// checkHitList verifies the structure of the hit list h and appends
+// the file paths and spot infos of h to buf. It returns the number of
+// spot infos in h.
+func (x *Index) checkHitList(h HitList, buf *bytes.Buffer) (ninfos int, err os.Error) {
+	var b [4]byte;
+	for _, p := range h {
+		if p == nil {
+			return 0, os.NewError("nil package run")
+		}
+		for _, f := range p.Files {
+			if f == nil || f.File == nil {
+				return 0, os.NewError("nil file run in package " + p.Pak.Path)
+			}
+			buf.WriteString(f.File.Path);
+			for _, g := range f.Groups {
+				if g == nil {
+					return 0, os.NewError("nil kind run in file " + f.File.Path)
+				}
+				for _, info := range g.Infos {
+					if info.Kind() != g.Kind {
+						return 0, os.NewError("inconsistent spot kind in file " + f.File.Path)
+					}
+					if info.IsIndex() && x.Snippet(info.Lori()) == nil {
+						return 0, os.NewError("invalid snippet index in file " + f.File.Path)
+					}
+					b[0] = byte(info);
+					b[1] = byte(info >> 8);
+					b[2] = byte(info >> 16);
+					b[3] = byte(info >> 24);
+					buf.Write(&b);
+					ninfos++;
+				}
+			}
+		}
+	}
+	return ninfos, nil;
+}
+
+
+// summarize verifies the structure of the index and computes its summary.
+// The checksum does not depend on the order in which words are visited.
+func (x *Index) summarize() (s indexSummary, err os.Error) {
+	var buf bytes.Buffer;
+	for w, match := range x.words {
+		if match == nil {
+			return s, os.NewError("nil lookup result for " + w)
+		}
+		buf.Reset();
+		buf.WriteString(w);
+		n, err := x.checkHitList(match.Decls, &buf);
+		if err != nil {
+			return s, err
+		}
+		s.ninfos += n;
+		n, err = x.checkHitList(match.Others, &buf);
+		if err != nil {
+			return s, err
+		}
+		s.ninfos += n;
+		s.checksum += crc32.ChecksumIEEE(buf.Bytes());
+	}
+	s.nwords = len(x.words);
+
+	for i, snippet := range x.snippets {
+		if snippet == nil {
+			return s, os.NewError(fmt.Sprintf("nil snippet %d", i))
+		}
+	}
+	s.nsnippets = len(x.snippets);
+
+	return s, nil;
+}
+
+
+// Check verifies the integrity of the index. It returns an error
+// if the index is inconsistent or has changed since its creation.
+func (x *Index) Check() os.Error {
+	if x.file != nil {
+		return x.file.check()
+	}
+	s, err := x.summarize();
+	switch {
+	case err != nil:
+		return err
+	case s.nwords != x.summary.nwords:
+		return os.NewError(fmt.Sprintf("%d words, expected %d", s.nwords, x.summary.nwords))
+	case s.ninfos != x.summary.ninfos:
+		return os.NewError(fmt.Sprintf("%d spots, expected %d", s.ninfos, x.summary.ninfos))
+	case s.nsnippets != x.summary.nsnippets:
+		return os.NewError(fmt.Sprintf("%d snippets, expected %d", s.nsnippets, x.summary.nsnippets))
+	case s.checksum != x.summary.checksum:
+		return os.NewError(fmt.Sprintf("checksum %08x, expected %08x", s.checksum, x.summary.checksum))
+	}
+	return nil;
+}
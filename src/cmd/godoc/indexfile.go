@@ -0,0 +1,497 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the on-disk representation of the search index.
+//
+// The index file is designed to be memory-mapped and queried in place:
+// only the hit lists of the words actually searched for are decoded,
+// so that loading even the index of a huge tree is fast, and the memory
+// used by the index is the file's page cache, which the system reclaims
+// as needed.
+//
+// All integers are little-endian uint32 values; offsets are relative to
+// the beginning of the file. The file consists of a header
+//
+//	magic		"godocidx"
+//	version
+//	checksum	CRC-32 (IEEE) of everything after the header
+//	nwords, nspots, nalts, nsnippets
+//	words		offset of the word table
+//	alts		offset of the alternative spellings table
+//	snippets	offset of the snippet table
+//
+// followed by strings, hit lists, and the tables:
+//
+//	string:		length, bytes (each string is stored once)
+//	result:		hit list of declarations, hit list of other spots
+//	hit list:	npaks, {pak path, pak name, nfiles,
+//			{file path, ngroups, {kind, ninfos, {info}}}}
+//	alt list:	nalts, {alternative spelling}
+//	word table:	nwords entries {word, result}, sorted by word
+//	alts table:	nalts entries {canonical spelling, alt list}, sorted
+//	snippet table:	nsnippets entries {line, text}
+//
+// where strings, results, and alt lists are referenced by offset.
+
+package main
+
+import (
+	"bytes";
+	"hash/crc32";
+	"io";
+	"os";
+	"sort";
+	"syscall";
+)
+
+
+const (
+	indexMagic	= "godocidx";
+	indexVersion	= 1;
+	indexHeaderSize	= 44;
+)
+
+
+// ----------------------------------------------------------------------------
+// Writing
+
+type indexWriter struct {
+	buf	bytes.Buffer;		// file contents following the header
+	strings	map[string]uint32;	// offsets of the strings written so far
+}
+
+
+func (w *indexWriter) offset() uint32	{ return indexHeaderSize + uint32(w.buf.Len()) }
+
+
+func (w *indexWriter) put(x uint32) {
+	w.buf.WriteByte(byte(x));
+	w.buf.WriteByte(byte(x >> 8));
+	w.buf.WriteByte(byte(x >> 16));
+	w.buf.WriteByte(byte(x >> 24));
+}
+
+
+// intern writes s unless it was written before
+// and returns its offset.
+func (w *indexWriter) intern(s string) uint32 {
+	if off, found := w.strings[s]; found {
+		return off
+	}
+	off := w.offset();
+	w.put(uint32(len(s)));
+	w.buf.WriteString(s);
+	w.strings[s] = off;
+	return off;
+}
+
+
+func (w *indexWriter) internHitList(h HitList) {
+	for _, p := range h {
+		w.intern(p.Pak.Path);
+		w.intern(p.Pak.Name);
+		for _, f := range p.Files {
+			w.intern(f.File.Path)
+		}
+	}
+}
+
+
+// hitList writes h; all its strings must have been interned.
+func (w *indexWriter) hitList(h HitList) {
+	w.put(uint32(len(h)));
+	for _, p := range h {
+		w.put(w.strings[p.Pak.Path]);
+		w.put(w.strings[p.Pak.Name]);
+		w.put(uint32(len(p.Files)));
+		for _, f := range p.Files {
+			w.put(w.strings[f.File.Path]);
+			w.put(uint32(len(f.Groups)));
+			for _, g := range f.Groups {
+				w.put(uint32(g.Kind));
+				w.put(uint32(len(g.Infos)));
+				for _, info := range g.Infos {
+					w.put(uint32(info))
+				}
+			}
+		}
+	}
+}
+
+
+func sortedKeys(m map[string]uint32) []string {
+	keys := make([]string, len(m));
+	i := 0;
+	for k, _ := range m {
+		keys[i] = k;
+		i++;
+	}
+	sort.SortStrings(keys);
+	return keys;
+}
+
+
+// Write writes the index to w in the format read by OpenIndex.
+func (x *Index) Write(w io.Writer) os.Error {
+	if x.file != nil {
+		// the index is a file already
+		_, err := w.Write(x.file.data);
+		return err;
+	}
+
+	var iw indexWriter;
+	iw.strings = make(map[string]uint32);
+
+	// results
+	results := make(map[string]uint32);
+	for word, match := range x.words {
+		iw.intern(word);
+		iw.internHitList(match.Decls);
+		iw.internHitList(match.Others);
+		results[word] = iw.offset();
+		iw.hitList(match.Decls);
+		iw.hitList(match.Others);
+	}
+
+	// alternative spellings
+	altLists := make(map[string]uint32);
+	for canon, a := range x.alts {
+		iw.intern(canon);
+		for _, s := range a.Alts {
+			iw.intern(s)
+		}
+		altLists[canon] = iw.offset();
+		iw.put(uint32(len(a.Alts)));
+		for _, s := range a.Alts {
+			iw.put(iw.strings[s])
+		}
+	}
+
+	// snippet texts
+	for _, s := range x.snippets {
+		iw.intern(s.Text)
+	}
+
+	// tables
+	words := iw.offset();
+	for _, word := range sortedKeys(results) {
+		iw.put(iw.strings[word]);
+		iw.put(results[word]);
+	}
+	alts := iw.offset();
+	for _, canon := range sortedKeys(altLists) {
+		iw.put(iw.strings[canon]);
+		iw.put(altLists[canon]);
+	}
+	snippets := iw.offset();
+	for _, s := range x.snippets {
+		iw.put(uint32(s.Line));
+		iw.put(iw.strings[s.Text]);
+	}
+
+	// header
+	var hw indexWriter;
+	hw.buf.WriteString(indexMagic);
+	hw.put(indexVersion);
+	hw.put(crc32.ChecksumIEEE(iw.buf.Bytes()));
+	hw.put(uint32(len(x.words)));
+	hw.put(uint32(x.nspots));
+	hw.put(uint32(len(x.alts)));
+	hw.put(uint32(len(x.snippets)));
+	hw.put(words);
+	hw.put(alts);
+	hw.put(snippets);
+
+	if _, err := w.Write(hw.buf.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(iw.buf.Bytes());
+	return err;
+}
+
+
+// WriteIndexFile writes the index to the named file. The file is
+// replaced atomically, so that a godoc reading it concurrently sees
+// either the old or the new index.
+func WriteIndexFile(x *Index, filename string) os.Error {
+	tmp := filename + ".tmp";
+	f, err := os.Open(tmp, os.O_WRONLY|os.O_CREAT|os.O_TRUNC, 0644);
+	if err != nil {
+		return err
+	}
+	err = x.Write(f);
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		if e := syscall.Rename(tmp, filename); e != 0 {
+			err = &os.PathError{"rename", tmp, os.Errno(e)}
+		}
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err;
+}
+
+
+// ----------------------------------------------------------------------------
+// Reading
+
+var errBadIndex = os.NewError("invalid index file")
+
+
+// An indexFile provides access to the contents of an index file.
+// Out-of-range offsets, which can only result from corrupted data,
+// read as zeroes and empty strings.
+type indexFile struct {
+	data		[]byte;
+	checksum	uint32;
+	nwords		int;
+	nspots		int;
+	nalts		int;
+	nsnippets	int;
+	words		uint32;	// offset of word table
+	alts		uint32;	// offset of alternative spellings table
+	snippets	uint32;	// offset of snippet table
+}
+
+
+func (f *indexFile) get(off uint32) uint32 {
+	if uint64(off)+4 > uint64(len(f.data)) {
+		return 0
+	}
+	b := f.data[off : off+4];
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24;
+}
+
+
+func (f *indexFile) str(off uint32) string {
+	n := uint64(f.get(off));
+	if uint64(off)+4+n > uint64(len(f.data)) {
+		return ""
+	}
+	return string(f.data[off+4 : off+4+uint32(n)]);
+}
+
+
+// find looks up key in the table of n {string, value} entries at
+// offset table, sorted by string, and returns the value.
+func (f *indexFile) find(table uint32, n int, key string) (value uint32, found bool) {
+	i, j := 0, n;
+	for i < j {
+		h := i + (j-i)/2;
+		if f.str(f.get(table+uint32(8*h))) < key {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	if i < n {
+		entry := table + uint32(8*i);
+		if f.str(f.get(entry)) == key {
+			return f.get(entry + 4), true
+		}
+	}
+	return 0, false;
+}
+
+
+// hitList decodes the hit list at offset off. It returns the
+// list and the offset of the data following it.
+func (f *indexFile) hitList(off uint32) (HitList, uint32) {
+	npaks := f.get(off);
+	off += 4;
+	if uint64(npaks) > uint64(len(f.data)) {
+		return nil, off	// corrupted
+	}
+	h := make(HitList, npaks);
+	for i := range h {
+		pak := Pak{f.str(f.get(off)), f.str(f.get(off + 4))};
+		nfiles := f.get(off + 8);
+		off += 12;
+		if uint64(nfiles) > uint64(len(f.data)) {
+			return nil, off	// corrupted
+		}
+		files := make([]*FileRun, nfiles);
+		for j := range files {
+			file := &File{f.str(f.get(off)), pak};
+			ngroups := f.get(off + 4);
+			off += 8;
+			if uint64(ngroups) > uint64(len(f.data)) {
+				return nil, off	// corrupted
+			}
+			groups := make([]*KindRun, ngroups);
+			for k := range groups {
+				kind := SpotKind(f.get(off));
+				ninfos := f.get(off + 4);
+				off += 8;
+				if uint64(ninfos) > uint64(len(f.data)) {
+					return nil, off	// corrupted
+				}
+				infos := make([]SpotInfo, ninfos);
+				for l := range infos {
+					infos[l] = SpotInfo(f.get(off));
+					off += 4;
+				}
+				groups[k] = &KindRun{kind, infos};
+			}
+			files[j] = &FileRun{file, groups};
+		}
+		h[i] = &PakRun{pak, files};
+	}
+	return h, off;
+}
+
+
+func (f *indexFile) lookupWord(w string) *LookupResult {
+	off, found := f.find(f.words, f.nwords, w);
+	if !found {
+		return nil
+	}
+	decls, off := f.hitList(off);
+	others, _ := f.hitList(off);
+	return &LookupResult{Decls: decls, Others: others};
+}
+
+
+func (f *indexFile) lookupAlts(canon string) *AltWords {
+	off, found := f.find(f.alts, f.nalts, canon);
+	if !found {
+		return nil
+	}
+	n := f.get(off);
+	if uint64(n) > uint64(len(f.data)) {
+		return nil	// corrupted
+	}
+	alts := make([]string, n);
+	for i := range alts {
+		alts[i] = f.str(f.get(off + 4 + uint32(4*i)))
+	}
+	return &AltWords{canon, alts};
+}
+
+
+func (f *indexFile) snippet(i int) *Snippet {
+	if 0 <= i && i < f.nsnippets {
+		entry := f.snippets + uint32(8*i);
+		return &Snippet{int(f.get(entry)), f.str(f.get(entry + 4))};
+	}
+	return nil;
+}
+
+
+// check verifies that the index data did not change since it was written.
+func (f *indexFile) check() os.Error {
+	if crc32.ChecksumIEEE(f.data[indexHeaderSize:len(f.data)]) != f.checksum {
+		return os.NewError("index file checksum mismatch")
+	}
+	return nil;
+}
+
+
+func newIndexFile(data []byte) (*indexFile, os.Error) {
+	if len(data) < indexHeaderSize || string(data[0:len(indexMagic)]) != indexMagic {
+		return nil, errBadIndex
+	}
+	f := &indexFile{data: data};
+	if f.get(8) != indexVersion {
+		return nil, os.NewError("unsupported index file version")
+	}
+	f.checksum = f.get(12);
+	f.nwords = int(f.get(16));
+	f.nspots = int(f.get(20));
+	f.nalts = int(f.get(24));
+	f.nsnippets = int(f.get(28));
+	f.words = f.get(32);
+	f.alts = f.get(36);
+	f.snippets = f.get(40);
+	if uint64(f.words)+8*uint64(f.nwords) > uint64(len(data)) ||
+		uint64(f.alts)+8*uint64(f.nalts) > uint64(len(data)) ||
+		uint64(f.snippets)+8*uint64(f.nsnippets) > uint64(len(data)) {
+		return nil, errBadIndex
+	}
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	return f, nil;
+}
+
+
+// mapFile returns the contents of the named file, memory-mapped
+// if the system supports it.
+func mapFile(filename string) ([]byte, os.Error) {
+	file, err := os.Open(filename, os.O_RDONLY, 0);
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close();
+	dir, err := file.Stat();
+	if err != nil {
+		return nil, err
+	}
+	if data, errno := syscall.Mmap(file.Fd(), 0, int(dir.Size), syscall.PROT_READ, syscall.MAP_SHARED); errno == 0 {
+		return data, nil
+	}
+	return io.ReadAll(file);
+}
+
+
+// OpenIndex returns the index stored in the named file by
+// WriteIndexFile. The file is memory-mapped and searched in place.
+//
+// The mapping is never released: an index that is replaced may still
+// be in use by a concurrent search, and indices are replaced rarely.
+func OpenIndex(filename string) (*Index, os.Error) {
+	data, err := mapFile(filename);
+	if err != nil {
+		return nil, err
+	}
+	f, err := newIndexFile(data);
+	if err != nil {
+		return nil, &os.PathError{"open index", filename, err}
+	}
+	return &Index{file: f}, nil;
+}
+
+
+// isCurrentIndex reports whether the index file filename is newer than
+// each directory of tree and the files in it, that is, whether it can
+// be used for tree: a file that was changed, added, or removed after
+// the index was written changes the modification time of the file or
+// of its directory.
+func isCurrentIndex(filename string, tree *Directory) bool {
+	d, err := os.Stat(filename);
+	if err != nil || tree == nil {
+		return false
+	}
+	current := true;
+	for dir := range tree.iter(false) {
+		// keep receiving so that the walk terminates
+		if current && !olderThan(dir.Path, d.Mtime_ns) {
+			current = false
+		}
+	}
+	return current;
+}
+
+
+// olderThan reports whether the directory dirname and the
+// files in it were last modified before the time t.
+func olderThan(dirname string, t uint64) bool {
+	d, err := os.Stat(dirname);
+	if err != nil || d.Mtime_ns >= t {
+		return false
+	}
+	list, err := io.ReadDir(dirname);
+	if err != nil {
+		return false
+	}
+	for _, d := range list {
+		if d.IsRegular() && d.Mtime_ns >= t {
+			return false
+		}
+	}
+	return true;
+}
@@ -0,0 +1,73 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the HTTP caching support of the package pages.
+//
+// A package page carries a Last-Modified header with the newest
+// modification time of the files it is made of: the files of the
+// package directory, the directory itself, which changes as files
+// and subdirectories come and go, and the templates of the page.
+// The ETag combines that time with the query of the page, so that the
+// forms of a page (?m=all, ?f=text, ...) have different tags. Conditional
+// requests are answered by http.NotModified, so that browsers and proxies
+// keep their copy.
+//
+// The time of the last sync shown at the bottom of the pages is not
+// taken into account; otherwise every sync would invalidate all pages.
+
+package main
+
+import (
+	"fmt";
+	"hash/crc32";
+	"http";
+	"io";
+	"os";
+	"strings";
+)
+
+
+// Templates used by the package pages.
+var pageTemplates = []string{"godoc.html", "package.html", "package.txt"}
+
+
+// newestMtime returns the newest modification time, in seconds, of the
+// directory dirname, the files in it, and the page templates; it
+// returns 0 if none of them can be read.
+func newestMtime(dirname string) int64 {
+	var t uint64;
+	newer := func(d *os.Dir) {
+		if d.Mtime_ns > t {
+			t = d.Mtime_ns
+		}
+	};
+	if d, err := os.Stat(dirname); err == nil {
+		newer(d)
+	}
+	list, _ := io.ReadDir(dirname);	// ignore errors
+	for _, d := range list {
+		if d.IsRegular() {
+			newer(d)
+		}
+	}
+	for _, name := range pageTemplates {
+		if d, err := os.Stat(templatePath(name)); err == nil {
+			newer(d)
+		}
+	}
+	return int64(t / 1e9);
+}
+
+
+// notModified sets the caching headers of the page of directory dirname
+// requested by r and reports whether the client's copy of the page is
+// up to date, in which case it has replied with 304 Not Modified.
+func notModified(c *http.Conn, r *http.Request, dirname string) bool {
+	mtime := newestMtime(dirname);
+	if mtime == 0 {
+		return false	// nothing to derive the headers from
+	}
+	etag := fmt.Sprintf(`"%x-%08x"`, mtime, crc32.ChecksumIEEE(strings.Bytes(r.URL.RawQuery)));
+	return http.NotModified(c, mtime, etag);
+}
@@ -0,0 +1,370 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the resolution of the identifiers of a source
+// file to their declarations, so that the source view can link each
+// identifier to the place where it is declared.
+//
+// The resolution is syntactic: identifiers are looked up in the scopes
+// of the file and its package as declared in the source, using the
+// ast.Scope type. Identifiers qualified by the name of an imported
+// package link to the documentation of that package. Selectors of
+// fields and methods, labels, struct literal keys, and predeclared
+// identifiers are not linked.
+
+package main
+
+import (
+	"fmt";
+	"go/ast";
+	"go/parser";
+	"go/printer";
+	"go/token";
+	"io";
+	pathutil "path";
+	"strconv";
+)
+
+
+// A resolver computes the links of the identifiers of a file.
+type resolver struct {
+	imports	map[string]string;	// import path, by package name
+	files	map[*ast.Ident]string;	// file of declarations outside the file
+	links	map[*ast.Ident]string;	// the links, by identifier
+}
+
+
+// A scopeVisitor resolves the identifiers of a syntax tree
+// in a scope; nested scopes have visitors of their own.
+type scopeVisitor struct {
+	r	*resolver;
+	scope	*ast.Scope;
+	enter	interface{};	// node opening scope; its children are visited in scope
+}
+
+
+func (v *scopeVisitor) open(node interface{}) *scopeVisitor {
+	return &scopeVisitor{v.r, ast.NewScope(v.scope), node}
+}
+
+
+func (v *scopeVisitor) walk(node interface{}) {
+	if node != nil {
+		ast.Walk(v, node)
+	}
+}
+
+
+func (v *scopeVisitor) walkList(list []ast.Stmt) {
+	for _, s := range list {
+		ast.Walk(v, s)
+	}
+}
+
+
+func (v *scopeVisitor) declare(id *ast.Ident) {
+	if id.Value != "_" {
+		v.scope.Declare(id)
+	}
+}
+
+
+// declareFields declares the names of a parameter or result list
+// after resolving their types.
+func (v *scopeVisitor) declareFields(list []*ast.Field) {
+	for _, f := range list {
+		v.walk(f.Type)
+	}
+	for _, f := range list {
+		for _, id := range f.Names {
+			v.declare(id)
+		}
+	}
+}
+
+
+// walkTypes resolves the types of a field list but not its names,
+// which are not declared in the current scope.
+func (v *scopeVisitor) walkTypes(list []*ast.Field) {
+	for _, f := range list {
+		v.walk(f.Type)
+	}
+}
+
+
+func (v *scopeVisitor) use(id *ast.Ident) {
+	d := v.scope.Lookup(id.Value);
+	if d == nil || d == id {
+		return
+	}
+	if path, found := v.r.files[d]; found {
+		v.r.links[id] = fmt.Sprintf("/%s#L%d", path, d.Pos().Line)
+	} else {
+		v.r.links[id] = fmt.Sprintf("#L%d", d.Pos().Line)
+	}
+}
+
+
+func (v *scopeVisitor) Visit(node interface{}) bool {
+	if node == v.enter {
+		v.enter = nil;
+		return true;
+	}
+
+	switch n := node.(type) {
+	case *ast.Ident:
+		v.use(n)
+
+	case *ast.File:
+		for _, d := range n.Decls {
+			v.walk(d)
+		}
+		return false;
+
+	case *ast.SelectorExpr:
+		if x, ok := n.X.(*ast.Ident); ok && v.scope.Lookup(x.Value) == nil {
+			if path, found := v.r.imports[x.Value]; found {
+				v.r.links[x] = "/pkg/" + path + "/";
+				v.r.links[n.Sel] = "/pkg/" + path + "/#" + n.Sel.Value;
+				return false;
+			}
+		}
+		v.walk(n.X);
+		return false;
+
+	case *ast.KeyValueExpr:
+		if _, ok := n.Key.(*ast.Ident); !ok {
+			// struct literal keys cannot be resolved syntactically
+			v.walk(n.Key)
+		}
+		v.walk(n.Value);
+		return false;
+
+	case *ast.StructType:
+		v.walkTypes(n.Fields);
+		return false;
+
+	case *ast.InterfaceType:
+		v.walkTypes(n.Methods);
+		return false;
+
+	case *ast.FuncType:
+		v.walkTypes(n.Params);
+		v.walkTypes(n.Results);
+		return false;
+
+	case *ast.FuncDecl:
+		s := v.open(nil);
+		if n.Recv != nil {
+			s.declareFields([]*ast.Field{n.Recv})
+		}
+		s.declareFields(n.Type.Params);
+		s.declareFields(n.Type.Results);
+		if n.Body != nil {
+			s.walkList(n.Body.List)
+		}
+		return false;
+
+	case *ast.FuncLit:
+		s := v.open(nil);
+		s.declareFields(n.Type.Params);
+		s.declareFields(n.Type.Results);
+		s.walkList(n.Body.List);
+		return false;
+
+	case *ast.BlockStmt, *ast.IfStmt, *ast.ForStmt, *ast.SwitchStmt,
+		*ast.TypeSwitchStmt, *ast.CaseClause, *ast.TypeCaseClause:
+		ast.Walk(v.open(node), node);
+		return false;
+
+	case *ast.CommClause:
+		s := v.open(nil);
+		s.walk(n.Rhs);
+		if id, ok := n.Lhs.(*ast.Ident); ok && n.Tok == token.DEFINE {
+			s.declare(id)
+		} else {
+			s.walk(n.Lhs)
+		}
+		s.walkList(n.Body);
+		return false;
+
+	case *ast.RangeStmt:
+		v.walk(n.X);
+		s := v.open(nil);
+		for _, x := range []ast.Expr{n.Key, n.Value} {
+			if id, ok := x.(*ast.Ident); ok && n.Tok == token.DEFINE {
+				s.declare(id)
+			} else {
+				s.walk(x)
+			}
+		}
+		s.walkList(n.Body.List);
+		return false;
+
+	case *ast.AssignStmt:
+		for _, x := range n.Rhs {
+			v.walk(x)
+		}
+		for _, x := range n.Lhs {
+			id, ok := x.(*ast.Ident);
+			if ok && n.Tok == token.DEFINE {
+				if _, found := v.scope.Names[id.Value]; !found {
+					v.declare(id);
+					continue;
+				}
+			}
+			v.walk(x);
+		}
+		return false;
+
+	case *ast.GenDecl:
+		for _, s := range n.Specs {
+			switch s := s.(type) {
+			case *ast.ValueSpec:
+				v.walk(s.Type);
+				for _, x := range s.Values {
+					v.walk(x)
+				}
+				for _, id := range s.Names {
+					v.declare(id)
+				}
+			case *ast.TypeSpec:
+				v.declare(s.Name);	// types may be recursive
+				v.walk(s.Type);
+			}
+		}
+		return false;
+
+	case *ast.LabeledStmt:
+		v.walk(n.Stmt);
+		return false;
+
+	case *ast.BranchStmt:
+		return false
+	}
+
+	return true;
+}
+
+
+// declareTop declares the package-level names declared by file in scope.
+// Methods are not declared; they belong to their receiver type.
+func declareTop(scope *ast.Scope, file *ast.File) []*ast.Ident {
+	var list []*ast.Ident;
+	add := func(id *ast.Ident) {
+		if id.Value != "_" && scope.Declare(id) {
+			n := len(list);
+			if n == cap(list) {
+				l := make([]*ast.Ident, n, 2*n+16);
+				copy(l, list);
+				list = l;
+			}
+			list = list[0 : n+1];
+			list[n] = id;
+		}
+	};
+	for _, d := range file.Decls {
+		switch d := d.(type) {
+		case *ast.GenDecl:
+			for _, s := range d.Specs {
+				switch s := s.(type) {
+				case *ast.ValueSpec:
+					for _, id := range s.Names {
+						add(id)
+					}
+				case *ast.TypeSpec:
+					add(s.Name)
+				}
+			}
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				add(d.Name)
+			}
+		}
+	}
+	return list;
+}
+
+
+// importName returns the name under which spec imports a package,
+// and the package's import path.
+func importName(spec *ast.ImportSpec) (name, path string) {
+	for _, lit := range spec.Path {
+		s, err := strconv.Unquote(string(lit.Value));
+		if err != nil {
+			return "", ""
+		}
+		path += s;
+	}
+	if spec.Name != nil {
+		return spec.Name.Value, path
+	}
+	_, name = pathutil.Split(path);
+	return name, path;
+}
+
+
+// resolveLinks returns the links of the identifiers of file, the
+// source file at path. The package-level declarations of the other
+// files of the package in the same directory are resolved as well.
+func resolveLinks(path string, file *ast.File) map[*ast.Ident]string {
+	r := &resolver{
+		imports: make(map[string]string),
+		files: make(map[*ast.Ident]string),
+		links: make(map[*ast.Ident]string),
+	};
+
+	// package scope: the declarations of the other files of the package
+	pkgScope := ast.NewScope(nil);
+	dir, name := pathutil.Split(path);
+	if list, err := io.ReadDir(dir); err == nil {
+		for _, d := range list {
+			if !isPkgFile(d) || d.Name == name {
+				continue
+			}
+			other := pathutil.Join(dir, d.Name);
+			f, err := parser.ParseFile(other, nil, 0);
+			if err != nil || f.Name.Value != file.Name.Value {
+				continue
+			}
+			for _, id := range declareTop(pkgScope, f) {
+				r.files[id] = other
+			}
+		}
+	}
+
+	// file scope: the declarations of the file itself, and its imports
+	fileScope := ast.NewScope(pkgScope);
+	declareTop(fileScope, file);
+	for _, d := range file.Decls {
+		if d, ok := d.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			for _, s := range d.Specs {
+				if name, path := importName(s.(*ast.ImportSpec)); name != "" && name != "." && name != "_" {
+					r.imports[name] = path
+				}
+			}
+		}
+	}
+
+	ast.Walk(&scopeVisitor{r, fileScope, nil}, file);
+	return r.links;
+}
+
+
+// A linkStyler is a Styler that links identifiers to their declarations.
+type linkStyler struct {
+	*Styler;
+	links	map[*ast.Ident]string;
+}
+
+
+func (s *linkStyler) Ident(id *ast.Ident) (text []byte, tag printer.HTMLTag) {
+	text, tag = s.Styler.Ident(id);
+	if link, found := s.links[id]; found {
+		// the offset lets the source view ask /hover about the identifier
+		tag.Start = fmt.Sprintf(`<a href="%s" data-offset="%d">`, htmlEscape(rooted(link)), id.Pos().Offset) + tag.Start;
+		tag.End += "</a>";
+	}
+	return;
+}
@@ -13,6 +13,9 @@
 //	http://godoc/pkg/	serve documentation about packages
 //				(idea is if you say import "compress/zlib", you go to
 //				http://godoc/pkg/compress/zlib)
+//	http://godoc/api/pkg/	package documentation as JSON, for editors
+//				and other tools (see api.go)
+//	http://godoc/compare	compare two packages (see compare.go)
 //
 // Command-line interface:
 //
@@ -22,6 +25,23 @@
 //		- prints doc for package compress/zlib
 //	godoc crypto/block Cipher NewCMAC
 //		- prints doc for Cipher and NewCMAC in package crypto/block
+//	godoc -compare path1 path2
+//		- prints the differences between the exported declarations
+//		  of packages path1 and path2 (see compare.go)
+//	godoc -man compress/zlib
+//		- prints doc for package compress/zlib as a man page
+//		  (see man.go)
+//	godoc -query Fprintf
+//		- searches the index (the -index_file, or a new index)
+//		  and prints the declarations and uses of Fprintf
+//		  (see query.go)
+//	godoc -remote=host:6060 fmt Printf
+//		- prints doc for Printf in package fmt as served by the
+//		  godoc server at host:6060, without a local GOROOT
+//		  (see remote.go)
+//	godoc -src fmt Printf
+//		- prints the complete source of the declaration of
+//		  Printf in package fmt (see declsrc.go)
 
 package main
 
@@ -33,6 +53,7 @@ import (
 	"io";
 	"log";
 	"os";
+	"runtime";
 	"time";
 )
 
@@ -43,10 +64,31 @@ var (
 	syncDelay	delayTime;	// actual sync delay in minutes; usually syncDelay == syncMin, but delay may back off exponentially
 
 	// server control
-	httpaddr	= flag.String("http", "", "HTTP service address (e.g., ':6060')");
+	httpaddr		= flag.String("http", "", "HTTP service address (e.g., ':6060')");
+	adminaddr	= flag.String("admin", "", "HTTP service address of the control pages /debug/reload, /debug/shutdown, ... (e.g., 'localhost:6061'); disabled if empty");
+	watchdogMin	= flag.Int("watchdog_minutes", 10, "index integrity check interval in minutes; disabled if <= 0");
+	indexFileName	= flag.String("index_file", "", "search index file, used at startup and updated with the index (if unrooted, relative to goroot)");
+	indexBodies	= flag.Bool("index_bodies", false, "also index the words of string literals in function bodies");
+	indexMaxLits	= flag.Int("index_max_literals", 1000000, "maximum number of string literal words indexed with -index_bodies; unlimited if <= 0");
+	indexThrottle	= flag.Float64("index_throttle", 1.0, "fraction of the time the index workers spend indexing, between 0 and 1; 1.0 = full speed");
+	indexSnapshots	= flag.Int("index_snapshots", 0, "number of built search indexes kept for rollback with /debug/rollback");
+	fulltext	= flag.Bool("fulltext", false, "also index the words of doc comments and string literals for full-text search");
+	logFile		= flag.String("log", "", "access log file, or \"-\" for standard error; disabled if empty");
+	slowQueryMs	= flag.Int("slow_query_ms", 500, "log index searches taking longer than this many milliseconds to the access log; disabled if <= 0");
 
 	// layout control
 	html	= flag.Bool("html", false, "print HTML in command-line mode");
+	man	= flag.Bool("man", false, "print a man page in command-line mode");
+	methods	= flag.Bool("methods", true, "indent the factories and methods of a type beneath it in plain-text mode");
+
+	// command-line mode
+	compareMode	= flag.Bool("compare", false, "compare the two packages given as arguments");
+	query		= flag.String("query", "", "search the index for the query and print the results");
+	srcMode		= flag.Bool("src", false, "print the complete source of the named declarations");
+	allMode		= flag.Bool("all", false, "include unexported declarations in the documentation");
+	writeHTML	= flag.String("write_html", "", "write the documentation of all packages and commands as static HTML pages to this directory");
+	sinceVersion	= flag.String("since", "", "only document the declarations added in this version or later, according to their \"Since:\" lines");
+	remote		= flag.String("remote", "", "fetch the documentation from the godoc server at this address (e.g., 'host:6060') in command-line mode");
 )
 
 
@@ -62,7 +104,8 @@ func exec(c *http.Conn, args []string) (status int) {
 	if *verbose {
 		log.Stderrf("executing %v", args)
 	}
-	pid, err := os.ForkExec(bin, args, os.Environ(), goroot, fds);
+	// the working directory is the Go tree found for goroot
+	pid, err := os.ForkExec(bin, args, os.Environ(), "", fds);
 	defer r.Close();
 	w.Close();
 	if err != nil {
@@ -125,6 +168,12 @@ func dosync(c *http.Conn, r *http.Request) {
 func usage() {
 	fmt.Fprintf(os.Stderr,
 		"usage: godoc package [name ...]\n"
+			"	godoc -man package [name ...]\n"
+			"	godoc -compare package1 package2\n"
+			"	godoc -query identifier\n"
+			"	godoc -src package name ...\n"
+			"	godoc -remote=host:port package [name ...]\n"
+			"	godoc -write_html=dir\n"
 			"	godoc -http=:6060\n");
 	flag.PrintDefaults();
 	os.Exit(2);
@@ -143,8 +192,19 @@ func main() {
 	flag.Usage = usage;
 	flag.Parse();
 
-	// Check usage: either server and no args, or command line and args
-	if (*httpaddr != "") != (flag.NArg() == 0) {
+	// Check usage: either server and no args, or command line and args,
+	// or a command-line search and no args
+	if *query != "" || *writeHTML != "" {
+		if *httpaddr != "" || flag.NArg() != 0 {
+			usage()
+		}
+	} else if (*httpaddr != "") != (flag.NArg() == 0) {
+		usage()
+	}
+	if *compareMode && flag.NArg() != 2 {
+		usage()
+	}
+	if *srcMode && flag.NArg() < 2 {
 		usage()
 	}
 
@@ -152,37 +212,83 @@ func main() {
 		log.Exitf("negative tabwidth %d", *tabwidth)
 	}
 
-	if err := os.Chdir(goroot); err != nil {
-		log.Exitf("chdir %s: %v", goroot, err)
+	if *remote != "" {
+		// Remote mode: no local files are needed.
+		if *httpaddr != "" || *query != "" || *writeHTML != "" || *compareMode || *srcMode || *html || *man {
+			usage()
+		}
+		args := flag.Args();
+		if err := printRemote(os.Stdout, *remote, args[0], args[1:len(args)]); err != nil {
+			log.Exitf("remote: %v", err)
+		}
+		return;
 	}
 
-	readTemplates();
+	// the output directory is relative to the current directory, not goroot
+	outDir := absPath(*writeHTML);
+
+	if err := initialize(goroot); err != nil {
+		log.Exitf("goroot %s: %v", goroot, err)
+	}
+
+	// let the index workers run in parallel
+	// unless $GOMAXPROCS says otherwise
+	if os.Getenv("GOMAXPROCS") == "" {
+		runtime.GOMAXPROCS(indexWorkers)
+	}
+
+	if *writeHTML != "" {
+		if err := writeStatic(outDir); err != nil {
+			log.Exitf("write_html: %v", err)
+		}
+		return;
+	}
 
 	if *httpaddr != "" {
 		// HTTP server mode.
-		var handler http.Handler = http.DefaultServeMux;
+		var handler http.Handler = prefixHandler(http.DefaultServeMux);
 		if *verbose {
 			log.Stderrf("Go Documentation Server\n");
 			log.Stderrf("address = %s\n", *httpaddr);
+			log.Stderrf("admin address = %s\n", *adminaddr);
 			log.Stderrf("goroot = %s\n", goroot);
 			log.Stderrf("cmdroot = %s\n", *cmdroot);
 			log.Stderrf("pkgroot = %s\n", *pkgroot);
 			log.Stderrf("tmplroot = %s\n", *tmplroot);
+			log.Stderrf("templates = %s\n", *tmpldirs);
+			log.Stderrf("urlprefix = %s\n", *urlPrefix);
 			log.Stderrf("tabwidth = %d\n", *tabwidth);
+			log.Stderrf("index_file = %s\n", *indexFileName);
+			log.Stderrf("index_throttle = %g\n", *indexThrottle);
 			handler = loggingHandler(handler);
 		}
+		if err := openAccessLog(*logFile); err != nil {
+			log.Exitf("access log: %v", err)
+		}
+		handler = accessLogHandler(handler);
 
-		registerPublicHandlers(http.DefaultServeMux);
+		// The documentation handler starts the indexer.
+		docs, err := NewHandler(goroot);
+		if err != nil {
+			log.Exitf("NewHandler: %v", err)
+		}
+		http.Handle("/", docs);
 		if *syncCmd != "" {
 			http.Handle("/debug/sync", http.HandlerFunc(dosync))
 		}
-
-		// Initialize directory tree with corresponding timestamp.
-		// Do it in two steps:
-		// 1) set timestamp right away so that the indexer is kicked on
-		fsTree.set(nil);
-		// 2) compute initial directory tree in a goroutine so that launch is quick
-		go func() { fsTree.set(newDirectory(".", maxDirDepth)) }();
+		http.Handle("/debug/examples", http.HandlerFunc(serveExamples));
+
+		// The control pages are served at a separate address,
+		// which must not be reachable by the clients.
+		if *adminaddr != "" {
+			admin := http.NewServeMux();
+			admin.Handle("/debug/reload", http.HandlerFunc(serveReload));
+			admin.Handle("/debug/shutdown", http.HandlerFunc(serveShutdown));
+			admin.Handle("/debug/rollback", http.HandlerFunc(serveRollback));
+			admin.Handle("/debug/snapshots", http.HandlerFunc(serveSnapshots));
+			admin.Handle("/debug/roots", http.HandlerFunc(serveRoots));
+			go serveAdmin(*adminaddr, tracker.handler(admin));
+		}
 
 		// Start sync goroutine, if enabled.
 		if *syncCmd != "" && *syncMin > 0 {
@@ -199,19 +305,19 @@ func main() {
 			}();
 		}
 
-		// Start indexing goroutine.
-		go indexer();
-
 		// The server may have been restarted; always wait 1sec to
 		// give the forking server a chance to shut down and release
 		// the http port.
 		// TODO(gri): Do we still need this?
 		time.Sleep(1e9);
 
-		// Start http server.
-		if err := http.ListenAndServe(*httpaddr, handler); err != nil {
+		// Start http server; it returns after a shutdown.
+		if err := serve(*httpaddr, handler); err != nil {
 			log.Exitf("ListenAndServe %s: %v", *httpaddr, err)
 		}
+		if *verbose {
+			log.Stderrf("shut down")
+		}
 		return;
 	}
 
@@ -220,18 +326,57 @@ func main() {
 		packageText = packageHTML;
 		parseerrorText = parseerrorHTML;
 	}
+	if *man {
+		packageText = packageMan
+	}
+
+	if *query != "" {
+		if !printSearch(os.Stdout, queryIndex(), *query) {
+			os.Exit(1)
+		}
+		return;
+	}
+
+	if *compareMode {
+		a := packageDoc(flag.Arg(0));
+		b := packageDoc(flag.Arg(1));
+		if a == nil || b == nil {
+			log.Exitf("cannot compare %s and %s: package not found", flag.Arg(0), flag.Arg(1))
+		}
+		tmpl := compareText;
+		if *html {
+			tmpl = compareHTML
+		}
+		if err := tmpl.Execute(comparePackages(flag.Arg(0), flag.Arg(1), a, b), os.Stdout); err != nil {
+			log.Stderrf("tmpl.Execute: %s", err)
+		}
+		return;
+	}
+
+	if *srcMode {
+		args := flag.Args();
+		if !printDeclSource(os.Stdout, args[0], args[1:len(args)]) {
+			os.Exit(1)
+		}
+		return;
+	}
 
-	info := pkgHandler.getPageInfo(flag.Arg(0));
+	mode := exportsOnly;
+	if *allMode {
+		mode = noFiltering
+	}
+	info := pkgHandler.getPageInfo(flag.Arg(0), mode);
 
 	if info.PDoc == nil && info.Dirs == nil {
 		// try again, this time assume it's a command
-		info = cmdHandler.getPageInfo(flag.Arg(0))
+		info = cmdHandler.getPageInfo(flag.Arg(0), mode)
 	}
 
 	if info.PDoc != nil && flag.NArg() > 1 {
 		args := flag.Args();
 		info.PDoc.Filter(args[1:len(args)]);
 	}
+	info.filterSince(*sinceVersion);
 
 	if err := packageText.Execute(info, os.Stdout); err != nil {
 		log.Stderrf("packageText.Execute: %s", err)
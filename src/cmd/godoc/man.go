@@ -0,0 +1,119 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the man page output of godoc.
+//
+//	godoc -man packagepath > page.3go
+//
+// formats the documentation of a package with the man(7) macros,
+// using the template package.man. Packages are documented in
+// section 3go, commands in section 1.
+
+package main
+
+import (
+	"bytes";
+	"io";
+	"strings";
+)
+
+
+// manEscape writes text to w, escaping the characters
+// that troff would interpret.
+func manEscape(w io.Writer, text []byte) {
+	bol := true;	// at beginning of line
+	last := 0;
+	for i, c := range text {
+		var esc string;
+		switch {
+		case c == '\\':
+			esc = `\e`
+		case c == '-':
+			esc = `\-`
+		case bol && (c == '.' || c == '\''):
+			esc = `\&` + string(c)
+		default:
+			bol = c == '\n';
+			continue;
+		}
+		w.Write(text[last:i]);
+		io.WriteString(w, esc);
+		last = i + 1;
+		bol = false;
+	}
+	w.Write(text[last:len(text)]);
+}
+
+
+// Template formatter for "man" format.
+func manFmt(w io.Writer, x interface{}, format string) {
+	var buf bytes.Buffer;
+	writeAny(&buf, x, false);
+	manEscape(w, buf.Bytes());
+}
+
+
+func spaceFor(c int) int {
+	if c == '\n' || c == '\t' {
+		return ' '
+	}
+	return c;
+}
+
+
+// Template formatter for "man-synopsis" format:
+// the first sentence of a doc comment on a single line.
+func manSynopsisFmt(w io.Writer, x interface{}, format string) {
+	s := strings.Map(spaceFor, strings.TrimSpace(firstSentence(x.(string))));
+	manEscape(w, strings.Bytes(s));
+}
+
+
+// Template formatter for "man-comment" format.
+// Paragraphs of a doc comment become .PP paragraphs and
+// indented lines become indented, unfilled blocks.
+func manCommentFmt(w io.Writer, x interface{}, format string) {
+	var buf bytes.Buffer;
+	writeAny(&buf, x, false);
+	lines := strings.Split(buf.String(), "\n", 0);
+
+	pre := false;	// in indented block
+	para := false;	// in paragraph
+	blank := 0;	// blank lines not yet written in indented block
+	for _, line := range lines {
+		switch {
+		case strings.TrimSpace(line) == "":
+			if pre {
+				blank++
+			}
+			para = false;
+			continue;
+		case line[0] == ' ' || line[0] == '\t':
+			if !pre {
+				io.WriteString(w, ".RS\n.nf\n");
+				pre = true;
+			}
+			for ; blank > 0; blank-- {
+				io.WriteString(w, "\n")
+			}
+			line = line[1:len(line)];	// remove comment indentation
+		default:
+			if pre {
+				io.WriteString(w, ".fi\n.RE\n");
+				pre = false;
+				blank = 0;
+				para = false;
+			}
+			if !para {
+				io.WriteString(w, ".PP\n");
+				para = true;
+			}
+		}
+		manEscape(w, strings.Bytes(line));
+		io.WriteString(w, "\n");
+	}
+	if pre {
+		io.WriteString(w, ".fi\n.RE\n")
+	}
+}
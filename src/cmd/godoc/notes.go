@@ -0,0 +1,121 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the notes page. Comments of the form
+//
+//	// BUG(uid): text
+//	// TODO(uid): text
+//
+// are notes (see go/doc). The package pages show the notes of their
+// package; the indexer collects the notes of the whole tree, which
+// /notes lists by package. The query parameters marker and uid, as in
+// /notes?marker=TODO&uid=gri, restrict the list to the notes with that
+// marker or by that user.
+
+package main
+
+import (
+	"bytes";
+	"go/ast";
+	"go/doc";
+	"http";
+	"log";
+	"strings";
+)
+
+
+// A FileNote is a note found by the indexer.
+type FileNote struct {
+	Marker	string;	// "BUG" or "TODO"
+	UID	string;	// user named in the marker; may be empty
+	Body	string;	// comment text without the marker
+	Pak	Pak;	// package containing the note
+	File	string;	// path of the file containing the note
+	Line	int;	// line of the note
+}
+
+
+// addNotes adds the notes in the comments of file to the index.
+func (x *Indexer) addNotes(file *ast.File) {
+	for g := file.Comments; g != nil; g = g.Next {
+		if n := doc.NoteOf(g); n != nil {
+			x.notes.Push(&FileNote{n.Marker, n.UID, n.Body, x.file.Pak, x.file.Path, g.List[0].Line})
+		}
+	}
+}
+
+
+// A NoteGroup is the list of notes of a package.
+type NoteGroup struct {
+	Pak	Pak;
+	Notes	[]*FileNote;
+}
+
+
+type NotesResult struct {
+	Available	bool;	// whether the index has notes
+	Marker		string;	// marker the notes are restricted to, if any
+	UID		string;	// user the notes are restricted to, if any
+	Total		int;	// number of notes listed
+	Groups		[]NoteGroup;
+}
+
+
+// groupNotes returns the notes in list with the given marker and uid,
+// if set, grouped by package; the index lists them in file order.
+func groupNotes(list []*FileNote, marker, uid string) (groups []NoteGroup, total int) {
+	var v []*FileNote;
+	var pak Pak;
+	flush := func() {
+		if len(v) > 0 {
+			n := len(groups);
+			g := make([]NoteGroup, n+1);
+			copy(g, groups);
+			g[n] = NoteGroup{pak, v};
+			groups = g;
+		}
+		v = nil;
+	};
+	for _, note := range list {
+		if marker != "" && note.Marker != marker || uid != "" && note.UID != uid {
+			continue
+		}
+		if note.Pak.Path != pak.Path || note.Pak.Name != pak.Name {
+			flush();
+			pak = note.Pak;
+		}
+		v = appendNote(v, note);
+		total++;
+	}
+	flush();
+	return;
+}
+
+
+func appendNote(list []*FileNote, note *FileNote) []*FileNote {
+	n := len(list);
+	l := make([]*FileNote, n+1);
+	copy(l, list);
+	l[n] = note;
+	return l;
+}
+
+
+func serveNotes(c *http.Conn, r *http.Request) {
+	var result NotesResult;
+	result.Marker = strings.ToUpper(r.FormValue("marker"));
+	result.UID = r.FormValue("uid");
+	if index, _ := searchIndex.get(); index != nil {
+		if list := index.(*Index).notes; list != nil {
+			result.Available = true;
+			result.Groups, result.Total = groupNotes(list, result.Marker, result.UID);
+		}
+	}
+
+	var buf bytes.Buffer;
+	if err := getTemplate(&notesHTML).Execute(result, &buf); err != nil {
+		log.Stderrf("notesHTML.Execute: %s", err)
+	}
+	servePage(c, "Notes", "", nil, nil, buf.Bytes());
+}
@@ -0,0 +1,126 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the command-line search: godoc -query prints
+// the results of a search of the index as plain text instead of
+// serving them as a web page.
+
+package main
+
+import (
+	"bytes";
+	"fmt";
+	"io";
+	"log";
+	"strings";
+)
+
+
+// htmlEntities maps the HTML entities that appear in snippets and
+// infoKinds to the characters they denote.
+var htmlEntities = map[string]string{
+	"&lt;": "<",
+	"&gt;": ">",
+	"&amp;": "&",
+	"&#34;": "\"",
+	"&#39;": "'",
+	"&nbsp;": " ",
+}
+
+
+// htmlText returns the plain text of the HTML fragment s: tags are
+// removed and the entities produced by the HTML escaping are decoded.
+func htmlText(s string) string {
+	var buf bytes.Buffer;
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '<':
+			if j := strings.Index(s[i:len(s)], ">"); j >= 0 {
+				i += j;
+				continue;
+			}
+		case '&':
+			if j := strings.Index(s[i:len(s)], ";"); j >= 0 {
+				if t, found := htmlEntities[s[i:i+j+1]]; found {
+					buf.WriteString(t);
+					i += j;
+					continue;
+				}
+			}
+		}
+		buf.WriteByte(s[i]);
+	}
+	return buf.String();
+}
+
+
+// queryIndex returns the index used for a command-line search: the
+// index file if it is up to date, or a new index of the file tree.
+func queryIndex() *Index {
+	if index := savedIndex(newDirectory(".", maxDirDepth)); index != nil {
+		return index
+	}
+	if *verbose {
+		log.Stderrf("building index...")
+	}
+	return NewIndex(".");
+}
+
+
+// printSearch looks up query in index and prints the results to w as
+// plain text: each package-level declaration found with its location
+// and snippet, then the locations of all other occurrences. Alternative
+// spellings are suggested if there are any. printSearch reports whether
+// the query was found.
+func printSearch(w io.Writer, index *Index, query string) bool {
+	match, alt, illegal := index.Lookup(query);
+	if illegal {
+		fmt.Fprintf(w, "illegal query: %s\n", query);
+		return false;
+	}
+
+	found := false;
+	if match != nil {
+		for _, p := range match.Decls.rank() {
+			for _, f := range p.Files {
+				for _, g := range f.Groups {
+					for _, info := range g.Infos {
+						line := info.Lori();
+						text := "";
+						if info.IsIndex() {
+							line = 0;
+							if s := index.Snippet(info.Lori()); s != nil {
+								line, text = s.Line, htmlText(s.Text)
+							}
+						}
+						fmt.Fprintf(w, "%s:%d:\n", f.File.Path, line);
+						for _, l := range strings.Split(text, "\n", 0) {
+							fmt.Fprintf(w, "\t%s\n", l)
+						}
+						found = true;
+					}
+				}
+			}
+		}
+		for _, p := range match.Others {
+			for _, f := range p.Files {
+				for _, g := range f.Groups {
+					kind := htmlText(infoKinds[g.Kind]);
+					for _, info := range g.Infos {
+						fmt.Fprintf(w, "%s:%d: %s\n", f.File.Path, info.Lori(), kind);
+						found = true;
+					}
+				}
+			}
+		}
+	}
+
+	if !found {
+		fmt.Fprintf(w, "no results found for query %q\n", query)
+	}
+	if alt != nil && len(alt.Alts) > 0 {
+		fmt.Fprintf(w, "did you mean: %s\n", strings.Join(alt.Alts, " "))
+	}
+	return found;
+}
@@ -0,0 +1,87 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the -remote mode. With
+//
+//	godoc -remote=host:port packagepath [name ...]
+//
+// godoc does not read any local files; instead it fetches the plain-text
+// documentation from the godoc server running at host:port and prints
+// it, so that documentation can be looked up from machines without a
+// Go tree. The request is the one for the package page, in text form:
+//
+//	http://host:port/pkg/packagepath/?f=text&name=name...
+//
+// If the server has no package packagepath, the command of that name is
+// tried instead, as in the local command-line mode. The -all and -since
+// flags are passed on to the server. If the server runs with -urlprefix,
+// the prefix is given with the address, as in -remote=host:port/prefix.
+
+package main
+
+import (
+	"http";
+	"io";
+	"os";
+	pathutil "path";
+	"strings";
+)
+
+
+// remoteURL returns the URL of the plain-text documentation of the
+// package or command path on the godoc server at addr; root is "/pkg"
+// or "/cmd", and names are the names of the declarations to document.
+func remoteURL(addr, root, path string, names []string) string {
+	if !strings.HasPrefix(addr, "http://") {
+		addr = "http://" + addr
+	}
+	if strings.HasSuffix(addr, "/") {
+		addr = addr[0 : len(addr)-1]
+	}
+	url := addr + pathutil.Join(root, path) + "/?f=text";
+	for _, name := range names {
+		url += "&name=" + http.URLEscape(name)
+	}
+	if *allMode {
+		url += "&m=all"
+	}
+	if *sinceVersion != "" {
+		url += "&since=" + http.URLEscape(*sinceVersion)
+	}
+	return url;
+}
+
+
+// getRemote returns the contents of the page at url.
+func getRemote(url string) ([]byte, os.Error) {
+	r, _, err := http.Get(url);
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close();
+	if r.StatusCode != http.StatusOK {
+		return nil, os.NewError(url + ": " + r.Status)
+	}
+	return io.ReadAll(r.Body);
+}
+
+
+// printRemote fetches the documentation of the package or command path,
+// restricted to names if there are any, from the godoc server at addr
+// and writes it to w.
+func printRemote(w io.Writer, addr, path string, names []string) os.Error {
+	text, err := getRemote(remoteURL(addr, "/pkg", path, names));
+	if err == nil && len(strings.TrimSpace(string(text))) == 0 {
+		// no such package; try again, this time assume it's a command
+		text, err = getRemote(remoteURL(addr, "/cmd", path, names))
+	}
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(text))) == 0 {
+		return os.NewError("no documentation for " + path)
+	}
+	_, err = w.Write(text);
+	return err;
+}
@@ -0,0 +1,256 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the discovery of the Go tree and its roots.
+//
+// Installations do not always have the layout of a checkout: a Cygwin
+// install may have the tree under a mount that is a symbolic link, name
+// directories with different case, or be given as a Windows path such
+// as C:\go. Hence godoc does not give up if the default -goroot does
+// not exist: it tries $GOROOT, $HOME/go, and the usual install
+// locations, and uses the first that holds a Go tree. A -goroot set on
+// the command line is used as given. Within the tree, the package,
+// command, and template roots are looked up with the case of their
+// path elements ignored, and, unless they were set with -pkgroot,
+// -cmdroot, or -tmplroot, in alternative layouts such as pkg instead
+// of src/pkg.
+//
+// A root that cannot be found is not fatal: its pages are empty, or,
+// for the template root, show a notice, and the rest of the tree is
+// served. The roots in use and the locations
+// tried are logged with -v and shown at /debug/roots, at the -admin
+// address.
+
+package main
+
+import (
+	"bytes";
+	"flag";
+	"fmt";
+	"http";
+	"io";
+	"log";
+	"os";
+	pathutil "path";
+	"strings";
+)
+
+
+// Locations tried after -goroot, in order.
+var gorootCandidates = []string{
+	"$GOROOT",
+	"$HOME/go",
+	"/usr/local/go",
+	"/usr/lib/go",
+	"/opt/go",
+	"/cygdrive/c/go",
+}
+
+
+// Alternative layouts of the roots within the Go tree, tried in order
+// if the flag has its default value (the first entry).
+var (
+	pkgrootLayouts	= []string{"src/pkg", "pkg", "src"};
+	cmdrootLayouts	= []string{"src/cmd", "cmd"};
+	tmplrootLayouts	= []string{"lib/godoc", "share/godoc", "godoc"};
+)
+
+
+// A Root describes a root of the documentation and how it was found.
+type Root struct {
+	Name	string;		// "goroot", "pkgroot", ...
+	Path	string;		// path in use; "" if not found
+	Tried	[]string;	// paths tried
+}
+
+
+var roots []*Root	// set by findRoots, read-only afterwards
+
+
+// isDir reports whether path is a directory, following symbolic links.
+func isDir(path string) bool {
+	d, err := os.Stat(path);
+	return err == nil && d.IsDirectory();
+}
+
+
+// cygwinPath converts a Windows path such as C:\go to the
+// Cygwin path /cygdrive/c/go; other paths are returned unchanged.
+func cygwinPath(path string) string {
+	if len(path) >= 2 && path[1] == ':' {
+		drive := strings.ToLower(path[0:1]);
+		path = "/cygdrive/" + drive + "/" + path[2:len(path)];
+	}
+	return pathutil.Clean(strings.Join(strings.Split(path, "\\", 0), "/"));
+}
+
+
+// findDir returns the path of the directory path, which is looked up
+// element by element with the case of the elements ignored if the
+// exact path does not exist, and whether it was found.
+func findDir(path string) (string, bool) {
+	path = cygwinPath(path);
+	if isDir(path) {
+		return path, true
+	}
+	dir := ".";
+	if strings.HasPrefix(path, "/") {
+		dir = "/"
+	}
+	for _, elem := range strings.Split(path, "/", 0) {
+		if elem == "" || elem == "." {
+			continue
+		}
+		next := pathutil.Join(dir, elem);
+		if !isDir(next) {
+			list, _ := io.ReadDir(dir);	// ignore errors
+			next = "";
+			for _, d := range list {
+				name := pathutil.Join(dir, d.Name);
+				if strings.ToLower(d.Name) == strings.ToLower(elem) && isDir(name) {
+					next = name;
+					break;
+				}
+			}
+			if next == "" {
+				return path, false
+			}
+		}
+		dir = next;
+	}
+	return dir, true;
+}
+
+
+// expand replaces $GOROOT and $HOME in path by their values;
+// it returns "" if one of them is not set.
+func expand(path string) string {
+	for _, v := range []string{"GOROOT", "HOME"} {
+		if strings.HasPrefix(path, "$"+v) {
+			val := os.Getenv(v);
+			if val == "" {
+				return ""
+			}
+			path = val + path[len(v)+1:len(path)];
+		}
+	}
+	return path;
+}
+
+
+// isGoTree reports whether dir looks like the root of a Go tree.
+func isGoTree(dir string) bool {
+	for _, layout := range pkgrootLayouts {
+		if _, ok := findDir(pathutil.Join(dir, layout)); ok {
+			return true
+		}
+	}
+	return false;
+}
+
+
+// gorootFlagSet reports whether -goroot was set on the command line.
+func gorootFlagSet() bool {
+	set := false;
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "goroot" {
+			set = true
+		}
+	});
+	return set;
+}
+
+
+// findGoroot returns the root of the Go tree to use for root and the
+// locations tried. The other candidate locations are tried only if
+// probe is set, that is, if root is the default -goroot.
+func findGoroot(root string, probe bool) (dir string, tried []string) {
+	candidates := []string{root};
+	if probe {
+		candidates = make([]string, 1+len(gorootCandidates));
+		candidates[0] = root;
+		for i, c := range gorootCandidates {
+			candidates[i+1] = expand(c)
+		}
+	}
+	seen := make(map[string]bool);
+	for _, c := range candidates {
+		if c == "" || seen[c] {
+			continue
+		}
+		seen[c] = true;
+		tried = appendPath(tried, c);
+		if d, ok := findDir(c); ok && isGoTree(d) {
+			return d, tried
+		}
+	}
+	// no Go tree; use root if it exists at all
+	if d, ok := findDir(root); ok {
+		return d, tried
+	}
+	return "", tried;
+}
+
+
+// findRoot looks up the root held by the flag value *path and sets it
+// to the path found, trying the layouts if it has the default value.
+func findRoot(name string, path *string, layouts []string) *Root {
+	r := &Root{Name: name};
+	candidates := []string{*path};
+	if *path == layouts[0] {
+		candidates = layouts
+	}
+	for _, c := range candidates {
+		r.Tried = appendPath(r.Tried, c);
+		if d, ok := findDir(c); ok {
+			r.Path = d;
+			*path = d;
+			return r;
+		}
+	}
+	return r;
+}
+
+
+func appendPath(list []string, path string) []string {
+	n := len(list);
+	l := make([]string, n+1);
+	copy(l, list);
+	l[n] = path;
+	return l;
+}
+
+
+// findRoots looks up the package, command, and template roots in the
+// Go tree dir, which is the working directory, and records them with
+// the locations tried for dir.
+func findRoots(dir string, tried []string) {
+	roots = []*Root{
+		&Root{"goroot", dir, tried},
+		findRoot("pkgroot", pkgroot, pkgrootLayouts),
+		findRoot("cmdroot", cmdroot, cmdrootLayouts),
+		findRoot("tmplroot", tmplroot, tmplrootLayouts),
+	};
+	for _, r := range roots {
+		switch {
+		case r.Path == "":
+			log.Stderrf("%s not found (tried %s); its pages will be empty", r.Name, strings.Join(r.Tried, ", "))
+		case *verbose:
+			log.Stderrf("%s = %s", r.Name, r.Path)
+		}
+	}
+}
+
+
+func serveRoots(c *http.Conn, r *http.Request) {
+	var buf bytes.Buffer;
+	for _, root := range roots {
+		path := root.Path;
+		if path == "" {
+			path = "(not found)"
+		}
+		fmt.Fprintf(&buf, "%s\t%s\ttried %s\n", root.Name, path, strings.Join(root.Tried, ", "));
+	}
+	serveText(c, buf.Bytes());
+}
@@ -0,0 +1,125 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the snapshots of the search index. With
+// -index_snapshots=k, godoc keeps the last k indexes it built,
+// together with the directory trees they were built from. If a
+// sync pulled broken sources, the service can be rolled back to
+// a previous snapshot with
+//
+//	/debug/rollback?n=1
+//
+// and serve the good index until the tree is repaired and the next
+// sync changes files again. The snapshots are listed at
+//
+//	/debug/snapshots
+//
+// Both are served at the -admin address only.
+
+package main
+
+import (
+	"bytes";
+	"fmt";
+	"http";
+	"os";
+	"strconv";
+	"sync";
+	"time";
+)
+
+
+// A snapshot is a search index and the directory tree it was built from.
+type snapshot struct {
+	index	*Index;
+	tree	interface{};	// *Directory, or nil if there was none
+	time	int64;	// time of the build, in seconds since epoch
+}
+
+
+var snapshots struct {
+	sync.Mutex;
+	list	[]snapshot;	// oldest first
+	current	int;		// index of the snapshot in use
+}
+
+
+// addSnapshot records a newly built index, which is in use, dropping
+// the oldest snapshot if there are more than -index_snapshots.
+func addSnapshot(index *Index, tree interface{}) {
+	k := *indexSnapshots;
+	if k <= 0 {
+		return
+	}
+	snapshots.Lock();
+	defer snapshots.Unlock();
+	list := snapshots.list;
+	if len(list) >= k {
+		// drop the oldest ones
+		n := copy(list, list[len(list)-k+1:len(list)]);
+		list = list[0:n];
+	}
+	if len(list) == cap(list) {
+		l := make([]snapshot, len(list), k);
+		copy(l, list);
+		list = l;
+	}
+	list = list[0 : len(list)+1];
+	list[len(list)-1] = snapshot{index, tree, time.Seconds()};
+	snapshots.list = list;
+	snapshots.current = len(list) - 1;
+}
+
+
+// rollback puts the snapshot n builds older than the one in use
+// into service; a negative n returns to a newer snapshot.
+func rollback(n int) os.Error {
+	snapshots.Lock();
+	defer snapshots.Unlock();
+	i := snapshots.current - n;
+	if i < 0 || i >= len(snapshots.list) {
+		return os.NewError("no such snapshot")
+	}
+	s := snapshots.list[i];
+	// Set the tree before the index so that the index is
+	// as current as the tree and the indexer leaves it alone.
+	fsTree.set(s.tree);
+	searchIndex.set(s.index);
+	snapshots.current = i;
+	return nil;
+}
+
+
+func serveRollback(c *http.Conn, r *http.Request) {
+	serveControl(c, r, func() os.Error {
+		n := 1;
+		if s := r.FormValue("n"); s != "" {
+			var err os.Error;
+			if n, err = strconv.Atoi(s); err != nil {
+				return err
+			}
+		}
+		return rollback(n);
+	})
+}
+
+
+func serveSnapshots(c *http.Conn, r *http.Request) {
+	snapshots.Lock();
+	var buf bytes.Buffer;
+	for i := len(snapshots.list) - 1; i >= 0; i-- {
+		s := snapshots.list[i];
+		nwords, nspots := s.index.Size();
+		fmt.Fprintf(&buf, "n=%d\t%s\t%d words\t%d spots", snapshots.current-i, time.SecondsToLocalTime(s.time), nwords, nspots);
+		if i == snapshots.current {
+			buf.WriteString("\t(in use)")
+		}
+		buf.WriteByte('\n');
+	}
+	snapshots.Unlock();
+	if buf.Len() == 0 {
+		buf.WriteString("no snapshots\n")
+	}
+	serveText(c, buf.Bytes());
+}
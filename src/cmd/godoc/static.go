@@ -0,0 +1,322 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the -write_html mode. With
+//
+//	godoc -write_html=dir
+//
+// godoc writes the documentation of all packages and commands as static
+// HTML pages to dir, together with the home page, the style sheet and
+// script of the pages, and an index of all exported identifiers, which
+// takes the place of the search. The result is a snapshot that any web
+// server for static files can serve. The pages are laid out like the
+// URLs of the server:
+//
+//	dir/index.html			the home page
+//	dir/pkg/index.html		the package directory
+//	dir/pkg/fmt/index.html		the documentation of package fmt
+//	dir/cmd/gofmt/index.html	the documentation of command gofmt
+//	dir/search/index.html		the identifier index
+//
+// Source files are not included: the links to them are removed from the
+// pages, keeping their text, and the search form is replaced by a link
+// to the identifier index.
+
+package main
+
+import (
+	"bytes";
+	"container/vector";
+	"go/ast";
+	"go/doc";
+	"io";
+	"log";
+	"os";
+	pathutil "path";
+	"sort";
+	"strings";
+)
+
+
+// Static files used by the pages, relative to goroot.
+var staticFiles = []string{
+	"doc/godocs.js",
+	"doc/logo-153x55.png",
+	"doc/style.css",
+}
+
+
+// A StaticName is an entry of the identifier index.
+type StaticName struct {
+	Name	string;	// identifier, or Type.Method
+	Pak	string;	// import path of the package
+	URL	string;	// URL of the declaration
+}
+
+
+type staticNames []StaticName
+
+func (p staticNames) Len() int	{ return len(p) }
+func (p staticNames) Less(i, j int) bool {
+	return p[i].Name < p[j].Name || p[i].Name == p[j].Name && p[i].Pak < p[j].Pak
+}
+func (p staticNames) Swap(i, j int)	{ p[i], p[j] = p[j], p[i] }
+
+
+// A staticWriter writes the pages of a documentation snapshot.
+type staticWriter struct {
+	dir	string;		// output directory
+	names	vector.Vector;	// of StaticName
+	npages	int;		// number of pages written
+}
+
+
+// writeFile writes data to the file with the given URL path.
+func (w *staticWriter) writeFile(path string, data []byte) os.Error {
+	filename := pathutil.Join(w.dir, path);
+	dir, _ := pathutil.Split(filename);
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return io.WriteFile(filename, data, 0644);
+}
+
+
+// writePage writes the page for the directory URL path url.
+func (w *staticWriter) writePage(url, title string, sibs []Link, content []byte) os.Error {
+	var buf bytes.Buffer;
+	writePage(&buf, title, "", breadcrumbs(url), sibs, content);
+	w.npages++;
+	return w.writeFile(pathutil.Join(url, "index.html"), staticLinks(buf.Bytes()));
+}
+
+
+// staticLinks returns the HTML text of a page with the references to
+// what a snapshot does not contain removed: the search form is replaced
+// by a link to the identifier index, and the links to the source files
+// under /src are replaced by their text.
+func staticLinks(page []byte) []byte {
+	root := urlRoot();
+
+	form := strings.Bytes(`<form method="GET" action="` + root + `/search"`);
+	if i := bytes.Index(page, form); i >= 0 {
+		if j := bytes.Index(page[i:len(page)], strings.Bytes("</form>")); j >= 0 {
+			j += i + len("</form>");
+			var buf bytes.Buffer;
+			buf.Write(page[0:i]);
+			buf.WriteString(`<a href="` + root + `/search/">Index of identifiers</a>`);
+			buf.Write(page[j:len(page)]);
+			page = buf.Bytes();
+		}
+	}
+
+	link := strings.Bytes(`<a href="` + root + `/src`);
+	end := strings.Bytes("</a>");
+	var buf bytes.Buffer;
+	for {
+		i := bytes.Index(page, link);
+		if i < 0 {
+			break
+		}
+		n := i + len(link);
+		if n < len(page) && page[n] != '/' && page[n] != '"' && page[n] != '#' {
+			// a path starting with /src, such as /srcfoo
+			buf.Write(page[0:n]);
+			page = page[n:len(page)];
+			continue;
+		}
+		// the start tag ends at the next '>', the element at the next "</a>"
+		j := bytes.Index(page[n:len(page)], strings.Bytes(">"));
+		k := bytes.Index(page[n:len(page)], end);
+		if j < 0 || k < j {
+			break	// malformed; leave the rest alone
+		}
+		buf.Write(page[0:i]);
+		buf.Write(page[n+j+1 : n+k]);
+		page = page[n+k+len(end) : len(page)];
+	}
+	buf.Write(page);
+	return buf.Bytes();
+}
+
+
+// addNames adds the exported package-level names documented in info
+// to the identifier index; url is the URL of the package page.
+func (w *staticWriter) addNames(url string, info PageInfo) {
+	pdoc := info.PDoc;
+	if pdoc == nil || !info.IsPkg {
+		return
+	}
+	add := func(name, anchor string) {
+		if ast.IsExported(name) {
+			w.names.Push(StaticName{name, pdoc.ImportPath, url + "#" + anchor})
+		}
+	};
+	addValues := func(list []*doc.ValueDoc, anchor string) {
+		for _, v := range list {
+			for _, s := range v.Decl.Specs {
+				for _, name := range s.(*ast.ValueSpec).Names {
+					add(name.Value, anchor)
+				}
+			}
+		}
+	};
+	addValues(pdoc.Consts, "Constants");
+	addValues(pdoc.Vars, "Variables");
+	for _, f := range pdoc.Funcs {
+		add(f.Name, f.Name)
+	}
+	for _, t := range pdoc.Types {
+		tname := t.Type.Name.Value;
+		add(tname, tname);
+		addValues(t.Consts, tname);
+		addValues(t.Vars, tname);
+		for _, f := range t.Factories {
+			add(f.Name, f.Name)
+		}
+		for _, m := range t.Methods {
+			if ast.IsExported(m.Name) {
+				w.names.Push(StaticName{tname + "." + m.Name, pdoc.ImportPath, url + "#" + tname + "." + m.Name})
+			}
+		}
+	}
+}
+
+
+// absPath returns path made absolute relative to the current
+// directory; an empty path is returned unchanged.
+func absPath(path string) string {
+	if path == "" || strings.HasPrefix(path, "/") {
+		return path
+	}
+	wd, err := os.Getwd();
+	if err != nil {
+		return path
+	}
+	return pathutil.Join(wd, path);
+}
+
+
+// relPath returns the path of the directory dir relative to root.
+func relPath(root, dir string) string {
+	root, dir = pathutil.Clean(root), pathutil.Clean(dir);
+	if dir == root {
+		return ""
+	}
+	return dir[len(root)+1 : len(dir)];
+}
+
+
+// writePackages writes the pages of the package directories.
+func (w *staticWriter) writePackages() os.Error {
+	root := newDirectory(*pkgroot, maxDirDepth);
+	if root == nil {
+		return os.NewError("no packages under " + *pkgroot)
+	}
+	for d := range root.iter(false) {
+		path := relPath(*pkgroot, d.Path);
+		url := pathutil.Join("/pkg", path) + "/";
+		info := pkgHandler.getPageInfo(path, exportsOnly);
+		var buf bytes.Buffer;
+		if err := getTemplate(&packageHTML).Execute(info, &buf); err != nil {
+			log.Stderrf("packageHTML.Execute: %s", err)
+		}
+		if err := w.writePage(url, pkgHandler.pageTitle(path, info), pkgHandler.siblings(path), buf.Bytes()); err != nil {
+			return err
+		}
+		w.addNames(url, info);
+	}
+	return nil;
+}
+
+
+// writeCommands writes the pages of the command directories.
+func (w *staticWriter) writeCommands() os.Error {
+	root := newDirectory(*cmdroot, maxDirDepth);
+	if root == nil {
+		return nil	// no commands
+	}
+	for d := range root.iter(false) {
+		path := relPath(*cmdroot, d.Path);
+		url := pathutil.Join("/cmd", path) + "/";
+		var err os.Error;
+		if cinfo, ok := getCommandInfo(path); ok {
+			title, sibs, content := commandPage(path, cinfo);
+			err = w.writePage(url, title, sibs, content);
+		} else {
+			info := cmdHandler.getPageInfo(path, exportsOnly);
+			var buf bytes.Buffer;
+			if err := getTemplate(&packageHTML).Execute(info, &buf); err != nil {
+				log.Stderrf("packageHTML.Execute: %s", err)
+			}
+			err = w.writePage(url, cmdHandler.pageTitle(path, info), cmdHandler.siblings(path), buf.Bytes());
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil;
+}
+
+
+// writeHome writes the home page and the static files.
+func (w *staticWriter) writeHome() os.Error {
+	src, err := io.ReadFile("doc/root.html");
+	if err != nil {
+		return err
+	}
+	if err := w.writePage("/", commentText(src), nil, rootLinks(src)); err != nil {
+		return err
+	}
+	for _, name := range staticFiles {
+		data, err := io.ReadFile(name);
+		if err != nil {
+			return err
+		}
+		if err := w.writeFile(name, data); err != nil {
+			return err
+		}
+	}
+	return nil;
+}
+
+
+// writeNames writes the identifier index.
+func (w *staticWriter) writeNames() os.Error {
+	names := make(staticNames, w.names.Len());
+	for i := range names {
+		names[i] = w.names.At(i).(StaticName)
+	}
+	sort.Sort(names);
+	var buf bytes.Buffer;
+	if err := getTemplate(&identifiersHTML).Execute(names, &buf); err != nil {
+		log.Stderrf("identifiersHTML.Execute: %s", err)
+	}
+	return w.writePage("/search/", "Identifiers", nil, buf.Bytes());
+}
+
+
+// writeStatic writes the documentation snapshot to dir.
+func writeStatic(dir string) os.Error {
+	// the page helpers use the directory tree
+	fsTree.set(newDirectory(".", maxDirDepth));
+
+	w := &staticWriter{dir: dir};
+	if err := w.writeHome(); err != nil {
+		return err
+	}
+	if err := w.writePackages(); err != nil {
+		return err
+	}
+	if err := w.writeCommands(); err != nil {
+		return err
+	}
+	if err := w.writeNames(); err != nil {
+		return err
+	}
+	if *verbose {
+		log.Stderrf("wrote %d pages to %s", w.npages, dir)
+	}
+	return nil;
+}
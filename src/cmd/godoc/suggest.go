@@ -0,0 +1,273 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the /suggest handler, which completes a prefix
+// typed into the search box: /suggest?q=prefix returns, as JSON,
+//
+//	{ "prefix": ..., "suggestions": [ { "text": ..., "kind": ..., "url": ... }, ... ] }
+//
+// with the best maxSuggestions package paths and exported identifiers
+// starting with the prefix, regardless of case. The query parameter n
+// asks for fewer. Packages are also found by their last path element,
+// so that "print" suggests go/printer.
+//
+// The completions are kept in a trie in which each node holds the best
+// completions of its prefix, so that a request takes time proportional
+// to the length of the prefix, however many words start with it. The
+// trie is built from the directory tree and the search index, again
+// whenever either changes; identifiers are not suggested while the
+// index read from -index_file is in use.
+
+package main
+
+import (
+	"go/ast";
+	"http";
+	"strconv";
+	"strings";
+	"sync";
+)
+
+
+// Maximum number of suggestions returned for a prefix.
+const maxSuggestions = 10
+
+
+// A Suggestion is a completion of a prefix.
+type Suggestion struct {
+	Text	string;	// package path or identifier
+	Kind	string;	// "package", "type", "func", ...
+	URL	string;	// page of the package or search for the identifier
+	rank	int;	// kinds with a lower rank are suggested first
+}
+
+
+// Kinds of suggestions, in order of their rank.
+var suggestKinds = []string{"package", "type", "func", "method", "const", "var"}
+
+
+func newSuggestion(text, kind, url string) *Suggestion {
+	s := &Suggestion{text, kind, url, len(suggestKinds)};
+	for i, k := range suggestKinds {
+		if k == kind {
+			s.rank = i
+		}
+	}
+	return s;
+}
+
+
+// less reports whether s is a better suggestion than t:
+// suggestions of a lower rank come first, then shorter ones.
+func (s *Suggestion) less(t *Suggestion) bool {
+	switch {
+	case s.rank != t.rank:
+		return s.rank < t.rank
+	case len(s.Text) != len(t.Text):
+		return len(s.Text) < len(t.Text)
+	}
+	return s.Text < t.Text;
+}
+
+
+// A suggestNode is a node of the trie; the labels of the nodes
+// on the path from the root spell the prefix it completes.
+type suggestNode struct {
+	label	byte;
+	kids	[]*suggestNode;	// sorted by label
+	top	[]*Suggestion;	// best completions of the prefix, best first
+}
+
+
+// child returns the child of n with the given label. If there
+// is none, it creates one if create is set and returns nil otherwise.
+func (n *suggestNode) child(label byte, create bool) *suggestNode {
+	i, j := 0, len(n.kids);
+	for i < j {
+		h := (i + j) / 2;
+		if n.kids[h].label < label {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	if i < len(n.kids) && n.kids[i].label == label {
+		return n.kids[i]
+	}
+	if !create {
+		return nil
+	}
+	kid := &suggestNode{label: label};
+	kids := make([]*suggestNode, len(n.kids)+1);
+	copy(kids, n.kids[0:i]);
+	kids[i] = kid;
+	copy(kids[i+1:len(kids)], n.kids[i:len(n.kids)]);
+	n.kids = kids;
+	return kid;
+}
+
+
+// offer adds s to the completions of n if it is among the best.
+func (n *suggestNode) offer(s *Suggestion) {
+	if n.top == nil {
+		n.top = make([]*Suggestion, 0, maxSuggestions)
+	}
+	list := n.top;
+	i := len(list);
+	for i > 0 && s.less(list[i-1]) {
+		i--
+	}
+	if i >= maxSuggestions || i > 0 && list[i-1] == s {
+		return	// not among the best, or already present
+	}
+	if len(list) < maxSuggestions {
+		list = list[0 : len(list)+1]
+	}
+	for j := len(list) - 1; j > i; j-- {
+		list[j] = list[j-1]
+	}
+	list[i] = s;
+	n.top = list;
+}
+
+
+// insert adds s as a completion of each prefix of key.
+func (n *suggestNode) insert(key string, s *Suggestion) {
+	key = strings.ToLower(key);
+	for i := 0; i < len(key); i++ {
+		n = n.child(key[i], true);
+		n.offer(s);
+	}
+}
+
+
+// lookup returns the best completions of prefix.
+func (n *suggestNode) lookup(prefix string) []*Suggestion {
+	prefix = strings.ToLower(prefix);
+	for i := 0; i < len(prefix) && n != nil; i++ {
+		n = n.child(prefix[i], false)
+	}
+	if n == nil {
+		return nil
+	}
+	return n.top;
+}
+
+
+// Names of the declaration kinds of the index.
+var declKinds = [nKinds]string{
+	ConstDecl: "const",
+	TypeDecl: "type",
+	VarDecl: "var",
+	FuncDecl: "func",
+	MethodDecl: "method",
+}
+
+
+// newSuggestTrie builds the trie of the packages in tree
+// and the exported identifiers declared in index.
+func newSuggestTrie(tree *Directory, index *Index) *suggestNode {
+	root := new(suggestNode);
+
+	prefix := *pkgroot + "/";
+	for d := range tree.iter(true) {
+		if !strings.HasPrefix(d.Path, prefix) {
+			continue
+		}
+		path := d.Path[len(prefix):len(d.Path)];
+		s := newSuggestion(path, "package", "/pkg/"+path+"/");
+		root.insert(path, s);
+		if d.Name != path {
+			root.insert(d.Name, s)
+		}
+	}
+
+	if index != nil && index.words != nil {
+		for w, r := range index.words {
+			if len(r.Decls) == 0 || !ast.IsExported(w) {
+				continue
+			}
+			kind := declKinds[r.Decls[0].Files[0].Groups[0].Kind];
+			if kind == "" {
+				continue	// not a declaration of interest
+			}
+			root.insert(w, newSuggestion(w, kind, "/search?q="+http.URLEscape(w)));
+		}
+	}
+
+	return root;
+}
+
+
+// The trie in use, and the tree and index it was built from.
+var suggestTrie struct {
+	sync.Mutex;
+	root	*suggestNode;
+	tree	interface{};
+	index	interface{};
+}
+
+
+// suggestions returns the trie for the current directory tree and
+// search index, building it first if they have changed.
+func suggestions() *suggestNode {
+	tree, _ := fsTree.get();
+	index, _ := searchIndex.get();
+	suggestTrie.Lock();
+	defer suggestTrie.Unlock();
+	if suggestTrie.root == nil || suggestTrie.tree != tree || suggestTrie.index != index {
+		var dir *Directory;
+		if tree != nil {
+			dir = tree.(*Directory)
+		}
+		var x *Index;
+		if index != nil {
+			x = index.(*Index)
+		}
+		suggestTrie.root = newSuggestTrie(dir, x);
+		suggestTrie.tree = tree;
+		suggestTrie.index = index;
+	}
+	return suggestTrie.root;
+}
+
+
+func serveSuggest(c *http.Conn, r *http.Request) {
+	prefix := r.FormValue("q");
+	n := maxSuggestions;
+	if v, err := strconv.Atoi(r.FormValue("n")); err == nil && 0 < v && v < n {
+		n = v
+	}
+	var list []*Suggestion;
+	if prefix != "" {
+		list = suggestions().lookup(prefix)
+	}
+	if len(list) > n {
+		list = list[0:n]
+	}
+
+	var w jsonWriter;
+	w.WriteByte('{');
+	w.key("prefix", true);
+	w.quote(prefix);
+	w.key("suggestions", false);
+	w.WriteByte('[');
+	for i, s := range list {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteByte('{');
+		w.key("text", true);
+		w.quote(s.Text);
+		w.key("kind", false);
+		w.quote(s.Kind);
+		w.key("url", false);
+		w.quote(rooted(s.URL));
+		w.WriteByte('}');
+	}
+	w.WriteString("]}\n");
+
+	c.SetHeader("content-type", "application/json; charset=utf-8");
+	c.Write(w.Bytes());
+}
@@ -0,0 +1,101 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the support of a URL path prefix. Behind a reverse
+// proxy that serves the documentation under a path such as /docs/, the
+// absolute links of the pages must start with that path. With
+//
+//	-urlprefix=/docs
+//
+// the links of the templates, of the generated HTML and of the HTML
+// documents, and the redirects, are rooted at /docs. Requests are
+// accepted with or without the prefix, so that it does not matter
+// whether the proxy strips it.
+
+package main
+
+import (
+	"bytes";
+	"http";
+	"io";
+	pathutil "path";
+	"strings";
+	"template";
+)
+
+
+// urlRoot returns the URL path prefix without a trailing slash,
+// or "" if there is none.
+func urlRoot() string {
+	if *urlPrefix == "" {
+		return ""
+	}
+	root := pathutil.Clean("/" + *urlPrefix);
+	if root == "/" {
+		return ""
+	}
+	return root;
+}
+
+
+// rooted returns the URL path with the URL path prefix if
+// the path is absolute; relative paths are returned unchanged.
+func rooted(path string) string {
+	if strings.HasPrefix(path, "/") {
+		return urlRoot() + path
+	}
+	return path;
+}
+
+
+// rootLinks returns the HTML text src with the URL path prefix
+// inserted in its absolute links.
+func rootLinks(src []byte) []byte {
+	root := urlRoot();
+	if root == "" {
+		return src
+	}
+	for _, attr := range []string{`href="/`, `src="/`, `action="/`} {
+		sep := strings.Bytes(attr);
+		repl := strings.Bytes(attr[0:len(attr)-1] + root + "/");
+		src = bytes.Join(bytes.Split(src, sep, 0), repl);
+	}
+	return src;
+}
+
+
+// Template formatter for "prefix" format: the URL path prefix,
+// regardless of the value. It is used before absolute links,
+// as in {@|prefix}/pkg/.
+func prefixFmt(w io.Writer, x interface{}, format string) {
+	template.HTMLEscape(w, strings.Bytes(urlRoot()))
+}
+
+
+// Template formatter for "url" format: a URL path, with the
+// URL path prefix if the path is absolute.
+func urlFmt(w io.Writer, x interface{}, format string) {
+	var buf bytes.Buffer;
+	writeAny(&buf, x, false);
+	template.HTMLEscape(w, strings.Bytes(rooted(buf.String())));
+}
+
+
+// prefixHandler returns a handler that removes the URL path prefix,
+// if any, from the path of a request before serving it with h.
+func prefixHandler(h http.Handler) http.Handler {
+	root := urlRoot();
+	if root == "" {
+		return h
+	}
+	return http.HandlerFunc(func(c *http.Conn, r *http.Request) {
+		if path := r.URL.Path; path == root || strings.HasPrefix(path, root+"/") {
+			r.URL.Path = path[len(root):len(path)];
+			if r.URL.Path == "" {
+				r.URL.Path = "/"
+			}
+		}
+		h.ServeHTTP(c, r);
+	})
+}
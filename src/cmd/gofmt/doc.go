@@ -26,6 +26,11 @@ The flags are:
 		tab width in spaces.
 	-align=true
 		align columns.
+	-profile=""
+		read printer settings from the given configuration profile (see
+		go/printer.ReadConfig); settings in the profile take precedence
+		over the defaults of -tabwidth, -spaces, and -align, but not
+		over those flags when they are set on the command line.
 
 Debugging flags:
 
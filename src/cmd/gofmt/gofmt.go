@@ -31,9 +31,13 @@ var (
 	align		= flag.Bool("align", true, "align columns");
 	tabwidth	= flag.Int("tabwidth", 8, "tab width");
 	usespaces	= flag.Bool("spaces", false, "align with spaces instead of tabs");
+	profile		= flag.String("profile", "", "printer configuration profile; overrides the defaults of the layout flags");
 )
 
 
+var config printer.Config	// printer configuration, set up by main
+
+
 var exitCode = 0
 
 func report(err os.Error) {
@@ -73,6 +77,33 @@ func printerMode() uint {
 }
 
 
+func loadProfile(filename string) (*printer.Config, uint, os.Error) {
+	f, err := os.Open(filename, os.O_RDONLY, 0);
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close();
+	return printer.ReadConfig(f);
+}
+
+
+// layoutFlagsSet returns the printer mode flags controlled by the layout
+// flags set on the command line, and whether -tabwidth was set.
+func layoutFlagsSet() (mask uint, tabwidthSet bool) {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "align":
+			mask |= printer.RawFormat
+		case "spaces":
+			mask |= printer.UseSpaces
+		case "tabwidth":
+			tabwidthSet = true
+		}
+	});
+	return;
+}
+
+
 func isGoFile(d *os.Dir) bool {
 	// ignore non-Go files
 	return d.IsRegular() && !strings.HasPrefix(d.Name, ".") && strings.HasSuffix(d.Name, ".go")
@@ -91,7 +122,7 @@ func processFile(filename string) os.Error {
 	}
 
 	var res bytes.Buffer;
-	_, err = (&printer.Config{printerMode(), *tabwidth, nil}).Fprint(&res, file);
+	_, err = config.Fprint(&res, file);
 	if err != nil {
 		return err
 	}
@@ -158,6 +189,22 @@ func main() {
 		os.Exit(2);
 	}
 
+	config = printer.Config{printerMode(), *tabwidth, nil};
+	if *profile != "" {
+		cfg, mask, err := loadProfile(*profile);
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", *profile, err);
+			os.Exit(2);
+		}
+		config.Merge(cfg, mask);
+		// layout flags set on the command line override the profile
+		mask, tabwidthSet := layoutFlagsSet();
+		config.Merge(&printer.Config{printerMode(), 0, nil}, mask);
+		if tabwidthSet {
+			config.Tabwidth = *tabwidth
+		}
+	}
+
 	if flag.NArg() == 0 {
 		if err := processFile("/dev/stdin"); err != nil {
 			report(err)
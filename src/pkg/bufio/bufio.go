@@ -85,6 +85,17 @@ func NewReader(rd io.Reader) *Reader {
 	return b;
 }
 
+// NewReadPeeker returns rd if it already implements io.ReadPeeker,
+// such as a Reader or an io.PipeReader, and a new Reader with the
+// default buffer size otherwise.  Layers that need to look ahead can
+// thus use the buffer of the layer below them instead of adding one.
+func NewReadPeeker(rd io.Reader) io.ReadPeeker {
+	if p, ok := rd.(io.ReadPeeker); ok {
+		return p
+	}
+	return NewReader(rd);
+}
+
 // fill reads a new chunk into the buffer.
 func (b *Reader) fill() {
 	// Slide existing data to beginning.
@@ -143,6 +154,29 @@ func (b *Reader) Read(p []byte) (nn int, err os.Error) {
 	return nn, nil;
 }
 
+// Peek returns the next n bytes without advancing the reader,
+// implementing io.Peeker.  The bytes stop being valid at the next
+// read call.  If Peek returns fewer than n bytes, it also returns an
+// error explaining why the read is short; the error is io.ErrPeekLimit
+// if n is larger than the buffer size of b.
+func (b *Reader) Peek(n int) ([]byte, os.Error) {
+	m := n;
+	if m > len(b.buf) {
+		m = len(b.buf)
+	}
+	for b.w-b.r < m && b.err == nil {
+		b.fill()
+	}
+	if b.w-b.r < n {
+		err := b.err;
+		if b.w-b.r == len(b.buf) {
+			err = io.ErrPeekLimit
+		}
+		return b.buf[b.r:b.w], err;
+	}
+	return b.buf[b.r : b.r+n], nil;
+}
+
 // ReadByte reads and returns a single byte.
 // If no byte is available, returns an error.
 func (b *Reader) ReadByte() (c byte, err os.Error) {
@@ -377,3 +377,27 @@ func TestWriteString(t *testing.T) {
 		t.Errorf("WriteString wants %q gets %q", s, string(buf.Bytes()))
 	}
 }
+
+func TestPeek(t *testing.T) {
+	b, _ := NewReaderSize(iotest.OneByteReader(bytes.NewBufferString("abcdef")), 4);
+	if p, err := b.Peek(3); string(p) != "abc" || err != nil {
+		t.Fatalf("Peek(3) = %q, %v; want \"abc\", nil", p, err)
+	}
+	if p, err := b.Peek(5); string(p) != "abcd" || err != io.ErrPeekLimit {
+		t.Fatalf("Peek(5) = %q, %v; want \"abcd\", io.ErrPeekLimit", p, err)
+	}
+	buf := make([]byte, 4);
+	if n, _ := b.Read(buf); string(buf[0:n]) != "abcd" {
+		t.Fatalf("Read after Peek = %q; want \"abcd\"", buf[0:n])
+	}
+	if p, err := b.Peek(2); string(p) != "ef" || err != nil {
+		t.Fatalf("Peek(2) = %q, %v; want \"ef\", nil", p, err)
+	}
+	b.Read(buf);
+	if p, err := b.Peek(1); len(p) != 0 || err != os.EOF {
+		t.Fatalf("Peek(1) at EOF = %q, %v; want \"\", os.EOF", p, err)
+	}
+	if p := NewReadPeeker(b); p != io.ReadPeeker(b) {
+		t.Errorf("NewReadPeeker did not adopt the Reader")
+	}
+}
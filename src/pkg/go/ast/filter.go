@@ -4,7 +4,12 @@
 
 package ast
 
-import "go/token"
+import (
+	"bytes";
+	"container/vector";
+	"go/token";
+	"sort";
+)
 
 
 func filterIdentList(list []*Ident) []*Ident {
@@ -193,61 +198,168 @@ func PackageExports(pkg *Package) bool {
 var separator = &Comment{noPos, []byte{'/', '/'}}
 
 
+// sortedFiles returns the files of pkg sorted by filename.
+func sortedFiles(pkg *Package) []*File {
+	filenames := make([]string, len(pkg.Files));
+	i := 0;
+	for filename, _ := range pkg.Files {
+		filenames[i] = filename;
+		i++;
+	}
+	sort.SortStrings(filenames);
+
+	files := make([]*File, len(filenames));
+	for i, filename := range filenames {
+		files[i] = pkg.Files[filename]
+	}
+	return files;
+}
+
+
+// commentText returns the concatenated text of the comments in g.
+func commentText(g *CommentGroup) string {
+	var buf bytes.Buffer;
+	for _, c := range g.List {
+		buf.Write(c.Text);
+		buf.WriteByte('\n');
+	}
+	return buf.String();
+}
+
+
+// importKey returns a key identifying the package imported by s
+// and the name under which it is imported.
+func importKey(s *ImportSpec) string {
+	var buf bytes.Buffer;
+	if s.Name != nil {
+		buf.WriteString(s.Name.Value)
+	}
+	for _, x := range s.Path {
+		buf.WriteByte(' ');
+		buf.Write(x.Value);
+	}
+	return buf.String();
+}
+
+
 // MergePackageFiles creates a file AST by merging the ASTs of the
-// files belonging to a package.
+// files belonging to a package. The files are processed in filename
+// order, so the result does not depend on the order of pkg.Files:
+//
+// Package comments are concatenated into a single comment group,
+// separated by empty // comments. A package comment that repeats
+// the text of an earlier one is dropped.
+//
+// All imports are collected into a single import declaration at the
+// beginning of the file. Duplicate imports (the same package imported
+// under the same name) are dropped; the import declarations of the
+// individual files are not part of the result. The other declarations
+// follow in file order.
+//
+// The comment lists of the files are appended to each other. Comment
+// positions continue to refer to the original files.
 //
 func MergePackageFiles(pkg *Package) *File {
-	// Count the number of package comments and declarations across
-	// all package files.
-	ncomments := 0;
-	ndecls := 0;
-	for _, f := range pkg.Files {
-		if f.Doc != nil {
-			ncomments += len(f.Doc.List) + 1	// +1 for separator
-		}
-		ndecls += len(f.Decls);
-	}
+	files := sortedFiles(pkg);
 
 	// Collect package comments from all package files into a single
-	// CommentGroup - the collected package documentation. The order
-	// is unspecified. In general there should be only one file with
-	// a package comment; but it's better to collect extra comments
-	// than drop them on the floor.
+	// CommentGroup - the collected package documentation. In general
+	// there should be only one file with a package comment; but it's
+	// better to collect extra comments than drop them on the floor.
 	var doc *CommentGroup;
-	if ncomments > 0 {
-		list := make([]*Comment, ncomments-1);	// -1: no separator before first group
-		i := 0;
-		for _, f := range pkg.Files {
-			if f.Doc != nil {
-				if i > 0 {
-					// not the first group - add separator
-					list[i] = separator;
-					i++;
-				}
-				for _, c := range f.Doc.List {
-					list[i] = c;
-					i++;
+	var group vector.Vector;
+	seen := make(map[string]bool);
+	for _, f := range files {
+		if f.Doc == nil {
+			continue
+		}
+		text := commentText(f.Doc);
+		if seen[text] {
+			continue
+		}
+		seen[text] = true;
+		if group.Len() > 0 {
+			// not the first group - add separator
+			group.Push(separator)
+		}
+		for _, c := range f.Doc.List {
+			group.Push(c)
+		}
+	}
+	if group.Len() > 0 {
+		comments := make([]*Comment, group.Len());
+		for i := range comments {
+			comments[i] = group.At(i).(*Comment)
+		}
+		doc = &CommentGroup{comments, nil};
+	}
+
+	// Collect imports and the remaining declarations from all
+	// package files.
+	var imports GenDecl;
+	var specs, decls vector.Vector;
+	seen = make(map[string]bool);
+	for _, f := range files {
+		for _, d := range f.Decls {
+			g, ok := d.(*GenDecl);
+			if !ok || g.Tok != token.IMPORT {
+				decls.Push(d);
+				continue;
+			}
+			if specs.Len() == 0 {
+				imports.Position = g.Pos()
+			}
+			for _, s := range g.Specs {
+				key := importKey(s.(*ImportSpec));
+				if !seen[key] {
+					seen[key] = true;
+					specs.Push(s);
 				}
 			}
 		}
-		doc = &CommentGroup{list, nil};
 	}
 
-	// Collect declarations from all package files.
-	var decls []Decl;
-	if ndecls > 0 {
-		decls = make([]Decl, ndecls);
-		i := 0;
-		for _, f := range pkg.Files {
-			for _, d := range f.Decls {
-				decls[i] = d;
-				i++;
+	// The import declaration (if any) comes first.
+	var list []Decl;
+	n := 0;
+	if specs.Len() > 0 {
+		n = 1
+	}
+	if n+decls.Len() > 0 {
+		list = make([]Decl, n+decls.Len())
+	}
+	if n > 0 {
+		imports.Tok = token.IMPORT;
+		imports.Specs = make([]Spec, specs.Len());
+		for i := range imports.Specs {
+			imports.Specs[i] = specs.At(i).(Spec)
+		}
+		if len(imports.Specs) > 1 {
+			// more than one import - use a parenthesized declaration
+			imports.Lparen = imports.Pos();
+			imports.Rparen = imports.Pos();
+		}
+		list[0] = &imports;
+	}
+	for i := 0; i < decls.Len(); i++ {
+		list[n+i] = decls.At(i).(Decl)
+	}
+
+	// Chain copies of the comment groups of all files so that the
+	// comment lists of the original files remain unchanged.
+	var comments, last *CommentGroup;
+	for _, f := range files {
+		for c := f.Comments; c != nil; c = c.Next {
+			g := &CommentGroup{c.List, nil};
+			if last == nil {
+				comments = g
+			} else {
+				last.Next = g
 			}
+			last = g;
 		}
 	}
 
-	// TODO(gri) Should collect comments as well. For that the comment
-	//           list should be changed back into a []*CommentGroup,
-	//           otherwise need to modify the existing linked list.
-	return &File{doc, noPos, &Ident{noPos, pkg.Name}, decls, nil};
+	return &File{doc, noPos, &Ident{noPos, pkg.Name}, list, comments};
 }
+
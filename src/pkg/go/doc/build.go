@@ -0,0 +1,151 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package doc
+
+import (
+	"go/ast";
+	"sort";
+	"strings";
+)
+
+
+// ----------------------------------------------------------------------------
+// Build constraints
+
+// A file applies to some systems only if its name ends in _$GOOS.go,
+// _$GOARCH.go, or _$GOOS_$GOARCH.go, as in fd_linux.go, or if one of
+// the comments before its package clause has a line of the form
+//
+//	// +build linux darwin
+//
+// The words following +build name the systems, architectures, or other
+// conditions the file is meant for.
+
+var knownOS = map[string]bool{
+	"cygwin": true,
+	"darwin": true,
+	"freebsd": true,
+	"linux": true,
+	"mingw": true,
+	"nacl": true,
+	"windows": true,
+}
+
+
+var knownArch = map[string]bool{
+	"386": true,
+	"amd64": true,
+	"arm": true,
+}
+
+
+const buildPrefix = "+build"
+
+
+// FileDoc describes a source file of a package.
+//
+type FileDoc struct {
+	Name	string;		// file name, without directory
+	Build	[]string;	// build constraints; nil if the file always applies
+}
+
+
+// nameConstraints returns the operating system and architecture
+// implied by the name of the file filename, if any.
+//
+func nameConstraints(filename string) []string {
+	name := filename;
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1 : len(name)]
+	}
+	if !strings.HasSuffix(name, ".go") {
+		return nil
+	}
+	name = name[0 : len(name)-len(".go")];
+	if strings.HasSuffix(name, "_test") {
+		name = name[0 : len(name)-len("_test")]
+	}
+	parts := strings.Split(name, "_", 0);
+	n := len(parts);
+	switch {
+	case n >= 3 && knownOS[parts[n-2]] && knownArch[parts[n-1]]:
+		return []string{parts[n-2], parts[n-1]}
+	case n >= 2 && (knownOS[parts[n-1]] || knownArch[parts[n-1]]):
+		return []string{parts[n-1]}
+	}
+	return nil;
+}
+
+
+// commentConstraints returns the build constraints of the +build lines
+// in the comments of file that precede its package clause.
+//
+func commentConstraints(file *ast.File) []string {
+	var list []string;
+	for g := file.Comments; g != nil; g = g.Next {
+		if len(g.List) == 0 || g.List[0].Offset >= file.Offset {
+			break
+		}
+		for _, c := range g.List {
+			for _, line := range strings.Split(string(c.Text), "\n", 0) {
+				line = strings.TrimSpace(line);
+				if strings.HasPrefix(line, "//") {
+					line = strings.TrimSpace(line[2:len(line)])
+				}
+				if strings.HasPrefix(line, buildPrefix) {
+					if cond := strings.TrimSpace(line[len(buildPrefix):len(line)]); cond != "" {
+						list = appendString(list, cond)
+					}
+				}
+			}
+		}
+	}
+	return list;
+}
+
+
+func appendString(list []string, s string) []string {
+	n := len(list);
+	l := make([]string, n+1);
+	copy(l, list);
+	l[n] = s;
+	return l;
+}
+
+
+// newFileDoc returns the description of the file filename, parsed as file.
+//
+func newFileDoc(filename string, file *ast.File) *FileDoc {
+	name := filename;
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1 : len(name)]
+	}
+	build := nameConstraints(name);
+	for _, cond := range commentConstraints(file) {
+		build = appendString(build, cond)
+	}
+	return &FileDoc{name, build};
+}
+
+
+type fileDocs []*FileDoc
+
+func (p fileDocs) Len() int		{ return len(p) }
+func (p fileDocs) Less(i, j int) bool	{ return p[i].Name < p[j].Name }
+func (p fileDocs) Swap(i, j int)	{ p[i], p[j] = p[j], p[i] }
+
+
+// makeFileDocs returns the sorted descriptions of the files of pkg.
+//
+func makeFileDocs(pkg *ast.Package) []*FileDoc {
+	list := make(fileDocs, len(pkg.Files));
+	i := 0;
+	for filename, file := range pkg.Files {
+		list[i] = newFileDoc(filename, file);
+		i++;
+	}
+	sort.Sort(list);
+	return list;
+}
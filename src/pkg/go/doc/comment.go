@@ -200,6 +200,21 @@ func unindent(block [][]byte) {
 }
 
 
+// join concatenates the lines of a block.
+func join(block [][]byte) []byte {
+	n := 0;
+	for _, line := range block {
+		n += len(line)
+	}
+	s := make([]byte, n);
+	n = 0;
+	for _, line := range block {
+		n += copy(s[n:len(s)], line)
+	}
+	return s;
+}
+
+
 // Convert comment text to formatted HTML.
 // The comment was prepared by DocReader,
 // so it is known not to have leading, trailing blank lines
@@ -211,7 +226,19 @@ func unindent(block [][]byte) {
 //
 // TODO(rsc): I'd like to pass in an array of variable names []string
 // and then italicize those strings when they appear as words.
-func ToHTML(w io.Writer, s []byte) {
+func ToHTML(w io.Writer, s []byte)	{ ToHTMLCode(w, s, nil) }
+
+
+// A CodeFormatter writes the code of an indented block of a comment,
+// without indent, as HTML to w. It reports whether it succeeded; if it
+// did not, it must not have written anything.
+type CodeFormatter func(w io.Writer, code []byte) bool
+
+
+// ToHTMLCode is like ToHTML, but the lines of each indented block are
+// formatted by code, if code is not nil. Blocks that code fails to
+// format are html-escaped as by ToHTML.
+func ToHTMLCode(w io.Writer, s []byte, code CodeFormatter) {
 	inpara := false;
 
 	close := func() {
@@ -257,10 +284,12 @@ func ToHTML(w io.Writer, s []byte) {
 
 			// put those lines in a pre block.
 			// they don't get the nice text formatting,
-			// just html escaping
+			// just html escaping, unless code formats them
 			w.Write(html_pre);
-			for _, line := range block {
-				template.HTMLEscape(w, line)
+			if code == nil || !code(w, join(block)) {
+				for _, line := range block {
+					template.HTMLEscape(w, line)
+				}
 			}
 			w.Write(html_endpre);
 			continue;
@@ -41,15 +41,19 @@ type docReader struct {
 	types	map[string]*typeDoc;
 	funcs	map[string]*ast.FuncDecl;
 	bugs	*vector.Vector;	// list of *ast.CommentGroup
+	notes	*vector.Vector;	// list of *Note
+	mode	Mode;
 }
 
 
-func (doc *docReader) init(pkgName string) {
+func (doc *docReader) init(pkgName string, mode Mode) {
 	doc.pkgName = pkgName;
+	doc.mode = mode;
 	doc.values = vector.New(0);
 	doc.types = make(map[string]*typeDoc);
 	doc.funcs = make(map[string]*ast.FuncDecl);
 	doc.bugs = vector.New(0);
+	doc.notes = vector.New(0);
 }
 
 
@@ -80,21 +84,29 @@ func (doc *docReader) lookupTypeDoc(name string) *typeDoc {
 }
 
 
-func baseTypeName(typ ast.Expr) string {
+// baseTypeName returns the name of the base type of typ, or "" if
+// there is none; unless allTypes is set, unexported names are dropped.
+func baseTypeName(typ ast.Expr, allTypes bool) string {
 	switch t := typ.(type) {
 	case *ast.Ident:
 		// if the type is not exported, the effect to
 		// a client is as if there were no type name
-		if t.IsExported() {
+		if allTypes || t.IsExported() {
 			return string(t.Value)
 		}
 	case *ast.StarExpr:
-		return baseTypeName(t.X)
+		return baseTypeName(t.X, allTypes)
 	}
 	return "";
 }
 
 
+// typeName returns the base type name of typ as seen by doc.
+func (doc *docReader) typeName(typ ast.Expr) string {
+	return baseTypeName(typ, doc.mode&AllDecls != 0)
+}
+
+
 func (doc *docReader) addValue(decl *ast.GenDecl) {
 	// determine if decl should be associated with a type
 	// Heuristic: For each typed entry, determine the type name, if any.
@@ -109,7 +121,7 @@ func (doc *docReader) addValue(decl *ast.GenDecl) {
 			switch {
 			case v.Type != nil:
 				// a type is present; determine it's name
-				name = baseTypeName(v.Type)
+				name = doc.typeName(v.Type)
 			case decl.Tok == token.CONST:
 				// no type is present but we have a constant declaration;
 				// use the previous type name (w/o more type information
@@ -153,9 +165,9 @@ func (doc *docReader) addFunc(fun *ast.FuncDecl) {
 	// determine if it should be associated with a type
 	if fun.Recv != nil {
 		// method
-		typ := doc.lookupTypeDoc(baseTypeName(fun.Recv.Type));
+		typ := doc.lookupTypeDoc(doc.typeName(fun.Recv.Type));
 		if typ != nil {
-			// exported receiver type
+			// exported receiver type, or any with AllDecls
 			typ.methods[name] = fun
 		}
 		// otherwise don't show the method
@@ -174,7 +186,7 @@ func (doc *docReader) addFunc(fun *ast.FuncDecl) {
 			// exactly one (named or anonymous) result associated
 			// with the first type in result signature (there may
 			// be more than one result)
-			tname := baseTypeName(res.Type);
+			tname := doc.typeName(res.Type);
 			typ := doc.lookupTypeDoc(tname);
 			if typ != nil {
 				// named and exported result type
@@ -257,11 +269,8 @@ var (
 func (doc *docReader) addFile(src *ast.File) {
 	// add package documentation
 	if src.Doc != nil {
-		// TODO(gri) This won't do the right thing if there is more
-		//           than one file with package comments. Consider
-		//           using ast.MergePackageFiles which handles these
-		//           comments correctly (but currently looses BUG(...)
-		//           comments).
+		// For packages, src is the result of ast.MergePackageFiles
+		// which collects the package comments of all files.
 		doc.doc = src.Doc;
 		src.Doc = nil;	// doc consumed - remove from ast.File node
 	}
@@ -271,8 +280,11 @@ func (doc *docReader) addFile(src *ast.File) {
 		doc.addDecl(decl)
 	}
 
-	// collect BUG(...) comments
+	// collect BUG(...) and TODO(...) comments
 	for c := src.Comments; c != nil; c = c.Next {
+		if n := NoteOf(c); n != nil {
+			doc.notes.Push(n)
+		}
 		text := c.List[0].Text;
 		cstr := string(text);
 		if m := bug_markers.ExecuteString(cstr); len(m) > 0 {
@@ -291,23 +303,48 @@ func (doc *docReader) addFile(src *ast.File) {
 
 func NewFileDoc(file *ast.File) *PackageDoc {
 	var r docReader;
-	r.init(file.Name.Value);
+	r.init(file.Name.Value, 0);
 	r.addFile(file);
 	return r.newDoc("", "", nil);
 }
 
 
+// A Mode controls the documentation extracted by NewPackageDocMode.
+type Mode uint
+
+const (
+	// AllDecls associates the methods, factory functions, and values
+	// of unexported types with their types; by default, the methods
+	// of unexported types are dropped and the rest is documented at
+	// the top level. It is meant for ASTs that keep the unexported
+	// declarations, unlike those filtered by ast.PackageExports.
+	AllDecls Mode = 1 << iota;
+)
+
+
 func NewPackageDoc(pkg *ast.Package, importpath string) *PackageDoc {
+	return NewPackageDocMode(pkg, importpath, 0)
+}
+
+
+// NewPackageDocMode is like NewPackageDoc but extracts the
+// documentation as controlled by mode.
+func NewPackageDocMode(pkg *ast.Package, importpath string, mode Mode) *PackageDoc {
 	var r docReader;
-	r.init(pkg.Name);
+	r.init(pkg.Name, mode);
+	// the build constraints are read from the comments of the
+	// individual files, before they are merged
+	files := makeFileDocs(pkg);
+	r.addFile(ast.MergePackageFiles(pkg));
 	filenames := make([]string, len(pkg.Files));
 	i := 0;
-	for filename, f := range pkg.Files {
-		r.addFile(f);
+	for filename, _ := range pkg.Files {
 		filenames[i] = filename;
 		i++;
 	}
-	return r.newDoc(importpath, pkg.Path, filenames);
+	p := r.newDoc(importpath, pkg.Path, filenames);
+	p.Files = files;
+	return p;
 }
 
 
@@ -319,6 +356,7 @@ func NewPackageDoc(pkg *ast.Package, importpath string) *PackageDoc {
 //
 type ValueDoc struct {
 	Doc	string;
+	Since	string;	// version of the "Since:" line, if any
 	Decl	*ast.GenDecl;
 	order	int;
 }
@@ -362,7 +400,8 @@ func makeValueDocs(v *vector.Vector, tok token.Token) []*ValueDoc {
 	for i := range d {
 		decl := v.At(i).(*ast.GenDecl);
 		if decl.Tok == tok {
-			d[n] = &ValueDoc{CommentText(decl.Doc), decl, i};
+			text, since := splitSince(CommentText(decl.Doc));
+			d[n] = &ValueDoc{text, since, decl, i};
 			n++;
 			decl.Doc = nil;	// doc consumed - removed from AST
 		}
@@ -377,10 +416,12 @@ func makeValueDocs(v *vector.Vector, tok token.Token) []*ValueDoc {
 // either a top-level function or a method function.
 //
 type FuncDoc struct {
-	Doc	string;
-	Recv	ast.Expr;	// TODO(rsc): Would like string here
-	Name	string;
-	Decl	*ast.FuncDecl;
+	Doc		string;
+	Since		string;		// version of the "Since:" line, if any
+	Recv		ast.Expr;	// TODO(rsc): Would like string here
+	Name		string;
+	Decl		*ast.FuncDecl;
+	Examples	[]*Example;	// see AddExamples
 }
 
 type sortFuncDoc []*FuncDoc
@@ -395,7 +436,7 @@ func makeFuncDocs(m map[string]*ast.FuncDecl) []*FuncDoc {
 	i := 0;
 	for _, f := range m {
 		doc := new(FuncDoc);
-		doc.Doc = CommentText(f.Doc);
+		doc.Doc, doc.Since = splitSince(CommentText(f.Doc));
 		f.Doc = nil;	// doc consumed - remove from ast.FuncDecl node
 		if f.Recv != nil {
 			doc.Recv = f.Recv.Type
@@ -416,12 +457,14 @@ func makeFuncDocs(m map[string]*ast.FuncDecl) []*FuncDoc {
 // Methods is a sorted list of method functions on that type.
 type TypeDoc struct {
 	Doc		string;
+	Since		string;		// version of the "Since:" line, if any
 	Type		*ast.TypeSpec;
 	Consts		[]*ValueDoc;
 	Vars		[]*ValueDoc;
 	Factories	[]*FuncDoc;
 	Methods		[]*FuncDoc;
 	Decl		*ast.GenDecl;
+	Examples	[]*Example;	// see AddExamples
 	order		int;
 }
 
@@ -460,7 +503,7 @@ func (doc *docReader) makeTypeDocs(m map[string]*typeDoc) []*TypeDoc {
 				doc = decl.Doc
 			}
 			decl.Doc = nil;	// doc consumed - remove from ast.Decl node
-			t.Doc = CommentText(doc);
+			t.Doc, t.Since = splitSince(CommentText(doc));
 			t.Type = typespec;
 			t.Consts = makeValueDocs(old.values, token.CONST);
 			t.Vars = makeValueDocs(old.values, token.VAR);
@@ -497,6 +540,15 @@ func (doc *docReader) makeTypeDocs(m map[string]*typeDoc) []*TypeDoc {
 }
 
 
+func makeNotes(v *vector.Vector) []*Note {
+	d := make([]*Note, v.Len());
+	for i := 0; i < v.Len(); i++ {
+		d[i] = v.At(i).(*Note)
+	}
+	return d;
+}
+
+
 func makeBugDocs(v *vector.Vector) []string {
 	d := make([]string, v.Len());
 	for i := 0; i < v.Len(); i++ {
@@ -513,12 +565,15 @@ type PackageDoc struct {
 	ImportPath	string;
 	FilePath	string;
 	Filenames	[]string;
+	Files		[]*FileDoc;	// files with their build constraints; see build.go
 	Doc		string;
 	Consts		[]*ValueDoc;
 	Types		[]*TypeDoc;
 	Vars		[]*ValueDoc;
 	Funcs		[]*FuncDoc;
 	Bugs		[]string;
+	Notes		[]*Note;	// BUG and TODO notes; see notes.go
+	Examples	[]*Example;	// see AddExamples
 }
 
 
@@ -540,6 +595,7 @@ func (doc *docReader) newDoc(importpath, filepath string, filenames []string) *P
 	p.Vars = makeValueDocs(doc.values, token.VAR);
 	p.Funcs = makeFuncDocs(doc.funcs);
 	p.Bugs = makeBugDocs(doc.bugs);
+	p.Notes = makeNotes(doc.notes);
 	return p;
 }
 
@@ -0,0 +1,165 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package doc
+
+import (
+	"go/ast";
+	"sort";
+	"strings";
+	"unicode";
+	"utf8";
+)
+
+
+// ----------------------------------------------------------------------------
+// Examples
+
+// An Example is a function of a test file that illustrates the use
+// of a package, function, type, or method. Its name determines what
+// it documents:
+//
+//	func Example()		// the package
+//	func ExampleF()		// the function F
+//	func ExampleT()		// the type T
+//	func ExampleT_M()	// the method M of type T
+//
+// A suffix starting with a lower-case letter may follow, to tell apart
+// several examples of the same declaration: ExampleF_second.
+//
+type Example struct {
+	Name	string;		// name of the example function
+	Doc	string;		// doc comment of the example function
+	Body	*ast.BlockStmt;	// body of the example function
+}
+
+type sortExample []*Example
+
+func (p sortExample) Len() int			{ return len(p) }
+func (p sortExample) Swap(i, j int)		{ p[i], p[j] = p[j], p[i] }
+func (p sortExample) Less(i, j int) bool	{ return p[i].Name < p[j].Name }
+
+
+func isLower(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s);
+	return unicode.IsLower(r);
+}
+
+
+// exampleTarget splits the name of an example function into the names
+// of the type or function and of the method it documents. The result
+// is ok if name is the name of an example function.
+func exampleTarget(name string) (decl, method string, ok bool) {
+	const prefix = "Example";
+	if !strings.HasPrefix(name, prefix) {
+		return "", "", false
+	}
+	name = name[len(prefix):len(name)];
+	if name != "" && name[0] != '_' && isLower(name) {
+		return "", "", false	// e.g. Examples
+	}
+
+	// remove a lower-case suffix
+	if i := strings.LastIndex(name, "_"); i >= 0 && isLower(name[i+1:len(name)]) {
+		name = name[0:i]
+	}
+	if i := strings.Index(name, "_"); i >= 0 {
+		return name[0:i], name[i+1 : len(name)], true
+	}
+	return name, "", true;
+}
+
+
+func appendExample(list []*Example, e *Example) []*Example {
+	n := len(list);
+	if n == cap(list) {
+		l := make([]*Example, n, 2*n+1);
+		copy(l, list);
+		list = l;
+	}
+	list = list[0 : n+1];
+	list[n] = e;
+	return list;
+}
+
+
+func findFunc(list []*FuncDoc, name string) *FuncDoc {
+	for _, f := range list {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil;
+}
+
+
+// addExample attaches e to the documentation of the declaration it
+// illustrates, if p has such a declaration.
+func (p *PackageDoc) addExample(e *Example) {
+	decl, method, _ := exampleTarget(e.Name);
+	if decl == "" {
+		p.Examples = appendExample(p.Examples, e);
+		return;
+	}
+	if method == "" {
+		if f := findFunc(p.Funcs, decl); f != nil {
+			f.Examples = appendExample(f.Examples, e);
+			return;
+		}
+	}
+	for _, t := range p.Types {
+		if method == "" {
+			if t.Type.Name.Value == decl {
+				t.Examples = appendExample(t.Examples, e);
+				return;
+			}
+			if f := findFunc(t.Factories, decl); f != nil {
+				f.Examples = appendExample(f.Examples, e);
+				return;
+			}
+		} else if t.Type.Name.Value == decl {
+			if f := findFunc(t.Methods, method); f != nil {
+				f.Examples = appendExample(f.Examples, e);
+				return;
+			}
+		}
+	}
+}
+
+
+func sortExamples(list []*FuncDoc) {
+	for _, f := range list {
+		sort.Sort(sortExample(f.Examples))
+	}
+}
+
+
+// AddExamples attaches the example functions declared in files, the
+// test files of the package, to the documentation of the declarations
+// they illustrate. The test files may belong to the package itself or
+// to a separate test package. Example functions must not have
+// parameters, results, or a receiver; those illustrating declarations
+// not in p, such as filtered or unexported ones, are ignored.
+//
+func (p *PackageDoc) AddExamples(files []*ast.File) {
+	for _, file := range files {
+		for _, d := range file.Decls {
+			f, ok := d.(*ast.FuncDecl);
+			if !ok || f.Recv != nil || f.Body == nil || len(f.Type.Params) > 0 || len(f.Type.Results) > 0 {
+				continue
+			}
+			if _, _, ok := exampleTarget(f.Name.Value); ok {
+				p.addExample(&Example{f.Name.Value, CommentText(f.Doc), f.Body})
+			}
+		}
+	}
+
+	sort.Sort(sortExample(p.Examples));
+	sortExamples(p.Funcs);
+	for _, t := range p.Types {
+		sort.Sort(sortExample(t.Examples));
+		sortExamples(t.Factories);
+		sortExamples(t.Methods);
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package doc
+
+import (
+	"go/ast";
+	"go/token";
+)
+
+
+// ----------------------------------------------------------------------------
+// Documentation by name
+
+// nameDocs collects the documentation of exported names.
+type nameDocs map[string]string
+
+
+// add records the text of doc, or of comment if doc is nil, as the
+// documentation of name, if name is exported and the text is not empty.
+func (m nameDocs) add(name string, doc, comment *ast.CommentGroup) {
+	if !ast.IsExported(name) {
+		return
+	}
+	if doc == nil {
+		doc = comment
+	}
+	if doc != nil {
+		if text := CommentText(doc); text != "" {
+			m[name] = text
+		}
+	}
+}
+
+
+// addFields records the documentation of the exported fields
+// or methods of the type typeName.
+func (m nameDocs) addFields(typeName string, list []*ast.Field) {
+	for _, f := range list {
+		for _, name := range f.Names {
+			if name.IsExported() {
+				m.add(typeName+"."+name.Value, f.Doc, f.Comment)
+			}
+		}
+	}
+}
+
+
+// specDoc returns the doc comment of a spec of the declaration d;
+// the documentation of a declaration group applies to each of its
+// specs without a doc comment of their own.
+func specDoc(doc *ast.CommentGroup, d *ast.GenDecl) *ast.CommentGroup {
+	if doc == nil {
+		doc = d.Doc
+	}
+	return doc;
+}
+
+
+func (m nameDocs) addGenDecl(d *ast.GenDecl) {
+	for _, s := range d.Specs {
+		switch s := s.(type) {
+		case *ast.ValueSpec:
+			for _, name := range s.Names {
+				m.add(name.Value, specDoc(s.Doc, d), s.Comment)
+			}
+		case *ast.TypeSpec:
+			name := s.Name.Value;
+			m.add(name, specDoc(s.Doc, d), s.Comment);
+			if !ast.IsExported(name) {
+				break
+			}
+			switch t := s.Type.(type) {
+			case *ast.StructType:
+				m.addFields(name, t.Fields)
+			case *ast.InterfaceType:
+				m.addFields(name, t.Methods)
+			}
+		}
+	}
+}
+
+
+func (m nameDocs) addFuncDecl(d *ast.FuncDecl) {
+	name := d.Name.Value;
+	if d.Recv != nil {
+		// method; only documented if the receiver type is exported
+		recv := baseTypeName(d.Recv.Type, false);
+		if recv == "" || !ast.IsExported(name) {
+			return
+		}
+		name = recv + "." + name;
+	}
+	m.add(name, d.Doc, nil);
+}
+
+
+// Docs returns the documentation of the exported top-level names
+// declared in file, without modifying the AST.  Types, functions,
+// constants, and variables are keyed by their name; methods and the
+// fields of struct types and methods of interface types by the name
+// of the type, a period, and their name (e.g., "Buffer.Len").
+//
+// The documentation of a name is its doc comment or, for the specs
+// of a declaration group without one of their own, the doc comment
+// of the group; if there is none, the line comment of the spec or
+// field is used.  Names without documentation are not in the map.
+//
+func Docs(file *ast.File) map[string]string {
+	m := make(nameDocs);
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.IMPORT {
+				m.addGenDecl(d)
+			}
+		case *ast.FuncDecl:
+			m.addFuncDecl(d)
+		}
+	}
+	return m;
+}
@@ -0,0 +1,53 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package doc
+
+import (
+	"go/ast";
+	"regexp";
+)
+
+
+// ----------------------------------------------------------------------------
+// Notes
+
+// A comment group starting with a marker such as
+//
+//	// BUG(uid): text
+//	// TODO(uid): text
+//
+// is a note: a known bug or a planned change, attributed to the user
+// uid. Like the BUG comments collected in Bugs, notes may appear
+// anywhere in the source, including function bodies.
+
+var note_markers = regexp.MustCompile("^/[/*][ \t]*(BUG|TODO)\\(([^)]*)\\):[ \t]*")	// BUG(uid): or TODO(uid):
+
+
+// A Note is a BUG or TODO comment.
+//
+type Note struct {
+	Marker	string;	// "BUG" or "TODO"
+	UID	string;	// user named in the marker; may be empty
+	Body	string;	// comment text without the marker
+}
+
+
+// NoteOf returns the note of the comment group c,
+// or nil if c is not a note or has no text.
+//
+func NoteOf(c *ast.CommentGroup) *Note {
+	if c == nil || len(c.List) == 0 {
+		return nil
+	}
+	text := c.List[0].Text;
+	cstr := string(text);
+	m := note_markers.ExecuteString(cstr);
+	if len(m) == 0 || !bug_content.MatchString(cstr[m[1]:len(cstr)]) {
+		return nil
+	}
+	list := copyCommentList(c.List);
+	list[0] = &ast.Comment{list[0].Position, text[m[1]:len(text)]};
+	return &Note{cstr[m[2]:m[3]], cstr[m[4]:m[5]], CommentText(&ast.CommentGroup{list, nil})};
+}
@@ -0,0 +1,181 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package doc
+
+import "strings"
+
+
+// ----------------------------------------------------------------------------
+// Availability
+
+// A line of the form
+//
+//	Since: r60
+//
+// in the doc comment of a declaration records the release in which the
+// declaration first appeared. The line is removed from the Doc text of
+// the ValueDoc, FuncDoc, or TypeDoc and its version is stored in the
+// Since field instead.
+
+const sincePrefix = "Since:"
+
+
+// splitSince returns the comment text without its "Since:" line,
+// and the version named in that line, or "" if there is none.
+//
+func splitSince(text string) (doc, since string) {
+	lines := strings.Split(text, "\n", 0);
+	n := 0;
+	for _, line := range lines {
+		if s := strings.TrimSpace(line); since == "" && strings.HasPrefix(s, sincePrefix) {
+			if v := strings.TrimSpace(s[len(sincePrefix):len(s)]); v != "" && strings.Index(v, " ") < 0 {
+				since = v;
+				continue;
+			}
+		}
+		lines[n] = line;
+		n++;
+	}
+	if since == "" {
+		return text, ""
+	}
+	// remove trailing blank lines left behind
+	for n > 0 && lines[n-1] == "" {
+		n--
+	}
+	if n == 0 {
+		return "", since
+	}
+	return strings.Join(lines[0:n], "\n") + "\n", since;
+}
+
+
+// isDigit reports whether ch is an ASCII digit.
+func isDigit(ch byte) bool	{ return '0' <= ch && ch <= '9' }
+
+
+// trimZeros returns the number s without leading zeros.
+func trimZeros(s string) string {
+	i := 0;
+	for i < len(s) && s[i] == '0' {
+		i++
+	}
+	return s[i:len(s)];
+}
+
+
+// CompareVersions compares the release names a and b, such as "r56" and
+// "r60.1", and returns -1, 0, or +1 if a is older than, the same as, or
+// newer than b. Runs of digits are compared as numbers and all other
+// characters as bytes, so that "r9" is older than "r10".
+//
+func CompareVersions(a, b string) int {
+	i, j := 0, 0;
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			// compare the numbers, ignoring leading zeros
+			i0, j0 := i, j;
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			x, y := trimZeros(a[i0:i]), trimZeros(b[j0:j]);
+			switch {
+			case len(x) < len(y):
+				return -1
+			case len(x) > len(y):
+				return +1
+			case x < y:
+				return -1
+			case x > y:
+				return +1
+			}
+			continue;
+		}
+		switch {
+		case a[i] < b[j]:
+			return -1
+		case a[i] > b[j]:
+			return +1
+		}
+		i++;
+		j++;
+	}
+	switch {
+	case i < len(a):
+		return +1
+	case j < len(b):
+		return -1
+	}
+	return 0;
+}
+
+
+// isSince reports whether a declaration available since version since
+// appeared in version min or later. Declarations without a version
+// are taken to be older than all versions.
+//
+func isSince(since, min string) bool {
+	return since != "" && CompareVersions(since, min) >= 0
+}
+
+
+func filterValueDocsSince(a []*ValueDoc, min string) []*ValueDoc {
+	w := 0;
+	for _, vd := range a {
+		if isSince(vd.Since, min) {
+			a[w] = vd;
+			w++;
+		}
+	}
+	return a[0:w];
+}
+
+
+func filterFuncDocsSince(a []*FuncDoc, min string) []*FuncDoc {
+	w := 0;
+	for _, fd := range a {
+		if isSince(fd.Since, min) {
+			a[w] = fd;
+			w++;
+		}
+	}
+	return a[0:w];
+}
+
+
+func filterTypeDocsSince(a []*TypeDoc, min string) []*TypeDoc {
+	w := 0;
+	for _, td := range a {
+		if !isSince(td.Since, min) {
+			// the type is older, but it may have newer members
+			td.Consts = filterValueDocsSince(td.Consts, min);
+			td.Vars = filterValueDocsSince(td.Vars, min);
+			td.Factories = filterFuncDocsSince(td.Factories, min);
+			td.Methods = filterFuncDocsSince(td.Methods, min);
+			if len(td.Consts) == 0 && len(td.Vars) == 0 && len(td.Factories) == 0 && len(td.Methods) == 0 {
+				continue
+			}
+		}
+		a[w] = td;
+		w++;
+	}
+	return a[0:w];
+}
+
+
+// FilterSince eliminates the declarations from p that appeared before
+// version min, as recorded by their "Since:" lines, so that only the
+// API added in version min or later remains. A type that appeared
+// earlier is kept if some of its members are newer.
+//
+func (p *PackageDoc) FilterSince(min string) {
+	p.Consts = filterValueDocsSince(p.Consts, min);
+	p.Vars = filterValueDocsSince(p.Vars, min);
+	p.Types = filterTypeDocsSince(p.Types, min);
+	p.Funcs = filterFuncDocsSince(p.Funcs, min);
+}
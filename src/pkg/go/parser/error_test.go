@@ -0,0 +1,108 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package parser
+
+import (
+	"bytes";
+	"go/scanner";
+	"io";
+	"strings";
+	"testing";
+)
+
+
+const testdata = "testdata/"
+
+
+// Files with syntax errors. Each line containing an errorMarker
+// must report an error; no other line may report one. The files
+// check that the parser resynchronizes after an error rather than
+// reporting follow-on errors.
+var errorFiles = []string{
+	"recover.src",
+}
+
+
+var errorMarker = []byte("/* ERROR */")
+
+
+// errorLines returns the set of lines in src containing an errorMarker.
+func errorLines(src []byte) map[int]bool {
+	lines := make(map[int]bool);
+	for i, line := range bytes.Split(src, []byte{'\n'}, 0) {
+		if bytes.Index(line, errorMarker) >= 0 {
+			lines[i+1] = true
+		}
+	}
+	return lines;
+}
+
+
+func TestErrorRecovery(t *testing.T) {
+	for _, filename := range errorFiles {
+		filename = testdata + filename;
+		src, err := io.ReadFile(filename);
+		if err != nil {
+			t.Errorf("%s: %v", filename, err);
+			continue;
+		}
+
+		expected := errorLines(src);
+		_, err = ParseFile(filename, src, 0);
+		list, ok := err.(scanner.ErrorList);
+		if !ok {
+			t.Errorf("%s: expected error list, got %v", filename, err);
+			continue;
+		}
+
+		for _, e := range list {
+			if !expected[e.Pos.Line] {
+				t.Errorf("%s: unexpected error: %v", filename, e);
+				continue;
+			}
+			expected[e.Pos.Line] = false, false;
+		}
+		for line, _ := range expected {
+			t.Errorf("%s:%d: expected error not reported", filename, line)
+		}
+	}
+}
+
+
+type suggestionTest struct {
+	src, hint string;
+}
+
+
+var suggestionTests = []suggestionTest{
+	suggestionTest{`package p; fucn f() {}`, `(did you mean "func"?)`},
+	suggestionTest{`package p; func f() int { retrun 0 }`, `(did you mean "return" for "retrun"?)`},
+	suggestionTest{`package p; func f() { Var x int }`, `(did you mean "var" for "Var"?)`},
+	suggestionTest{`package p; type Buffer int; func f() { Bufer x }`, `(did you mean "Buffer" for "Bufer"?)`},
+	suggestionTest{`package p; func f() { count := 0; cuont x }`, `(did you mean "count" for "cuont"?)`},
+	suggestionTest{`package p; func f() { x y }`, ""},
+}
+
+
+func TestSuggestions(t *testing.T) {
+	for _, test := range suggestionTests {
+		_, err := ParseFile("", test.src, 0);
+		list, ok := err.(scanner.ErrorList);
+		if !ok || len(list) == 0 {
+			t.Errorf("%s: expected error list, got %v", test.src, err);
+			continue;
+		}
+		msg := list[0].Msg;
+		if test.hint == "" {
+			if strings.Index(msg, "did you mean") >= 0 {
+				t.Errorf("%s: unexpected suggestion: %s", test.src, msg)
+			}
+			continue;
+		}
+		if !strings.HasSuffix(msg, test.hint) {
+			t.Errorf("%s: got %q; want suggestion %s", test.src, msg, test.hint)
+		}
+	}
+}
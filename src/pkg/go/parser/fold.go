@@ -0,0 +1,84 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the folding of adjacent string literals.
+
+package parser
+
+import (
+	"go/ast";
+	"go/token";
+	"os";
+	"strconv";
+	"strings";
+)
+
+
+// A StringLit is the canonical form of a string literal or of a
+// sequence of adjacent string literals (ast.StringList): a single
+// literal denoting the concatenated string, together with the source
+// span of the original literals.  The original nodes are not changed,
+// so that formatters such as go/printer can still reproduce the source.
+//
+type StringLit struct {
+	Lit	*ast.BasicLit;	// folded literal, positioned at the first literal
+	Value	string;		// the logical (unquoted) string
+	End	token.Position;	// position immediately after the last literal
+	Orig	ast.Expr;	// the original *ast.BasicLit or *ast.StringList
+}
+
+
+// endPos returns the position immediately after the literal x.
+func endPos(x *ast.BasicLit) token.Position {
+	pos := x.Pos();
+	pos.Offset += len(x.Value);
+	for _, ch := range string(x.Value) {
+		if ch == '\n' {
+			pos.Line++;
+			pos.Column = 1;
+		} else {
+			pos.Column++
+		}
+	}
+	return pos;
+}
+
+
+// FoldString folds the string literal or string list x into a single
+// literal. Import paths and field tags, which are represented as lists
+// of literals, may be folded by passing &ast.StringList{list}.  An error
+// is returned if x is not a string literal or if one of the literals
+// is malformed.
+//
+func FoldString(x ast.Expr) (*StringLit, os.Error) {
+	var list []*ast.BasicLit;
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		list = []*ast.BasicLit{x}
+	case *ast.StringList:
+		list = x.Strings
+	}
+	if len(list) == 0 {
+		return nil, os.ErrorString("not a string literal")
+	}
+
+	s := "";
+	for _, lit := range list {
+		if lit.Kind != token.STRING {
+			return nil, os.ErrorString("not a string literal")
+		}
+		v, err := strconv.Unquote(string(lit.Value));
+		if err != nil {
+			return nil, err
+		}
+		s += v;
+	}
+
+	first := list[0];
+	lit := first;
+	if len(list) > 1 {
+		lit = &ast.BasicLit{first.Pos(), token.STRING, strings.Bytes(strconv.Quote(s))}
+	}
+	return &StringLit{lit, s, endPos(list[len(list)-1]), x}, nil;
+}
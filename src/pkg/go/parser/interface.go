@@ -8,12 +8,15 @@ package parser
 
 import (
 	"bytes";
+	"container/vector";
 	"fmt";
 	"go/ast";
 	"go/scanner";
+	"go/token";
 	"io";
 	"os";
 	pathutil "path";
+	"sort";
 	"strings";
 )
 
@@ -63,7 +66,9 @@ func ParseExpr(filename string, src interface{}) (ast.Expr, os.Error) {
 
 	var p parser;
 	p.init(filename, data, 0);
-	return p.parseExpr(), p.GetError(scanner.Sorted);
+	var x ast.Expr;
+	p.run(func(p *parser) { x = p.parseExpr() });
+	return x, p.getError(scanner.Sorted);
 }
 
 
@@ -80,7 +85,9 @@ func ParseStmtList(filename string, src interface{}) ([]ast.Stmt, os.Error) {
 
 	var p parser;
 	p.init(filename, data, 0);
-	return p.parseStmtList(), p.GetError(scanner.Sorted);
+	var list []ast.Stmt;
+	p.run(func(p *parser) { list = p.parseStmtList() });
+	return list, p.getError(scanner.Sorted);
 }
 
 
@@ -97,7 +104,9 @@ func ParseDeclList(filename string, src interface{}) ([]ast.Decl, os.Error) {
 
 	var p parser;
 	p.init(filename, data, 0);
-	return p.parseDeclList(), p.GetError(scanner.Sorted);
+	var list []ast.Decl;
+	p.run(func(p *parser) { list = p.parseDeclList() });
+	return list, p.getError(scanner.Sorted);
 }
 
 
@@ -118,6 +127,8 @@ func ParseDeclList(filename string, src interface{}) ([]ast.Decl, os.Error) {
 // errors were found, the result is a partial AST (with ast.BadX nodes
 // representing the fragments of erroneous source code). Multiple errors
 // are returned via a scanner.ErrorList which is sorted by file position.
+// If there are more than MaxErrors errors, parsing stops early, the AST
+// is nil, and the error list ends with ErrTooManyErrors.
 //
 func ParseFile(filename string, src interface{}, mode uint) (*ast.File, os.Error) {
 	data, err := readSource(filename, src);
@@ -127,7 +138,113 @@ func ParseFile(filename string, src interface{}, mode uint) (*ast.File, os.Error
 
 	var p parser;
 	p.init(filename, data, mode);
-	return p.parseFile(), p.GetError(scanner.NoMultiples);
+	var file *ast.File;
+	p.run(func(p *parser) { file = p.parseFile() });
+	return file, p.getError(scanner.NoMultiples);
+}
+
+
+// ParseFiles parses the files specified by filenames concurrently,
+// with at most workers files being parsed at a time (at least one),
+// and returns the corresponding ASTs: the i'th AST is the result of
+// ParseFile(filenames[i], nil, mode).
+//
+// The errors of all files are merged into a single scanner.ErrorList
+// sorted by file position; a file that couldn't be read contributes an
+// error without line information. If parsing of any file stopped after
+// MaxErrors errors, the list ends with ErrTooManyErrors. If there are
+// no errors, the result error is nil.
+//
+func ParseFiles(filenames []string, mode uint, workers int) ([]*ast.File, os.Error) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(filenames) {
+		workers = len(filenames)
+	}
+
+	files := make([]*ast.File, len(filenames));
+	errors := make([]os.Error, len(filenames));
+	work := make(chan int, len(filenames));
+	for i := range filenames {
+		work <- i
+	}
+	close(work);
+	done := make(chan bool);
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range work {
+				files[i], errors[i] = ParseFile(filenames[i], nil, mode)
+			}
+			done <- true;
+		}()
+	}
+	for w := 0; w < workers; w++ {
+		<-done
+	}
+
+	// merge the errors
+	var list scanner.ErrorList;
+	tooMany := false;
+	for i, err := range errors {
+		if err == nil {
+			continue
+		}
+		elist, ok := err.(scanner.ErrorList);
+		if !ok {
+			elist = scanner.ErrorList{&scanner.Error{token.Position{Filename: filenames[i]}, err.String()}}
+		}
+		n := len(list);
+		l := make(scanner.ErrorList, n, n+len(elist));
+		copy(l, list);
+		for _, e := range elist {
+			if e == ErrTooManyErrors {
+				tooMany = true;
+				continue;
+			}
+			l = l[0 : len(l)+1];
+			l[len(l)-1] = e;
+		}
+		list = l;
+	}
+	sort.Sort(list);
+	if tooMany {
+		n := len(list);
+		l := make(scanner.ErrorList, n+1);
+		copy(l, list);
+		l[n] = ErrTooManyErrors;
+		list = l;
+	}
+	if len(list) == 0 {
+		return files, nil
+	}
+	return files, list;
+}
+
+
+// ParseFileSemicolons is like ParseFile but also returns the semicolons
+// separating the statements and declarations of the file, in source
+// order.  With the AutoSemicolons mode, each Semicolon records whether
+// it was written in the source or inserted at the end of a line, which
+// helps to find the files and statements still to be converted from one
+// dialect to the other.
+//
+func ParseFileSemicolons(filename string, src interface{}, mode uint) (*ast.File, []Semicolon, os.Error) {
+	data, err := readSource(filename, src);
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var p parser;
+	p.semis = vector.New(0);
+	p.init(filename, data, mode);
+	var file *ast.File;
+	p.run(func(p *parser) { file = p.parseFile() });
+	semis := make([]Semicolon, p.semis.Len());
+	for i := 0; i < len(semis); i++ {
+		semis[i] = p.semis.At(i).(Semicolon)
+	}
+	return file, semis, p.getError(scanner.NoMultiples);
 }
 
 
@@ -15,6 +15,8 @@ import (
 	"go/ast";
 	"go/scanner";
 	"go/token";
+	"os";
+	"runtime";
 )
 
 
@@ -31,9 +33,27 @@ const (
 	ImportsOnly;			// parsing stops after import declarations
 	ParseComments;			// parse comments and add them to AST
 	Trace;				// print a trace of parsed productions
+	Strict;				// report legacy constructs as errors
+	AutoSemicolons;			// also accept newline-terminated statements
 )
 
 
+// MaxErrors is the maximum number of errors reported for a single
+// source; if it is exceeded, parsing stops and the error list returned
+// ends with ErrTooManyErrors. MaxErrors <= 0 means no limit. A limit
+// protects interactive tools from pathological sources causing a flood
+// of follow-on errors. Changing MaxErrors does not affect parses already
+// in progress.
+//
+var MaxErrors = 0
+
+
+// ErrTooManyErrors is the last element of the scanner.ErrorList
+// returned if parsing stopped after MaxErrors errors.
+//
+var ErrTooManyErrors = &scanner.Error{noPos, "too many errors"}
+
+
 // The parser structure holds the parser's internal state.
 type parser struct {
 	scanner.ErrorVector;
@@ -55,6 +75,15 @@ type parser struct {
 	tok	token.Token;	// one token look-ahead
 	lit	[]byte;		// token literal
 
+	// Previous token, for the suggestions of error messages
+	prevTok	token.Token;
+	prevLit	[]byte;
+	// Automatic semicolons (AutoSemicolons mode)
+	implicit	bool;		// true if the current token is an inserted semicolon
+	held		heldToken;	// token following an inserted semicolon
+	importPath	bool;		// true while parsing an import path
+	semis		*vector.Vector;	// list of Semicolons, if recorded
+
 	// Non-syntactic parser control
 	optSemi	bool;	// true if semicolon separator is optional in statement list
 	exprLev	int;	// < 0: in control clause, >= 0: in expression
@@ -63,6 +92,16 @@ type parser struct {
 	pkgScope	*ast.Scope;
 	fileScope	*ast.Scope;
 	topScope	*ast.Scope;
+
+	// Declared names, for the suggestions of error messages
+	src	[]byte;			// the source
+	parse	func(*parser);		// function parsing it (see run)
+	names	map[string]bool;	// declared names; nil until needed
+
+	// Error limit
+	maxErrors	int;		// == MaxErrors at start of parse
+	done		chan bool;	// signals the end of a limited parse; or nil
+	aborted		bool;		// true if the error limit was exceeded
 }
 
 
@@ -77,6 +116,8 @@ func scannerMode(mode uint) uint {
 
 func (p *parser) init(filename string, src []byte, mode uint) {
 	p.ErrorVector.Init();
+	p.src = src;
+	p.maxErrors = MaxErrors;
 	p.scanner.Init(filename, src, p, scannerMode(mode));
 	p.mode = mode;
 	p.trace = mode&Trace != 0;	// for convenience (p.trace is used frequently)
@@ -84,6 +125,63 @@ func (p *parser) init(filename string, src []byte, mode uint) {
 }
 
 
+// ----------------------------------------------------------------------------
+// Error limit
+
+// Error records an error; the parser is the error handler of its scanner.
+// If the error limit is exceeded, Error stops the goroutine running the
+// parse (see run).
+//
+func (p *parser) Error(pos token.Position, msg string) {
+	p.ErrorVector.Error(pos, msg);
+	if p.done != nil && p.ErrorCount() > p.maxErrors {
+		p.aborted = true;
+		p.done <- true;
+		runtime.Goexit();
+	}
+}
+
+
+// run calls parse with p. If there is an error limit, parse runs in a
+// separate goroutine, so that it can be stopped once the limit is
+// exceeded; the results of parse must not be used in this case.
+//
+// Parse may be called again, with another parser, to collect the names
+// declared in the source for the suggestions of error messages (see
+// suggest); that call returns before the call of run does, so that the
+// results of parse are those of p.
+//
+func (p *parser) run(parse func(*parser)) {
+	p.parse = parse;
+	if p.maxErrors <= 0 {
+		parse(p);
+		return;
+	}
+	p.done = make(chan bool);
+	go func() {
+		parse(p);
+		p.done <- true;
+	}();
+	<-p.done;
+}
+
+
+// getError is like p.GetError but applies the error limit.
+func (p *parser) getError(mode int) os.Error {
+	if !p.aborted && (p.maxErrors <= 0 || p.ErrorCount() <= p.maxErrors) {
+		return p.GetError(mode)
+	}
+	list := p.GetErrorList(mode);
+	if len(list) > p.maxErrors {
+		list = list[0:p.maxErrors]
+	}
+	l := make(scanner.ErrorList, len(list)+1);
+	copy(l, list);
+	l[len(list)] = ErrTooManyErrors;
+	return l;
+}
+
+
 // ----------------------------------------------------------------------------
 // Parsing support
 
@@ -207,6 +305,16 @@ func (p *parser) consumeCommentGroup() int {
 // stored in the AST.
 //
 func (p *parser) next() {
+	p.prevTok, p.prevLit = p.tok, p.lit;
+	if p.implicit {
+		// return the token held back by the inserted semicolon
+		p.pos, p.tok, p.lit = p.held.pos, p.held.tok, p.held.lit;
+		p.leadComment, p.lineComment = p.held.leadComment, p.held.lineComment;
+		p.implicit = false;
+		return;
+	}
+
+	prev := heldToken{pos: p.pos, tok: p.tok, lit: p.lit};
 	p.leadComment = nil;
 	p.lineComment = nil;
 	line := p.pos.Line;	// current line
@@ -236,6 +344,91 @@ func (p *parser) next() {
 			p.leadComment = p.lastComment
 		}
 	}
+
+	if p.mode&AutoSemicolons != 0 && p.insertSemi(&prev) {
+		p.held = heldToken{p.pos, p.tok, p.lit, p.leadComment, p.lineComment};
+		p.pos, p.tok, p.lit = prev.end(), token.SEMICOLON, newline;
+		p.leadComment = nil;	// the line comment belongs to the previous line
+		p.implicit = true;
+	}
+	if p.tok == token.SEMICOLON && p.semis != nil {
+		p.semis.Push(Semicolon{p.pos, p.implicit})
+	}
+}
+
+
+// ----------------------------------------------------------------------------
+// Automatic semicolons
+//
+// In AutoSemicolons mode, the parser accepts the newline-terminated
+// statements of newer Go sources in addition to explicit semicolons:
+// a semicolon is inserted after the last token of a line if that token
+// is an identifier, a basic literal, one of the keywords break, continue,
+// fallthrough, or return, or one of the operators ++, --, ), ], or }.
+// The insertion happens before any comments on the following lines,
+// so that lead and line comments are recognized as usual.
+//
+// To accept the lists of adjacent string literals of this tree, no
+// semicolon is inserted between a string literal ending one line and a
+// string literal starting the next, except after an import path, since
+// the imports of a group are on lines of their own.  No semicolon is
+// inserted before a closing ) or } either, where one is optional, so
+// that composite literals and argument lists spanning several lines
+// need no trailing comma.
+
+// A Semicolon describes a semicolon separating statements or declarations.
+type Semicolon struct {
+	Pos		token.Position;	// position of the semicolon or of the end of the line
+	Implicit	bool;		// true if inserted at the end of a line, false if explicit
+}
+
+
+var newline = []byte{'\n'}
+
+
+// A heldToken is a token and its comments held back by the parser.
+type heldToken struct {
+	pos		token.Position;
+	tok		token.Token;
+	lit		[]byte;
+	leadComment	*ast.CommentGroup;
+	lineComment	*ast.CommentGroup;
+}
+
+
+// end returns the position immediately after the token t.
+func (t *heldToken) end() token.Position {
+	pos := t.pos;
+	pos.Offset += len(t.lit);
+	for _, b := range t.lit {
+		if b == '\n' {
+			pos.Line++;
+			pos.Column = 0;
+		}
+		pos.Column++;
+	}
+	return pos;
+}
+
+
+// insertSemi reports whether a semicolon is to be inserted between
+// the previous token prev and the current token.
+func (p *parser) insertSemi(prev *heldToken) bool {
+	switch prev.tok {
+	case token.IDENT, token.INT, token.FLOAT, token.CHAR, token.STRING,
+		token.BREAK, token.CONTINUE, token.FALLTHROUGH, token.RETURN,
+		token.INC, token.DEC, token.RPAREN, token.RBRACK, token.RBRACE:
+		// ok
+	default:
+		return false
+	}
+	switch {
+	case p.tok == token.SEMICOLON || p.tok == token.RPAREN || p.tok == token.RBRACE:
+		return false
+	case p.pos.Line <= prev.end().Line && p.tok != token.EOF:
+		return false
+	}
+	return !(prev.tok == token.STRING && p.tok == token.STRING && !p.importPath);
 }
 
 
@@ -248,21 +441,146 @@ func (p *parser) errorExpected(pos token.Position, msg string) {
 		if p.tok.IsLiteral() {
 			msg += " " + string(p.lit)
 		}
+		msg += p.suggestion();
 	}
 	p.Error(pos, msg);
 }
 
 
+// strictError reports a legacy construct at pos if the parser is
+// in Strict mode. Such constructs are legal but are being phased out.
+//
+func (p *parser) strictError(pos token.Position, msg string) {
+	if p.mode&Strict != 0 {
+		p.Error(pos, msg)
+	}
+}
+
+
 func (p *parser) expect(tok token.Token) token.Position {
 	pos := p.pos;
 	if p.tok != tok {
-		p.errorExpected(pos, "'"+tok.String()+"'")
+		p.errorExpected(pos, "'"+tok.String()+"'");
+		if stmtAnchors[p.tok] {
+			// the token likely ends the current or starts the
+			// next statement; leave it for the caller to use
+			return pos
+		}
 	}
-	p.next();	// make progress in any case
+	p.next();	// make progress
 	return pos;
 }
 
 
+// ----------------------------------------------------------------------------
+// Error recovery
+//
+// After a syntax error, the parser skips tokens up to the nearest token
+// in the anchor set of the production being parsed and resumes parsing
+// from there. Anchor sets contain the tokens that may follow or start
+// the respective production; resuming at such a token avoids follow-on
+// errors caused by a single mistake.
+
+// stmtAnchors contains the tokens that start a statement other than a
+// simple statement, end a statement, or end a statement list.
+var stmtAnchors = map[token.Token]bool{
+	token.BREAK: true,
+	token.CONST: true,
+	token.CONTINUE: true,
+	token.DEFER: true,
+	token.FALLTHROUGH: true,
+	token.FOR: true,
+	token.GO: true,
+	token.GOTO: true,
+	token.IF: true,
+	token.RETURN: true,
+	token.SELECT: true,
+	token.SWITCH: true,
+	token.TYPE: true,
+	token.VAR: true,
+	token.SEMICOLON: true,
+	token.RBRACE: true,
+	token.CASE: true,
+	token.DEFAULT: true,
+}
+
+
+// declAnchors contains the tokens that start a top-level declaration.
+var declAnchors = map[token.Token]bool{
+	token.CONST: true,
+	token.FUNC: true,
+	token.IMPORT: true,
+	token.TYPE: true,
+	token.VAR: true,
+}
+
+
+// exprAnchors contains the tokens that may follow an expression or
+// a type, and the tokens that start a statement other than a simple
+// statement (which indicates a missing expression).
+var exprAnchors = map[token.Token]bool{
+	token.COMMA: true,
+	token.COLON: true,
+	token.SEMICOLON: true,
+	token.RPAREN: true,
+	token.RBRACK: true,
+	token.RBRACE: true,
+	token.BREAK: true,
+	token.CONST: true,
+	token.CONTINUE: true,
+	token.DEFER: true,
+	token.FALLTHROUGH: true,
+	token.FOR: true,
+	token.GO: true,
+	token.GOTO: true,
+	token.IF: true,
+	token.RETURN: true,
+	token.SELECT: true,
+	token.SWITCH: true,
+	token.TYPE: true,
+	token.VAR: true,
+}
+
+
+// skipTo skips tokens until the next token in anchors outside of any
+// parentheses, brackets, or braces opened while skipping, or until EOF.
+// The anchor token is not consumed.
+//
+func (p *parser) skipTo(anchors map[token.Token]bool) {
+	depth := 0;
+	for p.tok != token.EOF {
+		if depth == 0 && anchors[p.tok] {
+			return
+		}
+		switch p.tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		}
+		p.next();
+	}
+}
+
+
+// syncExpr recovers from a missing expression or type at the current
+// token. The current token is consumed only if it cannot follow an
+// expression; an opening brace in a control clause is always left
+// for the statement body.
+//
+func (p *parser) syncExpr() {
+	if p.tok == token.LBRACE && p.exprLev < 0 {
+		return
+	}
+	if !exprAnchors[p.tok] {
+		p.next();	// make progress
+		p.skipTo(exprAnchors);
+	}
+}
+
+
 // ----------------------------------------------------------------------------
 // Scope support
 
@@ -359,9 +677,10 @@ func (p *parser) parseType() ast.Expr {
 	typ := p.tryType();
 
 	if typ == nil {
-		p.errorExpected(p.pos, "type");
-		p.next();	// make progress
-		return &ast.BadExpr{p.pos};
+		pos := p.pos;
+		p.errorExpected(pos, "type");
+		p.syncExpr();
+		return &ast.BadExpr{pos};
 	}
 
 	return typ;
@@ -534,9 +853,10 @@ func (p *parser) tryParameterType(ellipsisOk bool) ast.Expr {
 func (p *parser) parseParameterType(ellipsisOk bool) ast.Expr {
 	typ := p.tryParameterType(ellipsisOk);
 	if typ == nil {
-		p.errorExpected(p.pos, "type");
-		p.next();	// make progress
-		typ = &ast.BadExpr{p.pos};
+		pos := p.pos;
+		p.errorExpected(pos, "type");
+		p.syncExpr();
+		typ = &ast.BadExpr{pos};
 	}
 	return typ;
 }
@@ -575,12 +895,14 @@ func (p *parser) parseParameterList(ellipsisOk bool) []*ast.Field {
 	if typ != nil {
 		// IdentifierList Type
 		idents := p.makeIdentList(list);
+		p.declareNames(idents);
 		list.Init(0);
 		list.Push(&ast.Field{nil, idents, typ, nil, nil});
 
 		for p.tok == token.COMMA {
 			p.next();
 			idents := p.parseIdentList();
+			p.declareNames(idents);
 			typ := p.parseParameterType(ellipsisOk);
 			list.Push(&ast.Field{nil, idents, typ, nil, nil});
 		}
@@ -626,7 +948,11 @@ func (p *parser) parseResult() []*ast.Field {
 
 	var results []*ast.Field;
 	if p.tok == token.LPAREN {
-		results = p.parseParameters(false)
+		pos := p.pos;
+		results = p.parseParameters(false);
+		if len(results) == 1 && len(results[0].Names) == 0 {
+			p.strictError(pos, "parenthesized single result type")
+		}
 	} else if p.tok != token.FUNC {
 		typ := p.tryType();
 		if typ != nil {
@@ -814,7 +1140,10 @@ func (p *parser) parseStmtList() []ast.Stmt {
 		}
 		list.Push(p.parseStmt());
 		if p.tok == token.SEMICOLON {
-			p.next()
+			if p.optSemi && !p.implicit {
+				p.strictError(p.pos, "unnecessary semicolon")
+			}
+			p.next();
 		} else if p.optSemi {
 			p.optSemi = false	// "consume" optional semicolon
 		} else {
@@ -856,6 +1185,9 @@ func (p *parser) parseStringList(x *ast.BasicLit) []*ast.BasicLit {
 	}
 
 	for p.tok == token.STRING {
+		if list.Len() > 0 {
+			p.strictError(p.pos, "adjacent string literals; use + to concatenate")
+		}
 		list.Push(&ast.BasicLit{p.pos, token.STRING, p.lit});
 		p.next();
 	}
@@ -929,9 +1261,10 @@ func (p *parser) parseOperand() ast.Expr {
 		}
 	}
 
-	p.errorExpected(p.pos, "operand");
-	p.next();	// make progress
-	return &ast.BadExpr{p.pos};
+	pos := p.pos;
+	p.errorExpected(pos, "operand");
+	p.syncExpr();
+	return &ast.BadExpr{pos};
 }
 
 
@@ -1271,6 +1604,9 @@ func (p *parser) parseSimpleStmt(labelOk bool) ast.Stmt {
 		// assignment statement
 		pos, tok := p.pos, p.tok;
 		p.next();
+		if tok == token.DEFINE {
+			p.declareExprs(x)
+		}
 		y := p.parseExprList();
 		if len(x) > 1 && len(y) > 1 && len(x) != len(y) {
 			p.Error(x[0].Pos(), "arity of lhs doesn't match rhs")
@@ -1705,9 +2041,11 @@ func (p *parser) parseStmt() ast.Stmt {
 	}
 
 	// no statement found
-	p.errorExpected(p.pos, "statement");
+	pos := p.pos;
+	p.errorExpected(pos, "statement");
 	p.next();	// make progress
-	return &ast.BadStmt{p.pos};
+	p.skipTo(stmtAnchors);
+	return &ast.BadStmt{pos};
 }
 
 
@@ -1744,7 +2082,10 @@ func parseImportSpec(p *parser, doc *ast.CommentGroup, getSemi bool) (spec ast.S
 
 	var path []*ast.BasicLit;
 	if p.tok == token.STRING {
-		path = p.parseStringList(nil)
+		// the next line holds the next import, not more of the path
+		p.importPath = true;
+		path = p.parseStringList(nil);
+		p.importPath = false;
 	} else {
 		p.expect(token.STRING)	// use expect() error handling
 	}
@@ -1761,6 +2102,7 @@ func parseConstSpec(p *parser, doc *ast.CommentGroup, getSemi bool) (spec ast.Sp
 	}
 
 	idents := p.parseIdentList();
+	p.declareNames(idents);
 	typ := p.tryType();
 	var values []ast.Expr;
 	if typ != nil || p.tok == token.ASSIGN {
@@ -1779,6 +2121,7 @@ func parseTypeSpec(p *parser, doc *ast.CommentGroup, getSemi bool) (spec ast.Spe
 	}
 
 	ident := p.parseIdent();
+	p.declareName(ident);
 	typ := p.parseType();
 	comment, gotSemi := p.parseComment(getSemi);
 
@@ -1792,6 +2135,7 @@ func parseVarSpec(p *parser, doc *ast.CommentGroup, getSemi bool) (spec ast.Spec
 	}
 
 	idents := p.parseIdentList();
+	p.declareNames(idents);
 	typ := p.tryType();
 	var values []ast.Expr;
 	if typ == nil || p.tok == token.ASSIGN {
@@ -1827,6 +2171,9 @@ func (p *parser) parseGenDecl(keyword token.Token, f parseSpecFunction, getSemi
 		rparen = p.expect(token.RPAREN);
 
 		if getSemi && p.tok == token.SEMICOLON {
+			if !p.implicit {
+				p.strictError(p.pos, "unnecessary semicolon")
+			}
 			p.next();
 			gotSemi = true;
 		} else {
@@ -1891,6 +2238,7 @@ func (p *parser) parseFunctionDecl() *ast.FuncDecl {
 	}
 
 	ident := p.parseIdent();
+	p.declareName(ident);
 	params, results := p.parseSignature();
 
 	var body *ast.BlockStmt;
@@ -1920,6 +2268,10 @@ func (p *parser) parseDecl(getSemi bool) (decl ast.Decl, gotSemi bool) {
 
 	case token.FUNC:
 		decl = p.parseFunctionDecl();
+		if getSemi && p.optSemi && p.tok == token.SEMICOLON && !p.implicit {
+			// function body is followed by a semicolon
+			p.strictError(p.pos, "unnecessary semicolon")
+		}
 		_, gotSemi := p.parseComment(getSemi);
 		return decl, gotSemi;
 
@@ -1927,9 +2279,9 @@ func (p *parser) parseDecl(getSemi bool) (decl ast.Decl, gotSemi bool) {
 		pos := p.pos;
 		p.errorExpected(pos, "declaration");
 		decl = &ast.BadDecl{pos};
-		gotSemi = getSemi && p.tok == token.SEMICOLON;
 		p.next();	// make progress in any case
-		return decl, gotSemi;
+		p.skipTo(declAnchors);
+		return decl, false;
 	}
 
 	return p.parseGenDecl(p.tok, f, getSemi);
@@ -1972,6 +2324,9 @@ func (p *parser) parseFile() *ast.File {
 	doc := p.leadComment;
 	pos := p.expect(token.PACKAGE);
 	ident := p.parseIdent();
+	if p.implicit {
+		p.next()	// newline-terminated package clause
+	}
 	var decls []ast.Decl;
 
 	// Don't bother parsing the rest if we had errors already.
@@ -5,7 +5,11 @@
 package parser
 
 import (
+	"go/ast";
+	"go/scanner";
+	"go/token";
 	"os";
+	"strings";
 	"testing";
 )
 
@@ -44,6 +48,35 @@ func TestParseValidPrograms(t *testing.T) {
 }
 
 
+var legacyPrograms = []interface{}{
+	`package main var s = "foo" "bar"`,
+	`package main func f() { if true {}; }`,
+	`package main func f() {};`,
+	`package main const ( c = 0 );`,
+	`package main func f() (int) { return 0 }`,
+}
+
+
+func TestParseStrict(t *testing.T) {
+	for _, src := range validPrograms {
+		_, err := ParseFile("", src, Strict);
+		if err != nil {
+			t.Errorf("ParseFile(%q, Strict): %v", src, err)
+		}
+	}
+	for _, src := range legacyPrograms {
+		_, err := ParseFile("", src, 0);
+		if err != nil {
+			t.Errorf("ParseFile(%q): %v", src, err)
+		}
+		_, err = ParseFile("", src, Strict);
+		if err == nil {
+			t.Errorf("ParseFile(%q, Strict) should have failed", src)
+		}
+	}
+}
+
+
 var validFiles = []string{
 	"parser.go",
 	"parser_test.go",
@@ -90,3 +123,313 @@ func TestParse4(t *testing.T) {
 		}
 	}
 }
+
+
+var mergeFiles = map[string]string{
+	"b.go": `// Package p is split across files.
+package p
+import ("fmt"; "os")
+func F() { fmt.Println(os.Args) }
+`,
+	"a.go": `// Package p is split across files.
+package p
+import "os"
+import str "strings"
+var V = os.Args
+`,
+	"c.go": `// More package documentation.
+package p
+import ("strings"; str "strings")
+type T int
+`,
+}
+
+
+func TestMergePackageFiles(t *testing.T) {
+	pkg := &ast.Package{"p", ".", make(map[string]*ast.File)};
+	for filename, src := range mergeFiles {
+		f, err := ParseFile(filename, src, ParseComments);
+		if err != nil {
+			t.Fatalf("ParseFile(%s): %v", filename, err)
+		}
+		pkg.Files[filename] = f;
+	}
+	f := ast.MergePackageFiles(pkg);
+
+	// the duplicate package comment of b.go is dropped
+	if f.Doc == nil || len(f.Doc.List) != 3 {
+		t.Fatalf("expected 3 package comments (including separator), got %v", f.Doc)
+	}
+	if s := string(f.Doc.List[2].Text); s != "// More package documentation." {
+		t.Errorf("last package comment is %q", s)
+	}
+
+	// one import declaration followed by the declarations in file order
+	if len(f.Decls) != 4 {
+		t.Fatalf("expected 4 declarations, got %d", len(f.Decls))
+	}
+	imports, ok := f.Decls[0].(*ast.GenDecl);
+	if !ok || imports.Tok != token.IMPORT {
+		t.Fatalf("first declaration is not an import declaration")
+	}
+	expected := []string{`os`, `str strings`, `fmt`, `strings`};
+	if len(imports.Specs) != len(expected) {
+		t.Fatalf("expected %d imports, got %d", len(expected), len(imports.Specs))
+	}
+	for i, s := range imports.Specs {
+		s := s.(*ast.ImportSpec);
+		name := "";
+		if s.Name != nil {
+			name = s.Name.Value + " "
+		}
+		path := string(s.Path[0].Value);
+		if name+path[1:len(path)-1] != expected[i] {
+			t.Errorf("import %d: got %s%s, expected %s", i, name, path, expected[i])
+		}
+	}
+	if _, ok := f.Decls[1].(*ast.GenDecl); !ok {
+		t.Errorf("declaration 1 should be var V")
+	}
+	if _, ok := f.Decls[2].(*ast.FuncDecl); !ok {
+		t.Errorf("declaration 2 should be func F")
+	}
+}
+
+
+func TestFoldString(t *testing.T) {
+	src := "package p\nconst s = \"a\\tb\" `c\nd` \"\\u00e9\"\n";
+	file, err := ParseFile("", src, 0);
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	x := file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0];
+	list, ok := x.(*ast.StringList);
+	if !ok {
+		t.Fatalf("got %T, expected *ast.StringList", x)
+	}
+	s, err := FoldString(x);
+	if err != nil {
+		t.Fatalf("FoldString: %v", err)
+	}
+	const value = "a\tbc\nd\u00e9";
+	if s.Value != value {
+		t.Errorf("Value = %q, expected %q", s.Value, value)
+	}
+	if v := string(s.Lit.Value); v != `"a\tbc\nd\u00e9"` {
+		t.Errorf("Lit.Value = %s", v)
+	}
+	if s.Lit.Pos().Offset != list.Pos().Offset || s.Lit.Kind != token.STRING {
+		t.Errorf("Lit = %v %v, expected STRING at %v", s.Lit.Kind, s.Lit.Pos(), list.Pos())
+	}
+	if s.End.Line != 3 || s.End.Offset != len(src)-1 {
+		t.Errorf("End = %v, expected line 3, offset %d", s.End, len(src)-1)
+	}
+	if s.Orig != x || len(list.Strings) != 3 {
+		t.Errorf("original node changed")
+	}
+
+	// single literals are returned as is
+	lit := list.Strings[0];
+	if s, err := FoldString(lit); err != nil || s.Lit != lit || s.Value != "a\tb" {
+		t.Errorf("FoldString(%s) = %v, %v", lit.Value, s, err)
+	}
+	if _, err := FoldString(&ast.BasicLit{Kind: token.INT, Value: strings.Bytes("1")}); err == nil {
+		t.Errorf("FoldString of an integer literal succeeded")
+	}
+}
+
+
+const autoSemiSrc = `package p
+
+import (
+	"fmt"
+	"os";
+)
+
+// T is a type.
+type T struct {
+	a	int	// a comment
+	b	string;
+}
+
+func f(x int) int {
+	x++
+	if x > 0 {
+		return x
+	}
+	s := "a"
+		"b";
+	fmt.Println(s, os.Args)
+	return 0;
+}
+`
+
+
+var autoSemiLists = []string{
+	"package p\nvar a = []int{\n\t1,\n\t2\n}\n",
+	"package p\nvar a = T{\n\tx: 1,\n\ty: []int{1, 2}\n}\n",
+	"package p\nfunc f() {\n\tg(1,\n\t\t2)\n\tg(\n\t\t3\n\t)\n}\n",
+}
+
+
+func TestAutoSemicolons(t *testing.T) {
+	if _, err := ParseFile("", autoSemiSrc, 0); err == nil {
+		t.Errorf("ParseFile succeeded without AutoSemicolons")
+	}
+
+	file, semis, err := ParseFileSemicolons("", autoSemiSrc, AutoSemicolons|ParseComments);
+	if err != nil {
+		t.Fatalf("ParseFileSemicolons: %v", err)
+	}
+	if len(file.Decls) != 3 {
+		t.Errorf("got %d declarations, expected 3", len(file.Decls))
+	}
+	if d, ok := file.Decls[0].(*ast.GenDecl); !ok || len(d.Specs) != 2 {
+		t.Errorf("expected an import declaration with 2 imports")
+	} else {
+		for i, path := range []string{`"fmt"`, `"os"`} {
+			s := d.Specs[i].(*ast.ImportSpec);
+			if len(s.Path) != 1 || string(s.Path[0].Value) != path {
+				t.Errorf("import %d: got %d path literals, expected %s", i, len(s.Path), path)
+			}
+		}
+	}
+	if d, ok := file.Decls[1].(*ast.GenDecl); !ok || d.Doc == nil {
+		t.Errorf("missing doc comment for T")
+	}
+
+	var explicit, implicit int;
+	for _, s := range semis {
+		if s.Implicit {
+			implicit++
+		} else {
+			explicit++
+		}
+	}
+	if explicit != 4 || implicit != 9 {
+		t.Errorf("got %d explicit and %d implicit semicolons, expected 4 and 9", explicit, implicit)
+	}
+
+	// lists spanning several lines need no trailing comma
+	for _, src := range autoSemiLists {
+		if _, err := ParseFile("", src, AutoSemicolons); err != nil {
+			t.Errorf("ParseFile(%q, AutoSemicolons): %v", src, err)
+		}
+	}
+
+	// sources with explicit semicolons parse the same way
+	for _, src := range validPrograms {
+		if _, err := ParseFile("", src, AutoSemicolons); err != nil {
+			t.Errorf("ParseFile(%q, AutoSemicolons): %v", src, err)
+		}
+	}
+}
+
+
+func TestMaxErrors(t *testing.T) {
+	// every line but the first has an error
+	src := "package p\n";
+	for i := 0; i < 100; i++ {
+		src += "var = 1;\n"
+	}
+
+	defer func(max int) { MaxErrors = max }(MaxErrors);
+	MaxErrors = 10;
+	file, err := ParseFile("", src, 0);
+	if file != nil {
+		t.Errorf("got AST despite exceeding the error limit")
+	}
+	list, ok := err.(scanner.ErrorList);
+	if !ok {
+		t.Fatalf("got error %v; expected a scanner.ErrorList", err)
+	}
+	n := len(list) - 1;
+	if n < 1 || n > MaxErrors || list[n] != ErrTooManyErrors {
+		t.Fatalf("got %d errors ending with %v; expected at most %d errors followed by %v",
+			n, list[n], MaxErrors, ErrTooManyErrors)
+	}
+	for i, e := range list[0:n] {
+		if e.Pos.Line != i+2 {
+			t.Errorf("error %d at line %d; expected line %d", i, e.Pos.Line, i+2)
+		}
+	}
+
+	// the same source yields the same errors
+	_, err2 := ParseFile("", src, 0);
+	if err2.String() != err.String() || len(err2.(scanner.ErrorList)) != len(list) {
+		t.Errorf("got different errors for the same source")
+	}
+
+	// below the limit, all errors are reported
+	MaxErrors = 1000;
+	if _, err := ParseFile("", src, 0); len(err.(scanner.ErrorList)) != 100 {
+		t.Errorf("got %d errors; expected 100", len(err.(scanner.ErrorList)))
+	}
+}
+
+
+func TestParseFiles(t *testing.T) {
+	filenames := []string{"testdata/recover.src", "parser.go", "nonexistent.go", "interface.go"};
+	for workers := 0; workers <= len(filenames)+1; workers++ {
+		files, err := ParseFiles(filenames, 0, workers);
+		if len(files) != len(filenames) {
+			t.Fatalf("workers = %d: got %d files; expected %d", workers, len(files), len(filenames))
+		}
+		for i, name := range []string{"recover", "parser", "", "parser"} {
+			switch {
+			case name == "" && files[i] != nil:
+				t.Errorf("workers = %d: got AST for %s", workers, filenames[i])
+			case name != "" && (files[i] == nil || files[i].Name.Value != name):
+				t.Errorf("workers = %d: got no AST for %s", workers, filenames[i])
+			}
+		}
+
+		list, ok := err.(scanner.ErrorList);
+		if !ok {
+			t.Fatalf("workers = %d: got error %v; expected a scanner.ErrorList", workers, err)
+		}
+		if list[0].Pos.Filename != "nonexistent.go" {
+			t.Errorf("workers = %d: first error %v; expected the read error of nonexistent.go", workers, list[0])
+		}
+		for i, e := range list[1:len(list)] {
+			if e.Pos.Filename != "testdata/recover.src" {
+				t.Errorf("workers = %d: error %v; expected an error in testdata/recover.src", workers, e)
+			}
+			if list.Less(i+1, i) {
+				t.Errorf("workers = %d: errors %v and %v not sorted", workers, list[i], e)
+			}
+		}
+	}
+
+	files, err := ParseFiles([]string{"parser.go", "interface.go"}, 0, 2);
+	if err != nil || files[0] == nil || files[1] == nil {
+		t.Errorf("ParseFiles of valid files: %v", err)
+	}
+}
+
+
+func TestParseFileStats(t *testing.T) {
+	src := "package p\n\n// comment\nvar x = 1 + 2 /* inline */\n";
+	file, stats, err := ParseFileStats("stats.go", src, ParseComments);
+	if err != nil || file == nil {
+		t.Fatalf("ParseFileStats: %v", err)
+	}
+	if stats.Filename != "stats.go" || stats.Bytes != len(src) || stats.Comments != 2 || stats.Errors != 0 {
+		t.Errorf("got %s; expected stats.go: %d bytes, 2 comments, 0 errors", stats, len(src))
+	}
+	// File, Ident p, GenDecl, ValueSpec, Ident x, BinaryExpr, BasicLit 1, BasicLit 2
+	if stats.Nodes != 8 {
+		t.Errorf("got %d nodes; expected 8", stats.Nodes)
+	}
+
+	_, stats, err = ParseFileStats("bad.go", "package p\nvar = ;\n", 0);
+	// the error list has at most one error per line
+	if err == nil || stats.Errors < len(err.(scanner.ErrorList)) {
+		t.Errorf("got %d errors for %v; expected at least the errors reported", stats.Errors, err)
+	}
+
+	_, stats, err = ParseFileStats("nonexistent.go", nil, 0);
+	if err == nil || stats.Bytes != 0 {
+		t.Errorf("got %s, %v; expected a read error", stats, err)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains ParseFileStats, which reports the cost of parsing a file.
+
+package parser
+
+import (
+	"fmt";
+	"go/ast";
+	"go/scanner";
+	"os";
+	"time";
+)
+
+
+// Stats describes the parsing of a file by ParseFileStats.
+// Programs parsing many files can collect them to find the files
+// that are slow to parse, and users can include them in reports
+// of performance problems.
+//
+type Stats struct {
+	Filename	string;
+	Bytes		int;	// size of the source
+	Nodes		int;	// number of AST nodes, not counting comments
+	Comments	int;	// number of comments collected
+	Errors		int;	// number of syntax errors
+	Ns		int64;	// wall time in nanoseconds, including reading the source
+}
+
+
+func (s *Stats) String() string {
+	return fmt.Sprintf("%s: %d bytes, %d nodes, %d comments, %d errors, %.3fms",
+		s.Filename, s.Bytes, s.Nodes, s.Comments, s.Errors, float64(s.Ns)/1e6)
+}
+
+
+// Add adds the counts and the time of t to s, so that s can
+// accumulate the statistics of several files.
+//
+func (s *Stats) Add(t *Stats) {
+	s.Bytes += t.Bytes;
+	s.Nodes += t.Nodes;
+	s.Comments += t.Comments;
+	s.Errors += t.Errors;
+	s.Ns += t.Ns;
+}
+
+
+// A nodeCounter counts the nodes of an AST except for comments.
+type nodeCounter int
+
+func (n *nodeCounter) Visit(node interface{}) bool {
+	switch node.(type) {
+	case *ast.Comment, *ast.CommentGroup:
+		return false
+	}
+	*n++;
+	return true;
+}
+
+
+// ParseFileStats is like ParseFile but also returns statistics about
+// the parse. The statistics are returned even if there is an error;
+// if the source couldn't be read, only the Filename and Ns fields are
+// set.
+//
+func ParseFileStats(filename string, src interface{}, mode uint) (*ast.File, *Stats, os.Error) {
+	stats := &Stats{Filename: filename};
+	t0 := time.Nanoseconds();
+	data, err := readSource(filename, src);
+	if err != nil {
+		stats.Ns = time.Nanoseconds() - t0;
+		return nil, stats, err;
+	}
+
+	var p parser;
+	p.init(filename, data, mode);
+	var file *ast.File;
+	p.run(func(p *parser) { file = p.parseFile() });
+	err = p.getError(scanner.NoMultiples);
+	stats.Ns = time.Nanoseconds() - t0;
+
+	stats.Bytes = len(data);
+	stats.Errors = p.ErrorCount();
+	if file != nil {
+		var n nodeCounter;
+		ast.Walk(&n, file);
+		stats.Nodes = int(n);
+		for g := file.Comments; g != nil; g = g.Next {
+			stats.Comments += len(g.List)
+		}
+	}
+	return file, stats, err;
+}
@@ -0,0 +1,210 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the suggestions of error messages: if an
+// unexpected identifier, or the identifier preceding an unexpected
+// token, closely matches a keyword or a name declared in the source,
+// the message ends with a hint such as
+//
+//	(did you mean "return"?)
+//
+// Identifiers match if they differ only in case or are within a small
+// edit distance of each other, counting a transposition of adjacent
+// bytes as one edit: 1 for identifiers of up to 4 bytes, and 2 for
+// longer ones.  The closest match is suggested; keywords are preferred
+// over declared names at the same distance.
+//
+// The declared names are collected only when they are needed, by
+// parsing the source once more, so that sources without errors are
+// parsed at no extra cost.
+
+package parser
+
+import (
+	"go/ast";
+	"go/token";
+	"strings";
+)
+
+
+// keywords is the list of Go keywords.
+var keywords []string
+
+
+func init() {
+	keywords = make([]string, token.VAR-token.BREAK+1);
+	for tok := token.BREAK; tok <= token.VAR; tok++ {
+		keywords[tok-token.BREAK] = tok.String()
+	}
+}
+
+
+// declareNames records the names of a declaration as candidates
+// for suggestions if p collects them. The parser does not track
+// scopes, so a name is a candidate anywhere in the source.
+//
+func (p *parser) declareNames(idents []*ast.Ident) {
+	if p.names == nil {
+		return	// not collecting
+	}
+	for _, ident := range idents {
+		if ident.Value != "" && ident.Value != "_" {
+			p.names[ident.Value] = true
+		}
+	}
+}
+
+
+func (p *parser) declareName(ident *ast.Ident) {
+	p.declareNames([]*ast.Ident{ident})
+}
+
+
+// declareExprs records the identifiers on the left-hand
+// side of a short variable declaration.
+//
+func (p *parser) declareExprs(list []ast.Expr) {
+	for _, x := range list {
+		if ident, ok := x.(*ast.Ident); ok {
+			p.declareName(ident)
+		}
+	}
+}
+
+
+// maxDistance returns the largest edit distance at which
+// a keyword or name is suggested for word.
+//
+func maxDistance(word string) int {
+	switch {
+	case len(word) < 3:
+		return 0	// case differences only
+	case len(word) <= 4:
+		return 1
+	}
+	return 2;
+}
+
+
+// editDistance returns the edit distance between a and b: the number
+// of insertions, deletions, substitutions, and transpositions of
+// adjacent bytes needed to turn a into b.
+//
+func editDistance(a, b string) int {
+	// d[i][j] is the distance between a[0:i] and b[0:j]
+	d := make([][]int, len(a)+1);
+	for i := range d {
+		d[i] = make([]int, len(b)+1);
+		d[i][0] = i;
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1;
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j-1]+cost, min(d[i-1][j]+1, d[i][j-1]+1));
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)];
+}
+
+
+func min(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y;
+}
+
+
+// closest returns the candidate closest to word, ignoring case, and
+// its distance; ties are broken in favor of the smaller candidate in
+// lexical order so that the suggestions are deterministic. Candidates
+// equal to word are skipped. If no candidate is within max of word,
+// the result is "".
+//
+func closest(word string, candidates []string, max int) (string, int) {
+	lword := strings.ToLower(word);
+	best, dist := "", max+1;
+	for _, c := range candidates {
+		if c == word {
+			continue
+		}
+		d := editDistance(lword, strings.ToLower(c));
+		if d < dist || d == dist && c < best {
+			best, dist = c, d
+		}
+	}
+	return best, dist;
+}
+
+
+// collectNames sets p.names to the names declared in the source
+// of p, which it parses again for that purpose.
+//
+func (p *parser) collectNames() {
+	var q parser;
+	q.names = make(map[string]bool);
+	q.init("", p.src, p.mode&^(ParseComments|Trace));
+	q.maxErrors = 0;	// q's errors are ignored
+	if p.parse != nil {
+		p.parse(&q)
+	}
+	p.names = q.names;
+}
+
+
+// suggest returns the keyword or declared name the identifier word
+// was likely meant to be, or "" if there is none.
+//
+func (p *parser) suggest(word string) string {
+	if token.Lookup(strings.Bytes(word)) != token.IDENT {
+		return ""	// a keyword
+	}
+	if p.names == nil {
+		p.collectNames()
+	}
+	if p.names[word] {
+		return ""	// a known name
+	}
+	max := maxDistance(word);
+	keyword, kdist := closest(word, keywords, max);
+	names := make([]string, len(p.names));
+	i := 0;
+	for name, _ := range p.names {
+		names[i] = name;
+		i++;
+	}
+	if name, ndist := closest(word, names, max); name != "" && ndist < kdist {
+		return name
+	}
+	return keyword;
+}
+
+
+// suggestion returns the hint appended to an error message at the
+// current token, or "".
+//
+func (p *parser) suggestion() string {
+	if p.tok == token.IDENT {
+		if s := p.suggest(string(p.lit)); s != "" {
+			return " (did you mean \"" + s + "\"?)"
+		}
+	}
+	// a misspelled keyword such as "retrun" is parsed as an identifier;
+	// the error is reported at the token following it
+	if p.prevTok == token.IDENT {
+		if s := p.suggest(string(p.prevLit)); s != "" {
+			return " (did you mean \"" + s + "\" for \"" + string(p.prevLit) + "\"?)"
+		}
+	}
+	return "";
+}
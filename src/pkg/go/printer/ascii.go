@@ -0,0 +1,131 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the ASCIIOnly printing mode.
+//
+// In ASCIIOnly mode, the non-ASCII characters of string and character
+// literals are replaced by escape sequences denoting the same value,
+// so that the output consists of ASCII characters only, except for
+// comments, which are printed unchanged. Identifiers cannot be
+// escaped; a non-ASCII identifier is printed as is and reported as
+// an error once printing has finished.
+
+package printer
+
+import (
+	"bytes";
+	"fmt";
+	"go/ast";
+	"go/token";
+	"os";
+	"strings";
+	"utf8";
+)
+
+
+const hexDigits = "0123456789abcdef"
+
+
+// isASCII reports whether s consists of ASCII characters only.
+func isASCII(s []byte) bool {
+	for _, b := range s {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true;
+}
+
+
+// writeHex writes the n lower hexadecimal digits of x to buf.
+func writeHex(buf *bytes.Buffer, x, n int) {
+	for i := n - 1; i >= 0; i-- {
+		buf.WriteByte(hexDigits[x>>uint(4*i)&0xf])
+	}
+}
+
+
+// writeEscaped writes the bytes of s to buf, replacing each non-ASCII
+// character by a \u or \U escape, and each byte that is not part of a
+// valid UTF-8 encoding by a \x escape; the latter denotes the same byte
+// value since s is the text of an interpreted string or character literal.
+func writeEscaped(buf *bytes.Buffer, s []byte) {
+	for i := 0; i < len(s); {
+		if s[i] < utf8.RuneSelf {
+			buf.WriteByte(s[i]);
+			i++;
+			continue;
+		}
+		rune, size := utf8.DecodeRune(s[i:len(s)]);
+		switch {
+		case rune == utf8.RuneError && size == 1:
+			buf.WriteString(`\x`);
+			writeHex(buf, int(s[i]), 2);
+		case rune <= 0xffff:
+			buf.WriteString(`\u`);
+			writeHex(buf, rune, 4);
+		default:
+			buf.WriteString(`\U`);
+			writeHex(buf, rune, 8);
+		}
+		i += size;
+	}
+}
+
+
+// asciiLit returns x if it consists of ASCII characters only; otherwise
+// it returns a copy of x with the non-ASCII characters escaped. A raw
+// string literal is turned into an interpreted string literal for this
+// purpose, since raw strings cannot contain escape sequences.
+func asciiLit(x *ast.BasicLit) *ast.BasicLit {
+	if isASCII(x.Value) || x.Kind != token.CHAR && x.Kind != token.STRING {
+		return x
+	}
+	s := x.Value;
+	if s[0] == '`' {
+		var buf bytes.Buffer;
+		buf.WriteByte('"');
+		for _, b := range s[1 : len(s)-1] {
+			switch b {
+			case '"', '\\':
+				buf.WriteByte('\\');
+				buf.WriteByte(b);
+			case '\n':
+				buf.WriteString(`\n`)
+			case '\r':
+				buf.WriteString(`\r`)
+			default:
+				buf.WriteByte(b)
+			}
+		}
+		buf.WriteByte('"');
+		s = buf.Bytes();
+	}
+	var buf bytes.Buffer;
+	writeEscaped(&buf, s);
+	return &ast.BasicLit{x.Position, x.Kind, buf.Bytes()};
+}
+
+
+// checkIdent records id if it is the first non-ASCII identifier printed.
+func (p *printer) checkIdent(id *ast.Ident) {
+	if p.nonASCII == nil && !isASCII(strings.Bytes(id.Value)) {
+		p.nonASCII = id
+	}
+}
+
+
+// nonASCIIError returns the error reporting the first non-ASCII
+// identifier printed, or nil if there is none.
+func (p *printer) nonASCIIError() os.Error {
+	if p.nonASCII == nil {
+		return nil
+	}
+	id := p.nonASCII;
+	msg := fmt.Sprintf("non-ASCII identifier %s", id.Value);
+	if id.Pos().IsValid() {
+		msg = id.Pos().String() + ": " + msg
+	}
+	return os.NewError("printer.Fprint: " + msg);
+}
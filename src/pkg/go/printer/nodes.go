@@ -76,6 +76,7 @@ func (p *printer) linebreak(line, min, max int, ws whiteSpace, newSection bool)
 func (p *printer) commentList(list []*ast.Comment) {
 	for i, c := range list {
 		t := c.Text;
+		p.markPrinted(c);
 		// TODO(gri): this needs to be styled like normal comments
 		p.print(c.Pos(), t);
 		if t[1] == '/' && i+1 < len(list) {
@@ -310,6 +311,38 @@ func (p *printer) isOneLineFieldList(list []*ast.Field) bool {
 }
 
 
+// maxFieldSpread is the maximum difference between the sizes of the
+// field types in a section of struct fields whose tags and comments
+// are aligned; adjust as appropriate, this is an approximate value.
+const maxFieldSpread = 24
+
+
+// fieldTypeSizes returns the sizes of the types of the named fields
+// in list, and 0 for anonymous fields.  Types that don't fit on one
+// line are considered wider than maxFieldSpread.  If no field has a
+// tag or comment, there is nothing to align and the result is nil.
+func (p *printer) fieldTypeSizes(list []*ast.Field) []int {
+	aligned := false;
+	for _, f := range list {
+		if f.Tag != nil || f.Comment != nil {
+			aligned = true;
+			break;
+		}
+	}
+	if !aligned {
+		return nil
+	}
+	const maxSize = 2 * maxFieldSpread;
+	sizes := make([]int, len(list));
+	for i, f := range list {
+		if len(f.Names) > 0 {
+			sizes[i] = p.nodeSize(f.Type, maxSize)
+		}
+	}
+	return sizes;
+}
+
+
 func (p *printer) fieldList(lbrace token.Position, list []*ast.Field, rbrace token.Position, isIncomplete bool, ctxt exprContext) {
 	if !isIncomplete && !p.commentBefore(rbrace) {
 		// possibly a one-line struct/interface
@@ -346,10 +379,32 @@ func (p *printer) fieldList(lbrace token.Position, list []*ast.Field, rbrace tok
 		if len(list) == 1 {
 			sep = blank
 		}
+		sizes := p.fieldTypeSizes(list);
 		var ml bool;
+		var min, max int;	// type sizes of the current alignment section
 		for i, f := range list {
+			size := 0;
+			if sizes != nil {
+				size = sizes[i]
+			}
 			if i > 0 {
-				p.linebreak(f.Pos().Line, 1, 2, ignore, ml)
+				// a field type much wider or narrower than the types of
+				// the preceding fields starts a new alignment section,
+				// so that it doesn't push out the tags and comments of
+				// the other fields
+				newSection := ml || size > 0 && min > 0 && (size > min+maxFieldSpread || max > size+maxFieldSpread);
+				p.linebreak(f.Pos().Line, 1, 2, ignore, newSection);
+				if newSection {
+					min, max = 0, 0
+				}
+			}
+			if size > 0 {
+				if min == 0 || size < min {
+					min = size
+				}
+				if size > max {
+					max = size
+				}
 			}
 			ml = false;
 			extraTabs := 0;
@@ -590,9 +645,82 @@ func isBinary(expr ast.Expr) bool {
 }
 
 
+// callArgs prints the parenthesized arguments of a call.
+func (p *printer) callArgs(x *ast.CallExpr, depth int, multiLine *bool) {
+	p.print(x.Lparen, token.LPAREN);
+	p.exprList(x.Lparen, x.Args, depth, commaSep, multiLine);
+	p.print(x.Rparen, token.RPAREN);
+}
+
+
+// maxChainSize is the maximum size of a chain of method calls printed
+// on one line in BreakCallChains mode; adjust as appropriate, this is
+// an approximate value.
+const maxChainSize = 80
+
+
+// brokenChain returns the calls of the method call chain ending in x,
+// such as a.B().C().D(), if the chain is printed one call per line:
+// in BreakCallChains mode, if it has at least three calls and does not
+// fit on one line. The first call of the result includes the receiver
+// of the chain; each further call is a method call on the result of
+// the previous one. Otherwise, brokenChain returns nil.
+//
+func (p *printer) brokenChain(x *ast.CallExpr) []*ast.CallExpr {
+	if p.Mode&BreakCallChains == 0 {
+		return nil
+	}
+	n := 1;
+	for call := x; ; n++ {
+		sel, ok := call.Fun.(*ast.SelectorExpr);
+		if !ok {
+			break
+		}
+		if call, ok = sel.X.(*ast.CallExpr); !ok {
+			break
+		}
+	}
+	if n < 3 || p.nodeSize(x, maxChainSize) <= maxChainSize {
+		return nil
+	}
+	chain := make([]*ast.CallExpr, n);
+	call := x;
+	for i := n - 1; i > 0; i-- {
+		chain[i] = call;
+		call = call.Fun.(*ast.SelectorExpr).X.(*ast.CallExpr);
+	}
+	chain[0] = call;
+	return chain;
+}
+
+
+// callChain prints a method call chain as returned by brokenChain: the
+// first call on the line of the expression, and each further call on a
+// line of its own, indented, after the period of the selector.
+//
+func (p *printer) callChain(chain []*ast.CallExpr, depth int, multiLine *bool) {
+	first := chain[0];
+	p.expr1(first.Fun, token.HighestPrec, depth, 0, multiLine);
+	p.callArgs(first, depth, multiLine);
+	p.print(indent);
+	for _, x := range chain[1:len(chain)] {
+		sel := x.Fun.(*ast.SelectorExpr);
+		p.print(token.PERIOD, formfeed);
+		p.expr1(sel.Sel, token.HighestPrec, depth, 0, multiLine);
+		p.callArgs(x, depth, multiLine);
+	}
+	p.print(unindent);
+	*multiLine = true;
+}
+
+
 // Returns true if a separating semicolon is optional.
 // Sets multiLine to true if the expression spans multiple lines.
 func (p *printer) expr1(expr ast.Expr, prec1, depth int, ctxt exprContext, multiLine *bool) (optSemi bool) {
+	if p.nodes != nil {
+		p.beginNode(expr);
+		defer p.endNode(expr);
+	}
 	p.print(expr.Pos());
 
 	switch x := expr.(type) {
@@ -680,13 +808,15 @@ func (p *printer) expr1(expr ast.Expr, prec1, depth int, ctxt exprContext, multi
 		p.print(token.RBRACK);
 
 	case *ast.CallExpr:
+		if chain := p.brokenChain(x); chain != nil {
+			p.callChain(chain, depth, multiLine);
+			break;
+		}
 		if len(x.Args) > 1 {
 			depth++
 		}
 		p.expr1(x.Fun, token.HighestPrec, depth, 0, multiLine);
-		p.print(x.Lparen, token.LPAREN);
-		p.exprList(x.Lparen, x.Args, depth, commaSep, multiLine);
-		p.print(x.Rparen, token.RPAREN);
+		p.callArgs(x, depth, multiLine);
 
 	case *ast.CompositeLit:
 		p.expr1(x.Type, token.HighestPrec, depth, compositeLit, multiLine);
@@ -809,6 +939,15 @@ func (p *printer) block(s *ast.BlockStmt, indent int) {
 }
 
 
+// oneLineBlock prints an *ast.BlockStmt containing a single
+// statement on one line.
+func (p *printer) oneLineBlock(s *ast.BlockStmt) {
+	p.print(s.Pos(), token.LBRACE, blank);
+	p.stmt(s.List[0], ignoreMultiLine);
+	p.print(blank, s.Rbrace, token.RBRACE);
+}
+
+
 // TODO(gri): Decide if this should be used more broadly. The printing code
 //            knows when to insert parentheses for precedence reasons, but
 //            need to be careful to keep them around type expressions.
@@ -858,6 +997,10 @@ func (p *printer) controlClause(isForStmt bool, init ast.Stmt, expr ast.Expr, po
 // Returns true if a separating semicolon is optional.
 // Sets multiLine to true if the statements spans multiple lines.
 func (p *printer) stmt(stmt ast.Stmt, multiLine *bool) (optSemi bool) {
+	if p.nodes != nil {
+		p.beginNode(stmt);
+		defer p.endNode(stmt);
+	}
 	p.print(stmt.Pos());
 
 	switch s := stmt.(type) {
@@ -928,8 +1071,12 @@ func (p *printer) stmt(stmt ast.Stmt, multiLine *bool) (optSemi bool) {
 	case *ast.IfStmt:
 		p.print(token.IF);
 		p.controlClause(false, s.Init, s.Cond, nil);
-		p.block(s.Body, 1);
-		*multiLine = true;
+		if s.Else == nil && p.isOneLineBody(s.Body, len("if ")+p.clauseSize(s.Init, s.Cond, nil)) {
+			p.oneLineBlock(s.Body)
+		} else {
+			p.block(s.Body, 1);
+			*multiLine = true;
+		}
 		optSemi = true;
 		if s.Else != nil {
 			p.print(blank, token.ELSE, blank);
@@ -1010,8 +1157,12 @@ func (p *printer) stmt(stmt ast.Stmt, multiLine *bool) (optSemi bool) {
 	case *ast.ForStmt:
 		p.print(token.FOR);
 		p.controlClause(true, s.Init, s.Cond, s.Post);
-		p.block(s.Body, 1);
-		*multiLine = true;
+		if p.isOneLineBody(s.Body, len("for ")+p.clauseSize(s.Init, s.Cond, s.Post)) {
+			p.oneLineBlock(s.Body)
+		} else {
+			p.block(s.Body, 1);
+			*multiLine = true;
+		}
 		optSemi = true;
 
 	case *ast.RangeStmt:
@@ -1053,6 +1204,11 @@ const (
 // multiple lines.
 //
 func (p *printer) spec(spec ast.Spec, n int, context declContext, multiLine *bool) {
+	if p.nodes != nil {
+		p.beginNode(spec);
+		defer p.endNode(spec);
+	}
+
 	var (
 		optSemi		bool;			// true if a semicolon is optional
 		comment		*ast.CommentGroup;	// a line comment, if any
@@ -1186,17 +1342,57 @@ func (p *printer) nodeSize(n ast.Node, maxSize int) (size int) {
 }
 
 
+// maxOneLineSize is the maximum size of a function or statement
+// printed on one line together with its body; adjust as appropriate,
+// this is an approximate value.
+const maxOneLineSize = 90
+
+
 func (p *printer) isOneLineFunc(b *ast.BlockStmt, headerSize int) bool {
-	const maxSize = 90;	// adjust as appropriate, this is an approximate value
 	bodySize := 0;
 	switch {
 	case len(b.List) > 1 || p.commentBefore(b.Rbrace):
 		return false	// too many statements or there is a comment - all bets are off
 	case len(b.List) == 1:
-		bodySize = p.nodeSize(b.List[0], maxSize)
+		bodySize = p.nodeSize(b.List[0], maxOneLineSize)
 	}
 	// require both headers and overall size to be not "too large"
-	return headerSize <= maxSize/2 && headerSize+bodySize <= maxSize;
+	return headerSize <= maxOneLineSize/2 && headerSize+bodySize <= maxOneLineSize;
+}
+
+
+// clauseSize returns the approximate size of the control clause
+// of an if or for statement; see controlClause.
+//
+func (p *printer) clauseSize(init ast.Stmt, expr ast.Expr, post ast.Stmt) (size int) {
+	if init != nil {
+		size += p.nodeSize(init, maxOneLineSize) + len("; ")
+	}
+	if expr != nil {
+		size += p.nodeSize(expr, maxOneLineSize) + len(" ")
+	}
+	if post != nil {
+		size += p.nodeSize(post, maxOneLineSize) + len("; ")
+	}
+	return;
+}
+
+
+// isOneLineBody reports whether the body b of an if or for statement
+// whose header has the given size is printed on one line. This is only
+// the case in OneLineBodies mode, and if the body consists of a single
+// simple statement such that the statement meets the size limits for
+// one-line functions.
+//
+func (p *printer) isOneLineBody(b *ast.BlockStmt, headerSize int) bool {
+	if p.Mode&OneLineBodies == 0 || len(b.List) != 1 {
+		return false
+	}
+	switch b.List[0].(type) {
+	case *ast.ExprStmt, *ast.IncDecStmt, *ast.AssignStmt, *ast.ReturnStmt, *ast.BranchStmt:
+		return p.isOneLineFunc(b, headerSize)
+	}
+	return false;
 }
 
 
@@ -1260,6 +1456,11 @@ func (p *printer) funcDecl(d *ast.FuncDecl, multiLine *bool) {
 
 // Sets multiLine to true if the declaration spans multiple lines.
 func (p *printer) decl(decl ast.Decl, context declContext, multiLine *bool) {
+	if p.nodes != nil {
+		p.beginNode(decl);
+		defer p.endNode(decl);
+	}
+
 	switch d := decl.(type) {
 	case *ast.BadDecl:
 		p.print(d.Pos(), "BadDecl")
@@ -1291,6 +1492,11 @@ func declToken(decl ast.Decl) (tok token.Token) {
 
 
 func (p *printer) file(src *ast.File) {
+	if p.nodes != nil {
+		p.beginNode(src);
+		defer p.endNode(src);
+	}
+
 	p.leadComment(src.Doc);
 	p.print(src.Pos(), token.PACKAGE, blank);
 	p.expr(src.Name, ignoreMultiLine);
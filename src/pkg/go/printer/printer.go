@@ -7,6 +7,7 @@ package printer
 
 import (
 	"bytes";
+	"container/vector";
 	"fmt";
 	"go/ast";
 	"go/token";
@@ -90,6 +91,17 @@ type printer struct {
 
 	// The list of comments; or nil.
 	comment	*ast.CommentGroup;
+
+	// Node ranges (see ranges.go); nodes is nil if not requested
+	nodes		map[interface{}]Range;	// ranges in significant bytes
+	pendingNodes	vector.Vector;		// nodes waiting for their first token
+	nsig		int;			// number of significant bytes written
+
+	// ASCIIOnly support (see ascii.go)
+	nonASCII	*ast.Ident;	// first non-ASCII identifier printed; or nil
+
+	// VerifyComments support (see verify.go)
+	printed	map[*ast.Comment]bool;	// comments printed; nil if not verifying
 }
 
 
@@ -98,6 +110,9 @@ func (p *printer) init(output io.Writer, cfg *Config) {
 	p.Config = *cfg;
 	p.errors = make(chan os.Error);
 	p.buffer = make([]whiteSpace, 0, 16);	// whitespace sequences are short
+	if cfg.Mode&VerifyComments != 0 {
+		p.printed = make(map[*ast.Comment]bool)
+	}
 }
 
 
@@ -116,6 +131,9 @@ func (p *printer) internalError(msg ...) {
 func (p *printer) write0(data []byte) {
 	n, err := p.output.Write(data);
 	p.written += n;
+	if p.nodes != nil {
+		p.nsig += countSignificant(data[0:n])
+	}
 	if err != nil {
 		p.errors <- err;
 		runtime.Goexit();
@@ -406,6 +424,71 @@ func split(text []byte) [][]byte {
 }
 
 
+// Comment text is sanitized unless the PreserveComments mode is set,
+// so that files edited on different systems print the same way:
+// line endings are normalized, trailing whitespace is removed from
+// each line, and with UseSpaces, tabs are expanded.
+
+// normalizeLineEnds replaces "\r\n" and single '\r' line endings by '\n'.
+func normalizeLineEnds(text []byte) []byte {
+	if bytes.Index(text, []byte{'\r'}) < 0 {
+		return text	// common case
+	}
+	res := make([]byte, len(text));
+	n := 0;
+	for i := 0; i < len(text); i++ {
+		c := text[i];
+		if c == '\r' {
+			c = '\n';
+			if i+1 < len(text) && text[i+1] == '\n' {
+				i++
+			}
+		}
+		res[n] = c;
+		n++;
+	}
+	return res[0:n];
+}
+
+
+// expandTabs replaces the tabs in line by blanks, assuming tab stops
+// every tabwidth columns from the beginning of the line.
+func expandTabs(line []byte, tabwidth int) []byte {
+	if bytes.Index(line, []byte{'\t'}) < 0 {
+		return line	// common case
+	}
+	if tabwidth <= 0 {
+		tabwidth = 8
+	}
+	var buf bytes.Buffer;
+	col := 0;
+	for _, c := range line {
+		if c == '\t' {
+			for n := tabwidth - col%tabwidth; n > 0; n-- {
+				buf.WriteByte(' ')
+			}
+			col += tabwidth - col%tabwidth;
+			continue;
+		}
+		buf.WriteByte(c);
+		if c&0xc0 != 0x80 {
+			col++	// not a UTF-8 continuation byte
+		}
+	}
+	return buf.Bytes();
+}
+
+
+// sanitizeLine sanitizes a single line of comment text.
+func (p *printer) sanitizeLine(line []byte) []byte {
+	line = trimRight(line);
+	if p.Mode&UseSpaces != 0 {
+		line = expandTabs(line, p.Tabwidth)
+	}
+	return line;
+}
+
+
 func isBlank(s []byte) bool {
 	for _, b := range s {
 		if b > ' ' {
@@ -543,18 +626,37 @@ func stripCommonPrefix(lines [][]byte) {
 
 
 func (p *printer) writeComment(comment *ast.Comment) {
+	p.markPrinted(comment);
 	text := comment.Text;
+	sanitize := p.Mode&PreserveComments == 0;
 
 	// shortcut common case of //-style comments
 	if text[1] == '/' {
+		if sanitize {
+			// a '\r' must not start a new line here
+			text = p.sanitizeLine(bytes.Join(bytes.Split(text, []byte{'\r'}, 0), []byte{' '}))
+		}
 		p.writeCommentLine(comment, comment.Pos(), text);
 		return;
 	}
 
 	// for /*-style comments, print line by line and let the
 	// write function take care of the proper indentation
+	if sanitize {
+		text = normalizeLineEnds(text)
+	}
 	lines := split(text);
+	if sanitize {
+		for i, line := range lines {
+			lines[i] = trimRight(line)
+		}
+	}
 	stripCommonPrefix(lines);
+	if sanitize && p.Mode&UseSpaces != 0 {
+		for i, line := range lines {
+			lines[i] = expandTabs(line, p.Tabwidth)
+		}
+	}
 
 	// write comment lines, separated by formfeed,
 	// without a line break after the last line
@@ -704,6 +806,7 @@ func (p *printer) print(args ...) {
 		var data []byte;
 		var tag HTMLTag;
 		isKeyword := false;
+		isComment := false;
 		switch x := f.Interface().(type) {
 		case whiteSpace:
 			if x == ignore {
@@ -725,18 +828,25 @@ func (p *printer) print(args ...) {
 		case []byte:
 			// TODO(gri): remove this case once commentList
 			//            handles comments correctly
-			data = x
+			data = x;
+			isComment = true;
 		case string:
 			// TODO(gri): remove this case once fieldList
 			//            handles comments correctly
 			data = strings.Bytes(x)
 		case *ast.Ident:
+			if p.Mode&ASCIIOnly != 0 {
+				p.checkIdent(x)
+			}
 			if p.Styler != nil {
 				data, tag = p.Styler.Ident(x)
 			} else {
 				data = strings.Bytes(x.Value)
 			}
 		case *ast.BasicLit:
+			if p.Mode&ASCIIOnly != 0 {
+				x = asciiLit(x)
+			}
 			if p.Styler != nil {
 				data, tag = p.Styler.BasicLit(x)
 			} else {
@@ -771,6 +881,9 @@ func (p *printer) print(args ...) {
 			// at the end of a file)
 			p.writeNewlines(next.Line - p.pos.Line);
 
+			if !isComment && p.pendingNodes.Len() > 0 {
+				p.startNodes()
+			}
 			p.writeItem(next, data, tag);
 		}
 	}
@@ -893,6 +1006,11 @@ const (
 	GenHTML		uint	= 1 << iota;	// generate HTML
 	RawFormat;		// do not use a tabwriter; if set, UseSpaces is ignored
 	UseSpaces;		// use spaces instead of tabs for indentation and alignment
+	OneLineBodies;		// print short if and for statement bodies on one line
+	PreserveComments;	// print comment text as is; do not normalize its whitespace
+	ASCIIOnly;		// escape non-ASCII characters in literals; report non-ASCII identifiers
+	VerifyComments;		// report comments of the input that were not printed
+	BreakCallChains;	// print long chains of method calls one call per line
 )
 
 
@@ -928,6 +1046,26 @@ type Config struct {
 // ast.Decl, or ast.Stmt.
 //
 func (cfg *Config) Fprint(output io.Writer, node interface{}) (int, os.Error) {
+	var comments *ast.CommentGroup;
+	if n, ok := node.(*ast.File); ok {
+		comments = n.Comments
+	}
+	return cfg.fprint(output, node, comments, nil);
+}
+
+
+// fprint prints node to output, interspersing the comments of the given
+// comment list (which may be nil). If nodes is not nil, the output ranges
+// of the nodes printed are recorded in nodes.
+//
+func (cfg *Config) fprint(output io.Writer, node interface{}, comments *ast.CommentGroup, nodes map[interface{}]Range) (int, os.Error) {
+	// track output offsets if needed
+	var ow *offsetWriter;
+	if nodes != nil {
+		ow = &offsetWriter{output: output};
+		output = ow;
+	}
+
 	// redirect output through a trimmer to eliminate trailing whitespace
 	// (Input to a tabwriter must be untrimmed since trailing tabs provide
 	// formatting information. The tabwriter could provide trimming
@@ -952,6 +1090,8 @@ func (cfg *Config) Fprint(output io.Writer, node interface{}) (int, os.Error) {
 	// setup printer and print node
 	var p printer;
 	p.init(output, cfg);
+	p.comment = comments;
+	p.nodes = nodes;
 	go func() {
 		switch n := node.(type) {
 		case ast.Expr:
@@ -961,8 +1101,7 @@ func (cfg *Config) Fprint(output io.Writer, node interface{}) (int, os.Error) {
 		case ast.Decl:
 			p.decl(n, atTop, ignoreMultiLine)
 		case *ast.File:
-			p.comment = n.Comments;
-			p.file(n);
+			p.file(n)
 		default:
 			p.errors <- os.NewError(fmt.Sprintf("printer.Fprint: unsupported node type %T", n));
 			runtime.Goexit();
@@ -971,16 +1110,190 @@ func (cfg *Config) Fprint(output io.Writer, node interface{}) (int, os.Error) {
 		p.errors <- nil;						// no errors
 	}();
 	err := <-p.errors;	// wait for completion of goroutine
+	if err == nil {
+		err = p.nonASCIIError()
+	}
+	if err == nil {
+		err = p.commentError(node, comments)
+	}
 
 	// flush tabwriter, if any
 	if tw != nil {
 		tw.Flush()	// ignore errors
 	}
 
+	// convert node ranges into output offsets
+	if nodes != nil {
+		for n, r := range nodes {
+			start := ow.offset(r.Start);
+			end := start;
+			if r.End > r.Start {
+				end = ow.offset(r.End-1) + 1
+			}
+			nodes[n] = Range{start, end};
+		}
+	}
+
 	return p.written, err;
 }
 
 
+// FprintRange is like Fprint for a complete source file, except that only
+// the top-level declarations overlapping the (1-based, inclusive) source line
+// range [from, to] are reformatted. All other text, including the whitespace
+// between declarations, is copied verbatim from src, which must be the source
+// text file was parsed from. To preserve the comments inside reformatted
+// declarations, file must have been parsed with comments. FprintRange returns
+// the number of bytes written and an error, if any.
+//
+func (cfg *Config) FprintRange(output io.Writer, src []byte, file *ast.File, from, to int) (int, os.Error) {
+	written := 0;
+	offs := 0;	// src[0:offs] has been written
+	for i, d := range file.Decls {
+		start, end := declExtent(src, file.Decls, i);
+		line := declLine(d);
+		if line > to {
+			break
+		}
+		if line+bytes.Count(src[start:end], newlines[0:1]) < from {
+			continue
+		}
+
+		// write source text preceeding the declaration
+		n, err := output.Write(src[offs:start]);
+		written += n;
+		if err != nil {
+			return written, err
+		}
+		offs = end;
+
+		// reformat the declaration; trailing whitespace is
+		// not part of the declaration and comes from src
+		var buf bytes.Buffer;
+		if _, err := cfg.fprint(&buf, d, rangeComments(file.Comments, start, end), nil); err != nil {
+			return written, err
+		}
+		n, err = output.Write(trimRight(buf.Bytes()));
+		written += n;
+		if err != nil {
+			return written, err
+		}
+	}
+
+	// write remaining source text
+	n, err := output.Write(src[offs:len(src)]);
+	return written + n, err;
+}
+
+
+// FprintDecl is like Fprint for the top-level declaration d of file, except
+// that the comments inside d are printed, too: file must have been parsed
+// with comments from src, the source text. The comments following d up to
+// the next declaration are not printed. FprintDecl returns the number of
+// bytes written and an error, if any.
+//
+func (cfg *Config) FprintDecl(output io.Writer, src []byte, file *ast.File, d ast.Decl) (int, os.Error) {
+	for i, x := range file.Decls {
+		if x == d {
+			start, end := declExtent(src, file.Decls, i);
+			var buf bytes.Buffer;
+			if _, err := cfg.fprint(&buf, d, rangeComments(file.Comments, start, end), nil); err != nil {
+				return 0, err
+			}
+			return output.Write(trimRight(buf.Bytes()));
+		}
+	}
+	return 0, os.ErrorString("go/printer: declaration not in file");
+}
+
+
+// declStart returns the source offset of the declaration d,
+// including its documentation comment, if any.
+//
+func declStart(d ast.Decl) int {
+	switch d := d.(type) {
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			return d.Doc.List[0].Offset
+		}
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			return d.Doc.List[0].Offset
+		}
+	}
+	return d.Pos().Offset;
+}
+
+
+// declLine returns the line of the first source line of the
+// declaration d, including its documentation comment, if any.
+//
+func declLine(d ast.Decl) int {
+	switch d := d.(type) {
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			return d.Doc.List[0].Line
+		}
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			return d.Doc.List[0].Line
+		}
+	}
+	return d.Pos().Line;
+}
+
+
+// declExtent returns the source range src[start:end] of the i'th declaration
+// in list. A declaration extends up to the next declaration (or the end of
+// src), excluding any trailing whitespace.
+//
+func declExtent(src []byte, list []ast.Decl, i int) (start, end int) {
+	start = declStart(list[i]);
+	end = len(src);
+	if i+1 < len(list) {
+		end = declStart(list[i+1])
+	}
+	for end > start && src[end-1] <= ' ' {
+		end--
+	}
+	return;
+}
+
+
+// rangeComments returns a copy of the comment groups in list
+// which start within the source range [start, end).
+//
+func rangeComments(list *ast.CommentGroup, start, end int) *ast.CommentGroup {
+	var first, last *ast.CommentGroup;
+	for g := list; g != nil; g = g.Next {
+		offs := g.List[0].Offset;
+		if offs >= end {
+			break
+		}
+		if offs >= start {
+			c := &ast.CommentGroup{g.List, nil};
+			if first == nil {
+				first = c
+			} else {
+				last.Next = c
+			}
+			last = c;
+		}
+	}
+	return first;
+}
+
+
+// trimRight returns s without trailing whitespace.
+func trimRight(s []byte) []byte {
+	i := len(s);
+	for i > 0 && s[i-1] <= ' ' {
+		i--
+	}
+	return s[0:i];
+}
+
+
 // Fprint "pretty-prints" an AST node to output.
 // It calls Config.Fprint with default settings.
 //
@@ -11,6 +11,7 @@ import (
 	"go/ast";
 	"go/parser";
 	"path";
+	"strings";
 	"testing";
 )
 
@@ -132,3 +133,571 @@ func Test(t *testing.T) {
 		//check(t, golden, golden, e.mode);
 	}
 }
+
+
+const rangeSrc = `package p
+
+func f() {
+x:=1
+}
+
+// g is reformatted.
+func g() {
+	// comment
+x:=2
+}
+
+func h() {
+x:=3
+}
+`
+
+const rangeGolden = `package p
+
+func f() {
+x:=1
+}
+
+// g is reformatted.
+func g() {
+	// comment
+	x := 2
+}
+
+func h() {
+x:=3
+}
+`
+
+
+func TestFprintRange(t *testing.T) {
+	src := strings.Bytes(rangeSrc);
+	prog, err := parser.ParseFile("range.go", src, parser.ParseComments);
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// line 10 is inside g
+	var buf bytes.Buffer;
+	cfg := Config{Tabwidth: tabwidth};
+	n, err := cfg.FprintRange(&buf, src, prog, 10, 10);
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != buf.Len() {
+		t.Errorf("n = %d, expected %d", n, buf.Len())
+	}
+	if res := buf.String(); res != rangeGolden {
+		t.Errorf("got:\n%s\nexpected:\n%s", res, rangeGolden)
+	}
+
+	// a range outside of any declaration leaves the source unchanged
+	buf.Reset();
+	if _, err := cfg.FprintRange(&buf, src, prog, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if res := buf.String(); res != rangeSrc {
+		t.Errorf("got:\n%s\nexpected:\n%s", res, rangeSrc)
+	}
+}
+
+
+const declGolden = `// g is reformatted.
+func g() {
+	// comment
+	x := 2
+}`
+
+
+func TestFprintDecl(t *testing.T) {
+	src := strings.Bytes(rangeSrc);
+	prog, err := parser.ParseFile("range.go", src, parser.ParseComments);
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer;
+	cfg := Config{Tabwidth: tabwidth};
+	if _, err := cfg.FprintDecl(&buf, src, prog, prog.Decls[1]); err != nil {
+		t.Fatal(err)
+	}
+	if res := buf.String(); res != declGolden {
+		t.Errorf("got:\n%s\nexpected:\n%s", res, declGolden)
+	}
+
+	// a declaration of another file is rejected
+	other, _ := parser.ParseFile("other.go", "package p; func g() {}", 0);
+	if _, err := cfg.FprintDecl(&buf, src, prog, other.Decls[0]); err == nil {
+		t.Errorf("FprintDecl of a declaration of another file succeeded")
+	}
+}
+
+
+const profileSrc = `
+# project formatting profile
+tabwidth = 4
+spaces = true
+`
+
+
+func TestReadConfig(t *testing.T) {
+	cfg, mask, err := ReadConfig(bytes.NewBufferString(profileSrc));
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Tabwidth != 4 || cfg.Mode != UseSpaces || mask != UseSpaces {
+		t.Errorf("got tabwidth %d, mode %d, mask %d; expected 4, %d, %d", cfg.Tabwidth, cfg.Mode, mask, UseSpaces, UseSpaces)
+	}
+
+	// a written profile reads back the same settings
+	var buf bytes.Buffer;
+	if err := WriteConfig(&buf, &Config{GenHTML | RawFormat, 2, nil}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err = ReadConfig(&buf);
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Tabwidth != 2 || cfg.Mode != GenHTML|RawFormat {
+		t.Errorf("got tabwidth %d, mode %d; expected 2, %d", cfg.Tabwidth, cfg.Mode, GenHTML|RawFormat)
+	}
+
+	// profile settings override defaults, both on and off;
+	// the mode flags the profile does not mention are kept
+	cfg, mask, err = ReadConfig(bytes.NewBufferString("spaces = false\nhtml = true\n"));
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := Config{RawFormat | UseSpaces, 8, nil};
+	base.Merge(cfg, mask);
+	if base.Tabwidth != 8 || base.Mode != GenHTML|RawFormat {
+		t.Errorf("Merge: got tabwidth %d, mode %d; expected 8, %d", base.Tabwidth, base.Mode, GenHTML|RawFormat)
+	}
+
+	for _, src := range []string{"tabwidth", "tabwidth = -1", "spaces = yes", "width = 80"} {
+		if _, _, err := ReadConfig(bytes.NewBufferString(src)); err == nil {
+			t.Errorf("%q: expected error", src)
+		}
+	}
+}
+
+
+const nodesSrc = `// comment
+
+package p
+
+// Doc comment.
+const (
+	a = 1;	// a
+	bbbbbb = "two";
+)
+
+func f(x int) int {
+	if x > a { return x*2 }
+	return len(bbbbbb);
+}
+`
+
+
+func TestFprintNodes(t *testing.T) {
+	prog, err := parser.ParseFile("nodes.go", nodesSrc, parser.ParseComments);
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer;
+	cfg := Config{Tabwidth: tabwidth};
+	_, nodes, err := cfg.FprintNodes(&buf, prog);
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := buf.String();
+
+	// the output must be the same as the output of Fprint
+	var buf2 bytes.Buffer;
+	cfg.Fprint(&buf2, prog);
+	if res != buf2.String() {
+		t.Errorf("got:\n%s\nexpected:\n%s", res, buf2.String())
+	}
+
+	check := func(node interface{}, prefix, suffix string) {
+		r, found := nodes[node];
+		if !found {
+			t.Errorf("no range for %T", node);
+			return;
+		}
+		if r.Start < 0 || r.Start > r.End || r.End > len(res) {
+			t.Errorf("invalid range %v for %T", r, node);
+			return;
+		}
+		s := res[r.Start:r.End];
+		if !strings.HasPrefix(s, prefix) || !strings.HasSuffix(s, suffix) {
+			t.Errorf("range %v for %T is %q, expected %q...%q", r, node, s, prefix, suffix)
+		}
+	}
+
+	check(prog, "package", "}");
+	for _, d := range prog.Decls {
+		switch d := d.(type) {
+		case *ast.GenDecl:
+			check(d, "const", ")");
+			for _, s := range d.Specs {
+				s := s.(*ast.ValueSpec);
+				check(s, s.Names[0].Value, "");
+				check(s.Names[0], s.Names[0].Value, s.Names[0].Value);
+				v := s.Values[0].(*ast.BasicLit);
+				check(v, string(v.Value), string(v.Value));
+			}
+		case *ast.FuncDecl:
+			check(d, "func f", "}");
+			check(d.Name, "f", "f");
+			check(d.Body.List[0], "if x > a", "}");
+			check(d.Body.List[1], "return len", "(bbbbbb)");
+		}
+	}
+}
+
+
+
+const oneLineSrc = `package p
+
+func f(x int) int {
+	if x < 0 { return -x }
+	for x > 10 { x /= 2 }
+	if x == 3 {
+		x++;
+		x++;
+	}
+	if x == 4 { x++ } else { x-- }
+	return x;
+}
+`
+
+const multiLineSrc = `package p
+
+func f(x int) int {
+	if x < 0 {
+		return -x
+	}
+	for x > 10 {
+		x /= 2
+	}
+	if x == 3 {
+		x++;
+		x++;
+	}
+	if x == 4 {
+		x++
+	} else {
+		x--
+	}
+	return x;
+}
+`
+
+// if statements with else branches are never compacted
+const compactSrc = `package p
+
+func f(x int) int {
+	if x < 0 { return -x }
+	for x > 10 { x /= 2 }
+	if x == 3 {
+		x++;
+		x++;
+	}
+	if x == 4 {
+		x++
+	} else {
+		x--
+	}
+	return x;
+}
+`
+
+
+func TestOneLineBodies(t *testing.T) {
+	prog, err := parser.ParseFile("src", oneLineSrc, 0);
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []uint{0, OneLineBodies} {
+		expected := multiLineSrc;
+		if mode == OneLineBodies {
+			expected = compactSrc
+		}
+		var buf bytes.Buffer;
+		cfg := Config{mode, tabwidth, nil};
+		if _, err := cfg.Fprint(&buf, prog); err != nil {
+			t.Fatal(err)
+		}
+		if res := buf.String(); res != expected {
+			t.Errorf("mode %d: got:\n%s\nexpected:\n%s", mode, res, expected)
+		}
+	}
+}
+
+
+// comments with carriage returns and trailing whitespace
+const crSrc = "package p\r\n\r\n// line comment \t\r\nvar x int\r\n\r\n/* general\t\r\n   comment  \r\n*/\r\nvar y int\r\n"
+
+func TestCommentWhitespace(t *testing.T) {
+	prog, err := parser.ParseFile("src", crSrc, parser.ParseComments);
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []uint{0, PreserveComments} {
+		var buf bytes.Buffer;
+		cfg := Config{mode, tabwidth, nil};
+		if _, err := cfg.Fprint(&buf, prog); err != nil {
+			t.Fatal(err)
+		}
+		res := buf.String();
+		if mode == PreserveComments {
+			if strings.Index(res, "\r") < 0 {
+				t.Errorf("mode %d: carriage returns not preserved:\n%q", mode, res)
+			}
+			continue;
+		}
+		if strings.Index(res, "\r") >= 0 {
+			t.Errorf("mode %d: carriage return in output:\n%q", mode, res)
+		}
+		for _, line := range strings.Split(res, "\n", 0) {
+			if n := len(line); n > 0 && (line[n-1] == ' ' || line[n-1] == '\t') {
+				t.Errorf("mode %d: trailing whitespace in %q", mode, line)
+			}
+		}
+	}
+}
+
+
+const fieldSrc = `package p
+type T struct {
+	a int;	// a
+	b string;	// b
+	c map[string]func(x, y int) (chan<- []byte, os.Error);	// c
+	d int;	// d
+	e string;	// e
+}
+`
+
+func TestFieldAlignment(t *testing.T) {
+	prog, err := parser.ParseFile("src", fieldSrc, parser.ParseComments);
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer;
+	cfg := Config{UseSpaces, tabwidth, nil};
+	if _, err := cfg.Fprint(&buf, prog); err != nil {
+		t.Fatal(err)
+	}
+	// the comments of the fields with short types must be aligned
+	// with each other, but not with the one of the long type
+	cols := make(map[string]int);
+	for _, line := range strings.Split(buf.String(), "\n", 0) {
+		if i := strings.Index(line, "// "); i >= 0 {
+			cols[line[i+3:len(line)]] = i
+		}
+	}
+	if cols["a"] != cols["b"] || cols["d"] != cols["e"] || cols["a"] != cols["d"] {
+		t.Errorf("short field comments not aligned:\n%s", buf.String())
+	}
+	if cols["a"] >= cols["c"] {
+		t.Errorf("long field type widens alignment:\n%s", buf.String())
+	}
+}
+
+
+const asciiSrc = "package p\n\nconst (\n\ta = \"caf\u00e9 \\\\ \\xff\";\n\tb = '\u00e9';\n\tc = `\u65e5\u672c\n\"x\"`;\n\td = \"\U0001d11e\";\n)\n"
+
+var asciiLits = []string{
+	`"caf\u00e9 \\ \xff"`,
+	`'\u00e9'`,
+	`"\u65e5\u672c\n\"x\""`,
+	`"\U0001d11e"`,
+}
+
+
+func TestASCIIOnly(t *testing.T) {
+	prog, err := parser.ParseFile("src", asciiSrc, 0);
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer;
+	cfg := Config{ASCIIOnly, tabwidth, nil};
+	if _, err := cfg.Fprint(&buf, prog); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String();
+	for i := 0; i < len(out); i++ {
+		if out[i] >= 0x80 {
+			t.Fatalf("non-ASCII output:\n%s", out)
+		}
+	}
+	for _, lit := range asciiLits {
+		if strings.Index(out, lit) < 0 {
+			t.Errorf("missing literal %s in output:\n%s", lit, out)
+		}
+	}
+
+	// non-ASCII identifiers are printed but reported
+	prog, err = parser.ParseFile("src", "package p\n\nvar caf\u00e9 int\n", 0);
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset();
+	if _, err := cfg.Fprint(&buf, prog); err == nil {
+		t.Errorf("non-ASCII identifier not reported")
+	}
+}
+
+
+const verifySrc = `package p
+
+// F is documented.
+func F() {
+	x := 1;	// line comment
+	/* block comment */
+	_ = x;
+}
+`
+
+
+func TestVerifyComments(t *testing.T) {
+	prog, err := parser.ParseFile("src", verifySrc, parser.ParseComments);
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer;
+	cfg := Config{VerifyComments, tabwidth, nil};
+	if _, err := cfg.Fprint(&buf, prog); err != nil {
+		t.Errorf("unexpected error: %s\n%s", err, buf.Bytes())
+	}
+
+	// a documentation comment not in the comment list is not printed
+	// while the comments of the list are interspersed, and is reported
+	prog.Decls[0].(*ast.FuncDecl).Doc = &ast.CommentGroup{List: []*ast.Comment{&ast.Comment{Text: strings.Bytes("// lost")}}};
+	buf.Reset();
+	if _, err := cfg.Fprint(&buf, prog); err == nil || strings.Index(err.String(), "lost") < 0 {
+		t.Errorf("dropped comment not reported: %v\n%s", err, buf.Bytes())
+	}
+}
+
+
+const chainSrc = `package p
+
+func f() {
+	b.Name("printer").Option("tabwidth", 8).Option("spaces", true).Option("html", false).Build();
+	b.Name("p").Build().Run();
+	b.Option("a long option name that does not fit on the line", 1).Option("another", 2);
+}
+`
+
+// only long chains of at least three calls are broken
+const brokenChainSrc = `package p
+
+func f() {
+	b.Name("printer").
+		Option("tabwidth", 8).
+		Option("spaces", true).
+		Option("html", false).
+		Build();
+	b.Name("p").Build().Run();
+	b.Option("a long option name that does not fit on the line", 1).Option("another", 2);
+}
+`
+
+
+func TestBreakCallChains(t *testing.T) {
+	prog, err := parser.ParseFile("src", chainSrc, 0);
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []uint{0, BreakCallChains} {
+		expected := chainSrc;
+		if mode == BreakCallChains {
+			expected = brokenChainSrc
+		}
+		var buf bytes.Buffer;
+		cfg := Config{mode, tabwidth, nil};
+		if _, err := cfg.Fprint(&buf, prog); err != nil {
+			t.Fatal(err)
+		}
+		if res := buf.String(); res != expected {
+			t.Errorf("mode %d: got:\n%s\nexpected:\n%s", mode, res, expected)
+		}
+	}
+
+	// the layout does not depend on the line breaks of the source
+	prog, err = parser.ParseFile("src", brokenChainSrc, 0);
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer;
+	cfg := Config{BreakCallChains, tabwidth, nil};
+	if _, err := cfg.Fprint(&buf, prog); err != nil {
+		t.Fatal(err)
+	}
+	if res := buf.String(); res != brokenChainSrc {
+		t.Errorf("got:\n%s\nexpected:\n%s", res, brokenChainSrc)
+	}
+}
+
+
+var roundTripSrcs = []string{
+	"package p\n",
+	"package p\n\nimport \"fmt\"\n\nfunc main()\t{ fmt.Println(\"hello\") }\n",
+	`package p
+
+// T is a type.
+type T struct {
+	a, b	int;	// fields
+	c	[]string;
+}
+
+var x = []T{T{1, 2, nil}, T{a: 3}}
+var m = map[string]int{"a": 1, "b": 2}
+
+/* f returns
+   the sum. */
+func f(t *T) int {
+	switch {
+	case t == nil:
+		return 0
+	}
+	return t.a + t.b;	// trailing comment
+}
+`,
+}
+
+
+func TestRoundTrip(t *testing.T) {
+	cfg := Config{Tabwidth: tabwidth};
+	for i, src := range roundTripSrcs {
+		if _, err := cfg.RoundTrip("src", strings.Bytes(src)); err != nil {
+			t.Errorf("#%d: %s", i, err)
+		}
+	}
+
+	// source that does not parse is reported with the parse error
+	_, err := cfg.RoundTrip("src", strings.Bytes("package p; func"));
+	if _, ok := err.(*RoundTripError); ok || err == nil {
+		t.Errorf("RoundTrip of invalid source: got %v, expected a parse error", err)
+	}
+}
+
+
+// TestRoundTripMutations runs RoundTrip as a fuzz target on the
+// variants of a source with one line removed; the variants that
+// still parse must round-trip.
+func TestRoundTripMutations(t *testing.T) {
+	cfg := Config{Tabwidth: tabwidth};
+	lines := strings.SplitAfter(roundTripSrcs[2], "\n", 0);
+	for i := range lines {
+		src := strings.Join(lines[0:i], "") + strings.Join(lines[i+1:len(lines)], "");
+		if _, err := cfg.RoundTrip("src", strings.Bytes(src)); err != nil {
+			if _, ok := err.(*RoundTripError); ok {
+				t.Errorf("without line %d: %s", i+1, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Printer configuration profiles.
+
+package printer
+
+import (
+	"bytes";
+	"fmt";
+	"io";
+	"os";
+	"strconv";
+	"strings";
+)
+
+
+// A configuration profile is a text file specifying the settings of a
+// printer Config, one setting per line, of the form
+//
+//	name = value
+//
+// Blank lines and lines starting with '#' are ignored. The settings are:
+//
+//	tabwidth	tab width (an integer >= 0)
+//	html		generate HTML (true or false)
+//	raw		do not use a tabwriter (true or false)
+//	spaces		use spaces instead of tabs (true or false)
+//	onelinebodies	print short if and for bodies on one line (true or false)
+//	preservecomments	print comment text unchanged (true or false)
+//	breakcallchains	print long method call chains one call per line (true or false)
+//
+// Settings not present in a profile keep their default values.
+// Profiles permit projects to keep their formatting settings under
+// version control and tools to share them.
+
+
+type modeFlag struct {
+	name	string;
+	mode	uint;
+}
+
+var modeFlags = []modeFlag{
+	modeFlag{"html", GenHTML},
+	modeFlag{"raw", RawFormat},
+	modeFlag{"spaces", UseSpaces},
+	modeFlag{"onelinebodies", OneLineBodies},
+	modeFlag{"preservecomments", PreserveComments},
+	modeFlag{"breakcallchains", BreakCallChains},
+}
+
+
+// A ProfileError describes a malformed line of a configuration profile.
+type ProfileError struct {
+	Line	int;	// line number, starting at 1
+	Msg	string;
+}
+
+
+func (e *ProfileError) String() string	{ return fmt.Sprintf("line %d: %s", e.Line, e.Msg) }
+
+
+func parseBool(s string) (value, ok bool) {
+	switch s {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return;
+}
+
+
+// ReadConfig reads a configuration profile from r and returns the
+// corresponding Config, and in mask the mode flags the profile sets,
+// whether to true or to false; see Merge. The Styler of the result
+// is always nil.
+//
+func ReadConfig(r io.Reader) (cfg *Config, mask uint, err os.Error) {
+	src, err := io.ReadAll(r);
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cfg = new(Config);
+	for i, line := range strings.Split(string(src), "\n", 0) {
+		line = strings.TrimSpace(line);
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		j := strings.Index(line, "=");
+		if j < 0 {
+			return nil, 0, &ProfileError{i + 1, "missing '='"}
+		}
+		name := strings.TrimSpace(line[0:j]);
+		value := strings.TrimSpace(line[j+1 : len(line)]);
+
+		if name == "tabwidth" {
+			n, err := strconv.Atoi(value);
+			if err != nil || n < 0 {
+				return nil, 0, &ProfileError{i + 1, "invalid tabwidth " + strconv.Quote(value)}
+			}
+			cfg.Tabwidth = n;
+			continue;
+		}
+
+		found := false;
+		for _, f := range modeFlags {
+			if name == f.name {
+				set, ok := parseBool(value);
+				if !ok {
+					return nil, 0, &ProfileError{i + 1, "invalid value " + strconv.Quote(value) + " for " + name}
+				}
+				mask |= f.mode;
+				if set {
+					cfg.Mode |= f.mode
+				} else {
+					cfg.Mode &^= f.mode
+				}
+				found = true;
+				break;
+			}
+		}
+		if !found {
+			return nil, 0, &ProfileError{i + 1, "unknown setting " + strconv.Quote(name)}
+		}
+	}
+
+	return cfg, mask, nil;
+}
+
+
+// WriteConfig writes the settings of cfg to w as a configuration
+// profile that can be read back with ReadConfig. The Styler of cfg
+// cannot be represented and is ignored.
+//
+func WriteConfig(w io.Writer, cfg *Config) os.Error {
+	var buf bytes.Buffer;
+	fmt.Fprintf(&buf, "tabwidth = %d\n", cfg.Tabwidth);
+	for _, f := range modeFlags {
+		fmt.Fprintf(&buf, "%s = %v\n", f.name, cfg.Mode&f.mode != 0)
+	}
+	_, err := w.Write(buf.Bytes());
+	return err;
+}
+
+
+// Merge overlays the settings of other onto cfg: the mode flags in
+// mask are set or cleared as in other, the other mode flags of cfg
+// are kept, and a non-zero tab width or a non-nil Styler of other
+// replaces the respective setting of cfg. This makes it possible to
+// combine a shared profile, with the mask returned by ReadConfig, with
+// settings provided by a tool, such as command-line flags.
+//
+func (cfg *Config) Merge(other *Config, mask uint) {
+	if other == nil {
+		return
+	}
+	cfg.Mode = cfg.Mode&^mask | other.Mode&mask;
+	if other.Tabwidth != 0 {
+		cfg.Tabwidth = other.Tabwidth
+	}
+	if other.Styler != nil {
+		cfg.Styler = other.Styler
+	}
+}
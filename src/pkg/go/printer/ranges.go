@@ -0,0 +1,153 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the mapping from AST nodes to the byte
+// ranges of their formatted text in the printer output.
+//
+// The printer output passes through a tabwriter and a trimmer before
+// it reaches its destination; both change the amount of whitespace
+// but neither changes any other byte. Thus the printer records the
+// extent of a node as the indices of the first and last non-whitespace
+// ("significant") bytes of the node, and an offsetWriter at the end
+// of the output chain maps those indices to output offsets.
+
+package printer
+
+import (
+	"container/vector";
+	"go/ast";
+	"io";
+	"os";
+	"tabwriter";
+)
+
+
+// A Range describes the formatted text of a node in the output
+// of FprintNodes: the text of the node is output[Start:End].
+type Range struct {
+	Start, End int;
+}
+
+
+func isSignificant(b byte) bool {
+	switch b {
+	case ' ', '\t', '\n', '\f', '\v', tabwriter.Escape:
+		return false
+	}
+	return true;
+}
+
+
+// countSignificant returns the number of significant bytes in data.
+func countSignificant(data []byte) (n int) {
+	for _, b := range data {
+		if isSignificant(b) {
+			n++
+		}
+	}
+	return;
+}
+
+
+// beginNode marks the beginning of node n. The start of the range
+// of n is determined when the next token is written (see print), so
+// that comments preceeding the node are not part of its range.
+//
+func (p *printer) beginNode(n interface{}) {
+	p.pendingNodes.Push(n)
+}
+
+
+// endNode marks the end of node n.
+func (p *printer) endNode(n interface{}) {
+	if p.pendingNodes.Len() > 0 && p.pendingNodes.Last() == n {
+		// no token was written for n
+		p.pendingNodes.Pop();
+		p.nodes[n] = Range{p.nsig, p.nsig};
+		return;
+	}
+	r := p.nodes[n];
+	r.End = p.nsig;
+	p.nodes[n] = r;
+}
+
+
+// startNodes sets the start of the ranges of all pending nodes.
+func (p *printer) startNodes() {
+	for i := 0; i < p.pendingNodes.Len(); i++ {
+		p.nodes[p.pendingNodes.At(i)] = Range{p.nsig, p.nsig}
+	}
+	p.pendingNodes.Init(0);
+}
+
+
+// An offsetWriter writes to output and records the offsets of
+// significant bytes in the output, in runs of consecutive
+// significant bytes.
+//
+type offsetWriter struct {
+	output		io.Writer;
+	written		int;		// number of bytes written
+	nsig		int;		// number of significant bytes written
+	inRun		bool;		// true if the last byte written was significant
+	runIndex	vector.IntVector;	// significant byte index of the start of each run
+	runOffset	vector.IntVector;	// output offset of the start of each run
+}
+
+
+func (w *offsetWriter) Write(data []byte) (n int, err os.Error) {
+	n, err = w.output.Write(data);
+	for _, b := range data[0:n] {
+		if isSignificant(b) {
+			if !w.inRun {
+				w.runIndex.Push(w.nsig);
+				w.runOffset.Push(w.written);
+				w.inRun = true;
+			}
+			w.nsig++;
+		} else {
+			w.inRun = false
+		}
+		w.written++;
+	}
+	return;
+}
+
+
+// offset returns the output offset of the significant byte with index i,
+// or the total number of bytes written if there is no such byte.
+func (w *offsetWriter) offset(i int) int {
+	if i >= w.nsig {
+		return w.written
+	}
+	// find the last run starting at or before i
+	lo, hi := 0, w.runIndex.Len();
+	for hi-lo > 1 {
+		m := (lo + hi) / 2;
+		if w.runIndex.At(m) <= i {
+			lo = m
+		} else {
+			hi = m
+		}
+	}
+	return w.runOffset.At(lo) + i - w.runIndex.At(lo);
+}
+
+
+// FprintNodes is like Fprint, but in addition it returns a map from the
+// nodes printed to the ranges of their formatted text in the output.
+// The map contains an entry for each expression, statement, specification,
+// and declaration node printed, and for the node itself. Comments preceeding
+// a node, such as the doc comment of a declaration, are not part of its range;
+// comments within a node are.
+//
+func (cfg *Config) FprintNodes(output io.Writer, node interface{}) (int, map[interface{}]Range, os.Error) {
+	var comments *ast.CommentGroup;
+	if n, ok := node.(*ast.File); ok {
+		comments = n.Comments
+	}
+	nodes := make(map[interface{}]Range);
+	n, err := cfg.fprint(output, node, comments, nodes);
+	return n, nodes, err;
+}
@@ -0,0 +1,181 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements RoundTrip, a check of the printer for tests.
+//
+// Source that parses must print as source that parses again into the
+// same AST, except for positions, and printing that AST must produce
+// the same output again. RoundTrip checks both for a given source, so
+// that tests can run it on golden files and on generated or mutated
+// input: it returns the parse error for input that does not parse,
+// which a driver feeding it arbitrary bytes skips, and a *RoundTripError
+// for any other failure, which is a printer bug.
+
+package printer
+
+import (
+	"bytes";
+	"fmt";
+	"go/ast";
+	"go/parser";
+	"go/token";
+	"os";
+	"reflect";
+)
+
+
+// A RoundTripError describes a printer bug found by RoundTrip.
+type RoundTripError struct {
+	Phase	string;	// "print", "reparse", "compare", or "reprint"
+	Msg	string;	// description of the failure
+	Output	[]byte;	// printer output of the failed phase, if any
+}
+
+
+func (e *RoundTripError) String() string {
+	return "printer.RoundTrip: " + e.Phase + ": " + e.Msg
+}
+
+
+var (
+	positionType	= reflect.Typeof(token.Position{});
+	commentsType	= reflect.Typeof((*ast.CommentGroup)(nil));
+)
+
+
+// diff returns a description of the first difference between the
+// AST values x and y found at path, or "" if there is none. Positions
+// are ignored, and so are comments, which are compared separately.
+func diff(path string, x, y reflect.Value) string {
+	if x.Type() != y.Type() {
+		return fmt.Sprintf("%s: %s != %s", path, x.Type(), y.Type())
+	}
+	switch x := x.(type) {
+	case *reflect.PtrValue:
+		y := y.(*reflect.PtrValue);
+		if x.IsNil() || y.IsNil() {
+			if x.IsNil() != y.IsNil() {
+				return path + ": nil mismatch"
+			}
+			return "";
+		}
+		return diff(path, x.Elem(), y.Elem());
+
+	case *reflect.InterfaceValue:
+		y := y.(*reflect.InterfaceValue);
+		if x.IsNil() || y.IsNil() {
+			if x.IsNil() != y.IsNil() {
+				return path + ": nil mismatch"
+			}
+			return "";
+		}
+		return diff(path, x.Elem(), y.Elem());
+
+	case *reflect.StructValue:
+		y := y.(*reflect.StructValue);
+		t := x.Type().(*reflect.StructType);
+		for i := 0; i < x.NumField(); i++ {
+			f := t.Field(i);
+			if f.Type == positionType || f.Type == commentsType {
+				continue
+			}
+			if d := diff(path+"."+f.Name, x.Field(i), y.Field(i)); d != "" {
+				return d
+			}
+		}
+
+	case *reflect.SliceValue:
+		y := y.(*reflect.SliceValue);
+		if x.Len() != y.Len() {
+			return fmt.Sprintf("%s: length %d != %d", path, x.Len(), y.Len())
+		}
+		for i := 0; i < x.Len(); i++ {
+			if d := diff(fmt.Sprintf("%s[%d]", path, i), x.Elem(i), y.Elem(i)); d != "" {
+				return d
+			}
+		}
+
+	case *reflect.ArrayValue:
+		y := y.(*reflect.ArrayValue);
+		for i := 0; i < x.Len(); i++ {
+			if d := diff(fmt.Sprintf("%s[%d]", path, i), x.Elem(i), y.Elem(i)); d != "" {
+				return d
+			}
+		}
+
+	default:
+		if xi, yi := x.Interface(), y.Interface(); xi != yi {
+			return fmt.Sprintf("%s: %v != %v", path, xi, yi)
+		}
+	}
+	return "";
+}
+
+
+// commentText returns the text of the comments of file, without
+// white space, which the printer may normalize.
+func commentText(file *ast.File) []byte {
+	var buf bytes.Buffer;
+	for g := file.Comments; g != nil; g = g.Next {
+		for _, c := range g.List {
+			for _, ch := range c.Text {
+				if ch != ' ' && ch != '\t' && ch != '\n' && ch != '\r' {
+					buf.WriteByte(ch)
+				}
+			}
+			buf.WriteByte('\n');
+		}
+	}
+	return buf.Bytes();
+}
+
+
+// RoundTrip parses src, prints the AST with cfg, parses the output
+// again, and checks that the second AST equals the first one except
+// for positions, that the comments are the same except for white
+// space, and that printing the second AST produces the same output.
+// If the checks pass, RoundTrip returns the output. If src does not
+// parse, RoundTrip returns the parse error; all other errors are of
+// type *RoundTripError. The filename is used for positions in error
+// messages. The GenHTML and ASCIIOnly modes change the source and
+// cannot be checked.
+//
+func (cfg *Config) RoundTrip(filename string, src []byte) ([]byte, os.Error) {
+	if cfg.Mode&(GenHTML|ASCIIOnly) != 0 {
+		return nil, os.NewError("printer.RoundTrip: GenHTML and ASCIIOnly modes are not supported")
+	}
+
+	file1, err := parser.ParseFile(filename, src, parser.ParseComments);
+	if err != nil {
+		return nil, err
+	}
+
+	var buf1 bytes.Buffer;
+	if _, err := cfg.Fprint(&buf1, file1); err != nil {
+		return nil, &RoundTripError{"print", err.String(), buf1.Bytes()}
+	}
+	out1 := buf1.Bytes();
+
+	file2, err := parser.ParseFile(filename, out1, parser.ParseComments);
+	if err != nil {
+		return nil, &RoundTripError{"reparse", err.String(), out1}
+	}
+
+	if d := diff("File", reflect.NewValue(file1), reflect.NewValue(file2)); d != "" {
+		return nil, &RoundTripError{"compare", "AST differs at " + d, out1}
+	}
+	if !bytes.Equal(commentText(file1), commentText(file2)) {
+		return nil, &RoundTripError{"compare", "comments differ", out1}
+	}
+
+	var buf2 bytes.Buffer;
+	if _, err := cfg.Fprint(&buf2, file2); err != nil {
+		return nil, &RoundTripError{"reprint", err.String(), buf2.Bytes()}
+	}
+	if out2 := buf2.Bytes(); !bytes.Equal(out1, out2) {
+		return nil, &RoundTripError{"reprint", "output is not idempotent", out2}
+	}
+
+	return out1, nil;
+}
@@ -0,0 +1,97 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the VerifyComments printing mode.
+//
+// In VerifyComments mode, the printer records every comment it prints.
+// Once printing has finished, the comments of the input - those of the
+// comment list interspersed with the output, and those attached to the
+// nodes printed as documentation or line comments - are checked against
+// the record, and a comment that was not printed is reported as an error.
+// This makes comments dropped by the printer visible right away instead
+// of when somebody notices that they are missing from formatted source.
+
+package printer
+
+import (
+	"fmt";
+	"go/ast";
+	"os";
+)
+
+
+// markPrinted records that comment c was printed.
+func (p *printer) markPrinted(c *ast.Comment) {
+	if p.printed != nil {
+		p.printed[c] = true
+	}
+}
+
+
+// A commentCollector collects the comments attached to the nodes of an AST.
+type commentCollector struct {
+	list	[]*ast.Comment;
+}
+
+
+func (v *commentCollector) add(c *ast.Comment) {
+	n := len(v.list);
+	if n == cap(v.list) {
+		l := make([]*ast.Comment, n, 2*n+16);
+		copy(l, v.list);
+		v.list = l;
+	}
+	v.list = v.list[0 : n+1];
+	v.list[n] = c;
+}
+
+
+func (v *commentCollector) Visit(node interface{}) bool {
+	if c, ok := node.(*ast.Comment); ok {
+		v.add(c)
+	}
+	return true;
+}
+
+
+// commentError returns the error reporting the comments of the input
+// that were not printed, or nil if there are none or if comments are
+// not verified. The input consists of node and the comment list.
+func (p *printer) commentError(node interface{}, comments *ast.CommentGroup) os.Error {
+	if p.printed == nil {
+		return nil
+	}
+
+	var v commentCollector;
+	for g := comments; g != nil; g = g.Next {
+		for _, c := range g.List {
+			v.add(c)
+		}
+	}
+	ast.Walk(&v, node);
+
+	var first *ast.Comment;
+	n := 0;
+	for _, c := range v.list {
+		if printed, _ := p.printed[c]; !printed {
+			p.printed[c] = true;	// report each comment once
+			if first == nil {
+				first = c
+			}
+			n++;
+		}
+	}
+	if first == nil {
+		return nil
+	}
+
+	msg := fmt.Sprintf("comment %q not printed", string(first.Text));
+	if n > 1 {
+		msg = fmt.Sprintf("%s (and %d more)", msg, n-1)
+	}
+	if first.Pos().IsValid() {
+		msg = first.Pos().String() + ": " + msg
+	}
+	return os.NewError("printer.Fprint: " + msg);
+}
@@ -0,0 +1,99 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP conditional requests.  See RFC 2616, sections 3.3.1, 13.3, and 14.
+
+package http
+
+import (
+	"os";
+	"strconv";
+	"strings";
+	"time";
+)
+
+// FormatTime formats sec, in seconds since January 1, 1970 UTC, as
+// an HTTP date, such as "Sun, 06 Nov 1994 08:49:37 GMT" (RFC 1123).
+func FormatTime(sec int64) string {
+	s := time.SecondsToUTC(sec).RFC1123();
+	if strings.HasSuffix(s, "UTC") {
+		s = s[0:len(s)-3] + "GMT"
+	}
+	return s;
+}
+
+var months = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+// ParseTime parses an HTTP date in the form produced by FormatTime and
+// returns it in seconds since January 1, 1970 UTC.  The obsolete forms
+// permitted by HTTP, of RFC 850 and asctime, are not accepted.
+func ParseTime(s string) (sec int64, ok bool) {
+	f := strings.Split(s, " ", 0);
+	if len(f) != 6 || len(f[0]) != 4 || f[0][3] != ',' || f[5] != "GMT" {
+		return
+	}
+	hms := strings.Split(f[4], ":", 0);
+	if len(hms) != 3 {
+		return
+	}
+	var t time.Time;
+	var err os.Error;
+	if t.Day, err = strconv.Atoi(f[1]); err != nil || t.Day < 1 || t.Day > 31 {
+		return
+	}
+	for i, m := range months {
+		if m == f[2] {
+			t.Month = i + 1
+		}
+	}
+	if t.Month == 0 {
+		return
+	}
+	if t.Year, err = strconv.Atoi64(f[3]); err != nil {
+		return
+	}
+	if t.Hour, err = strconv.Atoi(hms[0]); err != nil || t.Hour > 23 {
+		return
+	}
+	if t.Minute, err = strconv.Atoi(hms[1]); err != nil || t.Minute > 59 {
+		return
+	}
+	if t.Second, err = strconv.Atoi(hms[2]); err != nil || t.Second > 60 {
+		return
+	}
+	return t.Seconds(), true;
+}
+
+// NotModified answers a conditional GET or HEAD request for a resource
+// last modified at mtime, in seconds, and with the entity tag etag, a
+// quoted string.  It sets the Last-Modified and ETag headers of the
+// reply and reports whether the client's copy of the resource is up to
+// date, in which case it has replied with 304 (Not Modified) and the
+// handler must not write a body.  A matching If-None-Match header
+// makes the copy up to date; without If-None-Match, an If-Modified-Since
+// header no earlier than mtime does.  An empty etag is not sent and
+// matches only "*".
+func NotModified(c *Conn, mtime int64, etag string) bool {
+	c.SetHeader("Last-Modified", FormatTime(mtime));
+	if etag != "" {
+		c.SetHeader("ETag", etag)
+	}
+
+	match := false;
+	if tags, found := c.Req.Header["If-None-Match"]; found {
+		for _, tag := range strings.Split(tags, ",", 0) {
+			if tag = strings.TrimSpace(tag); tag == "*" || tag == etag && etag != "" {
+				match = true
+			}
+		}
+	} else if since, found := c.Req.Header["If-Modified-Since"]; found {
+		if t, ok := ParseTime(since); ok && mtime <= t {
+			match = true
+		}
+	}
+	if match {
+		c.WriteHeader(StatusNotModified)
+	}
+	return match;
+}
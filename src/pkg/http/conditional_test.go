@@ -0,0 +1,105 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bufio";
+	"bytes";
+	"strings";
+	"testing";
+)
+
+type timeTest struct {
+	in	string;
+	sec	int64;
+	ok	bool;
+}
+
+var timeTests = []timeTest{
+	timeTest{"Sun, 06 Nov 1994 08:49:37 GMT", 784111777, true},
+	timeTest{"Thu, 01 Jan 1970 00:00:00 GMT", 0, true},
+	timeTest{"Sunday, 06-Nov-94 08:49:37 GMT", 0, false},
+	timeTest{"Sun Nov  6 08:49:37 1994", 0, false},
+	timeTest{"Sun, 06 Nov 1994 08:49:37 PST", 0, false},
+	timeTest{"Sun, 06 Fov 1994 08:49:37 GMT", 0, false},
+	timeTest{"Sun, 06 Nov 1994 08:49 GMT", 0, false},
+	timeTest{"Sun, 32 Nov 1994 08:49:37 GMT", 0, false},
+	timeTest{"", 0, false},
+}
+
+func TestParseTime(t *testing.T) {
+	for _, test := range timeTests {
+		sec, ok := ParseTime(test.in);
+		if sec != test.sec || ok != test.ok {
+			t.Errorf("ParseTime(%q) = %d, %v; want %d, %v", test.in, sec, ok, test.sec, test.ok)
+		}
+		if ok {
+			if s := FormatTime(sec); s != test.in {
+				t.Errorf("FormatTime(%d) = %q; want %q", sec, s, test.in)
+			}
+		}
+	}
+}
+
+type notModifiedTest struct {
+	header	map[string]string;
+	match	bool;
+}
+
+const (
+	testMtime	= 784111777;
+	testETag	= `"abc"`;
+)
+
+var notModifiedTests = []notModifiedTest{
+	notModifiedTest{map[string]string{}, false},
+	notModifiedTest{map[string]string{"If-None-Match": `"abc"`}, true},
+	notModifiedTest{map[string]string{"If-None-Match": `"xyz", "abc"`}, true},
+	notModifiedTest{map[string]string{"If-None-Match": "*"}, true},
+	notModifiedTest{map[string]string{"If-None-Match": `"xyz"`}, false},
+	// If-Modified-Since is ignored if there is an If-None-Match
+	notModifiedTest{map[string]string{"If-None-Match": `"xyz"`, "If-Modified-Since": "Sun, 06 Nov 1994 08:49:37 GMT"}, false},
+	notModifiedTest{map[string]string{"If-Modified-Since": "Sun, 06 Nov 1994 08:49:37 GMT"}, true},
+	notModifiedTest{map[string]string{"If-Modified-Since": "Mon, 07 Nov 1994 08:49:37 GMT"}, true},
+	notModifiedTest{map[string]string{"If-Modified-Since": "Sun, 06 Nov 1994 08:49:36 GMT"}, false},
+	notModifiedTest{map[string]string{"If-Modified-Since": "yesterday"}, false},
+}
+
+// newTestConn returns a Conn replying to a GET request with the
+// given header into out, set up for a chunked reply as readRequest does.
+func newTestConn(header map[string]string, out *bytes.Buffer) *Conn {
+	req := &Request{Method: "GET", ProtoMajor: 1, ProtoMinor: 1, Header: header};
+	c := &Conn{Req: req, header: make(map[string]string), chunking: true};
+	c.header["Transfer-Encoding"] = "chunked";
+	c.buf = bufio.NewReadWriter(nil, bufio.NewWriter(out));
+	return c;
+}
+
+func TestNotModified(t *testing.T) {
+	for i, test := range notModifiedTests {
+		var out bytes.Buffer;
+		c := newTestConn(test.header, &out);
+		if match := NotModified(c, testMtime, testETag); match != test.match {
+			t.Errorf("#%d: NotModified = %v; want %v", i, match, test.match)
+		}
+		if c.header["Last-Modified"] != "Sun, 06 Nov 1994 08:49:37 GMT" || c.header["Etag"] != testETag {
+			t.Errorf("#%d: header = %v", i, c.header)
+		}
+		if !test.match {
+			continue
+		}
+		if _, err := c.Write(strings.Bytes("body")); err != ErrBodyNotAllowed {
+			t.Errorf("#%d: Write after 304 = %v; want %v", i, err, ErrBodyNotAllowed)
+		}
+		c.flush();
+		reply := out.String();
+		if !strings.HasPrefix(reply, "HTTP/1.1 304 ") {
+			t.Errorf("#%d: reply %q is not a 304", i, reply)
+		}
+		if strings.Index(reply, "Transfer-Encoding") >= 0 || !strings.HasSuffix(reply, "\r\n\r\n") || strings.HasSuffix(reply, "0\r\n\r\n") {
+			t.Errorf("#%d: 304 reply %q has a body", i, reply)
+		}
+	}
+}
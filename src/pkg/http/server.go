@@ -27,6 +27,7 @@ import (
 var (
 	ErrWriteAfterFlush	= os.NewError("Conn.Write called after Flush");
 	ErrHijacked		= os.NewError("Conn has been hijacked");
+	ErrBodyNotAllowed	= os.NewError("Conn.Write called for a reply without body");
 )
 
 // Objects implementing the Handler interface can be
@@ -49,6 +50,7 @@ type Conn struct {
 	// state for the current reply
 	closeAfterReply	bool;			// close connection after this reply
 	chunking	bool;			// using chunked transfer encoding for reply body
+	noBody		bool;			// the status code of the reply does not allow a body
 	wroteHeader	bool;			// reply header has been written
 	header		map[string]string;	// reply header parameters
 	written		int64;			// number of bytes written in body
@@ -81,6 +83,7 @@ func (c *Conn) readRequest() (req *Request, err os.Error) {
 	// Reset per-request connection state.
 	c.header = make(map[string]string);
 	c.wroteHeader = false;
+	c.noBody = false;
 	c.Req = req;
 
 	// Default output is HTML encoded in UTF-8.
@@ -114,14 +117,16 @@ func (c *Conn) readRequest() (req *Request, err os.Error) {
 // being sent.  UTF-8 encoded HTML is the default setting for
 // Content-Type in this library, so users need not make that
 // particular call.  Calls to SetHeader after WriteHeader (or Write)
-// are ignored.
+// are ignored.  Setting the Connection header to "close" makes the
+// server close the connection after the reply.
 func (c *Conn) SetHeader(hdr, val string)	{ c.header[CanonicalHeaderKey(hdr)] = val }
 
 // WriteHeader sends an HTTP response header with status code.
 // If WriteHeader is not called explicitly, the first call to Write
 // will trigger an implicit WriteHeader(http.StatusOK).
 // Thus explicit calls to WriteHeader are mainly used to
-// send error codes.
+// send error codes.  Replies with a 1xx, 204 (No Content),
+// or 304 (Not Modified) status code have no body.
 func (c *Conn) WriteHeader(code int) {
 	if c.hijacked {
 		log.Stderr("http: Conn.WriteHeader on hijacked connection");
@@ -134,6 +139,15 @@ func (c *Conn) WriteHeader(code int) {
 	c.wroteHeader = true;
 	c.status = code;
 	c.written = 0;
+	if strings.ToLower(c.header["Connection"]) == "close" {
+		c.closeAfterReply = true
+	}
+	if code/100 == 1 || code == StatusNoContent || code == StatusNotModified {
+		// no body, hence no chunks (RFC 2616, section 4.3)
+		c.noBody = true;
+		c.chunking = false;
+		c.header["Transfer-Encoding"] = "", false;
+	}
 	if !c.Req.ProtoAtLeast(1, 0) {
 		return
 	}
@@ -153,6 +167,15 @@ func (c *Conn) WriteHeader(code int) {
 	io.WriteString(c.buf, "\r\n");
 }
 
+// Status returns the status code of the current reply, or 0 if
+// the reply header has not been written yet.
+func (c *Conn) Status() int {
+	if !c.wroteHeader {
+		return 0
+	}
+	return c.status;
+}
+
 // Write writes the data to the connection as part of an HTTP reply.
 // If WriteHeader has not yet been called, Write calls WriteHeader(http.StatusOK)
 // before writing the data.
@@ -167,6 +190,9 @@ func (c *Conn) Write(data []byte) (n int, err os.Error) {
 	if len(data) == 0 {
 		return 0, nil
 	}
+	if c.noBody {
+		return 0, ErrBodyNotAllowed
+	}
 
 	c.written += int64(len(data));	// ignoring errors, for errorKludge
 
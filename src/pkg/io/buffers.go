@@ -0,0 +1,91 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Vectored writes.
+
+package io
+
+import "os"
+
+// Buffers contains zero or more runs of bytes to write, such as the
+// header and the body of a message.  Writing Buffers to a Writer that
+// implements BuffersWriter hands all the runs to the writer at once,
+// which may write them with a single system call (scatter-gather I/O);
+// other Writers receive one Write per run.
+//
+// Reading from or writing Buffers consumes the data: the runs are
+// trimmed and dropped as their bytes are read or written.
+type Buffers [][]byte
+
+// BuffersWriter is the interface implemented by Writers that can
+// write several runs of bytes in one operation, such as network
+// connections.
+//
+// WriteBuffers writes the runs in v in order and returns the total
+// number of bytes written and any error encountered that caused the
+// write to stop early.  It must not modify v.
+type BuffersWriter interface {
+	WriteBuffers(v Buffers) (n int64, err os.Error);
+}
+
+// Len returns the total number of bytes in v.
+func (v Buffers) Len() int64 {
+	n := int64(0);
+	for _, b := range v {
+		n += int64(len(b))
+	}
+	return n;
+}
+
+// consume drops the first n bytes of v.
+func (v *Buffers) consume(n int64) {
+	for len(*v) > 0 {
+		b := (*v)[0];
+		if int64(len(b)) > n {
+			(*v)[0] = b[int(n):len(b)];
+			return;
+		}
+		n -= int64(len(b));
+		*v = (*v)[1:len(*v)];
+	}
+}
+
+// Read reads from the runs in v in order.  It returns os.EOF
+// once all runs have been consumed.
+func (v *Buffers) Read(p []byte) (n int, err os.Error) {
+	for len(p) > 0 && len(*v) > 0 {
+		m := copy(p, (*v)[0]);
+		v.consume(int64(m));
+		p = p[m:len(p)];
+		n += m;
+	}
+	if n == 0 && len(*v) == 0 {
+		err = os.EOF
+	}
+	return;
+}
+
+// WriteTo writes the runs in v to w, using a single call of
+// WriteBuffers if w implements BuffersWriter.  It implements
+// the WriterTo interface, so Copy recognizes Buffers.
+func (v *Buffers) WriteTo(w Writer) (n int64, err os.Error) {
+	if bw, ok := w.(BuffersWriter); ok {
+		n, err = bw.WriteBuffers(*v);
+		v.consume(n);
+		return;
+	}
+	for len(*v) > 0 {
+		b := (*v)[0];
+		m, e := w.Write(b);
+		n += int64(m);
+		v.consume(int64(m));
+		if e != nil {
+			return n, e
+		}
+		if m != len(b) {
+			return n, ErrShortWrite
+		}
+	}
+	return n, nil;
+}
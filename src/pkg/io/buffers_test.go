@@ -0,0 +1,83 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	"bytes";
+	. "io";
+	"os";
+	"strings";
+	"testing";
+)
+
+func newBuffers() Buffers {
+	return Buffers{strings.Bytes("hello, "), nil, strings.Bytes("vectored"), strings.Bytes(" world")}
+}
+
+const buffersText = "hello, vectored world"
+
+// vecWriter records the calls of WriteBuffers.
+type vecWriter struct {
+	bytes.Buffer;
+	calls	int;
+}
+
+func (w *vecWriter) WriteBuffers(v Buffers) (n int64, err os.Error) {
+	w.calls++;
+	for _, b := range v {
+		m, _ := w.Write(b);
+		n += int64(m);
+	}
+	return;
+}
+
+func TestBuffersRead(t *testing.T) {
+	v := newBuffers();
+	if n := v.Len(); n != int64(len(buffersText)) {
+		t.Errorf("Len = %d, expected %d", n, len(buffersText))
+	}
+	var p [4]byte;
+	var res []byte;
+	for {
+		n, err := v.Read(&p);
+		res = bytes.Add(res, p[0:n]);
+		if err == os.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+	}
+	if string(res) != buffersText {
+		t.Errorf("read %q, expected %q", string(res), buffersText)
+	}
+	if len(v) != 0 {
+		t.Errorf("%d buffers left after reading", len(v))
+	}
+}
+
+func TestBuffersWriteTo(t *testing.T) {
+	// a plain Writer receives the buffers one by one
+	var buf bytes.Buffer;
+	v := newBuffers();
+	n, err := Copy(&buf, &v);
+	if err != nil || n != int64(len(buffersText)) || buf.String() != buffersText {
+		t.Errorf("Copy: %d, %v, %q; expected %d, nil, %q", n, err, buf.String(), len(buffersText), buffersText)
+	}
+	if len(v) != 0 {
+		t.Errorf("%d buffers left after Copy", len(v))
+	}
+
+	// a BuffersWriter receives all buffers at once
+	var w vecWriter;
+	v = newBuffers();
+	n, err = Copy(&w, &v);
+	if err != nil || n != int64(len(buffersText)) || w.String() != buffersText {
+		t.Errorf("Copy: %d, %v, %q; expected %d, nil, %q", n, err, w.String(), len(buffersText), buffersText)
+	}
+	if w.calls != 1 {
+		t.Errorf("WriteBuffers called %d times, expected 1", w.calls)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Coalescing of small writes.
+
+package io
+
+import (
+	"os";
+	"sync";
+	"syscall";
+)
+
+// A CoalescingWriter batches small writes into larger ones, so that
+// a chatty protocol writing a few bytes at a time makes few calls of
+// the underlying Writer, such as few system calls on a net.Conn.
+// Buffered data is written once it reaches the size limit, or once
+// the oldest buffered byte has waited for the maximum delay; a timer
+// goroutine flushes the buffer in the latter case.
+//
+// An error of the underlying Writer is sticky: once a write fails,
+// all subsequent Writes and Flushes return the error.  Since a timed
+// flush happens in the background, its error is reported by the next
+// call.  A CoalescingWriter is safe for concurrent use.
+type CoalescingWriter struct {
+	mu	sync.Mutex;
+	w	Writer;
+	delay	int64;		// maximum delay of buffered data, in ns
+	buf	[]byte;		// buffered data; cap(buf) is the size limit
+	err	os.Error;	// first error of w; sticky
+	timer	int;		// number of the pending flush timer; 0 if none
+	timers	int;		// number of timers started
+	closed	bool;
+}
+
+// NewCoalescingWriter returns a CoalescingWriter writing to w that
+// buffers at most maxBytes bytes for at most maxDelayNs nanoseconds.
+// Writes of at least maxBytes bytes are passed to w directly, after
+// the data buffered before them.
+func NewCoalescingWriter(w Writer, maxDelayNs int64, maxBytes int) *CoalescingWriter {
+	if maxBytes <= 0 {
+		maxBytes = 1
+	}
+	return &CoalescingWriter{w: w, delay: maxDelayNs, buf: make([]byte, 0, maxBytes)};
+}
+
+// flush writes the buffered data and cancels the pending timer.
+// c.mu must be held.
+func (c *CoalescingWriter) flush() os.Error {
+	c.timer = 0;
+	if c.err != nil || len(c.buf) == 0 {
+		return c.err
+	}
+	n, err := c.w.Write(c.buf);
+	if err == nil && n < len(c.buf) {
+		err = ErrShortWrite
+	}
+	c.buf = c.buf[0:0];
+	c.err = err;
+	return err;
+}
+
+// startTimer arranges for the buffer to be flushed after
+// the maximum delay.  c.mu must be held.
+func (c *CoalescingWriter) startTimer() {
+	c.timers++;
+	timer := c.timers;
+	c.timer = timer;
+	go func() {
+		syscall.Sleep(c.delay);
+		c.mu.Lock();
+		if c.timer == timer {
+			// the data has not been flushed since the timer started
+			c.flush()
+		}
+		c.mu.Unlock();
+	}();
+}
+
+// Write buffers p, writing the buffer to the underlying Writer
+// if the data exceeds the size limit.
+func (c *CoalescingWriter) Write(p []byte) (n int, err os.Error) {
+	c.mu.Lock();
+	defer c.mu.Unlock();
+	if c.closed {
+		return 0, os.EINVAL
+	}
+	if c.err != nil {
+		return 0, c.err
+	}
+	if len(c.buf)+len(p) > cap(c.buf) {
+		if err = c.flush(); err != nil {
+			return 0, err
+		}
+		if len(p) >= cap(c.buf) {
+			n, err = c.w.Write(p);
+			if err == nil && n < len(p) {
+				err = ErrShortWrite
+			}
+			c.err = err;
+			return;
+		}
+	}
+	m := len(c.buf);
+	c.buf = c.buf[0 : m+len(p)];
+	copy(c.buf[m:len(c.buf)], p);
+	if len(c.buf) == cap(c.buf) || c.delay <= 0 {
+		err = c.flush()
+	} else if c.timer == 0 {
+		c.startTimer()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil;
+}
+
+// Flush writes any buffered data to the underlying Writer.
+func (c *CoalescingWriter) Flush() os.Error {
+	c.mu.Lock();
+	defer c.mu.Unlock();
+	return c.flush();
+}
+
+// Close flushes the buffered data; subsequent Writes fail with
+// os.EINVAL.  Close does not close the underlying Writer.
+func (c *CoalescingWriter) Close() os.Error {
+	c.mu.Lock();
+	defer c.mu.Unlock();
+	if c.closed {
+		return os.EINVAL
+	}
+	c.closed = true;
+	return c.flush();
+}
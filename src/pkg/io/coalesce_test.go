@@ -0,0 +1,79 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	. "io";
+	"os";
+	"sync";
+	"testing";
+	"time";
+)
+
+// A recordWriter records the data of each Write.
+type recordWriter struct {
+	mu	sync.Mutex;
+	writes	[]string;
+}
+
+func (w *recordWriter) Write(p []byte) (n int, err os.Error) {
+	w.mu.Lock();
+	defer w.mu.Unlock();
+	a := make([]string, len(w.writes)+1);
+	copy(a, w.writes);
+	a[len(w.writes)] = string(p);
+	w.writes = a;
+	return len(p), nil;
+}
+
+func (w *recordWriter) get() []string {
+	w.mu.Lock();
+	defer w.mu.Unlock();
+	return w.writes;
+}
+
+func checkWrites(t *testing.T, what string, w *recordWriter, expect []string) {
+	writes := w.get();
+	if len(writes) != len(expect) {
+		t.Errorf("%s: got writes %q, expected %q", what, writes, expect);
+		return;
+	}
+	for i, s := range writes {
+		if s != expect[i] {
+			t.Errorf("%s: got writes %q, expected %q", what, writes, expect);
+			return;
+		}
+	}
+}
+
+func TestCoalescingWriterSize(t *testing.T) {
+	w := new(recordWriter);
+	c := NewCoalescingWriter(w, 1e9, 4);
+	for _, s := range []string{"a", "b", "c", "d", "e"} {
+		WriteString(c, s)
+	}
+	checkWrites(t, "size limit", w, []string{"abcd"});
+	WriteString(c, "fghij");
+	checkWrites(t, "large write", w, []string{"abcd", "e", "fghij"});
+	WriteString(c, "k");
+	c.Close();
+	checkWrites(t, "close", w, []string{"abcd", "e", "fghij", "k"});
+	if _, err := WriteString(c, "l"); err != os.EINVAL {
+		t.Errorf("write after close: got %v, expected os.EINVAL", err)
+	}
+}
+
+func TestCoalescingWriterDelay(t *testing.T) {
+	w := new(recordWriter);
+	c := NewCoalescingWriter(w, 10e6, 1024);
+	WriteString(c, "a");
+	WriteString(c, "b");
+	checkWrites(t, "before delay", w, nil);
+	time.Sleep(100e6);
+	checkWrites(t, "after delay", w, []string{"ab"});
+	WriteString(c, "c");
+	c.Flush();
+	checkWrites(t, "flush", w, []string{"ab", "c"});
+}
@@ -0,0 +1,267 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Ordered fan-in of readers with prefetching.
+
+package io
+
+import (
+	"os";
+	"sync";
+)
+
+// Parameters of the Combiner returned by Combine.
+const (
+	combineAhead	= 2;		// sources prefetched beyond the current one
+	combineBuffer	= 32 << 10;	// prefetch buffer per source, in bytes
+	combineChunk	= 4 << 10;	// size of a single read of a source
+)
+
+// A chunk is the result of a single Read of a source.
+type chunk struct {
+	data	[]byte;
+	err	os.Error;
+}
+
+// A source is a Reader of a Combiner with its prefetched data.
+type source struct {
+	r	Reader;
+	data	chan chunk;	// prefetched chunks; nil until prefetching starts
+}
+
+// A Combiner reads a sequence of sources in order, as their
+// concatenation, like Copy-ing one after another would.  Unlike
+// reading them one after another, a Combiner starts reading later
+// sources into bounded buffers while an earlier one is being read,
+// so that slow sources, such as the parts of a transfer split into
+// chunks fetched over separate connections, are read concurrently
+// but reassembled in order.
+//
+// An error other than os.EOF from a source ends the combined stream:
+// Read returns the data up to the error and then the error itself.
+// Sources can be added while the Combiner is being read; Read blocks
+// at the end of the last source until another one is added or Finish
+// is called.  Read must not be called concurrently; AddSource, Finish
+// and Close may be called at any time.
+type Combiner struct {
+	mu		sync.Mutex;
+	pending		[]*source;	// sources not yet finished, in order; the first is being read
+	finished	bool;		// no more sources will be added
+	closed		bool;		// Close was called
+	ahead		int;		// number of sources prefetched beyond the current one
+	buffer		int;		// capacity of a source's buffer, in chunks
+	wake		chan bool;	// signals a waiting Read about new sources
+	quit		chan bool;	// closed by Close; stops prefetching
+
+	// owned by Read
+	cur	*source;	// source being read; or nil
+	chunk	[]byte;		// unread part of the current chunk
+	err	os.Error;	// sticky error, os.EOF at the end
+}
+
+// NewCombiner returns a Combiner without sources that prefetches up to
+// ahead sources beyond the one being read, buffering at most about
+// bufSize bytes of each.  Sources are added with AddSource; Finish must
+// be called after the last one.
+func NewCombiner(ahead, bufSize int) *Combiner {
+	if ahead < 0 {
+		ahead = 0
+	}
+	return &Combiner{
+		ahead: ahead,
+		buffer: (bufSize + combineChunk - 1) / combineChunk,
+		wake: make(chan bool, 1),
+		quit: make(chan bool),
+	};
+}
+
+// Combine returns a Combiner reading the sources in the given order,
+// prefetching a few of them ahead with a moderate buffer size.
+func Combine(order []Reader) *Combiner {
+	c := NewCombiner(combineAhead, combineBuffer);
+	for _, r := range order {
+		c.AddSource(r)
+	}
+	c.Finish();
+	return c;
+}
+
+// signal wakes a Read waiting for sources, if any.
+func (c *Combiner) signal() {
+	select {
+	case c.wake <- true:
+	default:	// already signaled
+	}
+}
+
+// prefetch starts reading the sources within the prefetch window:
+// the source being read, or to be read next, and ahead more.
+// c.mu must be held.
+func (c *Combiner) prefetch() {
+	for i, s := range c.pending {
+		if i > c.ahead {
+			break
+		}
+		c.start(s);
+	}
+}
+
+// start starts a goroutine reading s into its buffer.  A source
+// returning no data and no error many times in a row fails with
+// ErrNoProgress.
+func (c *Combiner) start(s *source) {
+	if s.data != nil {
+		return	// already started
+	}
+	s.data = make(chan chunk, c.buffer);
+	go func() {
+		empty := 0;
+		for {
+			b := make([]byte, combineChunk);
+			n, err := s.r.Read(b);
+			if n == 0 && err == nil {
+				empty++;
+				if empty < maxEmptyReads {
+					continue
+				}
+				err = ErrNoProgress;
+			}
+			empty = 0;
+			select {
+			case s.data <- chunk{b[0:n], err}:
+			case <-c.quit:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}();
+}
+
+// AddSource appends r to the sources of c.  It panics if called
+// after Finish, and does nothing if c is closed.
+func (c *Combiner) AddSource(r Reader) {
+	c.mu.Lock();
+	if c.closed {
+		c.mu.Unlock();
+		return;
+	}
+	if c.finished {
+		c.mu.Unlock();
+		panic("io: Combiner.AddSource after Finish");
+	}
+	n := len(c.pending);
+	if n == cap(c.pending) {
+		p := make([]*source, n, 2*n+4);
+		copy(p, c.pending);
+		c.pending = p;
+	}
+	c.pending = c.pending[0 : n+1];
+	c.pending[n] = &source{r: r};
+	c.prefetch();
+	c.mu.Unlock();
+	c.signal();
+}
+
+// Finish declares that no more sources will be added: once
+// the last source is exhausted, Read returns os.EOF.
+func (c *Combiner) Finish() {
+	c.mu.Lock();
+	c.finished = true;
+	c.mu.Unlock();
+	c.signal();
+}
+
+// next returns the next source, the first of the pending sources,
+// waiting for AddSource if there is none.  It returns os.EOF if
+// there are no more sources.
+func (c *Combiner) next() (*source, os.Error) {
+	for {
+		c.mu.Lock();
+		if len(c.pending) > 0 {
+			s := c.pending[0];
+			c.mu.Unlock();
+			return s, nil;
+		}
+		finished, closed := c.finished, c.closed;
+		c.mu.Unlock();
+		switch {
+		case closed:
+			return nil, os.EINVAL
+		case finished:
+			return nil, os.EOF
+		}
+		<-c.wake;
+	}
+	panic("unreachable");
+}
+
+// done removes the exhausted source s from the pending sources
+// and moves the prefetch window on to the next ones.
+func (c *Combiner) done(s *source) {
+	c.mu.Lock();
+	if n := len(c.pending); n > 0 && c.pending[0] == s {
+		c.pending = c.pending[1:n];
+		c.prefetch();
+	}
+	c.mu.Unlock();
+}
+
+// Read reads data from the current source, moving on to the next
+// one at the end of it.
+func (c *Combiner) Read(p []byte) (n int, err os.Error) {
+	for {
+		select {
+		case <-c.quit:
+			return 0, os.EINVAL
+		default:
+		}
+		if len(c.chunk) > 0 {
+			n = copy(p, c.chunk);
+			c.chunk = c.chunk[n:len(c.chunk)];
+			return;
+		}
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.cur == nil {
+			c.cur, c.err = c.next();
+			continue;
+		}
+		var ch chunk;
+		select {
+		case ch = <-c.cur.data:
+		case <-c.quit:
+			return 0, os.EINVAL
+		}
+		c.chunk = ch.data;
+		switch {
+		case ch.err == os.EOF:
+			c.done(c.cur);
+			c.cur = nil;
+		case ch.err != nil:
+			c.err = ch.err
+		}
+	}
+	panic("unreachable");
+}
+
+// Close stops prefetching and discards the data buffered so far;
+// subsequent Reads, and a Read blocked at the time, return os.EINVAL.
+// Close does not close the sources, and a source may remain in a
+// pending Read.
+func (c *Combiner) Close() os.Error {
+	c.mu.Lock();
+	defer c.mu.Unlock();
+	if c.closed {
+		return os.EINVAL
+	}
+	c.closed = true;
+	c.finished = true;
+	c.pending = nil;
+	close(c.quit);
+	c.signal();
+	return nil;
+}
@@ -0,0 +1,76 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	"bytes";
+	. "io";
+	"strings";
+	"testing";
+	"testing/iotest";
+)
+
+func TestCombine(t *testing.T) {
+	c := Combine([]Reader{
+		bytes.NewBufferString("hello, "),
+		iotest.OneByteReader(bytes.NewBufferString("combined ")),
+		bytes.NewBufferString(""),
+		bytes.NewBufferString("world"),
+	});
+	data, err := ReadAll(c);
+	if err != nil || string(data) != "hello, combined world" {
+		t.Errorf("ReadAll = %q, %v; want %q", data, err, "hello, combined world")
+	}
+}
+
+func TestCombineError(t *testing.T) {
+	c := Combine([]Reader{
+		bytes.NewBufferString("abc"),
+		&flakyReader{data: strings.Bytes("defghi"), failAfter: 1},
+		bytes.NewBufferString("never read"),
+	});
+	data, err := ReadAll(c);
+	if string(data) != "abcdef" || err != errFlaky {
+		t.Errorf("ReadAll = %q, %v; want %q, %v", data, err, "abcdef", errFlaky)
+	}
+}
+
+func TestCombineNoProgress(t *testing.T) {
+	c := Combine([]Reader{bytes.NewBufferString("abc"), emptyReader{}});
+	data, err := ReadAll(c);
+	if string(data) != "abc" || err != ErrNoProgress {
+		t.Errorf("ReadAll = %q, %v; want %q, %v", data, err, "abc", ErrNoProgress)
+	}
+}
+
+func TestCombinerPrefetch(t *testing.T) {
+	r1, w1 := Pipe();
+	r2, w2 := Pipe();
+	c := NewCombiner(1, 1024);
+	c.AddSource(r1);
+	c.AddSource(r2);
+
+	// the second source is written to completion before the first
+	// one is, which works only if the Combiner reads it ahead
+	done := make(chan bool);
+	go func() {
+		WriteString(w2, "world");
+		w2.Close();
+		done <- true;
+	}();
+	<-done;
+	go func() {
+		WriteString(w1, "hello, ");
+		w1.Close();
+	}();
+
+	// a source added while reading
+	c.AddSource(bytes.NewBufferString("!"));
+	c.Finish();
+	data, err := ReadAll(c);
+	if err != nil || string(data) != "hello, world!" {
+		t.Errorf("ReadAll = %q, %v; want %q", data, err, "hello, world!")
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	"bytes";
+	. "io";
+	"testing";
+	"testing/iotest";
+)
+
+func TestPipeConformance(t *testing.T) {
+	iotest.CheckPipe(t, func() (ReadCloser, WriteCloser) {
+		r, w := Pipe();
+		return r, w;
+	})
+}
+
+func TestMultiCursorConformance(t *testing.T) {
+	iotest.CheckReader(t, func(data []byte) Reader { return NewMultiCursor(&seekReader{data, 0}, 2)[1] })
+}
+
+func TestRewindReaderConformance(t *testing.T) {
+	iotest.CheckFilter(t, func(r Reader) Reader {
+		rr := NewRewindReader(r, 64);
+		rr.Mark();
+		return rr;
+	})
+}
+
+func TestLimitWriterConformance(t *testing.T) {
+	iotest.CheckWriter(t, func() (Writer, func() []byte) {
+		buf := new(bytes.Buffer);
+		return LimitWriter(buf, 1<<20), func() []byte { return buf.Bytes() };
+	})
+}
@@ -0,0 +1,20 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// export access to io internals for tests
+
+package io
+
+// PriorityPipeQueued is like PriorityPipe but also returns
+// a channel receiving a value whenever a write is queued,
+// so that tests need not wait for the writers to get there.
+func PriorityPipeQueued(burst int) (*PriorityPipeReader, *PriorityPipeWriter, <-chan bool) {
+	r, w := PriorityPipe(burst);
+	queued := make(chan bool);
+	w.p.queued = queued;
+	return r, w, queued;
+}
+
+// Retained returns the number of bytes r retains for rewinding.
+func (r *RewindReader) Retained() int	{ return len(r.buf) }
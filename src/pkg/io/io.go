@@ -27,6 +27,14 @@ var ErrShortWrite os.Error = &Error{"short write"}
 // middle of reading a fixed-size block or data structure.
 var ErrUnexpectedEOF os.Error = &Error{"unexpected EOF"}
 
+// ErrQuotaExceeded means that a write to a Writer returned by
+// LimitWriter would have exceeded its limit.
+var ErrQuotaExceeded os.Error = &Error{"quota exceeded"}
+
+// ErrPeekLimit means that a Peek asked for more bytes than
+// the Peeker can hold without consuming them.
+var ErrPeekLimit os.Error = &Error{"peek exceeds available buffer"}
+
 // Reader is the interface that wraps the basic Read method.
 //
 // Read reads up to len(p) bytes into p.  It returns the number of bytes
@@ -141,9 +149,59 @@ type WriterAt interface {
 	WriteAt(p []byte, off int64) (n int, err os.Error);
 }
 
+// WriterTo is the interface that wraps the WriteTo method.
+//
+// WriteTo writes data to w until there's no more data to write or
+// when an error occurs.  It returns the number of bytes written
+// and any error encountered.
+type WriterTo interface {
+	WriteTo(w Writer) (n int64, err os.Error);
+}
+
+// Peeker is the interface that wraps the basic Peek method.
+//
+// Peek returns the next n bytes of the stream without consuming
+// them; the following Read returns the same bytes.  If Peek returns
+// fewer than n bytes, it also returns an error explaining why, such
+// as os.EOF or ErrPeekLimit.  The returned slice may share memory
+// with the Peeker and is valid only until the next call of one of
+// its methods.
+//
+// Readers implement Peeker only when they can do so cheaply, for
+// instance because they keep buffered data anyway, so that clients
+// can test for Peeker instead of inserting a buffer of their own.
+type Peeker interface {
+	Peek(n int) (p []byte, err os.Error);
+}
+
+// ReadPeeker is the interface that groups the basic Read and Peek methods.
+type ReadPeeker interface {
+	Reader;
+	Peeker;
+}
+
+// ReaderFrom is the interface that wraps the ReadFrom method.
+//
+// ReadFrom reads data from r until os.EOF or an error occurs.
+// It returns the number of bytes read and any error encountered
+// other than os.EOF.
+type ReaderFrom interface {
+	ReadFrom(r Reader) (n int64, err os.Error);
+}
+
+// stringWriter is the interface of Writers that write strings
+// without converting them, such as bytes.Buffer.
+type stringWriter interface {
+	WriteString(s string) (n int, err os.Error);
+}
+
 // WriteString writes the contents of the string s to w, which accepts an array of bytes.
+// If w has a WriteString method, it is called directly.
 func WriteString(w Writer, s string) (n int, err os.Error) {
-	return w.Write(strings.Bytes(s))
+	if sw, ok := w.(stringWriter); ok {
+		return sw.WriteString(s)
+	}
+	return w.Write(strings.Bytes(s));
 }
 
 // ReadAtLeast reads from r into buf until it has read at least min bytes.
@@ -209,15 +267,71 @@ func Copyn(dst Writer, src Reader, n int64) (written int64, err os.Error) {
 	return written, err;
 }
 
+// Size limits of the buffer used by Copy.
+const (
+	minCopyBuffer	= 4 * 1024;
+	maxCopyBuffer	= 256 * 1024;
+)
+
+// A copyBuffer adapts its size to the observed throughput:
+// consecutive reads filling the entire buffer double its size
+// (up to maxCopyBuffer), consecutive reads using less than half
+// of it halve its size (down to minCopyBuffer).  It is passed by
+// value, so that Copy does not allocate it.  Its buffers are not
+// pooled: the Writer may keep the slices it is passed.
+type copyBuffer struct {
+	buf	[]byte;
+	full	int;	// number of consecutive full reads
+	short	int;	// number of consecutive short reads
+}
+
+func newCopyBuffer() copyBuffer	{ return copyBuffer{buf: make([]byte, minCopyBuffer)} }
+
+// adapt returns the buffer adjusted after a read of n bytes.
+func (b copyBuffer) adapt(n int) copyBuffer {
+	switch size := len(b.buf); {
+	case n == size:
+		b.full++;
+		b.short = 0;
+		if b.full >= 2 && size < maxCopyBuffer {
+			b.buf = make([]byte, 2*size);
+			b.full = 0;
+		}
+	case n < size/2:
+		b.short++;
+		b.full = 0;
+		if b.short >= 2 && size > minCopyBuffer {
+			b.buf = make([]byte, size/2);
+			b.short = 0;
+		}
+	default:
+		b.full, b.short = 0, 0
+	}
+	return b;
+}
+
 // Copy copies from src to dst until either EOF is reached
 // on src or an error occurs.  It returns the number of bytes
 // copied and the error, if any.
+//
+// Copy starts with a small buffer and grows it while src keeps
+// filling it, so that fast transfers need fewer calls while
+// many concurrent slow transfers use little memory.
+//
+// If src implements WriterTo, Copy calls src.WriteTo(dst) instead;
+// otherwise, if dst implements ReaderFrom, Copy calls dst.ReadFrom(src).
 func Copy(dst Writer, src Reader) (written int64, err os.Error) {
-	buf := make([]byte, 32*1024);
+	if wt, ok := src.(WriterTo); ok {
+		return wt.WriteTo(dst)
+	}
+	if rf, ok := dst.(ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	b := newCopyBuffer();
 	for {
-		nr, er := src.Read(buf);
+		nr, er := src.Read(b.buf);
 		if nr > 0 {
-			nw, ew := dst.Write(buf[0:nr]);
+			nw, ew := dst.Write(b.buf[0:nr]);
 			if nw > 0 {
 				written += int64(nw)
 			}
@@ -233,6 +347,7 @@ func Copy(dst Writer, src Reader) (written int64, err os.Error) {
 		if er == os.EOF {
 			break
 		}
+		b = b.adapt(nr);
 		if er != nil {
 			err = er;
 			break;
@@ -242,8 +357,19 @@ func Copy(dst Writer, src Reader) (written int64, err os.Error) {
 }
 
 // LimitReader returns a Reader that reads from r
-// but stops with os.EOF after n bytes.
-func LimitReader(r Reader, n int64) Reader	{ return &limitedReader{r, n} }
+// but stops with os.EOF after n bytes.  If r is
+// a Peeker, so is the result.
+//
+// The result implements WriterTo, so that wrapping a Reader in a limit
+// does not hide the ReadFrom method of the destination from wrappers
+// that forward WriteTo.  It never calls r.WriteTo, which could consume
+// more than n bytes of r before the limit stopped it.
+func LimitReader(r Reader, n int64) Reader {
+	if p, ok := r.(Peeker); ok {
+		return &limitedPeeker{limitedReader{r, n}, p}
+	}
+	return &limitedReader{r, n};
+}
 
 type limitedReader struct {
 	r	Reader;
@@ -262,14 +388,112 @@ func (l *limitedReader) Read(p []byte) (n int, err os.Error) {
 	return;
 }
 
+// WriteTo copies the remaining bytes of l to w, using the
+// ReadFrom method of w if it has one.
+func (l *limitedReader) WriteTo(w Writer) (n int64, err os.Error) {
+	return Copy(w, readerOnly{l})
+}
+
+// A readerOnly hides all methods of its Reader but Read, so that
+// Copy from it does not call the WriteTo method forwarding to it.
+type readerOnly struct {
+	Reader;
+}
+
+type limitedPeeker struct {
+	limitedReader;
+	p	Peeker;
+}
+
+func (l *limitedPeeker) Peek(n int) (p []byte, err os.Error) {
+	if l.n <= 0 {
+		return nil, os.EOF
+	}
+	if int64(n) <= l.n {
+		return l.p.Peek(n)
+	}
+	p, err = l.p.Peek(int(l.n));
+	if err == nil {
+		err = os.EOF
+	}
+	return;
+}
+
+// LimitWriter returns a Writer that writes to w but accepts at most
+// n bytes in total.  A Write that would exceed the limit writes the
+// part of its data within the limit and returns ErrQuotaExceeded;
+// all subsequent Writes return ErrQuotaExceeded.  If w is a ReaderFrom,
+// so is the result, and Copy to it uses the ReadFrom method of w.
+func LimitWriter(w Writer, n int64) Writer {
+	if rf, ok := w.(ReaderFrom); ok {
+		return &limitedReaderFrom{limitedWriter{w, n}, rf}
+	}
+	return &limitedWriter{w, n};
+}
+
+type limitedWriter struct {
+	w	Writer;
+	n	int64;	// number of bytes remaining
+}
+
+func (l *limitedWriter) Write(p []byte) (n int, err os.Error) {
+	if l.n <= 0 {
+		return 0, ErrQuotaExceeded
+	}
+	exceeded := false;
+	if int64(len(p)) > l.n {
+		p = p[0:l.n];
+		exceeded = true;
+	}
+	n, err = l.w.Write(p);
+	l.n -= int64(n);
+	if err == nil && exceeded {
+		err = ErrQuotaExceeded
+	}
+	return;
+}
+
+type limitedReaderFrom struct {
+	limitedWriter;
+	rf	ReaderFrom;
+}
+
+// ReadFrom passes the first l.n bytes of r to the ReadFrom method of
+// the underlying Writer.  If r has more data, ReadFrom reads one more
+// byte and returns ErrQuotaExceeded, like Copy using Write would after
+// reading data beyond the limit.
+func (l *limitedReaderFrom) ReadFrom(r Reader) (n int64, err os.Error) {
+	if l.n > 0 {
+		n, err = l.rf.ReadFrom(&limitedReader{r, l.n});
+		l.n -= n;
+		if err != nil || l.n > 0 {
+			return
+		}
+	}
+	var b [1]byte;
+	for {
+		nr, er := r.Read(&b);
+		if nr > 0 {
+			return n, ErrQuotaExceeded
+		}
+		if er == os.EOF {
+			return n, nil
+		}
+		if er != nil {
+			return n, er
+		}
+	}
+	panic("unreachable");
+}
+
 // NewSectionReader returns a SectionReader that reads from r
 // starting at offset off and stops with os.EOF after n bytes.
 func NewSectionReader(r ReaderAt, off int64, n int64) *SectionReader {
 	return &SectionReader{r, off, off, off + n}
 }
 
-// SectionReader implements Read, Seek, and ReadAt on a section
-// of an underlying ReaderAt.
+// SectionReader implements Read, Seek, ReadAt, and WriteTo on a
+// section of an underlying ReaderAt.
 type SectionReader struct {
 	r	ReaderAt;
 	base	int64;
@@ -289,6 +513,12 @@ func (s *SectionReader) Read(p []byte) (n int, err os.Error) {
 	return;
 }
 
+// WriteTo copies the rest of the section to w, using the ReadFrom
+// method of w if it has one; it advances the offset as Read does.
+func (s *SectionReader) WriteTo(w Writer) (n int64, err os.Error) {
+	return Copy(w, readerOnly{s})
+}
+
 func (s *SectionReader) Seek(offset int64, whence int) (ret int64, err os.Error) {
 	switch whence {
 	default:
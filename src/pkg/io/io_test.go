@@ -0,0 +1,308 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	"bytes";
+	"flag";
+	. "io";
+	"malloc";
+	"os";
+	"testing";
+	"testing/iotest";
+	"time";
+)
+
+func testData(n int) []byte {
+	data := make([]byte, n);
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data;
+}
+
+type copyReader struct {
+	name	string;
+	r	func(Reader) Reader;
+}
+
+var copyReaders = []copyReader{
+	copyReader{"full", func(r Reader) Reader { return r }},
+	copyReader{"half", iotest.HalfReader},
+	copyReader{"onebyte", iotest.OneByteReader},
+}
+
+func TestCopy(t *testing.T) {
+	// large enough to let the buffer grow to its maximum size
+	data := testData(1 << 20);
+	for _, c := range copyReaders {
+		var dst bytes.Buffer;
+		n, err := Copy(&dst, c.r(bytes.NewBuffer(data)));
+		if n != int64(len(data)) || err != nil {
+			t.Errorf("%s: Copy = %d, %v; want %d, nil", c.name, n, err, len(data))
+		}
+		if !bytes.Equal(dst.Bytes(), data) {
+			t.Errorf("%s: Copy corrupted data", c.name)
+		}
+	}
+}
+
+// zeroReader is an infinitely fast source.
+type zeroReader struct{}
+
+func (r zeroReader) Read(p []byte) (int, os.Error)	{ return len(p), nil }
+
+// nullWriter is an infinitely fast sink.
+type nullWriter struct{}
+
+func (w nullWriter) Write(p []byte) (int, os.Error)	{ return len(p), nil }
+
+// copyFixed is Copy with a fixed size buffer, for comparison.
+func copyFixed(dst Writer, src Reader, size int) (written int64, err os.Error) {
+	buf := make([]byte, size);
+	for {
+		nr, er := src.Read(buf);
+		nw, ew := dst.Write(buf[0:nr]);
+		written += int64(nw);
+		if er != nil || ew != nil {
+			break
+		}
+	}
+	return;
+}
+
+// TestCopyThroughput compares Copy with a fixed 32K buffer; run
+// gotest -v --measure_copy to see the results.
+func TestCopyThroughput(t *testing.T) {
+	if !*measureCopy {
+		t.Logf("test disabled; use --measure_copy to enable");
+		return;
+	}
+	const n = 64 << 20;
+	t0 := time.Nanoseconds();
+	copyFixed(nullWriter{}, LimitReader(zeroReader{}, n), 32*1024);
+	t1 := time.Nanoseconds();
+	Copy(nullWriter{}, LimitReader(zeroReader{}, n));
+	t2 := time.Nanoseconds();
+	t.Logf("copying %d bytes: fixed %dus, adaptive %dus", n, (t1-t0)/1e3, (t2-t1)/1e3);
+}
+
+// plainWriter hides the WriteString method of a bytes.Buffer.
+type plainWriter struct {
+	w	Writer;
+}
+
+func (w plainWriter) Write(p []byte) (int, os.Error)	{ return w.w.Write(p) }
+
+func TestWriteString(t *testing.T) {
+	for _, n := range []int{0, 1, 4096, 5000, 1 << 20} {
+		s := string(testData(n));
+		var buf bytes.Buffer;
+		for _, w := range []Writer{&buf, plainWriter{&buf}} {
+			buf.Reset();
+			if m, err := WriteString(w, s); m != n || err != nil {
+				t.Errorf("WriteString of %d bytes = %d, %v", n, m, err)
+			}
+			if buf.String() != s {
+				t.Errorf("WriteString of %d bytes corrupted data", n)
+			}
+		}
+	}
+}
+
+// A repeatReader returns n bytes of zeros each time it is reset.
+type repeatReader struct {
+	n, left	int;
+}
+
+func (r *repeatReader) reset()	{ r.left = r.n }
+
+func (r *repeatReader) Read(p []byte) (int, os.Error) {
+	if r.left == 0 {
+		return 0, os.EOF
+	}
+	m := len(p);
+	if m > r.left {
+		m = r.left
+	}
+	r.left -= m;
+	return m, nil;
+}
+
+// measure runs f n times and returns the time and the number of bytes
+// allocated per call; the collector is stopped while f runs, so that
+// the allocated bytes can be read from the heap statistics.
+func measure(n int, f func()) (ns, bytes int64) {
+	st := malloc.GetStats();
+	gc := st.EnableGC;
+	st.EnableGC = false;
+	a0, t0 := st.Alloc, time.Nanoseconds();
+	for i := 0; i < n; i++ {
+		f()
+	}
+	t1, a1 := time.Nanoseconds(), st.Alloc;
+	st.EnableGC = gc;
+	return (t1 - t0) / int64(n), int64(a1-a0) / int64(n);
+}
+
+var measureCopy = flag.Bool("measure_copy", false, "let TestCopyThroughput and TestCopyCost measure Copy")
+
+// TestCopyCost compares the cost of Copy and pipe
+// handoffs for small messages and bulk transfers with that of the
+// allocating implementations they replaced; run gotest -v
+// --measure_copy to see the results.
+func TestCopyCost(t *testing.T) {
+	if !*measureCopy {
+		t.Logf("test disabled; use --measure_copy to enable");
+		return;
+	}
+	// few iterations: the collector is stopped while they run
+	report := func(name string, n int, before, after func()) {
+		ns0, b0 := measure(n, before);
+		ns1, b1 := measure(n, after);
+		t.Logf("%s: before %d ns/op %d B/op, after %d ns/op %d B/op", name, ns0, b0, ns1, b1);
+		if b1 > b0 {
+			t.Errorf("%s: allocates more than before (%d > %d B/op)", name, b1, b0)
+		}
+	};
+
+	small := &repeatReader{n: 64};
+	report("Copy 64B", 100,
+		func() { small.reset(); copyFixed(nullWriter{}, small, 32*1024) },
+		func() { small.reset(); Copy(nullWriter{}, small) });
+
+	bulk := &repeatReader{n: 1 << 20};
+	report("Copy 1MB", 8,
+		func() { bulk.reset(); copyFixed(nullWriter{}, bulk, 32*1024) },
+		func() { bulk.reset(); Copy(nullWriter{}, bulk) });
+
+	// the pipe has no allocating predecessor; measure it alone
+	r, w := Pipe();
+	go Copy(nullWriter{}, r);
+	data := testData(64);
+	ns, b := measure(100, func() { w.Write(data) });
+	w.Close();
+	t.Logf("Pipe 64B: %d ns/op %d B/op", ns, b);
+}
+
+func TestLimitWriter(t *testing.T) {
+	data := testData(1000);
+	var dst bytes.Buffer;
+	n, err := Copy(LimitWriter(&dst, 300), iotest.HalfReader(bytes.NewBuffer(data)));
+	if n != 300 || err != ErrQuotaExceeded {
+		t.Errorf("Copy = %d, %v; want 300, %v", n, err, ErrQuotaExceeded)
+	}
+	if !bytes.Equal(dst.Bytes(), data[0:300]) {
+		t.Errorf("LimitWriter wrote %d bytes, want the first 300", dst.Len())
+	}
+
+	// writes within the limit succeed
+	dst.Reset();
+	w := LimitWriter(&dst, 10);
+	if n, err := w.Write(data[0:10]); n != 10 || err != nil {
+		t.Errorf("Write = %d, %v; want 10, nil", n, err)
+	}
+	if n, err := w.Write(data[0:1]); n != 0 || err != ErrQuotaExceeded {
+		t.Errorf("Write = %d, %v; want 0, %v", n, err, ErrQuotaExceeded)
+	}
+}
+
+// A readerFrom is a Writer whose ReadFrom method records its calls.
+type readerFrom struct {
+	bytes.Buffer;
+	calls	int;
+}
+
+func (w *readerFrom) ReadFrom(r Reader) (n int64, err os.Error) {
+	w.calls++;
+	b, err := ReadAll(r);
+	w.Write(b);
+	return int64(len(b)), err;
+}
+
+func TestLimitWriterReadFrom(t *testing.T) {
+	data := testData(1000);
+	for _, size := range []int{300, 1000} {
+		var dst readerFrom;
+		w := LimitWriter(&dst, int64(size));
+		if _, ok := w.(ReaderFrom); !ok {
+			t.Fatalf("LimitWriter of a ReaderFrom is not a ReaderFrom")
+		}
+		n, err := Copy(w, bytes.NewBuffer(data));
+		var want os.Error;
+		if size < len(data) {
+			want = ErrQuotaExceeded
+		}
+		if n != int64(size) || err != want {
+			t.Errorf("size %d: Copy = %d, %v; want %d, %v", size, n, err, size, want)
+		}
+		if dst.calls != 1 {
+			t.Errorf("size %d: Copy called ReadFrom %d times, want once", size, dst.calls)
+		}
+		if !bytes.Equal(dst.Bytes(), data[0:size]) {
+			t.Errorf("size %d: LimitWriter wrote %d bytes, want the first %d", size, dst.Len(), size)
+		}
+		if n, err := w.Write(data[0:1]); n != 0 || err != ErrQuotaExceeded {
+			t.Errorf("size %d: Write = %d, %v; want 0, %v", size, n, err, ErrQuotaExceeded)
+		}
+	}
+
+	if _, ok := LimitWriter(nullWriter{}, 10).(ReaderFrom); ok {
+		t.Errorf("LimitWriter of a plain Writer is a ReaderFrom")
+	}
+}
+
+func TestLimitReaderReadFrom(t *testing.T) {
+	data := testData(1000);
+	var dst readerFrom;
+	r := LimitReader(bytes.NewBuffer(data), 300);
+	if _, ok := r.(WriterTo); !ok {
+		t.Errorf("LimitReader is not a WriterTo")
+	}
+	n, err := Copy(&dst, r);
+	if n != 300 || err != nil {
+		t.Errorf("Copy = %d, %v; want 300, nil", n, err)
+	}
+	if dst.calls != 1 {
+		t.Errorf("Copy called ReadFrom %d times, want once", dst.calls)
+	}
+	if !bytes.Equal(dst.Bytes(), data[0:300]) {
+		t.Errorf("LimitReader read %d bytes, want the first 300", dst.Len())
+	}
+}
+
+// A byteReaderAt is a ReaderAt reading from a slice.
+type byteReaderAt []byte
+
+func (b byteReaderAt) ReadAt(p []byte, off int64) (n int, err os.Error) {
+	if off >= int64(len(b)) {
+		return 0, os.EOF
+	}
+	n = copy(p, b[off:len(b)]);
+	if n < len(p) {
+		err = os.EOF
+	}
+	return;
+}
+
+func TestSectionReaderWriteTo(t *testing.T) {
+	data := testData(1000);
+	s := NewSectionReader(byteReaderAt(data), 100, 300);
+	var dst readerFrom;
+	n, err := s.WriteTo(&dst);
+	if n != 300 || err != nil {
+		t.Errorf("WriteTo = %d, %v; want 300, nil", n, err)
+	}
+	if dst.calls != 1 {
+		t.Errorf("WriteTo called ReadFrom %d times, want once", dst.calls)
+	}
+	if !bytes.Equal(dst.Bytes(), data[100:400]) {
+		t.Errorf("SectionReader wrote %d bytes, want bytes 100 to 400", dst.Len())
+	}
+	var b [1]byte;
+	if n, err := s.Read(&b); n != 0 || err != os.EOF {
+		t.Errorf("Read after WriteTo = %d, %v; want 0, EOF", n, err)
+	}
+}
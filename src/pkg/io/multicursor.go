@@ -0,0 +1,101 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Independent cursors over one ReadSeeker.
+
+package io
+
+import (
+	"os";
+	"sync";
+)
+
+// A cursorSource is the ReadSeeker shared by the cursors
+// returned by NewMultiCursor.
+type cursorSource struct {
+	mu	sync.Mutex;
+	rs	ReadSeeker;
+	pos	int64;	// offset of rs; -1 if unknown
+}
+
+// A cursor is a ReadSeeker with its own offset in a cursorSource.
+type cursor struct {
+	src	*cursorSource;
+	off	int64;
+}
+
+// NewMultiCursor returns n ReadSeekers reading from rs, each with its
+// own offset, starting at the current offset of rs.  The cursors can
+// be used by different goroutines at the same time: each Read seeks
+// rs to the offset of its cursor if another cursor moved it, and the
+// Reads and Seeks of the cursors are serialized.  The caller must not
+// use rs directly while it uses the cursors.
+//
+// A cursor itself, like other Readers, must not be used by several
+// goroutines at once.
+func NewMultiCursor(rs ReadSeeker, n int) []ReadSeeker {
+	off, err := rs.Seek(0, 1);
+	if err != nil {
+		// rs cannot report its offset; the cursors
+		// start at the beginning
+		off = -1
+	}
+	src := &cursorSource{rs: rs, pos: off};
+	if off < 0 {
+		off = 0
+	}
+	list := make([]ReadSeeker, n);
+	for i := range list {
+		list[i] = &cursor{src, off}
+	}
+	return list;
+}
+
+func (c *cursor) Read(p []byte) (n int, err os.Error) {
+	s := c.src;
+	s.mu.Lock();
+	defer s.mu.Unlock();
+	if s.pos != c.off {
+		if _, err = s.rs.Seek(c.off, 0); err != nil {
+			s.pos = -1;
+			return 0, err;
+		}
+		s.pos = c.off;
+	}
+	n, err = s.rs.Read(p);
+	c.off += int64(n);
+	s.pos = c.off;
+	return;
+}
+
+// Seek sets the offset of the cursor.  Seeks relative to the start
+// (whence 0) or to the current offset (whence 1) of the cursor only
+// record the new offset; seeks relative to the end seek rs.
+func (c *cursor) Seek(offset int64, whence int) (ret int64, err os.Error) {
+	switch whence {
+	default:
+		return 0, os.EINVAL
+	case 0:
+	case 1:
+		offset += c.off
+	case 2:
+		s := c.src;
+		s.mu.Lock();
+		offset, err = s.rs.Seek(offset, 2);
+		if err != nil {
+			s.pos = -1
+		} else {
+			s.pos = offset
+		}
+		s.mu.Unlock();
+		if err != nil {
+			return 0, err
+		}
+	}
+	if offset < 0 {
+		return 0, os.EINVAL
+	}
+	c.off = offset;
+	return offset, nil;
+}
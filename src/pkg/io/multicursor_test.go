@@ -0,0 +1,114 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	. "io";
+	"os";
+	"strings";
+	"testing";
+)
+
+// A seekReader is an in-memory ReadSeeker.
+type seekReader struct {
+	data	[]byte;
+	off	int64;
+}
+
+func (r *seekReader) Read(p []byte) (n int, err os.Error) {
+	if r.off >= int64(len(r.data)) {
+		return 0, os.EOF
+	}
+	n = copy(p, r.data[r.off:len(r.data)]);
+	r.off += int64(n);
+	return;
+}
+
+func (r *seekReader) Seek(offset int64, whence int) (int64, os.Error) {
+	switch whence {
+	case 1:
+		offset += r.off
+	case 2:
+		offset += int64(len(r.data))
+	}
+	r.off = offset;
+	return offset, nil;
+}
+
+func TestMultiCursor(t *testing.T) {
+	const text = "0123456789abcdefghij";
+	rs := &seekReader{strings.Bytes(text), 0};
+	rs.Seek(5, 0);
+	c := NewMultiCursor(rs, 2);
+
+	// the cursors start at the offset of rs
+	if s := readString(t, c[0], 3); s != "567" {
+		t.Errorf("cursor 0 read %q, expected %q", s, "567")
+	}
+	if s := readString(t, c[1], 2); s != "56" {
+		t.Errorf("cursor 1 read %q, expected %q", s, "56")
+	}
+	if s := readString(t, c[0], 3); s != "89a" {
+		t.Errorf("cursor 0 read %q, expected %q", s, "89a")
+	}
+
+	if off, err := c[1].Seek(-4, 2); off != 16 || err != nil {
+		t.Errorf("Seek(-4, 2) = %d, %v; expected 16, nil", off, err)
+	}
+	if s := readString(t, c[1], 4); s != "ghij" {
+		t.Errorf("cursor 1 read %q, expected %q", s, "ghij")
+	}
+	if off, err := c[0].Seek(-1, 1); off != 10 || err != nil {
+		t.Errorf("Seek(-1, 1) = %d, %v; expected 10, nil", off, err)
+	}
+	if s := readString(t, c[0], 2); s != "ab" {
+		t.Errorf("cursor 0 read %q, expected %q", s, "ab")
+	}
+	if _, err := c[0].Seek(-1, 0); err != os.EINVAL {
+		t.Errorf("Seek(-1, 0): %v, expected %v", err, os.EINVAL)
+	}
+}
+
+func TestMultiCursorConcurrent(t *testing.T) {
+	data := make([]byte, 4096);
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	const n = 4;
+	cursors := NewMultiCursor(&seekReader{data, 0}, n);
+	done := make(chan bool);
+	for i, c := range cursors {
+		go func(i int, c ReadSeeker) {
+			// cursor i reads the i'th section in small pieces
+			section := len(data) / n;
+			c.Seek(int64(i*section), 0);
+			buf := make([]byte, 7);
+			ok := true;
+			for off := i * section; off < (i+1)*section; {
+				m := len(buf);
+				if m > (i+1)*section-off {
+					m = (i+1)*section - off
+				}
+				if _, err := ReadFull(c, buf[0:m]); err != nil {
+					t.Errorf("cursor %d: ReadFull: %v", i, err);
+					break;
+				}
+				for j := 0; j < m; j++ {
+					if buf[j] != data[off+j] {
+						ok = false
+					}
+				}
+				off += m;
+			}
+			if !ok {
+				t.Errorf("cursor %d read wrong data", i)
+			}
+			done <- true;
+		}(i, c)
+	}
+	for i := 0; i < n; i++ {
+		<-done
+	}
+}
@@ -50,9 +50,7 @@ func (p *pipe) Read(data []byte) (n int, err os.Error) {
 	if n > len(p.wpend) {
 		n = len(p.wpend)
 	}
-	for i := 0; i < n; i++ {
-		data[i] = p.wpend[i]
-	}
+	copy(data, p.wpend[0:n]);
 	p.wtot += n;
 	p.wpend = p.wpend[n:len(p.wpend)];
 
@@ -66,6 +64,28 @@ func (p *pipe) Read(data []byte) (n int, err os.Error) {
 	return n, nil;
 }
 
+// peek returns the unread part of the current write block,
+// at most n bytes, waiting for a write if necessary.
+func (p *pipe) peek(n int) (data []byte, err os.Error) {
+	if p == nil || p.rclosed {
+		return nil, os.EINVAL
+	}
+	if p.wpend == nil {
+		if !p.wclosed {
+			p.wpend = <-p.cr
+		}
+		if p.wpend == nil {
+			return nil, p.werr
+		}
+		p.wtot = 0;
+	}
+	if n > len(p.wpend) {
+		// the writer is blocked until the block is consumed
+		return p.wpend, ErrPeekLimit
+	}
+	return p.wpend[0:n], nil;
+}
+
 func (p *pipe) Write(data []byte) (n int, err os.Error) {
 	if p == nil || p.wclosed {
 		return 0, os.EINVAL
@@ -122,6 +142,29 @@ func (p *pipe) CloseWriter(werr os.Error) os.Error {
 	return nil;
 }
 
+// reset returns a pipe whose ends have both been closed
+// to its initial state.
+func (p *pipe) reset() os.Error {
+	if p == nil || !p.rclosed || !p.wclosed {
+		return os.EINVAL
+	}
+
+	// Drop the wakeups sent by CloseReader and CloseWriter
+	// that nobody received.
+	for _, ok := <-p.cr; ok; _, ok = <-p.cr {
+	}
+	for _, ok := <-p.cw; ok; _, ok = <-p.cw {
+	}
+
+	p.rclosed = false;
+	p.rerr = nil;
+	p.wclosed = false;
+	p.werr = nil;
+	p.wpend = nil;
+	p.wtot = 0;
+	return nil;
+}
+
 // Read/write halves of the pipe.
 // They are separate structures for two reasons:
 //  1.  If one end becomes garbage without being Closed,
@@ -142,10 +185,24 @@ type PipeReader struct {
 // If the write end is closed with an error, that error is
 // returned as err; otherwise err is nil.
 func (r *PipeReader) Read(data []byte) (n int, err os.Error) {
+	// no defer: Read and Write are the hot path of the pipe
+	r.lock.Lock();
+	n, err = r.p.Read(data);
+	r.lock.Unlock();
+	return;
+}
+
+// Peek implements the Peeker interface: it returns the next n
+// bytes of the pipe without consuming them, blocking until a writer
+// arrives or the write end is closed.  Since the pipe does not
+// buffer data, Peek can only return bytes of the pending write; if
+// it is shorter than n bytes, Peek returns it with ErrPeekLimit.
+// The returned slice is the writer's data and must not be modified.
+func (r *PipeReader) Peek(n int) (data []byte, err os.Error) {
 	r.lock.Lock();
 	defer r.lock.Unlock();
 
-	return r.p.Read(data);
+	return r.p.peek(n);
 }
 
 // Close closes the reader; subsequent writes to the
@@ -166,6 +223,20 @@ func (r *PipeReader) CloseWithError(rerr os.Error) os.Error {
 	return r.p.CloseReader(rerr);
 }
 
+// Reset returns the pipe to the state it had when it was created,
+// so that the pipe can be reused instead of allocating a new one.
+// Both halves of the pipe must have been closed, and no goroutine
+// may be using either half while or after it is reset; otherwise
+// data written before Reset may be read after it.  Reset returns
+// os.EINVAL if either half is still open.  Resetting one half
+// resets the other half as well.
+func (r *PipeReader) Reset() os.Error {
+	r.lock.Lock();
+	defer r.lock.Unlock();
+
+	return r.p.reset();
+}
+
 func (r *PipeReader) finish()	{ r.Close() }
 
 // Write half of pipe.
@@ -181,9 +252,9 @@ type PipeWriter struct {
 // returned as err; otherwise err is os.EPIPE.
 func (w *PipeWriter) Write(data []byte) (n int, err os.Error) {
 	w.lock.Lock();
-	defer w.lock.Unlock();
-
-	return w.p.Write(data);
+	n, err = w.p.Write(data);
+	w.lock.Unlock();
+	return;
 }
 
 // Close closes the writer; subsequent reads from the
@@ -204,6 +275,15 @@ func (w *PipeWriter) CloseWithError(werr os.Error) os.Error {
 	return w.p.CloseWriter(werr);
 }
 
+// Reset returns the pipe to the state it had when it was created;
+// see PipeReader.Reset.
+func (w *PipeWriter) Reset() os.Error {
+	w.lock.Lock();
+	defer w.lock.Unlock();
+
+	return w.p.reset();
+}
+
 func (w *PipeWriter) finish()	{ w.Close() }
 
 // Pipe creates a synchronous in-memory pipe.
@@ -211,6 +291,11 @@ func (w *PipeWriter) finish()	{ w.Close() }
 // with code expecting an io.Writer.
 // Reads on one end are matched with writes on the other,
 // copying data directly between the two; there is no internal buffering.
+// Once both halves have been closed, the pipe can be reused by calling
+// Reset.
+//
+// The zero values of PipeReader and PipeWriter are not connected to
+// any pipe; all their methods return os.EINVAL.
 func Pipe() (*PipeReader, *PipeWriter) {
 	p := new(pipe);
 	p.cr = make(chan []byte, 1);
@@ -236,3 +236,82 @@ func TestPipeWriteClose(t *testing.T) {
 		}
 	}
 }
+
+// Test that a closed pipe can be reused after Reset.
+func TestPipeReset(t *testing.T) {
+	c := make(chan int);
+	r, w := Pipe();
+	if err := r.Reset(); err != os.EINVAL {
+		t.Errorf("Reset of open pipe: %v, expected os.EINVAL", err)
+	}
+	buf := make([]byte, 64);
+	for i := 0; i < 3; i++ {
+		go checkWrite(t, w, strings.Bytes("hello, world"), c);
+		n, err := r.Read(buf);
+		if err != nil || n != 12 || string(buf[0:n]) != "hello, world" {
+			t.Errorf("round %d: read %d, %v", i, n, err)
+		}
+		<-c;
+
+		// closing the reader first leaves a stale write
+		// result in the pipe that Reset must drop
+		r.CloseWithError(os.EBADF);
+		if _, err := w.Write(buf); err != os.EBADF {
+			t.Errorf("round %d: write after close: %v", i, err)
+		}
+		w.Close();
+		if i%2 == 0 {
+			err = r.Reset()
+		} else {
+			err = w.Reset()
+		}
+		if err != nil {
+			t.Fatalf("round %d: Reset: %v", i, err)
+		}
+	}
+}
+
+func TestPipeZero(t *testing.T) {
+	var r PipeReader;
+	var w PipeWriter;
+	if _, err := r.Read(make([]byte, 1)); err != os.EINVAL {
+		t.Errorf("zero PipeReader Read: %v", err)
+	}
+	if _, err := w.Write(make([]byte, 1)); err != os.EINVAL {
+		t.Errorf("zero PipeWriter Write: %v", err)
+	}
+	if err := w.Reset(); err != os.EINVAL {
+		t.Errorf("zero PipeWriter Reset: %v", err)
+	}
+}
+
+func TestPipePeek(t *testing.T) {
+	c := make(chan int);
+	r, w := Pipe();
+	go checkWrite(t, w, strings.Bytes("hello"), c);
+	if p, err := r.Peek(2); err != nil || string(p) != "he" {
+		t.Fatalf("Peek(2) = %q, %v; want \"he\", nil", p, err)
+	}
+	if p, err := r.Peek(10); err != ErrPeekLimit || string(p) != "hello" {
+		t.Fatalf("Peek(10) = %q, %v; want \"hello\", ErrPeekLimit", p, err)
+	}
+	lr := LimitReader(r, 3);
+	if _, ok := lr.(Peeker); !ok {
+		t.Fatalf("LimitReader of a pipe is not a Peeker")
+	}
+	if p, err := lr.(Peeker).Peek(4); err != os.EOF || string(p) != "hel" {
+		t.Fatalf("limited Peek(4) = %q, %v; want \"hel\", os.EOF", p, err)
+	}
+	buf := make([]byte, 64);
+	if n, err := r.Read(buf); err != nil || string(buf[0:n]) != "hello" {
+		t.Fatalf("Read after Peek = %q, %v; want \"hello\", nil", buf[0:n], err)
+	}
+	<-c;
+	w.Close();
+	if _, err := r.Peek(1); err != os.EOF {
+		t.Errorf("Peek after Close = %v; want os.EOF", err)
+	}
+	if _, ok := LimitReader(strings.NewReader("x"), 1).(Peeker); ok {
+		t.Errorf("LimitReader of a non-Peeker is a Peeker")
+	}
+}
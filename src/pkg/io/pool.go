@@ -0,0 +1,66 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Pooled internal buffers.
+
+package io
+
+// The ReadFrom methods of Discarder and Discard take their buffers from
+// free lists, one for each buffer size from minCopyBuffer to
+// maxCopyBuffer, and return them when they are done, so that repeated
+// calls do not allocate.  The lists are bounded; buffers that do not fit
+// are left to the collector.  A buffer is reused as soon as the call
+// returns, so the pool holds only buffers whose contents do not matter:
+// data that is read only to be dropped.  Buffers carrying data to a
+// Writer are never pooled, since a Writer may keep the slices it is
+// passed.
+
+// Number of buffer sizes: minCopyBuffer, 2*minCopyBuffer, ..., maxCopyBuffer.
+const nBufferSizes = 7
+
+// Maximum number of free buffers kept of each size.
+const maxFreeBuffers = 4
+
+var freeBuffers [nBufferSizes]chan []byte
+
+func init() {
+	for i := range freeBuffers {
+		freeBuffers[i] = make(chan []byte, maxFreeBuffers)
+	}
+}
+
+// sizeClass returns the index of the smallest buffer size
+// that holds n bytes, or -1 if n exceeds maxCopyBuffer.
+func sizeClass(n int) int {
+	c := 0;
+	for size := minCopyBuffer; size < n; size *= 2 {
+		if size >= maxCopyBuffer {
+			return -1
+		}
+		c++;
+	}
+	return c;
+}
+
+// getBuffer returns a buffer of at least n bytes, which must not
+// exceed maxCopyBuffer, from the free lists if possible.
+func getBuffer(n int) []byte {
+	c := sizeClass(n);
+	select {
+	case b := <-freeBuffers[c]:
+		return b
+	default:
+	}
+	return make([]byte, minCopyBuffer<<uint(c));
+}
+
+// putBuffer returns the buffer b, which must have been
+// returned by getBuffer, to the free lists.
+func putBuffer(b []byte) {
+	select {
+	case freeBuffers[sizeClass(len(b))] <- b:
+	default:
+		// list is full
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Pipe with an urgent and a bulk lane, for multiplexing
+// control messages and data over a single stream.
+
+package io
+
+import (
+	"os";
+	"sync";
+)
+
+// A lane is one of the two ways into a priority pipe.
+type lane struct {
+	lock	sync.Mutex;		// One write per lane at a time.
+	cr	chan []byte;		// Write sends data here...
+	cw	chan pipeReturn;	// ... and reads the n, err back from here.
+}
+
+func newLane() *lane {
+	l := new(lane);
+	l.cr = make(chan []byte, 1);
+	l.cw = make(chan pipeReturn, 1);
+	return l;
+}
+
+// Shared priority pipe structure.
+type priorityPipe struct {
+	rclosed	bool;		// Read end closed?
+	rerr	os.Error;	// Error supplied to CloseReader
+	wclosed	bool;		// Write end closed?
+	werr	os.Error;	// Error supplied to CloseWriter
+	urgent	*lane;
+	bulk	*lane;
+	cur	*lane;		// Lane of the write being read.
+	wpend	[]byte;		// Written data waiting to be read.
+	wtot	int;		// Bytes consumed so far in current write.
+	burst	int;		// Max. urgent writes in a row before a bulk write.
+	run	int;		// Urgent writes read in a row.
+	wake	chan bool;	// CloseWriter wakes a waiting reader here.
+	queued	chan<- bool;	// If set, each queued write is reported here.
+}
+
+// take makes data written to lane l the current write block.
+func (p *priorityPipe) take(l *lane, data []byte) {
+	p.cur = l;
+	p.wpend = data;
+	p.wtot = 0;
+	if l == p.urgent {
+		p.run++
+	} else {
+		p.run = 0
+	}
+}
+
+// next waits for the next write block.  Urgent writes are
+// preferred, but after burst of them in a row a waiting bulk
+// write goes first, so that neither lane starves.
+func (p *priorityPipe) next() {
+	var data []byte;
+	var ok bool;
+	if p.run < p.burst {
+		if data, ok = <-p.urgent.cr; ok {
+			p.take(p.urgent, data);
+			return;
+		}
+	}
+	if data, ok = <-p.bulk.cr; ok {
+		p.take(p.bulk, data);
+		return;
+	}
+	if data, ok = <-p.urgent.cr; ok {
+		p.take(p.urgent, data);
+		return;
+	}
+	select {
+	case data = <-p.urgent.cr:
+		p.take(p.urgent, data)
+	case data = <-p.bulk.cr:
+		p.take(p.bulk, data)
+	case <-p.wake:
+	}
+}
+
+func (p *priorityPipe) Read(data []byte) (n int, err os.Error) {
+	if p == nil || p.rclosed {
+		return 0, os.EINVAL
+	}
+
+	// Wait for next write block if necessary.
+	if p.wpend == nil {
+		if !p.wclosed {
+			p.next()
+		}
+		if p.wpend == nil {
+			return 0, p.werr
+		}
+	}
+
+	// Read from current write block.
+	n = copy(data, p.wpend);
+	p.wtot += n;
+	p.wpend = p.wpend[n:len(p.wpend)];
+
+	// If write block is done, finish the write.
+	// The next Read may pick a block from the other lane.
+	if len(p.wpend) == 0 {
+		p.wpend = nil;
+		p.cur.cw <- pipeReturn{p.wtot, nil};
+		p.wtot = 0;
+	}
+
+	return n, nil;
+}
+
+func (p *priorityPipe) write(l *lane, data []byte) (n int, err os.Error) {
+	l.lock.Lock();
+	defer l.lock.Unlock();
+
+	if p.wclosed {
+		return 0, os.EINVAL
+	}
+	if p.rclosed {
+		return 0, p.rerr
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	// Send data to reader.
+	l.cr <- data;
+	if p.queued != nil {
+		p.queued <- true
+	}
+
+	// Wait for reader to finish copying it.
+	res := <-l.cw;
+	return res.n, res.err;
+}
+
+func (p *priorityPipe) CloseReader(rerr os.Error) os.Error {
+	if p == nil || p.rclosed {
+		return os.EINVAL
+	}
+
+	// Stop any future writes.
+	p.rclosed = true;
+	if rerr == nil {
+		rerr = os.EPIPE
+	}
+	p.rerr = rerr;
+
+	// Stop the current write and the writes not yet started.
+	if p.wpend != nil {
+		p.cur.cw <- pipeReturn{p.wtot, rerr};
+		p.wpend = nil;
+	}
+	if _, ok := <-p.urgent.cr; ok {
+		p.urgent.cw <- pipeReturn{0, rerr}
+	}
+	if _, ok := <-p.bulk.cr; ok {
+		p.bulk.cw <- pipeReturn{0, rerr}
+	}
+
+	return nil;
+}
+
+func (p *priorityPipe) CloseWriter(werr os.Error) os.Error {
+	if werr == nil {
+		werr = os.EOF
+	}
+	if p == nil {
+		return os.EINVAL
+	}
+
+	// Wait for the writes in progress.
+	p.urgent.lock.Lock();
+	defer p.urgent.lock.Unlock();
+	p.bulk.lock.Lock();
+	defer p.bulk.lock.Unlock();
+
+	if p.wclosed {
+		return os.EINVAL
+	}
+
+	// Stop any future reads.
+	p.wclosed = true;
+	p.werr = werr;
+
+	// Stop the current read.
+	if !p.rclosed {
+		p.wake <- true
+	}
+
+	return nil;
+}
+
+// A PriorityPipeReader is the read half of a priority pipe.
+type PriorityPipeReader struct {
+	lock	sync.Mutex;
+	p	*priorityPipe;
+}
+
+// Read implements the standard Read interface:
+// it reads data from the pipe, blocking until a writer
+// arrives or the write end is closed.
+// Data of a single write is read without interruption;
+// between writes, urgent writes are read before bulk writes.
+// If the write end is closed with an error, that error is
+// returned as err; otherwise err is os.EOF.
+func (r *PriorityPipeReader) Read(data []byte) (n int, err os.Error) {
+	r.lock.Lock();
+	defer r.lock.Unlock();
+
+	return r.p.Read(data);
+}
+
+// Close closes the reader; subsequent writes to the
+// write half of the pipe will return the error os.EPIPE.
+func (r *PriorityPipeReader) Close() os.Error {
+	r.lock.Lock();
+	defer r.lock.Unlock();
+
+	return r.p.CloseReader(nil);
+}
+
+// CloseWithError closes the reader; subsequent writes
+// to the write half of the pipe will return the error rerr.
+func (r *PriorityPipeReader) CloseWithError(rerr os.Error) os.Error {
+	r.lock.Lock();
+	defer r.lock.Unlock();
+
+	return r.p.CloseReader(rerr);
+}
+
+// A PriorityPipeWriter is the write half of a priority pipe.
+// Its Write and WriteUrgent methods may be called concurrently.
+type PriorityPipeWriter struct {
+	p *priorityPipe;
+}
+
+// Write implements the standard Write interface:
+// it writes bulk data to the pipe, blocking until readers
+// have consumed all the data or the read end is closed.
+// If the read end is closed with an error, that err is
+// returned as err; otherwise err is os.EPIPE.
+func (w *PriorityPipeWriter) Write(data []byte) (n int, err os.Error) {
+	if w.p == nil {
+		return 0, os.EINVAL
+	}
+	return w.p.write(w.p.bulk, data);
+}
+
+// WriteUrgent is like Write but the data overtakes any bulk writes
+// that have not yet begun to be read.  A bulk write that is being read
+// is never interrupted; the urgent data follows it.
+func (w *PriorityPipeWriter) WriteUrgent(data []byte) (n int, err os.Error) {
+	if w.p == nil {
+		return 0, os.EINVAL
+	}
+	return w.p.write(w.p.urgent, data);
+}
+
+// Close closes the writer once the writes in progress are done;
+// subsequent reads from the read half of the pipe will return
+// no bytes and os.EOF.
+func (w *PriorityPipeWriter) Close() os.Error	{ return w.p.CloseWriter(nil) }
+
+// CloseWithError closes the writer once the writes in progress are
+// done; subsequent reads from the read half of the pipe will return
+// no bytes and the error werr.
+func (w *PriorityPipeWriter) CloseWithError(werr os.Error) os.Error {
+	return w.p.CloseWriter(werr)
+}
+
+// PriorityPipe creates a synchronous in-memory pipe with two lanes:
+// data written with WriteUrgent, such as control messages, overtakes
+// data written with Write at the boundaries between writes.  To keep
+// bulk data from starving, at most burst urgent writes are read in a
+// row while a bulk write is waiting; burst values below 1 mean 1.
+// Like Pipe, PriorityPipe does no internal buffering.
+func PriorityPipe(burst int) (*PriorityPipeReader, *PriorityPipeWriter) {
+	if burst < 1 {
+		burst = 1
+	}
+	p := new(priorityPipe);
+	p.urgent = newLane();
+	p.bulk = newLane();
+	p.burst = burst;
+	p.wake = make(chan bool, 1);
+	r := new(PriorityPipeReader);
+	r.p = p;
+	w := new(PriorityPipeWriter);
+	w.p = p;
+	return r, w;
+}
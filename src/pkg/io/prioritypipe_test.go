@@ -0,0 +1,75 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	. "io";
+	"os";
+	"strings";
+	"testing";
+)
+
+func expectString(t *testing.T, r Reader, expected string) {
+	buf := make([]byte, 64);
+	n, err := r.Read(buf);
+	if err != nil || string(buf[0:n]) != expected {
+		t.Errorf("read %q, %v; expected %q", buf[0:n], err, expected)
+	}
+}
+
+func writeStrings(t *testing.T, write func([]byte) (int, os.Error), list []string, c chan int) {
+	for _, s := range list {
+		if n, err := write(strings.Bytes(s)); n != len(s) || err != nil {
+			t.Errorf("write %q: %d, %v", s, n, err)
+		}
+	}
+	c <- 0;
+}
+
+// Test that urgent writes overtake waiting bulk writes
+// but do not interrupt the bulk write being read.
+func TestPriorityPipeOrder(t *testing.T) {
+	c := make(chan int);
+	r, w, queued := PriorityPipeQueued(2);
+	go writeStrings(t, w.Write, []string{"bulk1", "bulk2"}, c);
+	<-queued;	// bulk1
+	var buf [3]byte;
+	if n, _ := r.Read(&buf); string(buf[0:n]) != "bul" {
+		t.Errorf("read %q, expected %q", buf[0:n], "bul")
+	}
+	go writeStrings(t, w.WriteUrgent, []string{"urgent"}, c);
+	<-queued;	// urgent
+	expectString(t, r, "k1");
+	<-queued;	// bulk2
+	expectString(t, r, "urgent");
+	expectString(t, r, "bulk2");
+	<-c;
+	<-c;
+	w.Close();
+	if n, err := r.Read(&buf); n != 0 || err != os.EOF {
+		t.Errorf("read after close: %d, %v; expected 0, os.EOF", n, err)
+	}
+}
+
+// Test that a stream of urgent writes does not starve bulk writes.
+func TestPriorityPipeBurst(t *testing.T) {
+	c := make(chan int);
+	r, w, queued := PriorityPipeQueued(1);
+	go writeStrings(t, w.Write, []string{"bulk"}, c);
+	go writeStrings(t, w.WriteUrgent, []string{"u1", "u2"}, c);
+	<-queued;	// bulk and u1
+	<-queued;
+	expectString(t, r, "u1");
+	<-queued;	// u2
+	expectString(t, r, "bulk");
+	expectString(t, r, "u2");
+	<-c;
+	<-c;
+	r.Close();
+	if _, err := w.WriteUrgent(strings.Bytes("x")); err != os.EPIPE {
+		t.Errorf("write after close: %v; expected os.EPIPE", err)
+	}
+	w.Close();
+}
@@ -0,0 +1,126 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Resumable Reader.
+
+package io
+
+import "os"
+
+// ErrNoResume means that a ResumeReader cannot reposition its
+// stream because it has neither a Seeker nor a ReopenFunc.
+var ErrNoResume os.Error = &Error{"cannot resume: no Seeker or ReopenFunc"}
+
+// A ReopenFunc returns a new Reader for the same stream, positioned
+// at offset off, for instance by reconnecting to a server and asking
+// for the data starting at off.
+type ReopenFunc func(off int64) (Reader, os.Error)
+
+// A ResumeReader reads from an underlying stream and keeps track of
+// the offset of the data it returned, so that a transfer can continue
+// after the stream failed.  The stream is repositioned by calling a
+// ReopenFunc or, if there is none, by seeking if the underlying Reader
+// is a Seeker.
+//
+// Read repositions the stream transparently: if the underlying Read
+// fails with an error other than os.EOF, the stream is reopened at the
+// offset of the data returned so far and the Read is retried, up to a
+// fixed number of times in a row.  Data returned along with the error
+// is returned first, and the stream is reopened by the next Read.
+//
+// The client may also confirm an offset, for instance once the data
+// before it has been written to disk, and resume the transfer from
+// the last confirmed offset later, discarding the data returned since.
+type ResumeReader struct {
+	r		Reader;
+	reopen		ReopenFunc;
+	retries		int;		// max. consecutive retries of a failed Read
+	failed		int;		// consecutive failed Reads
+	err		os.Error;	// error of the last Read, if the stream is to be reopened
+	off		int64;		// offset of the next byte returned by Read
+	confirmed	int64;		// last confirmed offset
+}
+
+// NewResumeReader returns a ResumeReader reading from r, which is
+// positioned at offset off of the stream.  If reopen is nil, r must be
+// a Seeker to resume.  A failed Read is retried up to retries times in
+// a row before its error is returned.
+func NewResumeReader(r Reader, off int64, reopen ReopenFunc, retries int) *ResumeReader {
+	return &ResumeReader{r: r, reopen: reopen, retries: retries, off: off, confirmed: off}
+}
+
+// Read reads from the stream, repositioning it after failures.
+func (r *ResumeReader) Read(p []byte) (n int, err os.Error) {
+	for {
+		if r.err != nil {
+			// the last Read failed; reposition the stream or give up
+			err = r.err;
+			r.err = nil;
+			if r.failed >= r.retries {
+				return 0, err
+			}
+			r.failed++;
+			if e := r.seek(r.off); e != nil {
+				return 0, err
+			}
+		}
+		n, err = r.r.Read(p);
+		r.off += int64(n);
+		switch {
+		case err == nil || err == os.EOF:
+			r.failed = 0;
+			return;
+		case n > 0:
+			// return the data; the next Read repositions the stream
+			r.failed = 0;
+			r.err = err;
+			return n, nil;
+		}
+		r.err = err;
+	}
+	panic("unreachable");
+}
+
+// seek repositions the stream at offset off.
+func (r *ResumeReader) seek(off int64) os.Error {
+	if r.reopen != nil {
+		nr, err := r.reopen(off);
+		if err != nil {
+			return err
+		}
+		if c, ok := r.r.(Closer); ok {
+			c.Close()
+		}
+		r.r = nr;
+		return nil;
+	}
+	s, ok := r.r.(Seeker);
+	if !ok {
+		return ErrNoResume
+	}
+	_, err := s.Seek(off, 0);
+	return err;
+}
+
+// Offset returns the offset of the next byte returned by Read.
+func (r *ResumeReader) Offset() int64	{ return r.off }
+
+// Confirm records the current offset, so that a later
+// Resume continues from there.
+func (r *ResumeReader) Confirm()	{ r.confirmed = r.off }
+
+// Confirmed returns the last confirmed offset.
+func (r *ResumeReader) Confirmed() int64	{ return r.confirmed }
+
+// Resume repositions the stream at the last confirmed offset;
+// subsequent reads return the data following it again.
+func (r *ResumeReader) Resume() os.Error {
+	if err := r.seek(r.confirmed); err != nil {
+		return err
+	}
+	r.off = r.confirmed;
+	r.failed = 0;
+	r.err = nil;
+	return nil;
+}
@@ -0,0 +1,132 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	"bytes";
+	. "io";
+	"os";
+	"strings";
+	"testing";
+)
+
+var errFlaky = os.NewError("connection reset")
+
+// A flakyReader reads data in chunks of 3 bytes and fails
+// once it has returned failAfter chunks since the last Seek.
+// With withData, the last chunk comes with the error.
+type flakyReader struct {
+	data		[]byte;
+	pos		int;
+	chunks		int;
+	failAfter	int;
+	withData	bool;
+	seeks		int;
+}
+
+func (r *flakyReader) Read(p []byte) (n int, err os.Error) {
+	if r.chunks == r.failAfter {
+		return 0, errFlaky
+	}
+	if r.pos >= len(r.data) {
+		return 0, os.EOF
+	}
+	if len(p) > 3 {
+		p = p[0:3]
+	}
+	n = copy(p, r.data[r.pos:len(r.data)]);
+	r.pos += n;
+	r.chunks++;
+	if r.withData && r.chunks == r.failAfter {
+		err = errFlaky
+	}
+	return;
+}
+
+func (r *flakyReader) Seek(off int64, whence int) (int64, os.Error) {
+	r.pos = int(off);
+	r.chunks = 0;
+	r.seeks++;
+	return off, nil;
+}
+
+const resumeData = "the quick brown fox jumps over the lazy dog"
+
+func TestResumeReaderSeek(t *testing.T) {
+	f := &flakyReader{data: strings.Bytes(resumeData), failAfter: 2};
+	r := NewResumeReader(f, 0, nil, 1);
+	var buf bytes.Buffer;
+	if _, err := Copy(&buf, r); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if s := buf.String(); s != resumeData {
+		t.Errorf("got %q, expected %q", s, resumeData)
+	}
+	if f.seeks == 0 {
+		t.Errorf("stream was never repositioned")
+	}
+	if r.Offset() != int64(len(resumeData)) {
+		t.Errorf("Offset = %d, expected %d", r.Offset(), len(resumeData))
+	}
+}
+
+func TestResumeReaderDataWithError(t *testing.T) {
+	f := &flakyReader{data: strings.Bytes(resumeData), failAfter: 2, withData: true};
+	r := NewResumeReader(f, 0, nil, 1);
+	var buf bytes.Buffer;
+	if _, err := Copy(&buf, r); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if s := buf.String(); s != resumeData {
+		t.Errorf("got %q, expected %q", s, resumeData)
+	}
+}
+
+func TestResumeReaderGiveUp(t *testing.T) {
+	f := &flakyReader{data: strings.Bytes(resumeData), failAfter: 0};
+	r := NewResumeReader(f, 0, nil, 2);
+	var b [10]byte;
+	if n, err := r.Read(&b); n != 0 || err != errFlaky {
+		t.Errorf("Read = %d, %v; expected 0, %v", n, err, errFlaky)
+	}
+	if f.seeks != 2 {
+		t.Errorf("%d retries, expected 2", f.seeks)
+	}
+}
+
+func TestResumeReaderConfirm(t *testing.T) {
+	var offsets []int64;
+	reopen := func(off int64) (Reader, os.Error) {
+		o := make([]int64, len(offsets)+1);
+		copy(o, offsets);
+		o[len(offsets)] = off;
+		offsets = o;
+		return bytes.NewBuffer(strings.Bytes(resumeData[int(off):len(resumeData)])), nil;
+	};
+	r := NewResumeReader(bytes.NewBufferString(resumeData), 0, reopen, 0);
+	var b [4]byte;
+	ReadFull(r, &b);
+	r.Confirm();
+	ReadFull(r, &b);
+	if err := r.Resume(); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if r.Offset() != 4 || r.Confirmed() != 4 {
+		t.Errorf("Offset, Confirmed = %d, %d; expected 4, 4", r.Offset(), r.Confirmed())
+	}
+	ReadFull(r, &b);
+	if s := string(b[0:4]); s != "quic" {
+		t.Errorf("read %q after Resume, expected %q", s, "quic")
+	}
+	if len(offsets) != 1 || offsets[0] != 4 {
+		t.Errorf("reopened at %v, expected [4]", offsets)
+	}
+
+	// without a Seeker or a ReopenFunc, Resume fails
+	r = NewResumeReader(bytes.NewBufferString(resumeData), 0, nil, 0);
+	if err := r.Resume(); err != ErrNoResume {
+		t.Errorf("Resume = %v, expected %v", err, ErrNoResume)
+	}
+}
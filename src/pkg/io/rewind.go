@@ -0,0 +1,128 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Checkpointing Reader.
+
+package io
+
+import "os"
+
+// ErrNoMark means that Rewind was called on a RewindReader
+// without a preceding Mark.
+var ErrNoMark os.Error = &Error{"rewind without mark"}
+
+// ErrRewindLimit means that Rewind was called on a RewindReader
+// after more data than its limit was read since the last Mark.
+var ErrRewindLimit os.Error = &Error{"rewind limit exceeded"}
+
+// A RewindReader reads from an underlying Reader and can return
+// to a previously marked position, so that, for instance, a protocol
+// negotiator can try one parse of the input, rewind, and try another.
+// The data read since the mark is retained in memory, but never more
+// than a fixed limit; reading beyond the limit does not fail, but a
+// subsequent Rewind does.
+type RewindReader struct {
+	r		Reader;
+	max		int;
+	buf		[]byte;	// data read since the mark
+	pos		int;	// read position in buf; pos < len(buf) after a Rewind
+	marked		bool;	// whether there is a mark
+	overflow	bool;	// whether more than max bytes were read since the mark
+}
+
+// NewRewindReader returns a RewindReader reading from r that retains
+// at most max bytes for rewinding.
+func NewRewindReader(r Reader, max int) *RewindReader {
+	return &RewindReader{r: r, max: max}
+}
+
+// Mark sets a checkpoint at the current position, replacing any
+// previous one.  Data read before the checkpoint can no longer be
+// reread with Rewind.
+func (r *RewindReader) Mark() {
+	if r.pos > 0 {
+		// drop the data before the checkpoint; it stays
+		// in buf only as long as it has not been reread
+		n := copy(r.buf, r.buf[r.pos:len(r.buf)]);
+		r.buf = r.buf[0:n];
+		r.pos = 0;
+	}
+	r.marked = true;
+	r.overflow = false;
+}
+
+// Release removes the checkpoint set by Mark, so that the reader
+// stops retaining data.
+func (r *RewindReader) Release() {
+	r.marked = false;
+	r.overflow = false;
+	if r.pos == len(r.buf) {
+		// nothing left to reread
+		r.buf = r.buf[0:0];
+		r.pos = 0;
+	}
+}
+
+// Rewind returns to the checkpoint set by the last Mark; subsequent
+// reads return the data read since then again.  The checkpoint
+// remains, so Rewind may be called repeatedly.  Rewind returns
+// ErrNoMark if there is no checkpoint, and ErrRewindLimit if more
+// than the limit passed to NewRewindReader has been read since the
+// checkpoint; in both cases, the read position is unchanged.
+func (r *RewindReader) Rewind() os.Error {
+	switch {
+	case !r.marked:
+		return ErrNoMark
+	case r.overflow:
+		return ErrRewindLimit
+	}
+	r.pos = 0;
+	return nil;
+}
+
+// Read reads previously retained data after a Rewind and
+// from the underlying Reader otherwise.
+func (r *RewindReader) Read(p []byte) (n int, err os.Error) {
+	if r.pos < len(r.buf) {
+		n = copy(p, r.buf[r.pos:len(r.buf)]);
+		r.pos += n;
+		if !r.marked && r.pos == len(r.buf) {
+			r.buf = r.buf[0:0];
+			r.pos = 0;
+		}
+		return;
+	}
+
+	n, err = r.r.Read(p);
+	if r.marked && !r.overflow && n > 0 {
+		r.save(p[0:n])
+	}
+	return;
+}
+
+// save retains p for rewinding.
+func (r *RewindReader) save(p []byte) {
+	n := len(r.buf);
+	if n+len(p) > r.max {
+		r.overflow = true;
+		r.buf = nil;
+		r.pos = 0;
+		return;
+	}
+	if n+len(p) > cap(r.buf) {
+		c := 2 * cap(r.buf);
+		if c < n+len(p) {
+			c = n + len(p)
+		}
+		if c > r.max {
+			c = r.max
+		}
+		b := make([]byte, n, c);
+		copy(b, r.buf);
+		r.buf = b;
+	}
+	r.buf = r.buf[0 : n+len(p)];
+	copy(r.buf[n:len(r.buf)], p);
+	r.pos = len(r.buf);
+}
@@ -0,0 +1,84 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	. "io";
+	"strings";
+	"testing";
+)
+
+func readString(t *testing.T, r Reader, n int) string {
+	buf := make([]byte, n);
+	if _, err := ReadFull(r, buf); err != nil {
+		t.Fatalf("ReadFull: %v", err)
+	}
+	return string(buf);
+}
+
+func TestRewindReader(t *testing.T) {
+	r := NewRewindReader(strings.NewReader("hello, world"), 8);
+
+	if err := r.Rewind(); err != ErrNoMark {
+		t.Errorf("Rewind without Mark: %v, expected %v", err, ErrNoMark)
+	}
+
+	r.Mark();
+	if s := readString(t, r, 5); s != "hello" {
+		t.Errorf("read %q, expected %q", s, "hello")
+	}
+	if err := r.Rewind(); err != nil {
+		t.Fatalf("Rewind: %v", err)
+	}
+	if s := readString(t, r, 7); s != "hello, " {
+		t.Errorf("read %q after Rewind, expected %q", s, "hello, ")
+	}
+
+	// move the mark within the retained data
+	if err := r.Rewind(); err != nil {
+		t.Fatalf("Rewind: %v", err)
+	}
+	if s := readString(t, r, 2); s != "he" {
+		t.Errorf("read %q after Rewind, expected %q", s, "he")
+	}
+	r.Mark();
+	if s := readString(t, r, 8); s != "llo, wor" {
+		t.Errorf("read %q, expected %q", s, "llo, wor")
+	}
+	if err := r.Rewind(); err != nil {
+		t.Fatalf("Rewind: %v", err)
+	}
+	if s := readString(t, r, 10); s != "llo, world" {
+		t.Errorf("read %q after Rewind, expected %q", s, "llo, world")
+	}
+
+	// more than 8 bytes have been read since the mark
+	if err := r.Rewind(); err != ErrRewindLimit {
+		t.Errorf("Rewind: %v, expected %v", err, ErrRewindLimit)
+	}
+}
+
+func TestRewindReaderRelease(t *testing.T) {
+	r := NewRewindReader(strings.NewReader("abcdef"), 4);
+	r.Mark();
+	readString(t, r, 3);
+	r.Rewind();
+	r.Release();
+	if s := readString(t, r, 6); s != "abcdef" {
+		t.Errorf("read %q after Release, expected %q", s, "abcdef")
+	}
+	if err := r.Rewind(); err != ErrNoMark {
+		t.Errorf("Rewind after Release: %v, expected %v", err, ErrNoMark)
+	}
+
+	// data read before the Release is not retained
+	r = NewRewindReader(strings.NewReader("abcdef"), 4);
+	r.Mark();
+	readString(t, r, 3);
+	r.Release();
+	if n := r.Retained(); n != 0 {
+		t.Errorf("%d bytes retained after Release, expected 0", n)
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Tokenizing Reader.
+
+package io
+
+import (
+	"os";
+	"unicode";
+	"utf8";
+)
+
+// ErrTokenTooLong means that a Scanner found a token
+// longer than its maximum token size.
+var ErrTokenTooLong os.Error = &Error{"scanner token too long"}
+
+// ErrNegativeAdvance means that the split function of a
+// Scanner returned a negative number of bytes to advance.
+var ErrNegativeAdvance os.Error = &Error{"split function returns negative advance count"}
+
+// ErrAdvanceTooFar means that the split function of a Scanner
+// returned a number of bytes to advance beyond its input.
+var ErrAdvanceTooFar os.Error = &Error{"split function returns advance count beyond input"}
+
+// ErrNoProgress means that a Scanner or a Combiner made no progress:
+// a Reader returned no data and no error, or the split function of a
+// Scanner returned empty tokens without consuming any data, many
+// times in a row.
+var ErrNoProgress os.Error = &Error{"no progress"}
+
+// MaxScanTokenSize is the default maximum size of a token
+// returned by a Scanner; see SetMaxTokenSize.
+const MaxScanTokenSize = 64 * 1024
+
+// Initial size of the buffer of a Scanner.
+const minScanBuffer = 4096
+
+// Number of consecutive empty reads, or empty tokens without progress,
+// after which a Scanner or a Combiner gives up with ErrNoProgress.
+const maxEmptyReads = 100
+
+// A SplitFunc splits the input of a Scanner into tokens.  It is
+// called with the data not yet consumed, and atEOF set if the Reader
+// has reached EOF, and returns the number of bytes of the data to
+// consume and the next token, if any.  A function that needs more
+// data to find a token returns 0 and nil; it is called again with
+// more data, or with atEOF set.  A token may be empty; a nil token
+// with advance > 0 skips data without returning a token.
+//
+// If the function returns an error, the Scanner stops and Err
+// returns it.  At EOF, data left that does not form a token must be
+// reported as an error, as ScanFrames does, or dropped, as ScanWords
+// does, since the Scanner does not call the function again once it
+// has returned 0 and nil with atEOF set.
+type SplitFunc func(data []byte, atEOF bool) (advance int, token []byte, err os.Error)
+
+// A Scanner reads the data of a Reader and splits it into tokens, such
+// as lines, with a SplitFunc.  Successive calls to Scan step through
+// the tokens; Bytes or Text return the current one.  Scanning stops at
+// the end of the input, at the first error of the Reader, at the first
+// error of the split function, and at a token longer than the maximum
+// token size.  Once Scan has returned false, it keeps returning false,
+// and Err reports why scanning stopped: nil at the end of the input
+// and the error otherwise.  The complete tokens read before a read
+// error are returned before Scan stops, but the data following them
+// is dropped; an error of the split function, or a token that is too
+// long, stops the Scanner right away.
+//
+// A Scanner is meant for streams of many small tokens, such as the
+// lines of a log or the frames of a protocol, read from a pipe or a
+// connection.  Programs that need finer control over errors or large
+// tokens should read the Reader directly.
+type Scanner struct {
+	r		Reader;
+	split		SplitFunc;
+	max		int;		// maximum token size
+	token		[]byte;		// current token
+	buf		[]byte;
+	start, end	int;		// unconsumed data in buf[start:end]
+	err		os.Error;	// first read error, or os.EOF
+	stopErr		os.Error;	// error stopping the Scanner; nil at EOF
+	scanning	bool;		// whether Scan has been called
+	empties		int;		// consecutive empty tokens without progress
+	done		bool;		// whether Scan has returned false
+}
+
+// NewScanner returns a Scanner reading from r that splits
+// its data into lines with ScanLines.
+func NewScanner(r Reader) *Scanner {
+	return &Scanner{r: r, split: ScanLines, max: MaxScanTokenSize}
+}
+
+// Split sets the split function of s.  It panics
+// if it is called after the first call to Scan.
+func (s *Scanner) Split(split SplitFunc) {
+	if s.scanning {
+		panic("io: Scanner.Split called after Scan")
+	}
+	s.split = split;
+}
+
+// SetMaxTokenSize sets the maximum size of a token, MaxScanTokenSize
+// by default; it bounds the memory used by s.  It panics if it is
+// called after the first call to Scan.
+func (s *Scanner) SetMaxTokenSize(n int) {
+	if s.scanning {
+		panic("io: Scanner.SetMaxTokenSize called after Scan")
+	}
+	s.max = n;
+}
+
+// Err returns the error that stopped s, or nil if
+// s reached the end of its input or has not stopped.
+func (s *Scanner) Err() os.Error	{ return s.stopErr }
+
+// Bytes returns the token found by the last call to Scan.  The slice
+// refers to the buffer of s; its contents are overwritten by the next
+// call to Scan.
+func (s *Scanner) Bytes() []byte	{ return s.token }
+
+// Text returns a copy of the token found by the last call to Scan.
+func (s *Scanner) Text() string	{ return string(s.token) }
+
+// stop stops s with the error err, which is nil at the end of the input.
+func (s *Scanner) stop(err os.Error) bool {
+	s.stopErr = err;
+	s.done = true;
+	s.token = nil;
+	return false;
+}
+
+// Scan advances s to the next token, which is then available through
+// Bytes or Text.  It returns false when s stops; see Err.
+func (s *Scanner) Scan() bool {
+	if s.done {
+		return false
+	}
+	s.scanning = true;
+	s.token = nil;
+	for {
+		// look for a token in the data read so far; at EOF, look
+		// even if there is no data, so that split can handle the end.
+		// After a read error, the data is not at EOF: an incomplete
+		// token must not be taken for a complete one.
+		atEOF := s.err == os.EOF;
+		if s.end > s.start || atEOF {
+			advance, token, err := s.split(s.buf[s.start:s.end], atEOF);
+			switch {
+			case err != nil:
+				return s.stop(err)
+			case advance < 0:
+				return s.stop(ErrNegativeAdvance)
+			case advance > s.end-s.start:
+				return s.stop(ErrAdvanceTooFar)
+			}
+			s.start += advance;
+			if token != nil {
+				if advance == 0 && len(token) == 0 {
+					s.empties++;
+					if s.empties > maxEmptyReads {
+						return s.stop(ErrNoProgress)
+					}
+				} else {
+					s.empties = 0
+				}
+				s.token = token;
+				return true;
+			}
+			if advance > 0 {
+				continue
+			}
+		}
+
+		// no token in the data; stop at EOF or after a read error
+		if s.err != nil {
+			if s.err == os.EOF {
+				return s.stop(nil)
+			}
+			return s.stop(s.err);
+		}
+
+		// make room for more data: move the unconsumed data to the
+		// front of the buffer, or, if that does not help, grow it
+		if s.start > 0 && (s.end == len(s.buf) || s.start > len(s.buf)/2) {
+			copy(s.buf, s.buf[s.start:s.end]);
+			s.end -= s.start;
+			s.start = 0;
+		}
+		if s.end == len(s.buf) {
+			if len(s.buf) >= s.max {
+				return s.stop(ErrTokenTooLong)
+			}
+			n := 2 * len(s.buf);
+			if n < minScanBuffer {
+				n = minScanBuffer
+			}
+			if n > s.max {
+				n = s.max
+			}
+			buf := make([]byte, n);
+			copy(buf, s.buf[s.start:s.end]);
+			s.end -= s.start;
+			s.start = 0;
+			s.buf = buf;
+		}
+
+		// read more data; a Reader that returns no data without
+		// an error is retried, but not forever
+		for i := 0; ; i++ {
+			n, err := s.r.Read(s.buf[s.end:len(s.buf)]);
+			if n > 0 {
+				s.end += n
+			}
+			if err != nil {
+				s.err = err;
+				break;
+			}
+			if n > 0 {
+				break
+			}
+			if i >= maxEmptyReads {
+				return s.stop(ErrNoProgress)
+			}
+		}
+	}
+	panic("unreachable");
+}
+
+// ScanLines is a SplitFunc returning the lines of the input without
+// their line endings, "\n" or "\r\n".  The last line need not end in
+// a newline; an empty last line is not returned.
+func ScanLines(data []byte, atEOF bool) (advance int, token []byte, err os.Error) {
+	for i, c := range data {
+		if c == '\n' {
+			return i + 1, dropCR(data[0:i]), nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), dropCR(data), nil
+	}
+	return 0, nil, nil;
+}
+
+// dropCR drops a terminating '\r' from line.
+func dropCR(line []byte) []byte {
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		return line[0 : len(line)-1]
+	}
+	return line;
+}
+
+// isSpaceAt reports whether the data at i starts with a space
+// character, and returns the size of the character.
+func isSpaceAt(data []byte, i int) (bool, int) {
+	if data[i] < utf8.RuneSelf {
+		return unicode.IsSpace(int(data[i])), 1
+	}
+	rune, size := utf8.DecodeRune(data[i:len(data)]);
+	return unicode.IsSpace(rune), size;
+}
+
+// ScanWords is a SplitFunc returning the words of the input, which
+// are separated by space characters as defined by unicode.IsSpace.
+func ScanWords(data []byte, atEOF bool) (advance int, token []byte, err os.Error) {
+	// skip leading spaces
+	start := 0;
+	for start < len(data) {
+		space, size := isSpaceAt(data, start);
+		if !space {
+			break
+		}
+		start += size;
+	}
+	// find the end of the word
+	for i := start; i < len(data); {
+		space, size := isSpaceAt(data, i);
+		if space {
+			return i + size, data[start:i], nil
+		}
+		i += size;
+	}
+	if atEOF && len(data) > start {
+		return len(data), data[start:len(data)], nil
+	}
+	return start, nil, nil;
+}
+
+// ScanFrames is a SplitFunc returning the frames of the input, each
+// of which is preceded by its length as a 4-byte big-endian number.
+// The returned tokens do not include the length.  Input ending within
+// a frame is reported as ErrUnexpectedEOF.
+func ScanFrames(data []byte, atEOF bool) (advance int, token []byte, err os.Error) {
+	if len(data) >= 4 {
+		n := int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3]);
+		if n >= 0 && 4+n <= len(data) {
+			return 4 + n, data[4 : 4+n], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return 0, nil, ErrUnexpectedEOF
+	}
+	return 0, nil, nil;
+}
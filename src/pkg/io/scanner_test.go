@@ -0,0 +1,155 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	"bytes";
+	. "io";
+	"os";
+	"strings";
+	"testing";
+	"testing/iotest";
+)
+
+// scanAll returns the tokens of s and the error that stopped it.
+func scanAll(s *Scanner) ([]string, os.Error) {
+	var list []string;
+	for s.Scan() {
+		n := len(list);
+		l := make([]string, n+1);
+		copy(l, list);
+		l[n] = s.Text();
+		list = l;
+	}
+	return list, s.Err();
+}
+
+func checkTokens(t *testing.T, name string, got, want []string) {
+	if len(got) != len(want) {
+		t.Errorf("%s: got %d tokens %q, want %d tokens %q", name, len(got), got, len(want), want);
+		return;
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("%s: token %d = %q, want %q", name, i, got[i], want[i])
+		}
+	}
+}
+
+type scanTest struct {
+	name	string;
+	split	SplitFunc;
+	in	string;
+	tokens	[]string;
+}
+
+var scanTests = []scanTest{
+	scanTest{"lines", ScanLines, "one\ntwo\r\n\nthree", []string{"one", "two", "", "three"}},
+	scanTest{"lines with final newline", ScanLines, "one\ntwo\n", []string{"one", "two"}},
+	scanTest{"no lines", ScanLines, "", nil},
+	scanTest{"words", ScanWords, "  hello,\tworld again \n", []string{"hello,", "world", "again"}},
+	scanTest{"only spaces", ScanWords, " \t\n ", nil},
+	scanTest{"frames", ScanFrames, "\x00\x00\x00\x03abc\x00\x00\x00\x00\x00\x00\x00\x01d", []string{"abc", "", "d"}},
+}
+
+func TestScanner(t *testing.T) {
+	for _, test := range scanTests {
+		// read all at once and one byte at a time
+		readers := []Reader{
+			strings.NewReader(test.in),
+			iotest.OneByteReader(strings.NewReader(test.in)),
+		};
+		for _, r := range readers {
+			s := NewScanner(r);
+			s.Split(test.split);
+			tokens, err := scanAll(s);
+			if err != nil {
+				t.Errorf("%s: Err = %v", test.name, err)
+			}
+			checkTokens(t, test.name, tokens, test.tokens);
+			if s.Scan() {
+				t.Errorf("%s: Scan after the end = true", test.name)
+			}
+		}
+	}
+}
+
+func TestScannerLongLines(t *testing.T) {
+	// lines longer than the initial buffer
+	buf := make([]byte, 10000);
+	for i := range buf {
+		buf[i] = 'x'
+	}
+	line := string(buf);
+	s := NewScanner(strings.NewReader(line + "\n" + line + "\n"));
+	tokens, err := scanAll(s);
+	if err != nil {
+		t.Errorf("Err = %v", err)
+	}
+	checkTokens(t, "long lines", tokens, []string{line, line});
+}
+
+func TestScannerTooLong(t *testing.T) {
+	s := NewScanner(strings.NewReader("short\nmuch too long\nshort\n"));
+	s.SetMaxTokenSize(8);
+	tokens, err := scanAll(s);
+	if err != ErrTokenTooLong {
+		t.Errorf("Err = %v, want %v", err, ErrTokenTooLong)
+	}
+	checkTokens(t, "too long", tokens, []string{"short"});
+}
+
+func TestScannerReadError(t *testing.T) {
+	// the reader fails within the third line, which is dropped
+	r := &flakyReader{data: strings.Bytes("a\nb\nccc\n"), failAfter: 2};
+	tokens, err := scanAll(NewScanner(r));
+	if err != errFlaky {
+		t.Errorf("Err = %v, want %v", err, errFlaky)
+	}
+	checkTokens(t, "read error", tokens, []string{"a", "b"});
+}
+
+func TestScannerTruncatedFrame(t *testing.T) {
+	s := NewScanner(bytes.NewBufferString("\x00\x00\x00\x01a\x00\x00\x00\x05bc"));
+	s.Split(ScanFrames);
+	tokens, err := scanAll(s);
+	if err != ErrUnexpectedEOF {
+		t.Errorf("Err = %v, want %v", err, ErrUnexpectedEOF)
+	}
+	checkTokens(t, "truncated frame", tokens, []string{"a"});
+}
+
+func badSplit(data []byte, atEOF bool) (int, []byte, os.Error) {
+	return len(data) + 1, nil, nil
+}
+
+func emptySplit(data []byte, atEOF bool) (int, []byte, os.Error) {
+	return 0, data[0:0], nil
+}
+
+func TestScannerBadSplit(t *testing.T) {
+	s := NewScanner(strings.NewReader("data"));
+	s.Split(badSplit);
+	if _, err := scanAll(s); err != ErrAdvanceTooFar {
+		t.Errorf("Err = %v, want %v", err, ErrAdvanceTooFar)
+	}
+
+	s = NewScanner(strings.NewReader("data"));
+	s.Split(emptySplit);
+	if _, err := scanAll(s); err != ErrNoProgress {
+		t.Errorf("Err = %v, want %v", err, ErrNoProgress)
+	}
+}
+
+// An emptyReader returns no data and no error.
+type emptyReader struct{}
+
+func (r emptyReader) Read(p []byte) (int, os.Error)	{ return 0, nil }
+
+func TestScannerNoProgress(t *testing.T) {
+	if _, err := scanAll(NewScanner(emptyReader{})); err != ErrNoProgress {
+		t.Errorf("Err = %v, want %v", err, ErrNoProgress)
+	}
+}
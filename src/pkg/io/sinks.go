@@ -0,0 +1,110 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Standard sinks and sources of data, for tests and benchmarks.
+
+package io
+
+import "os"
+
+// A Discarder is a Writer that discards all data written to it.
+// It counts the bytes in N.  The zero value is ready to use.
+type Discarder struct {
+	N int64;	// number of bytes discarded
+}
+
+// Write discards p; it always succeeds.
+func (d *Discarder) Write(p []byte) (n int, err os.Error) {
+	d.N += int64(len(p));
+	return len(p), nil;
+}
+
+// ReadFrom reads from r until os.EOF or an error and discards
+// the data.  It reads with a large buffer, so that Copy to a
+// Discarder makes few calls of r.Read.
+func (d *Discarder) ReadFrom(r Reader) (n int64, err os.Error) {
+	n, err = discardFrom(r);
+	d.N += n;
+	return;
+}
+
+// discardFrom reads from r until os.EOF or an error
+// and discards the data.
+func discardFrom(r Reader) (n int64, err os.Error) {
+	// a pooled buffer of each call, since r may
+	// look at the data it reads into the buffer
+	buf := getBuffer(maxCopyBuffer);
+	for {
+		nr, er := r.Read(buf);
+		n += int64(nr);
+		if er != nil {
+			if er != os.EOF {
+				err = er
+			}
+			break;
+		}
+	}
+	putBuffer(buf);
+	return;
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (n int, err os.Error)	{ return len(p), nil }
+
+func (discard) ReadFrom(r Reader) (n int64, err os.Error)	{ return discardFrom(r) }
+
+// Discard is a Writer, shared by all its users, that discards all data
+// written to it.  Unlike a Discarder, it does not count the bytes, so
+// that goroutines may use it concurrently; use a Discarder of your own
+// to count the bytes of a particular transfer.
+var Discard Writer = discard{}
+
+type zeroReader struct{}
+
+func (z zeroReader) Read(p []byte) (n int, err os.Error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil;
+}
+
+// Zero is a Reader that supplies an endless stream of zero bytes.
+var Zero Reader = zeroReader{}
+
+// randReader generates bytes with the xorshift64* algorithm;
+// it is fast but not suitable for cryptography.
+type randReader struct {
+	x	uint64;	// generator state, never 0
+	word	uint64;	// current output word
+	n	int;	// bytes of word not yet used
+}
+
+func (r *randReader) Read(p []byte) (n int, err os.Error) {
+	for i := range p {
+		if r.n == 0 {
+			r.x ^= r.x >> 12;
+			r.x ^= r.x << 25;
+			r.x ^= r.x >> 27;
+			r.word = r.x * 2685821657736338717;
+			r.n = 8;
+		}
+		p[i] = byte(r.word);
+		r.word >>= 8;
+		r.n--;
+	}
+	return len(p), nil;
+}
+
+// RandReader returns a Reader that supplies an endless stream of
+// pseudo-random bytes.  The stream depends only on seed, not on the
+// sizes of the reads, so that benchmarks can reproduce their input.
+// The bytes are not suitable for cryptographic use.
+func RandReader(seed int64) Reader {
+	x := uint64(seed);
+	if x == 0 {
+		x = 0x9e3779b97f4a7c15	// the state must not be 0
+	}
+	return &randReader{x: x};
+}
@@ -0,0 +1,72 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	"bytes";
+	. "io";
+	"testing";
+)
+
+func TestDiscarder(t *testing.T) {
+	var d Discarder;
+	n, err := Copy(&d, LimitReader(Zero, 1000000));
+	if n != 1000000 || err != nil {
+		t.Errorf("Copy = %d, %v; expected 1000000, nil", n, err)
+	}
+	d.Write(make([]byte, 10));
+	if d.N != 1000010 {
+		t.Errorf("N = %d, expected 1000010", d.N)
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	if _, ok := Discard.(ReaderFrom); !ok {
+		t.Errorf("Discard is not a ReaderFrom")
+	}
+	n, err := Copy(Discard, LimitReader(Zero, 1000000));
+	if n != 1000000 || err != nil {
+		t.Errorf("Copy = %d, %v; expected 1000000, nil", n, err)
+	}
+}
+
+func TestZero(t *testing.T) {
+	p := bytes.Add(nil, []byte{1, 2, 3});
+	if n, err := Zero.Read(p); n != 3 || err != nil || p[0]|p[1]|p[2] != 0 {
+		t.Errorf("Read = %d, %v, %v; expected 3, nil, [0 0 0]", n, err, p)
+	}
+}
+
+func TestRandReader(t *testing.T) {
+	// the stream does not depend on the read sizes
+	a := make([]byte, 100);
+	ReadFull(RandReader(42), a);
+	b := make([]byte, 100);
+	r := RandReader(42);
+	for i := 0; i < len(b); i += 7 {
+		j := i + 7;
+		if j > len(b) {
+			j = len(b)
+		}
+		r.Read(b[i:j]);
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("streams of the same seed differ")
+	}
+
+	ReadFull(RandReader(43), b);
+	if bytes.Equal(a, b) {
+		t.Errorf("streams of different seeds are equal")
+	}
+	zeros := 0;
+	for _, c := range a {
+		if c == 0 {
+			zeros++
+		}
+	}
+	if zeros > 10 {
+		t.Errorf("%d of 100 random bytes are 0", zeros)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Writer with a replaceable destination, for log rotation.
+
+package io
+
+import (
+	"os";
+	"sync";
+)
+
+// A destination is a Writer of a SwappableWriter
+// with the number of Writes in progress on it.
+type destination struct {
+	w		Writer;
+	inflight	int;		// number of Writes in progress
+	drained		chan bool;	// closed once inflight drops to 0; or nil
+}
+
+// A SwappableWriter forwards each Write to its current destination
+// Writer, which can be replaced at any time with Swap, for instance
+// to rotate a log file without coordinating the goroutines writing
+// to it.  Each Write goes entirely to one destination: to the one
+// current when the Write starts.  The lock of a SwappableWriter is
+// held only to pick the destination, not during the Write itself, so
+// concurrent Writes are passed on concurrently; whether they may
+// overlap depends on the destination.
+type SwappableWriter struct {
+	mu	sync.Mutex;
+	dst	*destination;
+}
+
+// NewSwappableWriter returns a SwappableWriter writing to w.
+func NewSwappableWriter(w Writer) *SwappableWriter {
+	return &SwappableWriter{dst: &destination{w: w}}
+}
+
+// Write writes p to the current destination.
+func (s *SwappableWriter) Write(p []byte) (n int, err os.Error) {
+	s.mu.Lock();
+	d := s.dst;
+	d.inflight++;
+	s.mu.Unlock();
+
+	n, err = d.w.Write(p);
+
+	s.mu.Lock();
+	d.inflight--;
+	if d.inflight == 0 && d.drained != nil {
+		close(d.drained);
+		d.drained = nil;
+	}
+	s.mu.Unlock();
+	return;
+}
+
+// Swap makes w the destination of subsequent Writes and returns the
+// previous destination.  Writes in progress at the time of the Swap
+// still complete on the previous destination; use SwapAndDrain to
+// wait for them before closing it.
+func (s *SwappableWriter) Swap(w Writer) Writer {
+	s.mu.Lock();
+	old := s.dst;
+	s.dst = &destination{w: w};
+	s.mu.Unlock();
+	return old.w;
+}
+
+// SwapAndDrain is like Swap but returns only once the Writes in
+// progress on the previous destination have completed, so that the
+// caller can close it right away.
+func (s *SwappableWriter) SwapAndDrain(w Writer) Writer {
+	s.mu.Lock();
+	old := s.dst;
+	s.dst = &destination{w: w};
+	var drained chan bool;
+	if old.inflight > 0 {
+		if old.drained == nil {
+			old.drained = make(chan bool)
+		}
+		drained = old.drained;
+	}
+	s.mu.Unlock();
+	if drained != nil {
+		<-drained
+	}
+	return old.w;
+}
+
+// Writer returns the current destination.
+func (s *SwappableWriter) Writer() Writer {
+	s.mu.Lock();
+	defer s.mu.Unlock();
+	return s.dst.w;
+}
@@ -0,0 +1,75 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	"bytes";
+	. "io";
+	"os";
+	"strings";
+	"testing";
+	"time";
+)
+
+// A gateWriter blocks each Write until the gate is opened.
+type gateWriter struct {
+	started	chan bool;
+	gate	chan bool;
+}
+
+func (w *gateWriter) Write(p []byte) (n int, err os.Error) {
+	w.started <- true;
+	<-w.gate;
+	return len(p), nil;
+}
+
+func TestSwappableWriter(t *testing.T) {
+	var a, b bytes.Buffer;
+	s := NewSwappableWriter(&a);
+	s.Write(strings.Bytes("one "));
+	if old := s.Swap(&b); old != &a {
+		t.Errorf("Swap returned %v, want the first destination", old)
+	}
+	s.Write(strings.Bytes("two"));
+	if a.String() != "one " || b.String() != "two" {
+		t.Errorf("destinations got %q and %q; want %q and %q", a.String(), b.String(), "one ", "two")
+	}
+	if s.Writer() != &b {
+		t.Errorf("Writer is not the current destination")
+	}
+}
+
+func TestSwapAndDrain(t *testing.T) {
+	g := &gateWriter{make(chan bool), make(chan bool)};
+	s := NewSwappableWriter(g);
+	go s.Write(strings.Bytes("slow"));
+	<-g.started;
+
+	var b bytes.Buffer;
+	swapped := make(chan bool);
+	go func() {
+		s.SwapAndDrain(&b);
+		swapped <- true;
+	}();
+
+	// new Writes go to the new destination right away
+	for s.Writer() != &b {
+		time.Sleep(1e6)
+	}
+	s.Write(strings.Bytes("fast"));
+	if b.String() != "fast" {
+		t.Errorf("new destination got %q, want %q", b.String(), "fast")
+	}
+
+	// but SwapAndDrain waits for the pending Write
+	time.Sleep(1e7);
+	select {
+	case <-swapped:
+		t.Fatalf("SwapAndDrain returned with a Write in progress")
+	default:
+	}
+	g.gate <- true;
+	<-swapped;
+}
@@ -0,0 +1,216 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Timeout adapters for Readers and Writers without native deadlines.
+
+package io
+
+import (
+	"os";
+	"sync";
+	"syscall";
+)
+
+// ErrTimeout means that a Read or Write on a Reader or Writer returned
+// by TimeoutReader or TimeoutWriter did not complete in time.  The
+// data of a Write that times out may still be written; see TimeoutWriter.
+var ErrTimeout os.Error = &Error{"i/o timeout"}
+
+// Number of steps in which a timer sleeps through its interval.
+const timerSteps = 10
+
+// A timer times the operations of one Reader or Writer, one at a
+// time, with at most one goroutine, however many operations it times:
+// the goroutine sleeps through the interval in timerSteps steps, so
+// that an operation that starts while it sleeps just resets the count,
+// and it exits at the first step after the operation is stopped.  It
+// sleeps for intervals rather than waiting for a wall clock time, so
+// it is not affected by changes to the system clock.
+type timer struct {
+	ns	int64;
+	mu	sync.Mutex;
+	left	int;		// steps left for the current operation; 0 if none
+	running	bool;		// whether the goroutine is running
+	c	chan bool;	// receives a value when the current operation expires
+}
+
+func newTimer(ns int64) *timer	{ return &timer{ns: ns, c: make(chan bool, 1)} }
+
+// start starts timing an operation; t.c receives a value once
+// it has taken at least t.ns nanoseconds, unless stop is called first.
+func (t *timer) start() {
+	t.mu.Lock();
+	select {
+	case <-t.c:	// expiry of an earlier operation
+	default:
+	}
+	t.left = timerSteps;
+	if t.running {
+		t.left++	// the current step has partly elapsed
+	} else {
+		t.running = true;
+		go t.run();
+	}
+	t.mu.Unlock();
+}
+
+// stop stops timing the current operation.
+func (t *timer) stop() {
+	t.mu.Lock();
+	t.left = 0;
+	t.mu.Unlock();
+}
+
+func (t *timer) run() {
+	step := t.ns / timerSteps;
+	if step < 1 {
+		step = 1
+	}
+	for {
+		syscall.Sleep(step);
+		t.mu.Lock();
+		if t.left > 0 {
+			t.left--;
+			if t.left == 0 {
+				t.c <- true	// cannot block: start empties t.c
+			}
+		}
+		if t.left == 0 {
+			t.running = false;
+			t.mu.Unlock();
+			return;
+		}
+		t.mu.Unlock();
+	}
+}
+
+type ioResult struct {
+	n	int;
+	err	os.Error;
+}
+
+// TimeoutReader returns a Reader that reads from r but fails an
+// individual Read with ErrTimeout if it blocks for longer than ns
+// nanoseconds.  The underlying Read continues in the background;
+// the data it delivers is returned by subsequent Reads.
+// If ns <= 0, TimeoutReader returns r.
+// The returned Reader is not safe for concurrent use.
+func TimeoutReader(r Reader, ns int64) Reader {
+	if ns <= 0 {
+		return r
+	}
+	return &timeoutReader{r: r, timer: newTimer(ns)};
+}
+
+type timeoutReader struct {
+	r	Reader;
+	timer	*timer;
+	buf	[]byte;		// buffer owned by the background Read
+	data	[]byte;		// data read but not yet returned
+	err	os.Error;	// error to return once data is consumed
+	done	chan ioResult;	// non-nil while a background Read is pending
+}
+
+func (t *timeoutReader) Read(p []byte) (n int, err os.Error) {
+	if len(t.data) == 0 && t.err == nil {
+		if t.done == nil {
+			// start a new background Read
+			if cap(t.buf) < len(p) {
+				t.buf = make([]byte, len(p))
+			}
+			buf := t.buf[0:len(p)];
+			done := make(chan ioResult, 1);
+			go func() {
+				n, err := t.r.Read(buf);
+				done <- ioResult{n, err};
+			}();
+			t.done = done;
+		}
+		t.timer.start();
+		select {
+		case res := <-t.done:
+			t.timer.stop();
+			t.done = nil;
+			t.data = t.buf[0:res.n];
+			t.err = res.err;
+		case <-t.timer.c:
+			return 0, ErrTimeout
+		}
+	}
+
+	n = copy(p, t.data);
+	t.data = t.data[n:len(t.data)];
+	if len(t.data) == 0 {
+		err = t.err;
+		t.err = nil;
+	}
+	return;
+}
+
+// TimeoutWriter returns a Writer that writes to w but fails an
+// individual Write with ErrTimeout if it blocks for longer than ns
+// nanoseconds.  The underlying Write continues in the background
+// with a copy of the data, so a Write that times out while writing
+// returns len(p), ErrTimeout: the data must not be written again.
+// The next Write waits for the background Write to complete and
+// returns its error, if any; if it times out while waiting, it
+// returns 0, ErrTimeout, and none of its data is written.
+// If ns <= 0, TimeoutWriter returns w.
+// The returned Writer is not safe for concurrent use.
+func TimeoutWriter(w Writer, ns int64) Writer {
+	if ns <= 0 {
+		return w
+	}
+	return &timeoutWriter{w: w, timer: newTimer(ns)};
+}
+
+type timeoutWriter struct {
+	w	Writer;
+	timer	*timer;
+	buf	[]byte;		// copy of the data being written
+	done	chan ioResult;	// non-nil while a background Write is pending
+}
+
+func (t *timeoutWriter) Write(p []byte) (n int, err os.Error) {
+	t.timer.start();
+
+	// wait for a previous Write to complete
+	if t.done != nil {
+		select {
+		case res := <-t.done:
+			t.done = nil;
+			if res.err != nil {
+				t.timer.stop();
+				return 0, res.err;
+			}
+		case <-t.timer.c:
+			return 0, ErrTimeout
+		}
+	}
+
+	// the background Write may outlive this call;
+	// don't let it hold on to the caller's buffer
+	if cap(t.buf) < len(p) {
+		t.buf = make([]byte, len(p))
+	}
+	buf := t.buf[0:len(p)];
+	copy(buf, p);
+	done := make(chan ioResult, 1);
+	go func() {
+		n, err := t.w.Write(buf);
+		if err == nil && n < len(buf) {
+			err = ErrShortWrite
+		}
+		done <- ioResult{n, err};
+	}();
+
+	select {
+	case res := <-done:
+		t.timer.stop();
+		return res.n, res.err;
+	case <-t.timer.c:
+		t.done = done
+	}
+	return len(p), ErrTimeout;	// still being written
+}
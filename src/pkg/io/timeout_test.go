@@ -0,0 +1,88 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	. "io";
+	"os";
+	"strings";
+	"testing";
+)
+
+func TestTimeoutReader(t *testing.T) {
+	pr, pw := Pipe();
+	r := TimeoutReader(pr, 1e7);	// 10ms
+	var buf [64]byte;
+	if n, err := r.Read(&buf); n != 0 || err != ErrTimeout {
+		t.Fatalf("Read = %d, %v; want 0, %v", n, err, ErrTimeout)
+	}
+
+	// the background Read picks up the data
+	go pw.Write(strings.Bytes("hello"));
+	n, err := r.Read(&buf);
+	for err == ErrTimeout {
+		n, err = r.Read(&buf)
+	}
+	if err != nil || string(buf[0:n]) != "hello" {
+		t.Errorf("Read = %q, %v; want %q, nil", buf[0:n], err, "hello")
+	}
+	pw.Close();
+}
+
+func TestTimeoutWriter(t *testing.T) {
+	pr, pw := Pipe();
+	w := TimeoutWriter(pw, 1e7);	// 10ms
+	data := strings.Bytes("hello");
+	if n, err := w.Write(data); n != len(data) || err != ErrTimeout {
+		t.Fatalf("Write = %d, %v; want %d, %v", n, err, len(data), ErrTimeout)
+	}
+
+	// the caller's buffer may be reused after a timeout
+	data[0] = 'j';
+	var buf [64]byte;
+	n, err := pr.Read(&buf);
+	if err != nil || string(buf[0:n]) != "hello" {
+		t.Errorf("Read = %q, %v; want %q, nil", buf[0:n], err, "hello")
+	}
+
+	// errors of the underlying Writer are passed through
+	pw.Close();
+	if _, err := w.Write(data); err == nil {
+		t.Errorf("Write after Close succeeded")
+	}
+}
+
+func TestTimeoutWriterPending(t *testing.T) {
+	pr, pw := Pipe();
+	w := TimeoutWriter(pw, 1e8);	// 100ms
+	if n, err := w.Write(strings.Bytes("hello")); n != 5 || err != ErrTimeout {
+		t.Fatalf("Write = %d, %v; want 5, %v", n, err, ErrTimeout)
+	}
+	// the next Write times out waiting for the first one
+	// and writes nothing
+	if n, err := w.Write(strings.Bytes("world")); n != 0 || err != ErrTimeout {
+		t.Fatalf("second Write = %d, %v; want 0, %v", n, err, ErrTimeout)
+	}
+	go func() {
+		w.Write(strings.Bytes("world"));
+		pw.Close();
+	}();
+	b, err := ReadAll(pr);
+	if err != nil || string(b) != "helloworld" {
+		t.Errorf("ReadAll = %q, %v; want %q, nil", b, err, "helloworld")
+	}
+}
+
+// A halfWriter accepts half of the data of each Write without an error.
+type halfWriter struct{}
+
+func (w halfWriter) Write(p []byte) (int, os.Error)	{ return len(p) / 2, nil }
+
+func TestTimeoutWriterShortWrite(t *testing.T) {
+	w := TimeoutWriter(halfWriter{}, 1e9);
+	if n, err := w.Write(strings.Bytes("hello")); n != 2 || err != ErrShortWrite {
+		t.Errorf("Write = %d, %v; want 2, %v", n, err, ErrShortWrite)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Stacks of transforming Writers.
+
+package io
+
+import "os"
+
+// A TransformWriter is a Writer that transforms the data written to
+// it, such as an encoder or compressor, and writes the result to
+// another Writer.
+//
+// Flush writes any data buffered by the transform.  CloseWithError
+// finishes the stream: if err is nil, the transform writes any final
+// data, such as a trailer; otherwise the stream is being abandoned
+// because of err and the transform should only release its resources.
+// A TransformWriter does not close the Writer it writes to.
+type TransformWriter interface {
+	Writer;
+	Flush() os.Error;
+	CloseWithError(err os.Error) os.Error;
+}
+
+// A Transform returns a TransformWriter writing to w.
+type Transform func(w Writer) TransformWriter
+
+// flusher is implemented by Writers, such as bufio.Writer,
+// that buffer data.
+type flusher interface {
+	Flush() os.Error;
+}
+
+// A chain is the TransformWriter returned by Compose.
+type chain struct {
+	stages	[]TransformWriter;	// outermost first
+	head	*link;			// Writer into the outermost stage
+	w	Writer;			// terminal Writer
+	err	os.Error;		// first error of any stage; sticky
+	closed	bool;
+}
+
+// A link is the Writer between two stages of a chain.
+// It records the first error in the chain, so that once
+// a stage fails, no stage writes any further data.
+type link struct {
+	c	*chain;
+	w	Writer;
+}
+
+func (l *link) Write(p []byte) (n int, err os.Error) {
+	if l.c.err != nil {
+		return 0, l.c.err
+	}
+	n, err = l.w.Write(p);
+	if err == nil && n < len(p) {
+		err = ErrShortWrite
+	}
+	l.c.fail(err);
+	return;
+}
+
+// fail records err if it is the first error in c.
+func (c *chain) fail(err os.Error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *chain) Write(p []byte) (n int, err os.Error) {
+	if c.closed {
+		return 0, os.EINVAL
+	}
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.head.Write(p);
+}
+
+// Flush flushes the stages from the outermost to the innermost,
+// so that data buffered in one stage reaches the next, and then
+// the terminal Writer if it has a Flush method.
+func (c *chain) Flush() os.Error {
+	if c.closed {
+		return os.EINVAL
+	}
+	for _, s := range c.stages {
+		if c.err != nil {
+			break
+		}
+		c.fail(s.Flush());
+	}
+	if f, ok := c.w.(flusher); ok && c.err == nil {
+		c.fail(f.Flush())
+	}
+	return c.err;
+}
+
+// CloseWithError closes the stages from the outermost to the
+// innermost, so that the final data of each stage passes through
+// the stages below it, and then flushes the terminal Writer.
+// Once a stage fails, the remaining stages are closed with its
+// error.  All stages are closed in any case.
+func (c *chain) CloseWithError(err os.Error) os.Error {
+	if c.closed {
+		return os.EINVAL
+	}
+	c.closed = true;
+	c.fail(err);
+	for _, s := range c.stages {
+		c.fail(s.CloseWithError(c.err))
+	}
+	if f, ok := c.w.(flusher); ok && c.err == nil {
+		c.fail(f.Flush())
+	}
+	if c.err == err {
+		// no failure besides the one reported by the caller
+		return nil
+	}
+	return c.err;
+}
+
+// Compose stacks the transforms onto the Writer w.  Data written to
+// the result passes through transforms[0] first, then transforms[1],
+// and so on; the output of the last transform is written to w.
+// The first error of any stage is sticky: subsequent writes to any
+// stage fail with it without writing data.  Closing the result
+// closes the stages in order, but not w.  With no transforms,
+// Compose returns a TransformWriter writing directly to w.
+func Compose(w Writer, transforms []Transform) TransformWriter {
+	c := &chain{w: w};
+	c.stages = make([]TransformWriter, len(transforms));
+	c.head = &link{c, w};
+	for i := len(transforms) - 1; i >= 0; i-- {
+		c.stages[i] = transforms[i](c.head);
+		c.head = &link{c, c.stages[i]};
+	}
+	return c;
+}
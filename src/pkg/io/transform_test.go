@@ -0,0 +1,132 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	"bytes";
+	. "io";
+	"os";
+	"testing";
+)
+
+// A wrapTransform buffers its input and writes it between
+// a prefix and a suffix when flushed or closed.
+type wrapTransform struct {
+	w		Writer;
+	pre, post	string;
+	buf		bytes.Buffer;
+	closeErr	os.Error;	// argument of CloseWithError
+}
+
+func (t *wrapTransform) Write(p []byte) (n int, err os.Error) {
+	return t.buf.Write(p)
+}
+
+func (t *wrapTransform) Flush() os.Error {
+	if t.buf.Len() == 0 {
+		return nil
+	}
+	if _, err := WriteString(t.w, t.pre); err != nil {
+		return err
+	}
+	if _, err := t.w.Write(t.buf.Bytes()); err != nil {
+		return err
+	}
+	t.buf.Reset();
+	_, err := WriteString(t.w, t.post);
+	return err;
+}
+
+func (t *wrapTransform) CloseWithError(err os.Error) os.Error {
+	t.closeErr = err;
+	if err != nil {
+		return nil
+	}
+	return t.Flush();
+}
+
+func wrap(pre, post string, stages *[]*wrapTransform) Transform {
+	return func(w Writer) TransformWriter {
+		t := &wrapTransform{w: w, pre: pre, post: post};
+		*stages = appendStage(*stages, t);
+		return t;
+	}
+}
+
+func appendStage(a []*wrapTransform, t *wrapTransform) []*wrapTransform {
+	b := make([]*wrapTransform, len(a)+1);
+	copy(b, a);
+	b[len(a)] = t;
+	return b;
+}
+
+// A failWriter fails once it has been written n bytes.
+type failWriter struct {
+	n int;
+}
+
+var errFail = os.NewError("fail")
+
+func (w *failWriter) Write(p []byte) (n int, err os.Error) {
+	if len(p) > w.n {
+		return 0, errFail
+	}
+	w.n -= len(p);
+	return len(p), nil;
+}
+
+func TestCompose(t *testing.T) {
+	var buf bytes.Buffer;
+	var stages []*wrapTransform;
+	w := Compose(&buf, []Transform{wrap("(", ")", &stages), wrap("[", "]", &stages)});
+	WriteString(w, "a");
+	WriteString(w, "b");
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	WriteString(w, "c");
+	if err := w.CloseWithError(nil); err != nil {
+		t.Fatalf("CloseWithError: %v", err)
+	}
+	if s, expect := buf.String(), "[(ab)][(c)]"; s != expect {
+		t.Errorf("got %q, expected %q", s, expect)
+	}
+	if _, err := WriteString(w, "d"); err != os.EINVAL {
+		t.Errorf("write after close: got %v, expected os.EINVAL", err)
+	}
+}
+
+func TestComposeError(t *testing.T) {
+	var stages []*wrapTransform;
+	w := Compose(&failWriter{3}, []Transform{wrap("(", ")", &stages), wrap("[", "]", &stages)});
+	WriteString(w, "abc");
+	if err := w.Flush(); err != errFail {
+		t.Errorf("Flush: got %v, expected %v", err, errFail)
+	}
+	if _, err := WriteString(w, "d"); err != errFail {
+		t.Errorf("write after failure: got %v, expected %v", err, errFail)
+	}
+	if err := w.CloseWithError(nil); err != errFail {
+		t.Errorf("CloseWithError: got %v, expected %v", err, errFail)
+	}
+	if len(stages) != 2 {
+		t.Fatalf("%d stages, expected 2", len(stages))
+	}
+	for i, s := range stages {
+		if s.closeErr != errFail {
+			t.Errorf("stage %d closed with %v, expected %v", i, s.closeErr, errFail)
+		}
+	}
+}
+
+func TestComposeNone(t *testing.T) {
+	var buf bytes.Buffer;
+	w := Compose(&buf, nil);
+	WriteString(w, "hello");
+	w.CloseWithError(nil);
+	if s := buf.String(); s != "hello" {
+		t.Errorf("got %q, expected %q", s, "hello")
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Splitting of a stream into work items for concurrent consumers.
+
+package io
+
+import (
+	"os";
+	"sync";
+)
+
+// ErrItemTooLong means that a WorkQueue found a delimited
+// item longer than its maximum item size.
+var ErrItemTooLong os.Error = &Error{"work item too long"}
+
+// A ConsumerFunc handles a work item of a WorkQueue.  The item
+// belongs to the function; it is not reused by the WorkQueue.
+type ConsumerFunc func(item []byte) os.Error
+
+// A WorkQueue is a Writer that splits the data written to it into work
+// items and hands each item to one of a fixed number of consumers, which
+// run in their own goroutines.  Items are either delimited, like lines,
+// or of a fixed size.
+//
+// The data passes through a Pipe, so a WorkQueue applies backpressure:
+// an item is only handed over when a consumer is ready for it, and the
+// data of a Write is only taken from the pipe once the items found in
+// earlier data have been handed over.  A producer writing faster than
+// the consumers can keep up with blocks in Write, and besides the
+// items being consumed, at most one item waiting for a consumer and
+// one item being assembled are held in memory.
+//
+// If a consumer returns an error, the queue stops: the item being
+// assembled and any later data are dropped, the remaining consumers
+// finish their current items, and Close and the Writes that find the
+// queue stopped return the error.
+type WorkQueue struct {
+	w	*PipeWriter;
+	delim	int;	// item delimiter; -1 for fixed-size items
+	size	int;	// maximum size (delimited) or size (fixed) of an item
+	items	chan []byte;
+	done	chan bool;	// receives a value when a consumer finishes
+	n	int;		// number of consumers
+	mu	sync.Mutex;
+	err	os.Error;	// first error of a consumer or of the stream
+	closed	bool;
+}
+
+// NewDelimitedWorkQueue returns a WorkQueue that splits the data at each
+// occurrence of delim and hands the items, without the delimiter, to n
+// consumers calling f.  An item may be at most maxItem bytes long; a
+// longer one stops the queue with ErrItemTooLong.  Data following the
+// last delimiter is handed over as a final item when the queue is closed.
+func NewDelimitedWorkQueue(delim byte, maxItem, n int, f ConsumerFunc) *WorkQueue {
+	return newWorkQueue(int(delim), maxItem, n, f)
+}
+
+// NewFixedWorkQueue returns a WorkQueue that splits the data into items
+// of size bytes and hands them to n consumers calling f.  If the queue
+// is closed in the middle of an item, the partial item is dropped and
+// Close returns ErrUnexpectedEOF.
+func NewFixedWorkQueue(size, n int, f ConsumerFunc) *WorkQueue {
+	return newWorkQueue(-1, size, n, f)
+}
+
+func newWorkQueue(delim, size, n int, f ConsumerFunc) *WorkQueue {
+	if size <= 0 {
+		size = 1
+	}
+	if n <= 0 {
+		n = 1
+	}
+	r, w := Pipe();
+	q := &WorkQueue{w: w, delim: delim, size: size, items: make(chan []byte), done: make(chan bool, n), n: n};
+	go q.split(r);
+	for i := 0; i < n; i++ {
+		go q.consume(f)
+	}
+	return q;
+}
+
+// Write writes data to the queue.  It blocks while the items
+// of the data written before are waiting for consumers.
+func (q *WorkQueue) Write(p []byte) (n int, err os.Error) {
+	n, err = q.w.Write(p);
+	if err != nil {
+		if qerr := q.error(); qerr != nil {
+			err = qerr
+		}
+	}
+	return;
+}
+
+// Close closes the queue, hands the last item to a consumer, and waits
+// for all consumers to finish.  It returns the first error of the queue,
+// or nil if all items were consumed successfully.
+func (q *WorkQueue) Close() os.Error {
+	q.mu.Lock();
+	if q.closed {
+		q.mu.Unlock();
+		return os.EINVAL;
+	}
+	q.closed = true;
+	q.mu.Unlock();
+
+	q.w.Close();
+	for i := 0; i < q.n; i++ {
+		<-q.done
+	}
+	return q.error();
+}
+
+// error returns the first error of the queue.
+func (q *WorkQueue) error() os.Error {
+	q.mu.Lock();
+	defer q.mu.Unlock();
+	return q.err;
+}
+
+// fail records err as the error of the queue unless there is one.
+func (q *WorkQueue) fail(err os.Error) {
+	q.mu.Lock();
+	if q.err == nil {
+		q.err = err
+	}
+	q.mu.Unlock();
+}
+
+// split reads the data written to the queue from r and sends
+// the items to the consumers until the stream ends or fails.
+func (q *WorkQueue) split(r *PipeReader) {
+	size := q.size;
+	if q.delim >= 0 {
+		size++	// room for the delimiter
+	}
+	buf := make([]byte, size);
+	n := 0;	// number of bytes buffered
+	var err os.Error;
+	for err == nil && q.error() == nil {
+		var m int;
+		m, err = r.Read(buf[n:len(buf)]);
+		n += m;
+		if q.delim < 0 {
+			if n == len(buf) {
+				q.items <- copyItem(buf);
+				n = 0;
+			}
+			continue;
+		}
+		// send the complete items
+		start := 0;
+		for i := n - m; i < n; i++ {
+			if buf[i] == byte(q.delim) {
+				q.items <- copyItem(buf[start:i]);
+				start = i + 1;
+			}
+		}
+		n = copy(buf, buf[start:n]);
+		if n == len(buf) && err == nil {
+			err = ErrItemTooLong
+		}
+	}
+
+	if err == os.EOF {
+		err = nil;
+		if n > 0 {
+			if q.delim >= 0 {
+				q.items <- copyItem(buf[0:n])
+			} else {
+				err = ErrUnexpectedEOF
+			}
+		}
+	}
+	if err != nil {
+		q.fail(err)
+	}
+	// Stop the writer, if it is still writing, with the error of the queue.
+	r.CloseWithError(q.error());
+	close(q.items);
+}
+
+// consume calls f for the items sent by split until there are
+// no more items.  After an error, items are dropped.
+func (q *WorkQueue) consume(f ConsumerFunc) {
+	for {
+		item := <-q.items;
+		if closed(q.items) {
+			break
+		}
+		if q.error() == nil {
+			if err := f(item); err != nil {
+				q.fail(err)
+			}
+		}
+	}
+	q.done <- true;
+}
+
+func copyItem(p []byte) []byte {
+	item := make([]byte, len(p));
+	copy(item, p);
+	return item;
+}
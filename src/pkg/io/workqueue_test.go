@@ -0,0 +1,153 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io_test
+
+import (
+	. "io";
+	"os";
+	"sort";
+	"sync";
+	"testing";
+	"time";
+)
+
+// An itemRecorder records the items handed to its consume method.
+type itemRecorder struct {
+	mu	sync.Mutex;
+	items	[]string;
+}
+
+func (r *itemRecorder) consume(item []byte) os.Error {
+	r.mu.Lock();
+	defer r.mu.Unlock();
+	a := make([]string, len(r.items)+1);
+	copy(a, r.items);
+	a[len(r.items)] = string(item);
+	r.items = a;
+	return nil;
+}
+
+// sorted returns the recorded items in sorted order, since
+// concurrent consumers may record them in any order.
+func (r *itemRecorder) sorted() []string {
+	r.mu.Lock();
+	defer r.mu.Unlock();
+	a := make([]string, len(r.items));
+	copy(a, r.items);
+	sort.SortStrings(a);
+	return a;
+}
+
+func checkItems(t *testing.T, what string, items, expect []string) {
+	if len(items) != len(expect) {
+		t.Errorf("%s: got items %q, expected %q", what, items, expect);
+		return;
+	}
+	for i, s := range items {
+		if s != expect[i] {
+			t.Errorf("%s: got items %q, expected %q", what, items, expect);
+			return;
+		}
+	}
+}
+
+func TestDelimitedWorkQueue(t *testing.T) {
+	r := new(itemRecorder);
+	q := NewDelimitedWorkQueue('\n', 8, 3, r.consume);
+	for _, s := range []string{"b\nd", "\n\na\n", "c", "\ne"} {
+		if _, err := WriteString(q, s); err != nil {
+			t.Fatalf("Write %q: %v", s, err)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	checkItems(t, "delimited", r.sorted(), []string{"", "a", "b", "c", "d", "e"});
+}
+
+func TestFixedWorkQueue(t *testing.T) {
+	r := new(itemRecorder);
+	q := NewFixedWorkQueue(3, 2, r.consume);
+	WriteString(q, "abcd");
+	WriteString(q, "efghi");
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	checkItems(t, "fixed", r.sorted(), []string{"abc", "def", "ghi"});
+
+	r = new(itemRecorder);
+	q = NewFixedWorkQueue(3, 2, r.consume);
+	WriteString(q, "abcde");
+	if err := q.Close(); err != ErrUnexpectedEOF {
+		t.Errorf("Close after partial item: got %v, expected %v", err, ErrUnexpectedEOF)
+	}
+	checkItems(t, "partial", r.sorted(), []string{"abc"});
+}
+
+func TestWorkQueueItemTooLong(t *testing.T) {
+	r := new(itemRecorder);
+	q := NewDelimitedWorkQueue('\n', 4, 1, r.consume);
+	WriteString(q, "abc\nabcdef\n");
+	if _, err := WriteString(q, "x\n"); err != ErrItemTooLong {
+		t.Errorf("Write after long item: got %v, expected %v", err, ErrItemTooLong)
+	}
+	if err := q.Close(); err != ErrItemTooLong {
+		t.Errorf("Close: got %v, expected %v", err, ErrItemTooLong)
+	}
+	checkItems(t, "too long", r.sorted(), []string{"abc"});
+}
+
+func TestWorkQueueConsumerError(t *testing.T) {
+	bad := os.NewError("bad item");
+	f := func(item []byte) os.Error {
+		if string(item) == "bad" {
+			return bad
+		}
+		return nil;
+	};
+	q := NewDelimitedWorkQueue(',', 8, 2, f);
+	WriteString(q, "a,bad,b,");
+	// The consumers run concurrently with the writer; wait
+	// until the queue has noticed the error.
+	var err os.Error;
+	for i := 0; i < 100 && err == nil; i++ {
+		_, err = WriteString(q, "c,");
+		time.Sleep(1e6);
+	}
+	if err != bad {
+		t.Errorf("Write after consumer error: got %v, expected %v", err, bad)
+	}
+	if err := q.Close(); err != bad {
+		t.Errorf("Close: got %v, expected %v", err, bad)
+	}
+}
+
+func TestWorkQueueBackpressure(t *testing.T) {
+	release := make(chan bool);
+	f := func(item []byte) os.Error {
+		<-release;
+		return nil;
+	};
+	q := NewDelimitedWorkQueue('\n', 8, 1, f);
+	wrote := make(chan bool);
+	go func() {
+		// The consumer takes the first item and blocks; the second
+		// item waits for it, and so does the data of the second Write.
+		WriteString(q, "a\nb\n");
+		WriteString(q, "c\n");
+		wrote <- true;
+	}();
+	time.Sleep(10e6);
+	if _, ok := <-wrote; ok {
+		t.Fatal("Write returned while the consumer was busy")
+	}
+	for i := 0; i < 3; i++ {
+		release <- true
+	}
+	<-wrote;
+	if err := q.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
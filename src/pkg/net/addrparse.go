@@ -0,0 +1,197 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Address parsing and validation
+
+package net
+
+import "os"
+
+// Errors describing malformed addresses; ParseAddr and Validate
+// return them as the Error field of an AddrParseError.
+var (
+	ErrMissingAddress	os.Error	= os.ErrorString("missing address");
+	ErrMissingPort		os.Error	= os.ErrorString("missing port in address");
+	ErrBadPort		os.Error	= os.ErrorString("invalid port");
+	ErrUnknownPort		os.Error	= os.ErrorString("unknown port");
+	ErrTooManyColons	os.Error	= os.ErrorString("too many colons in address");
+	ErrMissingBracket	os.Error	= os.ErrorString("missing bracket in address");
+	ErrBadIPv6		os.Error	= os.ErrorString("invalid IPv6 address");
+	ErrBadIP		os.Error	= os.ErrorString("invalid IP address");
+	ErrBadHost		os.Error	= os.ErrorString("invalid host name");
+)
+
+// An AddrParseError reports why an address is malformed.
+type AddrParseError struct {
+	Error	os.Error;	// one of the Err values above
+	Addr	string;		// the address, or the malformed part of it
+}
+
+func (e *AddrParseError) String() string {
+	s := e.Error.String();
+	if e.Addr != "" {
+		s += " " + e.Addr
+	}
+	return s;
+}
+
+// parseHostPort splits "host:port", "[host]:port" or ":port" into
+// host and port, checking that a bracketed host is an IPv6 address.
+func parseHostPort(hostport string) (host, port string, err os.Error) {
+	if hostport == "" {
+		return "", "", &AddrParseError{ErrMissingAddress, hostport}
+	}
+	if hostport[0] == '[' {
+		i := byteIndex(hostport, ']');
+		if i < 0 {
+			return "", "", &AddrParseError{ErrMissingBracket, hostport}
+		}
+		host, rest := hostport[1:i], hostport[i+1:len(hostport)];
+		if len(rest) == 0 || rest[0] != ':' {
+			return "", "", &AddrParseError{ErrMissingPort, hostport}
+		}
+		if byteIndex(host, ':') < 0 || ParseIP(host) == nil {
+			return "", "", &AddrParseError{ErrBadIPv6, host}
+		}
+		return host, rest[1:len(rest)], nil;
+	}
+	if byteIndex(hostport, '[') >= 0 || byteIndex(hostport, ']') >= 0 {
+		return "", "", &AddrParseError{ErrMissingBracket, hostport}
+	}
+	i := last(hostport, ':');
+	if i < 0 {
+		return "", "", &AddrParseError{ErrMissingPort, hostport}
+	}
+	host, port = hostport[0:i], hostport[i+1:len(hostport)];
+	if byteIndex(host, ':') >= 0 {
+		// most likely an IPv6 address without brackets
+		return "", "", &AddrParseError{ErrTooManyColons, hostport}
+	}
+	return;
+}
+
+// parsePort returns the number of the numeric port or service
+// name port of the network net.
+func parsePort(net, port string) (int, os.Error) {
+	if port == "" {
+		return 0, &AddrParseError{ErrMissingPort, port}
+	}
+	p, i, ok := dtoi(port, 0);
+	if ok && i == len(port) {
+		if p > 0xFFFF {
+			return 0, &AddrParseError{ErrBadPort, port}
+		}
+		return p, nil;
+	}
+	if '0' <= port[0] && port[0] <= '9' || port[0] == '-' {
+		return 0, &AddrParseError{ErrBadPort, port}
+	}
+	p, err := LookupPort(net, port);
+	if err != nil {
+		return 0, &AddrParseError{ErrUnknownPort, port}
+	}
+	return p, nil;
+}
+
+// parseIPHost checks the host of an Internet address.  If the host
+// is an IP address, parseIPHost returns it; otherwise, if names are
+// allowed, the host must be a valid domain name.  The empty host
+// means the unspecified address and yields a nil IP.
+func parseIPHost(net, host string, names bool) (IP, os.Error) {
+	if host == "" {
+		return nil, nil
+	}
+	ip := ParseIP(host);
+	if ip == nil {
+		if byteIndex(host, ':') >= 0 {
+			return nil, &AddrParseError{ErrBadIPv6, host}
+		}
+		if !names || isNumericHost(host) {
+			return nil, &AddrParseError{ErrBadIP, host}
+		}
+		if !isDomainName(host) {
+			return nil, &AddrParseError{ErrBadHost, host}
+		}
+		return nil, nil;
+	}
+	if net[len(net)-1] == '4' && ip.To4() == nil {
+		return nil, &AddrParseError{ErrBadIP, host}
+	}
+	return ip, nil;
+}
+
+// isNumericHost reports whether host consists of digits and dots
+// only, so that it is meant to be an IPv4 address.
+func isNumericHost(host string) bool {
+	for i := 0; i < len(host); i++ {
+		if c := host[i]; c != '.' && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true;
+}
+
+// ParseAddr parses the address addr of the network net without
+// looking up host names: Internet addresses must have the form
+// "host:port" or "[host]:port" where host is empty or an IP address,
+// and port is a port number or service name.  The result is a
+// *TCPAddr, *UDPAddr, *SCTPAddr or *UnixAddr.  If addr is malformed,
+// ParseAddr returns an *AddrParseError describing the problem.
+func ParseAddr(net, addr string) (Addr, os.Error) {
+	switch net {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "sctp", "sctp4", "sctp6":
+		host, port, err := parseHostPort(addr);
+		if err != nil {
+			return nil, err
+		}
+		ip, err := parseIPHost(net, host, false);
+		if err != nil {
+			return nil, err
+		}
+		p, err := parsePort(net, port);
+		if err != nil {
+			return nil, err
+		}
+		switch net[0] {
+		case 't':
+			return &TCPAddr{ip, p}, nil
+		case 's':
+			return &SCTPAddr{ip, p}, nil
+		}
+		return &UDPAddr{ip, p}, nil;
+	case "unix", "unixgram":
+		if addr == "" {
+			return nil, &AddrParseError{ErrMissingAddress, addr}
+		}
+		return ResolveUnixAddr(net, addr);
+	}
+	return nil, UnknownNetworkError(net);
+}
+
+// Validate checks that addr is a well-formed address of the network
+// net, as accepted by Dial or Listen, without connecting to it or
+// looking up host names; the host of an Internet address may be a
+// domain name.  If addr is malformed, Validate returns an
+// *AddrParseError describing the problem, so that programs can report
+// it precisely rather than as the error of a later Dial.
+func Validate(net, addr string) os.Error {
+	switch net {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "sctp", "sctp4", "sctp6":
+		host, port, err := parseHostPort(addr);
+		if err != nil {
+			return err
+		}
+		if _, err := parseIPHost(net, host, true); err != nil {
+			return err
+		}
+		_, err = parsePort(net, port);
+		return err;
+	case "unix", "unixgram":
+		if addr == "" {
+			return &AddrParseError{ErrMissingAddress, addr}
+		}
+		return nil;
+	}
+	return UnknownNetworkError(net);
+}
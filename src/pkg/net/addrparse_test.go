@@ -0,0 +1,76 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"os";
+	"testing";
+)
+
+type addrParseTest struct {
+	net	string;
+	addr	string;
+	err	os.Error;	// expected AddrParseError.Error, or nil
+}
+
+var parseaddrtests = []addrParseTest{
+	addrParseTest{"tcp", "127.0.0.1:80", nil},
+	addrParseTest{"tcp", ":80", nil},
+	addrParseTest{"tcp", "[::1]:80", nil},
+	addrParseTest{"udp", "[::ffff:1.2.3.4]:53", nil},
+	addrParseTest{"unix", "/tmp/sock", nil},
+	addrParseTest{"tcp", "", ErrMissingAddress},
+	addrParseTest{"tcp", "127.0.0.1", ErrMissingPort},
+	addrParseTest{"tcp", "127.0.0.1:", ErrMissingPort},
+	addrParseTest{"tcp", "[::1]", ErrMissingPort},
+	addrParseTest{"tcp", "127.0.0.1:65536", ErrBadPort},
+	addrParseTest{"tcp", "127.0.0.1:8x", ErrBadPort},
+	addrParseTest{"tcp", "127.0.0.1:no-such-service", ErrUnknownPort},
+	addrParseTest{"tcp", "::1:80", ErrTooManyColons},
+	addrParseTest{"tcp", "[::1:80", ErrMissingBracket},
+	addrParseTest{"tcp", "::1]:80", ErrMissingBracket},
+	addrParseTest{"tcp", "[1.2.3.4]:80", ErrBadIPv6},
+	addrParseTest{"tcp", "[::g]:80", ErrBadIPv6},
+	addrParseTest{"tcp", "1.2.3.256:80", ErrBadIP},
+	addrParseTest{"tcp4", "[::1]:80", ErrBadIP},
+	addrParseTest{"tcp", "localhost:80", ErrBadIP},
+	addrParseTest{"unix", "", ErrMissingAddress},
+}
+
+func TestParseAddr(t *testing.T) {
+	for _, tt := range parseaddrtests {
+		a, err := ParseAddr(tt.net, tt.addr);
+		if tt.err == nil {
+			if err != nil {
+				t.Errorf("ParseAddr(%q, %q) = %v", tt.net, tt.addr, err)
+			} else if a.Network() != tt.net {
+				t.Errorf("ParseAddr(%q, %q).Network() = %q", tt.net, tt.addr, a.Network())
+			}
+			continue;
+		}
+		e, ok := err.(*AddrParseError);
+		if !ok || e.Error != tt.err {
+			t.Errorf("ParseAddr(%q, %q) = %v, want %v", tt.net, tt.addr, err, tt.err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate("tcp", "localhost:80"); err != nil {
+		t.Errorf("Validate localhost:80: %v", err)
+	}
+	if err := Validate("tcp", "bad_host!:80"); err == nil || err.(*AddrParseError).Error != ErrBadHost {
+		t.Errorf("Validate bad_host!:80: got %v, want %v", err, ErrBadHost)
+	}
+	if err := Validate("tcp", "[::1:80"); err == nil || err.(*AddrParseError).Error != ErrMissingBracket {
+		t.Errorf("Validate [::1:80: got %v, want %v", err, ErrMissingBracket)
+	}
+	if err := Validate("sctp", "localhost:80"); err != nil {
+		t.Errorf("Validate sctp localhost:80: %v", err)
+	}
+	if _, ok := Validate("ipx", "localhost:80").(UnknownNetworkError); !ok {
+		t.Errorf("Validate ipx: expected UnknownNetworkError")
+	}
+}
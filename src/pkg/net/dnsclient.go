@@ -17,6 +17,7 @@ package net
 import (
 	"once";
 	"os";
+	"sync";
 )
 
 // DNSError represents a DNS lookup error.
@@ -38,8 +39,8 @@ func (e *DNSError) String() string {
 const noSuchHost = "no such host"
 
 // Send a request on the connection and hope for a reply.
-// Up to cfg.attempts attempts.
-func _Exchange(cfg *_DNS_Config, c Conn, name string) (m *_DNS_Msg, err os.Error) {
+// Up to cfg.attempts attempts, unless the lookup l is canceled.
+func _Exchange(cfg *_DNS_Config, c Conn, name string, l *HostLookup) (m *_DNS_Msg, err os.Error) {
 	if len(name) >= 256 {
 		return nil, &DNSError{"name too long", name, ""}
 	}
@@ -55,6 +56,9 @@ func _Exchange(cfg *_DNS_Config, c Conn, name string) (m *_DNS_Msg, err os.Error
 	}
 
 	for attempt := 0; attempt < cfg.attempts; attempt++ {
+		if l.canceled() {
+			return nil, l.cancelError()
+		}
 		n, err := c.Write(msg);
 		if err != nil {
 			return nil, err
@@ -138,11 +142,14 @@ Cname:
 
 // Do a lookup for a single name, which must be rooted
 // (otherwise answer will not find the answers).
-func tryOneName(cfg *_DNS_Config, name string) (addrs []string, err os.Error) {
+func tryOneName(cfg *_DNS_Config, name string, l *HostLookup) (addrs []string, err os.Error) {
 	if len(cfg.servers) == 0 {
 		return nil, &DNSError{"no DNS servers", name, ""}
 	}
 	for i := 0; i < len(cfg.servers); i++ {
+		if l.canceled() {
+			return nil, l.cancelError()
+		}
 		// Calling Dial here is scary -- we have to be sure
 		// not to dial a name that will require a DNS lookup,
 		// or Dial will call back here to translate it.
@@ -150,13 +157,16 @@ func tryOneName(cfg *_DNS_Config, name string) (addrs []string, err os.Error) {
 		// all the cfg.servers[i] are IP addresses, which
 		// Dial will use without a DNS lookup.
 		server := cfg.servers[i] + ":53";
+		dnsQueries <- true;	// acquire query slot
 		c, cerr := Dial("udp", "", server);
 		if cerr != nil {
+			<-dnsQueries;
 			err = cerr;
 			continue;
 		}
-		msg, merr := _Exchange(cfg, c, name);
+		msg, merr := _Exchange(cfg, c, name, l);
 		c.Close();
+		<-dnsQueries;	// release query slot
 		if merr != nil {
 			err = merr;
 			continue;
@@ -228,6 +238,12 @@ func isDomainName(s string) bool {
 // It returns the canonical name for the host and an array of that
 // host's addresses.
 func LookupHost(name string) (cname string, addrs []string, err os.Error) {
+	return lookupHost(name, nil)
+}
+
+// lookupHost implements LookupHost; l is the corresponding
+// background lookup, or nil.
+func lookupHost(name string, l *HostLookup) (cname string, addrs []string, err os.Error) {
 	if !isDomainName(name) {
 		return name, nil, &DNSError{"invalid domain name", name, ""}
 	}
@@ -246,7 +262,7 @@ func LookupHost(name string) (cname string, addrs []string, err os.Error) {
 			rname += "."
 		}
 		// Can try as ordinary name.
-		addrs, err = tryOneName(cfg, rname);
+		addrs, err = tryOneName(cfg, rname, l);
 		if err == nil {
 			cname = rname;
 			return;
@@ -262,11 +278,14 @@ func LookupHost(name string) (cname string, addrs []string, err os.Error) {
 		if rname[len(rname)-1] != '.' {
 			rname += "."
 		}
-		addrs, err = tryOneName(cfg, rname);
+		addrs, err = tryOneName(cfg, rname, l);
 		if err == nil {
 			cname = rname;
 			return;
 		}
+		if l.canceled() {
+			return
+		}
 	}
 
 	// Last ditch effort: try unsuffixed.
@@ -274,10 +293,120 @@ func LookupHost(name string) (cname string, addrs []string, err os.Error) {
 	if !rooted {
 		rname += "."
 	}
-	addrs, err = tryOneName(cfg, rname);
+	addrs, err = tryOneName(cfg, rname, l);
 	if err == nil {
 		cname = rname;
 		return;
 	}
 	return;
 }
+
+// Lookups running in the background
+
+// Maximum number of DNS queries in flight at any time, for
+// LookupHost and background lookups.  Each query uses a UDP
+// socket; queries wait for the reply using the pollServer.
+const maxDNSQueries = 64
+
+// Number of goroutines running background lookups, and
+// the number of lookups that may be queued for them.
+const (
+	dnsWorkers	= 16;
+	dnsQueueLen	= 1024;
+)
+
+var dnsQueries = make(chan bool, maxDNSQueries)	// query slots in use
+var dnsQueue chan *HostLookup			// background lookups waiting to run
+
+// A HostLookup is a host name lookup running in the background,
+// started by StartLookupHost.
+type HostLookup struct {
+	Name	string;	// name looked up
+
+	mu		sync.Mutex;
+	done		chan bool;	// closed once the lookup has finished or was canceled
+	finished	bool;
+	cancel		bool;
+	cname		string;
+	addrs		[]string;
+	err		os.Error;
+}
+
+func startDNSWorkers() {
+	dnsQueue = make(chan *HostLookup, dnsQueueLen);
+	for i := 0; i < dnsWorkers; i++ {
+		go dnsWorker()
+	}
+}
+
+func dnsWorker() {
+	for {
+		l := <-dnsQueue;
+		if l.canceled() {
+			continue
+		}
+		cname, addrs, err := lookupHost(l.Name, l);
+		l.finish(cname, addrs, err);
+	}
+}
+
+// StartLookupHost starts a lookup of the host name using the local DNS
+// resolver and returns without waiting for it to complete.  Lookups are
+// run by a fixed number of goroutines, so that programs can start
+// thousands of lookups without creating thousands of goroutines;
+// StartLookupHost blocks only if too many lookups are waiting to run.
+func StartLookupHost(name string) *HostLookup {
+	once.Do(startDNSWorkers);
+	l := &HostLookup{Name: name, done: make(chan bool)};
+	dnsQueue <- l;
+	return l;
+}
+
+// Wait waits for the lookup to complete and returns its results,
+// which are the same as the results of LookupHost.  If the lookup
+// was canceled, Wait returns an error.
+func (l *HostLookup) Wait() (cname string, addrs []string, err os.Error) {
+	<-l.done;
+	l.mu.Lock();
+	defer l.mu.Unlock();
+	return l.cname, l.addrs, l.err;
+}
+
+// Cancel cancels the lookup if it has not yet finished.  Pending and
+// future calls of Wait return immediately.  The lookup stops sending
+// queries, but a query already sent may not be abandoned until it
+// times out.
+func (l *HostLookup) Cancel() {
+	l.mu.Lock();
+	l.cancel = true;
+	if !l.finished {
+		l.finished = true;
+		l.err = l.cancelError();
+		close(l.done);
+	}
+	l.mu.Unlock();
+}
+
+func (l *HostLookup) finish(cname string, addrs []string, err os.Error) {
+	l.mu.Lock();
+	if !l.finished {
+		l.finished = true;
+		l.cname, l.addrs, l.err = cname, addrs, err;
+		close(l.done);
+	}
+	l.mu.Unlock();
+}
+
+// canceled reports whether l was canceled; l may be nil.
+func (l *HostLookup) canceled() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock();
+	defer l.mu.Unlock();
+	return l.cancel;
+}
+
+func (l *HostLookup) cancelError() os.Error {
+	return &DNSError{"lookup canceled", l.Name, ""}
+}
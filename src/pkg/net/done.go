@@ -0,0 +1,186 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Close notification
+
+package net
+
+import (
+	"os";
+	"syscall";
+)
+
+// The Done channel of a connection is closed when the connection is
+// torn down: when it is closed, shut down after an idle timeout, or
+// closed or reset by the peer.  Programs can select on it together
+// with other channels, such as those of I/O completions, instead of
+// dedicating a goroutine per connection to block in Read to find out
+// that the peer has gone.
+//
+// To notice the peer without a pending Read, the pollServer watches
+// a socket whose Done channel is in use for the peer shutting it down
+// or it failing (pollster mode 'h'), which data arriving does not
+// trigger, so that the watch goes on while data waits to be read.
+// When the watch triggers and no Read is waiting, the pollServer peeks
+// at the socket to rule out a spurious wakeup; otherwise the peer has
+// torn the connection down, even if data is still waiting.
+
+// A DoneConn is a Conn with a close notification channel.  The stream
+// connections of this package implement DoneConn.
+type DoneConn interface {
+	Conn;
+
+	// Done returns a channel that is closed when the
+	// connection is torn down.  No values are sent on it.
+	Done() <-chan bool;
+}
+
+// ConnDone returns the Done channel of c, or nil if c does not
+// implement DoneConn.  Receiving from a nil channel blocks forever,
+// so the result can be used in a select statement in either case.
+func ConnDone(c Conn) <-chan bool {
+	if dc, ok := c.(DoneConn); ok {
+		return dc.Done()
+	}
+	return nil;
+}
+
+// closedDone is the Done channel of connections closed
+// before their Done channel was requested.
+var closedDone = make(chan bool)
+
+func init()	{ close(closedDone) }
+
+// A watchRequest asks the pollServer to start or stop
+// watching a socket for being torn down by the peer.
+type watchRequest struct {
+	fd	*netFD;
+	on	bool;
+	ack	chan bool;	// receives a value when a stop request is done; nil for start requests
+}
+
+// done returns the Done channel of fd, creating it on first use.
+func (fd *netFD) done() <-chan bool {
+	fd.doneMu.Lock();
+	if fd.doneC != nil {
+		c := fd.doneC;
+		fd.doneMu.Unlock();
+		return c;
+	}
+	fd.doneC = make(chan bool);
+	c := fd.doneC;
+	watch := !fd.isDone;
+	if fd.isDone {
+		close(fd.doneC)
+	}
+	fd.doneMu.Unlock();
+	if watch {
+		fd.watch()
+	}
+	return c;
+}
+
+// markDone closes the Done channel of fd, if it is in use,
+// and makes Done channels requested later closed.
+func (fd *netFD) markDone() {
+	fd.doneMu.Lock();
+	if !fd.isDone {
+		fd.isDone = true;
+		if fd.doneC != nil {
+			close(fd.doneC)
+		}
+	}
+	fd.doneMu.Unlock();
+}
+
+// watch asks the pollServer to watch fd if its Done channel
+// is in use and the connection has not been torn down yet.
+func (fd *netFD) watch() {
+	fd.doneMu.Lock();
+	ok := fd.doneC != nil && !fd.isDone && fd.proto == syscall.SOCK_STREAM;
+	if ok {
+		fd.watched = true
+	}
+	fd.doneMu.Unlock();
+	if ok {
+		pollserver.cd <- watchRequest{fd, true, nil};
+		pollserver.Wakeup();
+	}
+}
+
+// unwatch makes the pollServer stop watching fd, which is about to be
+// closed, so that the descriptor is not left registered in the poll set.
+func (fd *netFD) unwatch() {
+	fd.doneMu.Lock();
+	ok := fd.watched;
+	fd.watched = false;
+	fd.doneMu.Unlock();
+	if ok {
+		ack := make(chan bool);
+		pollserver.cd <- watchRequest{fd, false, ack};
+		pollserver.Wakeup();
+		<-ack;
+	}
+}
+
+// doneError records a Read or Write error that means
+// the connection has been torn down.
+func (fd *netFD) doneError(err os.Error) {
+	if err != nil && !isEAGAIN(err) && fd.proto == syscall.SOCK_STREAM {
+		fd.markDone()
+	}
+}
+
+// handleWatch starts or stops watching a socket.
+func (s *pollServer) handleWatch(req watchRequest) {
+	fd := req.fd;
+	intfd := fd.fd;
+	key := intfd << 1;
+	if !req.on {
+		if intfd >= 0 && s.watching[intfd] == fd {
+			s.watching[intfd] = nil, false;
+			if fd.ncr == 0 && s.pending[key] == fd {
+				s.pending[key] = nil, false;
+				s.poll.DelFD(intfd, 'r');
+			}
+		}
+		req.ack <- true;
+		return;
+	}
+	if intfd < 0 || s.watching[intfd] == fd {
+		return	// closed underfoot or watched already
+	}
+	if fd.ncr > 0 {
+		// A Read is waiting and will notice the peer;
+		// the watch resumes after it.
+		return
+	}
+	if err := s.poll.AddFD(intfd, 'h', false); err != nil {
+		panicln("pollServer AddFD ", intfd, ": ", err.String(), "\n");
+		return;
+	}
+	s.watching[intfd] = fd;
+	s.pending[key] = fd;
+	s.trace(PollRegister, intfd, 'r');
+}
+
+// checkWatch is called when the socket fd of netfd is readable.
+// If netfd is watched and no Read is waiting, the watch has found
+// the peer gone, unless a peek at the socket shows a spurious wakeup.
+func (s *pollServer) checkWatch(fd int, netfd *netFD) {
+	if s.watching[fd] != netfd {
+		return
+	}
+	s.watching[fd] = nil, false;
+	if netfd.ncr > 0 {
+		return	// the Read will notice the peer
+	}
+	if _, errno := peek(netfd); errno == syscall.EAGAIN {
+		// spurious wakeup; keep watching
+		s.handleWatch(watchRequest{netfd, true, nil});
+		return;
+	}
+	// end of file or an error, or data followed by the peer's shutdown
+	netfd.markDone();
+}
@@ -0,0 +1,118 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"io";
+	"testing";
+	"time";
+)
+
+// isDone reports whether the Done channel c is closed within 1 second.
+func isDone(c <-chan bool) bool {
+	timeout := make(chan bool, 1);
+	go func() {
+		time.Sleep(1e9);
+		timeout <- true;
+	}();
+	select {
+	case <-c:
+		return true
+	case <-timeout:
+	}
+	return false;
+}
+
+// dialPair returns the two ends of a TCP connection over the loopback interface.
+func dialPair(t *testing.T, l *TCPListener) (c, s Conn) {
+	c, err := Dial("tcp", "", l.Addr().String());
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	s, err = l.Accept();
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	return;
+}
+
+func TestDone(t *testing.T) {
+	l, err := ListenTCP("tcp4", &TCPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenTCP: %v", err)
+	}
+	defer l.Close();
+
+	// closed by the peer, without a pending Read
+	c, s := dialPair(t, l);
+	done := ConnDone(c);
+	if done == nil {
+		t.Fatalf("%T does not implement DoneConn", c)
+	}
+	s.Close();
+	if !isDone(done) {
+		t.Errorf("Done not closed after the peer closed the connection")
+	}
+	c.Close();
+
+	// unread data does not count as tear-down
+	c, s = dialPair(t, l);
+	done = ConnDone(c);
+	io.WriteString(s, "hello");
+	if isDone(done) {
+		t.Errorf("Done closed while the connection is up")
+	}
+	var b [5]byte;
+	if _, err := io.ReadFull(c, &b); err != nil {
+		t.Errorf("Read: %v", err)
+	}
+	s.Close();
+	if !isDone(done) {
+		t.Errorf("Done not closed after the peer closed the connection")
+	}
+	c.Close();
+
+	// the watch goes on while data waits to be read
+	c, s = dialPair(t, l);
+	done = ConnDone(c);
+	io.WriteString(s, "hello");
+	if isDone(done) {
+		t.Errorf("Done closed while the connection is up")
+	}
+	s.Close();
+	if !isDone(done) {
+		t.Errorf("Done not closed after the peer closed the connection with data unread")
+	}
+	c.Close();
+
+	// closed locally, before and after Done is requested
+	c, s = dialPair(t, l);
+	done = ConnDone(c);
+	c.Close();
+	if !isDone(done) {
+		t.Errorf("Done not closed after Close")
+	}
+	if !isDone(ConnDone(c)) {
+		t.Errorf("Done of a closed connection not closed")
+	}
+	s.Close();
+}
+
+func TestLoopbackDone(t *testing.T) {
+	l, err := ListenTCP("tcp4", &TCPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenTCP: %v", err)
+	}
+	defer l.Close();
+	l.SetLoopback(true);
+
+	c, s := dialPair(t, l);
+	done := ConnDone(c);
+	s.Close();
+	if !isDone(done) {
+		t.Errorf("Done not closed after the peer closed the in-process connection")
+	}
+	c.Close();
+}
@@ -0,0 +1,134 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Fault injection for testing
+
+package net
+
+import (
+	"os";
+	"sync";
+	"syscall";
+)
+
+// A Fault describes a simulated network condition: the operations
+// matching Op and Addr are delayed, fail, lose their data, or
+// transfer fewer bytes than requested.  Faults let tests exercise
+// timeout, partial write and reconnect logic deterministically.
+type Fault struct {
+	// Op is the operation affected: "dial", "accept", "read" or
+	// "write".  The empty string matches all operations.
+	Op	string;
+
+	// Addr is matched against the remote address of a dial, read
+	// or write, against the source or destination address of a
+	// datagram read by ReadFrom or written by WriteTo, and against
+	// the address of the listener for an accept.  A datagram read
+	// is matched once it has arrived, so its delay follows the
+	// arrival.  The empty string matches all addresses; a pattern
+	// ending in '*' matches all addresses beginning with the rest
+	// of the pattern; any other pattern must match exactly.
+	Addr	string;
+
+	// Delay is the time in nanoseconds to wait before the operation.
+	Delay	int64;
+
+	// If Error is not nil, the operation fails with Error.
+	Error	os.Error;
+
+	// If Drop is set, the data of a read or write is lost: a read
+	// discards the data it received and waits for more, and a write
+	// reports success without sending anything.  An accept closes
+	// the connection it received and waits for the next one.
+	Drop	bool;
+
+	// If Limit is positive, a read or write transfers at most Limit
+	// bytes, and a datagram is truncated to Limit bytes; a write
+	// cut short this way returns io.ErrShortWrite.
+	Limit	int;
+
+	// Count is the number of operations the fault applies to;
+	// 0 means all matching operations.
+	Count	int;
+}
+
+var faults struct {
+	mu		sync.Mutex;
+	list		[]*Fault;	// installed faults; Count is decremented as they apply
+	installed	bool;		// len(list) > 0; read without mu
+}
+
+// SetFaults installs the faults applied to subsequent operations of
+// the sockets of the net package, replacing the faults installed
+// before.  The first fault matching an operation applies to it.
+// SetFaults(nil) restores normal operation.  Faults are meant for
+// tests; when none are installed, operations check for them without
+// taking a lock.
+func SetFaults(list []Fault) {
+	l := make([]*Fault, len(list));
+	for i := range list {
+		f := list[i];
+		l[i] = &f;
+	}
+	faults.mu.Lock();
+	faults.list = l;
+	faults.installed = len(l) > 0;
+	faults.mu.Unlock();
+}
+
+func (f *Fault) match(op string, addr Addr) bool {
+	if f.Op != "" && f.Op != op {
+		return false
+	}
+	if f.Addr == "" {
+		return true
+	}
+	s := "";
+	if addr != nil {
+		s = addr.String()
+	}
+	if n := len(f.Addr) - 1; f.Addr[n] == '*' {
+		return len(s) >= n && s[0:n] == f.Addr[0:n]
+	}
+	return s == f.Addr;
+}
+
+// fault returns the fault applying to the operation op on addr, if
+// any, after waiting for its delay.  A fault with a delay only is
+// not returned.
+func fault(op string, addr Addr) *Fault {
+	if !faults.installed {
+		return nil
+	}
+	faults.mu.Lock();
+	var f *Fault;
+	for i, g := range faults.list {
+		if g.match(op, addr) {
+			f = g;
+			if f.Count > 0 {
+				f.Count--;
+				if f.Count == 0 {
+					// used up; remove it
+					l := make([]*Fault, len(faults.list)-1);
+					copy(l, faults.list[0:i]);
+					copy(l[i:len(l)], faults.list[i+1:len(faults.list)]);
+					faults.list = l;
+					faults.installed = len(l) > 0;
+				}
+			}
+			break;
+		}
+	}
+	faults.mu.Unlock();
+	if f == nil {
+		return nil
+	}
+	if f.Delay > 0 {
+		syscall.Sleep(f.Delay)
+	}
+	if f.Error == nil && !f.Drop && f.Limit <= 0 {
+		return nil
+	}
+	return f;
+}
@@ -0,0 +1,112 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"io";
+	"os";
+	"strings";
+	"testing";
+)
+
+func TestFaults(t *testing.T) {
+	l, err := ListenTCP("tcp", &TCPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenTCP: %v", err)
+	}
+	defer l.Close();
+	addr := l.Addr().String();
+
+	errInjected := os.NewError("injected");
+	SetFaults([]Fault{
+		Fault{Op: "dial", Addr: addr, Error: errInjected, Count: 1},
+		Fault{Op: "write", Addr: addr, Limit: 3, Count: 1},
+		Fault{Op: "read", Addr: "127.0.0.1:*", Error: errInjected, Count: 1},
+	});
+	defer SetFaults(nil);
+
+	if _, err := Dial("tcp", "", addr); err == nil {
+		t.Fatalf("Dial succeeded despite fault")
+	}
+	c, err := Dial("tcp", "", addr);
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close();
+	s, err := l.Accept();
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	defer s.Close();
+
+	// partial write, then a complete one
+	n, err := c.Write(strings.Bytes("hello"));
+	if n != 3 || err != io.ErrShortWrite {
+		t.Errorf("Write = %d, %v; want 3, short write", n, err)
+	}
+	if n, err = c.Write(strings.Bytes("lo")); n != 2 || err != nil {
+		t.Errorf("Write = %d, %v; want 2, nil", n, err)
+	}
+
+	// failed read, then a successful one
+	var b [10]byte;
+	if _, err := s.Read(&b); err != errInjected {
+		t.Errorf("Read = %v; want injected error", err)
+	}
+	if n, err = io.ReadAtLeast(s, &b, 5); n != 5 || string(b[0:n]) != "hello" {
+		t.Errorf("Read = %q, %v; want \"hello\"", b[0:n], err)
+	}
+}
+
+func TestFaultsWriteBuffers(t *testing.T) {
+	l, err := ListenTCP("tcp", &TCPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenTCP: %v", err)
+	}
+	defer l.Close();
+	c, s := tcpPair(t, l);
+	defer c.Close();
+	defer s.Close();
+
+	SetFaults([]Fault{Fault{Op: "write", Limit: 4, Count: 1}});
+	defer SetFaults(nil);
+
+	v := io.Buffers{strings.Bytes("ab"), strings.Bytes("cde")};
+	if n, err := c.(*TCPConn).WriteBuffers(v); n != 4 || err != io.ErrShortWrite {
+		t.Errorf("WriteBuffers = %d, %v; want 4, short write", n, err)
+	}
+	var b [4]byte;
+	if n, err := io.ReadFull(s, &b); n != 4 || string(b[0:n]) != "abcd" {
+		t.Errorf("Read = %q, %v; want \"abcd\"", b[0:n], err)
+	}
+}
+
+func TestFaultsDatagrams(t *testing.T) {
+	c, err := ListenUDP("udp4", &UDPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	defer c.Close();
+	addr := c.LocalAddr().(*UDPAddr);
+
+	SetFaults([]Fault{
+		Fault{Op: "write", Addr: addr.String(), Limit: 3, Count: 1},
+		Fault{Op: "read", Addr: addr.String(), Drop: true, Count: 1},
+	});
+	defer SetFaults(nil);
+
+	// a truncated datagram, which is lost, then a complete one
+	if n, err := c.WriteToUDP(strings.Bytes("hello"), addr); n != 3 || err != io.ErrShortWrite {
+		t.Errorf("WriteToUDP = %d, %v; want 3, short write", n, err)
+	}
+	if n, err := c.WriteToUDP(strings.Bytes("world"), addr); n != 5 || err != nil {
+		t.Errorf("WriteToUDP = %d, %v; want 5, nil", n, err)
+	}
+	var b [10]byte;
+	n, _, err := c.ReadFromUDPTimeout(&b, 1e9);
+	if err != nil || string(b[0:n]) != "world" {
+		t.Errorf("ReadFromUDP = %q, %v; want \"world\"", b[0:n], err)
+	}
+}
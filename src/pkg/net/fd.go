@@ -7,6 +7,7 @@
 package net
 
 import (
+	"io";
 	"once";
 	"os";
 	"sync";
@@ -25,6 +26,8 @@ type netFD struct {
 	net	string;
 	laddr	Addr;
 	raddr	Addr;
+	host	string;	// remote host charged against socket limits
+	form	AddrForm;	// form of the addresses of the socket and accepted sockets
 
 	// owned by client
 	rdeadline_delta	int64;
@@ -34,6 +37,18 @@ type netFD struct {
 	wdeadline	int64;
 	wio		sync.Mutex;
 
+	// idle tracking; see idle.go
+	idleMu		sync.Mutex;	// protects the three fields below
+	idle		int64;		// idle timeout (nsec); 0 if not tracked
+	lastIO		int64;		// time of last Read or Write (nsec since 1970)
+	idleClosed	bool;		// shut down after idle timeout
+
+	// close notification; see done.go
+	doneMu	sync.Mutex;
+	doneC	chan bool;	// Done channel; nil until requested
+	isDone	bool;		// torn down
+	watched	bool;		// watch requested from the pollServer
+
 	// owned by fd wait server
 	ncr, ncw	int;
 }
@@ -73,12 +88,24 @@ type pollServer struct {
 	pending		map[int]*netFD;
 	poll		*pollster;	// low-level OS hooks
 	deadline	int64;		// next deadline (nsec since 1970)
+
+	// connections with an idle timeout; see idle.go
+	idleMu	sync.Mutex;
+	idleFDs	map[int]*netFD;
+	sweep	int64;	// next idle sweep (nsec since 1970)
+
+	// sockets watched for being torn down; see done.go
+	cd		chan watchRequest;	// buffered >= 1
+	watching	map[int]*netFD;
+
+	tracer	pollTracer;	// see polltrace.go
 }
 
 func newPollServer() (s *pollServer, err os.Error) {
 	s = new(pollServer);
 	s.cr = make(chan *netFD, 1);
 	s.cw = make(chan *netFD, 1);
+	s.cd = make(chan watchRequest, 1);
 	if s.pr, s.pw, err = os.Pipe(); err != nil {
 		return nil, err
 	}
@@ -102,6 +129,8 @@ func newPollServer() (s *pollServer, err os.Error) {
 		goto Error;
 	}
 	s.pending = make(map[int]*netFD);
+	s.idleFDs = make(map[int]*netFD);
+	s.watching = make(map[int]*netFD);
 	go s.Run();
 	return s, nil;
 }
@@ -144,6 +173,7 @@ func (s *pollServer) AddFD(fd *netFD, mode int) {
 		t = fd.wdeadline;
 	}
 	s.pending[key] = fd;
+	s.trace(PollRegister, intfd, mode);
 	if t > 0 && (s.deadline == 0 || t < s.deadline) {
 		s.deadline = t
 	}
@@ -200,12 +230,16 @@ func (s *pollServer) CheckDeadlines() {
 			mode = 'w'
 		}
 		if mode == 'r' {
-			t = fd.rdeadline
+			if fd.ncr == 0 {
+				continue	// watched only; see done.go
+			}
+			t = fd.rdeadline;
 		} else {
 			t = fd.wdeadline
 		}
 		if t > 0 {
 			if t <= now {
+				s.trace(PollDeadline, fd.fd, mode);
 				s.pending[key] = nil, false;
 				if mode == 'r' {
 					s.poll.DelFD(fd.fd, mode);
@@ -221,12 +255,19 @@ func (s *pollServer) CheckDeadlines() {
 		}
 	}
 	s.deadline = next_deadline;
+
+	if t := s.nextSweep(); t > 0 && t <= now {
+		s.sweepIdle(now)
+	}
 }
 
 func (s *pollServer) Run() {
 	var scratch [100]byte;
 	for {
 		var t = s.deadline;
+		if sweep := s.nextSweep(); sweep > 0 && (t == 0 || sweep < t) {
+			t = sweep
+		}
 		if t > 0 {
 			t = t - s.Now();
 			if t < 0 {
@@ -241,10 +282,12 @@ func (s *pollServer) Run() {
 		}
 		if fd < 0 {
 			// Timeout happened.
+			s.trace(PollTimeout, -1, 0);
 			s.CheckDeadlines();
 			continue;
 		}
 		if fd == s.pr.Fd() {
+			s.trace(PollRequest, -1, 0);
 			// Drain our wakeup pipe.
 			for nn, _ := s.pr.Read(&scratch); nn > 0; {
 				nn, _ = s.pr.Read(&scratch)
@@ -257,12 +300,20 @@ func (s *pollServer) Run() {
 			for fd, ok := <-s.cw; ok; fd, ok = <-s.cw {
 				s.AddFD(fd, 'w')
 			}
+			for req, ok := <-s.cd; ok; req, ok = <-s.cd {
+				s.handleWatch(req)
+			}
 		} else {
 			netfd := s.LookupFD(fd, mode);
 			if netfd == nil {
+				s.trace(PollSpurious, fd, mode);
 				print("pollServer: unexpected wakeup for fd=", netfd, " mode=", string(mode), "\n");
 				continue;
 			}
+			s.trace(PollWake, fd, mode);
+			if mode == 'r' {
+				s.checkWatch(fd, netfd)
+			}
 			s.WakeFD(netfd, mode);
 		}
 	}
@@ -344,9 +395,13 @@ func (fd *netFD) Close() os.Error {
 	// for Close too.  Sigh.
 	syscall.SetNonblock(fd.file.Fd(), false);
 
+	pollserver.untrackIdle(fd);
+	fd.unwatch();
 	e := fd.file.Close();
 	fd.file = nil;
 	fd.fd = -1;
+	fd.markDone();
+	limits.release(fd.host);
 	return e;
 }
 
@@ -356,19 +411,41 @@ func (fd *netFD) Read(p []byte) (n int, err os.Error) {
 	}
 	fd.rio.Lock();
 	defer fd.rio.Unlock();
+	f := fault("read", fd.raddr);
+	if f != nil {
+		if f.Error != nil {
+			return 0, f.Error
+		}
+		if f.Limit > 0 && len(p) > f.Limit {
+			p = p[0:f.Limit]
+		}
+	}
 	if fd.rdeadline_delta > 0 {
 		fd.rdeadline = pollserver.Now() + fd.rdeadline_delta
 	} else {
 		fd.rdeadline = 0
 	}
+	waited := false;
 	for {
 		n, err = fd.file.Read(p);
 		if isEAGAIN(err) && fd.rdeadline >= 0 {
 			pollserver.WaitRead(fd);
+			waited = true;
 			continue;
 		}
+		if n > 0 && f != nil && f.Drop {
+			continue	// data lost; wait for more
+		}
 		break;
 	}
+	if n > 0 {
+		fd.touch()
+	}
+	if err != nil && !isEAGAIN(err) {
+		fd.doneError(err)
+	} else if waited {
+		fd.watch()	// resume the watch, which waiting suspended
+	}
 	return;
 }
 
@@ -378,6 +455,18 @@ func (fd *netFD) Write(p []byte) (n int, err os.Error) {
 	}
 	fd.wio.Lock();
 	defer fd.wio.Unlock();
+	short := false;
+	if f := fault("write", fd.raddr); f != nil {
+		switch {
+		case f.Error != nil:
+			return 0, f.Error
+		case f.Drop:
+			return len(p), nil
+		case f.Limit < len(p):
+			p = p[0:f.Limit];
+			short = true;
+		}
+	}
 	if fd.wdeadline_delta > 0 {
 		fd.wdeadline = pollserver.Now() + fd.wdeadline_delta
 	} else {
@@ -401,14 +490,388 @@ func (fd *netFD) Write(p []byte) (n int, err os.Error) {
 			break
 		}
 	}
+	if nn > 0 {
+		fd.touch()
+	}
+	fd.doneError(err);
+	if short && err == nil {
+		err = io.ErrShortWrite
+	}
 	return nn, err;
 }
 
+// readFrom receives a single datagram into p and returns its
+// length and source address.  The call times out after nsec
+// nanoseconds; nsec < 0 means the read timeout of fd and
+// nsec == 0 means no deadline.
+func (fd *netFD) readFrom(p []byte, nsec int64) (n int, sa syscall.Sockaddr, err os.Error) {
+	if fd == nil || fd.file == nil {
+		return 0, nil, os.EINVAL
+	}
+	fd.rio.Lock();
+	defer fd.rio.Unlock();
+	if nsec < 0 {
+		nsec = fd.rdeadline_delta
+	}
+	if nsec > 0 {
+		fd.rdeadline = pollserver.Now() + nsec
+	} else {
+		fd.rdeadline = 0
+	}
+	var errno int;
+	for {
+		n, sa, errno = syscall.Recvfrom(fd.fd, p, 0);
+		if errno == syscall.EAGAIN && fd.rdeadline >= 0 {
+			pollserver.WaitRead(fd);
+			continue;
+		}
+		if errno == 0 {
+			if f := fault("read", sockaddrToUDP(sa)); f != nil {
+				switch {
+				case f.Error != nil:
+					return 0, nil, f.Error
+				case f.Drop:
+					continue	// datagram lost; wait for the next one
+				case f.Limit < n:
+					n = f.Limit
+				}
+			}
+		}
+		break;
+	}
+	if errno != 0 {
+		return 0, nil, os.Errno(errno)
+	}
+	fd.touch();
+	return;
+}
+
+// writeTo sends p as a single datagram to sa.  The call times out
+// after nsec nanoseconds; nsec < 0 means the write timeout of fd
+// and nsec == 0 means no deadline.
+func (fd *netFD) writeTo(p []byte, sa syscall.Sockaddr, nsec int64) (n int, err os.Error) {
+	if fd == nil || fd.file == nil {
+		return 0, os.EINVAL
+	}
+	fd.wio.Lock();
+	defer fd.wio.Unlock();
+	short := false;
+	if f := fault("write", sockaddrToUDP(sa)); f != nil {
+		switch {
+		case f.Error != nil:
+			return 0, f.Error
+		case f.Drop:
+			return len(p), nil
+		case f.Limit < len(p):
+			p = p[0:f.Limit];
+			short = true;
+		}
+	}
+	if nsec < 0 {
+		nsec = fd.wdeadline_delta
+	}
+	if nsec > 0 {
+		fd.wdeadline = pollserver.Now() + nsec
+	} else {
+		fd.wdeadline = 0
+	}
+	var errno int;
+	for {
+		errno = syscall.Sendto(fd.fd, p, 0, sa);
+		if errno == syscall.EAGAIN && fd.wdeadline >= 0 {
+			pollserver.WaitWrite(fd);
+			continue;
+		}
+		break;
+	}
+	if errno != 0 {
+		return 0, os.Errno(errno)
+	}
+	fd.touch();
+	if short {
+		return len(p), io.ErrShortWrite
+	}
+	return len(p), nil;
+}
+
+// readSCTP reads an SCTP message, or the next part of it, into p
+// and returns the number of the stream it arrived on and its
+// payload protocol identifier.
+func (fd *netFD) readSCTP(p []byte) (n, stream int, ppid uint32, err os.Error) {
+	if fd == nil || fd.file == nil {
+		return 0, 0, 0, os.EINVAL
+	}
+	fd.rio.Lock();
+	defer fd.rio.Unlock();
+	if fd.rdeadline_delta > 0 {
+		fd.rdeadline = pollserver.Now() + fd.rdeadline_delta
+	} else {
+		fd.rdeadline = 0
+	}
+	var errno int;
+	for {
+		n, stream, ppid, errno = syscall.SctpRecvmsg(fd.fd, p, 0);
+		if errno == syscall.EAGAIN && fd.rdeadline >= 0 {
+			pollserver.WaitRead(fd);
+			continue;
+		}
+		break;
+	}
+	if errno != 0 {
+		return 0, 0, 0, os.Errno(errno)
+	}
+	if n == 0 && len(p) > 0 {
+		return 0, 0, 0, os.EOF
+	}
+	fd.touch();
+	return;
+}
+
+// writeSCTP sends p as a single SCTP message on the given stream,
+// with the payload protocol identifier ppid.
+func (fd *netFD) writeSCTP(p []byte, stream int, ppid uint32) (n int, err os.Error) {
+	if fd == nil || fd.file == nil {
+		return 0, os.EINVAL
+	}
+	fd.wio.Lock();
+	defer fd.wio.Unlock();
+	if fd.wdeadline_delta > 0 {
+		fd.wdeadline = pollserver.Now() + fd.wdeadline_delta
+	} else {
+		fd.wdeadline = 0
+	}
+	var errno int;
+	for {
+		n, errno = syscall.SctpSendmsg(fd.fd, p, stream, ppid, 0);
+		if errno == syscall.EAGAIN && fd.wdeadline >= 0 {
+			pollserver.WaitWrite(fd);
+			continue;
+		}
+		break;
+	}
+	if errno != 0 {
+		return 0, os.Errno(errno)
+	}
+	fd.touch();
+	return;
+}
+
+// maxIovecs is the maximum number of buffers passed
+// to a single writev system call (IOV_MAX).
+const maxIovecs = 1024
+
+func (fd *netFD) writeBuffers(v [][]byte) (n int64, err os.Error) {
+	if fd == nil || fd.file == nil {
+		return 0, os.EINVAL
+	}
+	fd.wio.Lock();
+	defer fd.wio.Unlock();
+	if fd.wdeadline_delta > 0 {
+		fd.wdeadline = pollserver.Now() + fd.wdeadline_delta
+	} else {
+		fd.wdeadline = 0
+	}
+
+	// work on a copy; partial writes trim the buffers
+	iov := make([][]byte, len(v));
+	copy(iov, v);
+
+	short := false;
+	if f := fault("write", fd.raddr); f != nil {
+		total := 0;
+		for _, b := range iov {
+			total += len(b)
+		}
+		switch {
+		case f.Error != nil:
+			return 0, f.Error
+		case f.Drop:
+			return int64(total), nil
+		case f.Limit < total:
+			// keep the first f.Limit bytes
+			m := f.Limit;
+			for i, b := range iov {
+				if m < len(b) {
+					iov[i] = b[0:m];
+					iov = iov[0 : i+1];
+					break;
+				}
+				m -= len(b);
+			}
+			short = true;
+		}
+	}
+
+	for len(iov) > 0 {
+		if len(iov[0]) == 0 {
+			iov = iov[1:len(iov)];
+			continue;
+		}
+		chunk := iov;
+		if len(chunk) > maxIovecs {
+			chunk = chunk[0:maxIovecs]
+		}
+		nw, errno := syscall.Writev(fd.fd, chunk);
+		if nw > 0 {
+			n += int64(nw);
+			for nw > 0 {
+				if nw < len(iov[0]) {
+					iov[0] = iov[0][nw:len(iov[0])];
+					break;
+				}
+				nw -= len(iov[0]);
+				iov = iov[1:len(iov)];
+			}
+			continue;
+		}
+		if errno == syscall.EAGAIN && fd.wdeadline >= 0 {
+			pollserver.WaitWrite(fd);
+			continue;
+		}
+		if errno != 0 {
+			err = &OpError{"writev", fd.net, fd.raddr, os.Errno(errno)}
+		} else {
+			err = io.ErrShortWrite
+		}
+		break;
+	}
+	if n > 0 {
+		fd.touch()
+	}
+	if short && err == nil {
+		err = io.ErrShortWrite
+	}
+	return n, err;
+}
+
+// writeBatch sends the datagrams p, the i'th one to to[i] or, if to
+// is nil, to the connected peer.  It uses a single sendmmsg system call
+// per maxIovecs datagrams where the system provides one.
+func (fd *netFD) writeBatch(p [][]byte, to []syscall.Sockaddr) (n int, err os.Error) {
+	if fd == nil || fd.file == nil {
+		return 0, os.EINVAL
+	}
+	fd.wio.Lock();
+	defer fd.wio.Unlock();
+	if fd.wdeadline_delta > 0 {
+		fd.wdeadline = pollserver.Now() + fd.wdeadline_delta
+	} else {
+		fd.wdeadline = 0
+	}
+	for n < len(p) {
+		chunk := p[n:len(p)];
+		if len(chunk) > maxIovecs {
+			chunk = chunk[0:maxIovecs]
+		}
+		var addrs []syscall.Sockaddr;
+		if to != nil {
+			addrs = to[n : n+len(chunk)]
+		}
+		nw, errno := syscall.Sendmmsg(fd.fd, chunk, addrs, 0);
+		if errno == syscall.ENOSYS {
+			// no sendmmsg; send one datagram at a time
+			nw, errno = 0, 0;
+			if to != nil {
+				errno = syscall.Sendto(fd.fd, chunk[0], 0, to[n])
+			} else {
+				_, errno = syscall.Write(fd.fd, chunk[0])
+			}
+			if errno == 0 {
+				nw = 1
+			}
+		}
+		if nw > 0 {
+			n += nw;
+			continue;
+		}
+		if errno == syscall.EAGAIN && fd.wdeadline >= 0 {
+			pollserver.WaitWrite(fd);
+			continue;
+		}
+		if errno != 0 {
+			err = &OpError{"sendmmsg", fd.net, fd.raddr, os.Errno(errno)}
+		} else {
+			err = io.ErrShortWrite
+		}
+		break;
+	}
+	if n > 0 {
+		fd.touch()
+	}
+	return n, err;
+}
+
+// readBatch receives up to len(p) datagrams into p.  It waits for the
+// first datagram and then returns the datagrams that are available
+// without waiting.  It uses a single recvmmsg system call where the
+// system provides one.
+func (fd *netFD) readBatch(p [][]byte) (n int, lens []int, from []syscall.Sockaddr, err os.Error) {
+	if fd == nil || fd.file == nil {
+		return 0, nil, nil, os.EINVAL
+	}
+	if len(p) > maxIovecs {
+		p = p[0:maxIovecs]
+	}
+	fd.rio.Lock();
+	defer fd.rio.Unlock();
+	if fd.rdeadline_delta > 0 {
+		fd.rdeadline = pollserver.Now() + fd.rdeadline_delta
+	} else {
+		fd.rdeadline = 0
+	}
+	var errno int;
+	for {
+		n, lens, from, errno = syscall.Recvmmsg(fd.fd, p, 0);
+		if errno == syscall.ENOSYS {
+			n, lens, from, errno = recvLoop(fd.fd, p)
+		}
+		if errno == syscall.EAGAIN && fd.rdeadline >= 0 {
+			pollserver.WaitRead(fd);
+			continue;
+		}
+		break;
+	}
+	if errno != 0 {
+		return 0, nil, nil, &OpError{"recvmmsg", fd.net, fd.laddr, os.Errno(errno)}
+	}
+	if n > 0 {
+		fd.touch()
+	}
+	return;
+}
+
+// recvLoop is the fallback for systems without recvmmsg:
+// it receives datagrams one at a time until none is available.
+func recvLoop(s int, p [][]byte) (n int, lens []int, from []syscall.Sockaddr, errno int) {
+	lens = make([]int, len(p));
+	from = make([]syscall.Sockaddr, len(p));
+	for n < len(p) {
+		m, sa, e := syscall.Recvfrom(s, p[n], 0);
+		if e != 0 {
+			if n == 0 {
+				errno = e
+			}
+			break;
+		}
+		lens[n] = m;
+		from[n] = sa;
+		n++;
+	}
+	return n, lens[0:n], from[0:n], errno;
+}
+
 func (fd *netFD) accept(toAddr func(syscall.Sockaddr) Addr) (nfd *netFD, err os.Error) {
 	if fd == nil || fd.file == nil {
 		return nil, os.EINVAL
 	}
 
+	// Reserve a socket before accepting the connection, so that
+	// connections beyond the limit stay queued in the kernel.
+	if err = limits.acquire(""); err != nil {
+		return nil, &OpError{"accept", fd.net, fd.laddr, err}
+	}
+
+Accept:
 	// See ../syscall/exec.go for description of ForkLock.
 	// It is okay to hold the lock across syscall.Accept
 	// because we have put fd.fd into non-blocking mode.
@@ -426,14 +889,40 @@ func (fd *netFD) accept(toAddr func(syscall.Sockaddr) Addr) (nfd *netFD, err os.
 	}
 	if e != 0 {
 		syscall.ForkLock.RUnlock();
+		limits.release("");
 		return nil, &OpError{"accept", fd.net, fd.laddr, os.Errno(e)};
 	}
 	syscall.CloseOnExec(s);
 	syscall.ForkLock.RUnlock();
 
-	if nfd, err = newFD(s, fd.family, fd.proto, fd.net, fd.laddr, toAddr(sa)); err != nil {
+	if f := fault("accept", fd.laddr); f != nil && (f.Error != nil || f.Drop) {
+		syscall.Close(s);
+		if f.Error == nil {
+			goto Accept	// dropped
+		}
+		limits.release("");
+		return nil, &OpError{"accept", fd.net, fd.laddr, f.Error};
+	}
+
+	// Drop connections from hosts exceeding the per-host limit.
+	host := sockaddrHost(sa);
+	if host != "" {
+		if err = limits.connect(host); err != nil {
+			syscall.Close(s);
+			if limits.Wait {
+				goto Accept
+			}
+			limits.release("");
+			return nil, &OpError{"accept", fd.net, fd.laddr, err};
+		}
+	}
+
+	if nfd, err = newFD(s, fd.family, fd.proto, fd.net, fd.laddr, normalizeAddr(toAddr(sa), fd.form)); err != nil {
 		syscall.Close(s);
+		limits.release(host);
 		return nil, err;
 	}
+	nfd.host = host;
+	nfd.form = fd.form;
 	return nfd, nil;
 }
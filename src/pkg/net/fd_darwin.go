@@ -11,6 +11,14 @@ import (
 	"syscall";
 )
 
+// NOTE_LOWAT, missing from package syscall.
+const _NOTE_LOWAT = 0x1
+
+// Low-water mark of the read filter of a hangup wait (mode 'h'; see
+// done.go): so large that the filter triggers only at end of file or
+// on an error, whatever data is waiting to be read.
+const hupLowat = 1 << 30
+
 type pollster struct {
 	kq		int;
 	eventbuf	[10]syscall.Kevent_t;
@@ -29,7 +37,7 @@ func newpollster() (p *pollster, err os.Error) {
 
 func (p *pollster) AddFD(fd int, mode int, repeat bool) os.Error {
 	var kmode int;
-	if mode == 'r' {
+	if mode == 'r' || mode == 'h' {
 		kmode = syscall.EVFILT_READ
 	} else {
 		kmode = syscall.EVFILT_WRITE
@@ -45,6 +53,10 @@ func (p *pollster) AddFD(fd int, mode int, repeat bool) os.Error {
 		flags |= syscall.EV_ONESHOT
 	}
 	syscall.SetKevent(ev, fd, kmode, flags);
+	if mode == 'h' {
+		ev.Fflags = _NOTE_LOWAT;
+		ev.Data = hupLowat;
+	}
 
 	n, e := syscall.Kevent(p.kq, &events, &events, nil);
 	if e != 0 {
@@ -14,6 +14,7 @@ import (
 const (
 	readFlags	= syscall.EPOLLIN | syscall.EPOLLRDHUP;
 	writeFlags	= syscall.EPOLLOUT;
+	hupFlags	= syscall.EPOLLRDHUP;	// peer shutdown only; see done.go
 )
 
 type pollster struct {
@@ -45,9 +46,12 @@ func (p *pollster) AddFD(fd int, mode int, repeat bool) os.Error {
 	if !repeat {
 		ev.Events |= syscall.EPOLLONESHOT
 	}
-	if mode == 'r' {
+	switch mode {
+	case 'r':
 		ev.Events |= readFlags
-	} else {
+	case 'h':
+		ev.Events |= hupFlags
+	default:
 		ev.Events |= writeFlags
 	}
 
@@ -0,0 +1,147 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Port forwarding
+
+package net
+
+import (
+	"io";
+	"os";
+	"sync";
+)
+
+// ForwardOptions controls the connections forwarded by Forward.
+type ForwardOptions struct {
+	// Net is the network of the target address; "tcp" if empty.
+	Net	string;
+
+	// IdleTimeout is the time in nanoseconds after which a forwarded
+	// connection that has carried no data in either direction is
+	// closed.  0 means no timeout.  The timeout is only applied to
+	// connections with a SetIdleTimeout method, such as *TCPConn.
+	IdleTimeout	int64;
+
+	// MaxConns is the maximum number of connections forwarded at
+	// once.  Connections accepted beyond it are closed right away.
+	// 0 means no limit.
+	MaxConns	int;
+}
+
+// An idleTimeouter is a Conn with an idle timeout.
+type idleTimeouter interface {
+	SetIdleTimeout(nsec int64) os.Error;
+}
+
+type forwarder struct {
+	opts	ForwardOptions;
+	target	string;
+	mu	sync.Mutex;
+	active	int;	// number of connections being forwarded
+}
+
+// Forward accepts connections from l and forwards each of them to
+// the address target: it dials target and copies the data in both
+// directions until either side closes its connection, or fails, or
+// the connection has been idle for longer than opts.IdleTimeout.
+// Then both connections are closed.  A connection whose target cannot
+// be dialed is closed.  opts may be nil.
+//
+// Forward returns the error of l.Accept, such as the error returned
+// once l has been closed; the connections being forwarded at that
+// time are not interrupted.
+func Forward(l Listener, target string, opts *ForwardOptions) os.Error {
+	f := &forwarder{target: target};
+	if opts != nil {
+		f.opts = *opts
+	}
+	if f.opts.Net == "" {
+		f.opts.Net = "tcp"
+	}
+	for {
+		c, err := l.Accept();
+		if err != nil {
+			return err
+		}
+		if !f.acquire() {
+			c.Close();
+			continue;
+		}
+		go f.forward(c);
+	}
+	panic("unreachable");
+}
+
+// acquire reserves one of the connections allowed by MaxConns.
+func (f *forwarder) acquire() bool {
+	f.mu.Lock();
+	defer f.mu.Unlock();
+	if f.opts.MaxConns > 0 && f.active >= f.opts.MaxConns {
+		return false
+	}
+	f.active++;
+	return true;
+}
+
+func (f *forwarder) release() {
+	f.mu.Lock();
+	f.active--;
+	f.mu.Unlock();
+}
+
+func (f *forwarder) setIdle(c Conn) {
+	if f.opts.IdleTimeout > 0 {
+		if t, ok := c.(idleTimeouter); ok {
+			t.SetIdleTimeout(f.opts.IdleTimeout)
+		}
+	}
+}
+
+// forward forwards the accepted connection c to the target.
+func (f *forwarder) forward(c Conn) {
+	defer f.release();
+	t, err := Dial(f.opts.Net, "", f.target);
+	if err != nil {
+		c.Close();
+		return;
+	}
+	f.setIdle(c);
+	f.setIdle(t);
+
+	// Copy in both directions; the first copy to end shuts down
+	// both connections, which ends the other copy.
+	done := make(chan bool, 2);
+	go func() {
+		io.Copy(t, c);
+		done <- true;
+	}();
+	go func() {
+		io.Copy(c, t);
+		done <- true;
+	}();
+	<-done;
+	shutdownConn(c);
+	shutdownConn(t);
+	<-done;
+	c.Close();
+	t.Close();
+}
+
+// shutdownConn shuts c down, so that a pending Read returns os.EOF.
+// Closing a socket does not wake a goroutine blocked in Read; other
+// connections, such as in-process ones, are closed instead.
+func shutdownConn(c Conn) {
+	switch c := c.(type) {
+	case *TCPConn:
+		if c.ok() {
+			shutdown(c.fd)
+		}
+	case *UnixConn:
+		if c.ok() {
+			shutdown(c.fd)
+		}
+	default:
+		c.Close()
+	}
+}
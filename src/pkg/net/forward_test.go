@@ -0,0 +1,66 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"io";
+	"os";
+	"strings";
+	"testing";
+)
+
+// echoServer echoes the data of the connections accepted by l.
+func echoServer(l Listener) {
+	for {
+		c, err := l.Accept();
+		if err != nil {
+			return
+		}
+		go func() {
+			io.Copy(c, c);
+			c.Close();
+		}();
+	}
+}
+
+func TestForward(t *testing.T) {
+	target, err := Listen("tcp", "127.0.0.1:0");
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer target.Close();
+	go echoServer(target);
+
+	l, err := Listen("tcp", "127.0.0.1:0");
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close();
+	go Forward(l, target.Addr().String(), &ForwardOptions{MaxConns: 1});
+
+	c, err := Dial("tcp", "", l.Addr().String());
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	msg := strings.Bytes("hello, forwarded world");
+	if _, err := c.Write(msg); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	b := make([]byte, len(msg));
+	if _, err := io.ReadFull(c, b); err != nil || string(b) != string(msg) {
+		t.Errorf("ReadFull = %q, %v; want %q", b, err, msg)
+	}
+
+	// a second connection exceeds MaxConns and is closed
+	c2, err := Dial("tcp", "", l.Addr().String());
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	if n, err := c2.Read(b); n != 0 || err != os.EOF {
+		t.Errorf("Read beyond MaxConns = %d, %v; want 0, os.EOF", n, err)
+	}
+	c2.Close();
+	c.Close();
+}
@@ -0,0 +1,110 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Idle connection tracking.
+
+package net
+
+import "os"
+
+// A connection with an idle timeout records the time of its last
+// successful Read or Write.  The pollServer sweeps the tracked
+// connections when the earliest of them may have expired and shuts
+// down those that have been idle for longer than their timeout.
+// Shutting a connection down (rather than closing it) wakes any
+// pending Read, which then returns os.EOF, and makes Writes fail,
+// while the file descriptor stays valid until the owner closes the
+// connection.  This protects servers from clients that disappear
+// without closing their connections.
+//
+// The idle fields of a netFD are protected by its idleMu, which is
+// acquired after the idleMu of the pollServer when both are held.
+
+func setIdleTimeout(fd *netFD, nsec int64) os.Error {
+	fd.idleMu.Lock();
+	closed := fd.idleClosed;
+	fd.idleMu.Unlock();
+	if nsec < 0 || closed {
+		return os.EINVAL
+	}
+	pollserver.trackIdle(fd, nsec);
+	return nil;
+}
+
+// touch records an I/O operation on fd.
+func (fd *netFD) touch() {
+	fd.idleMu.Lock();
+	if fd.idle > 0 {
+		fd.lastIO = pollserver.Now()
+	}
+	fd.idleMu.Unlock();
+}
+
+// trackIdle sets the idle timeout of fd; nsec == 0 stops tracking fd.
+func (s *pollServer) trackIdle(fd *netFD, nsec int64) {
+	s.idleMu.Lock();
+	fd.idleMu.Lock();
+	fd.idle = nsec;
+	if nsec == 0 {
+		s.idleFDs[fd.fd] = nil, false
+	} else {
+		fd.lastIO = s.Now();
+		s.idleFDs[fd.fd] = fd;
+		if t := fd.lastIO + nsec; s.sweep == 0 || t < s.sweep {
+			s.sweep = t
+		}
+	}
+	fd.idleMu.Unlock();
+	s.idleMu.Unlock();
+
+	// The poll server may be waiting without a timeout.
+	s.Wakeup();
+}
+
+// untrackIdle stops tracking fd before it is closed.
+func (s *pollServer) untrackIdle(fd *netFD) {
+	s.idleMu.Lock();
+	fd.idleMu.Lock();
+	if fd.idle != 0 {
+		s.idleFDs[fd.fd] = nil, false;
+		fd.idle = 0;
+	}
+	fd.idleMu.Unlock();
+	s.idleMu.Unlock();
+}
+
+// nextSweep returns the time of the next idle sweep,
+// or 0 if no connection is tracked.
+func (s *pollServer) nextSweep() int64 {
+	s.idleMu.Lock();
+	t := s.sweep;
+	s.idleMu.Unlock();
+	return t;
+}
+
+// sweepIdle shuts down the connections that have been idle
+// for longer than their timeout and schedules the next sweep.
+func (s *pollServer) sweepIdle(now int64) {
+	s.idleMu.Lock();
+	var next int64;
+	for key, fd := range s.idleFDs {
+		fd.idleMu.Lock();
+		t := fd.lastIO + fd.idle;
+		expired := t <= now;
+		if expired {
+			fd.idle = 0;
+			fd.idleClosed = true;
+		}
+		fd.idleMu.Unlock();
+		if expired {
+			s.idleFDs[key] = nil, false;
+			shutdown(fd);
+			fd.markDone();
+		} else if next == 0 || t < next {
+			next = t
+		}
+	}
+	s.sweep = next;
+	s.idleMu.Unlock();
+}
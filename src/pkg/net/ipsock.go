@@ -42,7 +42,13 @@ type sockaddr interface {
 	family() int;
 }
 
-func internetSocket(net string, laddr, raddr sockaddr, proto int, mode string, toAddr func(syscall.Sockaddr) Addr) (fd *netFD, err os.Error) {
+func internetSocket(net string, laddr, raddr sockaddr, sotype, proto int, mode, dev string, toAddr func(syscall.Sockaddr) Addr) (fd *netFD, err os.Error) {
+	return policySocket(net, laddr, raddr, sotype, proto, mode, dev, toAddr, currentIPv6Policy())
+}
+
+// policySocket is like internetSocket but applies the given IPv6Policy
+// rather than the package's.
+func policySocket(net string, laddr, raddr sockaddr, sotype, proto int, mode, dev string, toAddr func(syscall.Sockaddr) Addr, policy IPv6Policy) (fd *netFD, err os.Error) {
 	// Figure out IP version.
 	// If network has a suffix like "tcp4", obey it.
 	family := syscall.AF_INET6;
@@ -54,9 +60,11 @@ func internetSocket(net string, laddr, raddr sockaddr, proto int, mode string, t
 	default:
 		// Otherwise, guess.
 		// If the addresses are IPv4 and we prefer IPv4, use 4; else 6.
-		if preferIPv4 &&
-			(laddr == nil || laddr.family() == syscall.AF_INET) &&
-			(raddr == nil || raddr.family() == syscall.AF_INET) {
+		// An IPv6-only socket cannot reach IPv4 addresses, so use 4
+		// for them under V6Only, too, unless listening on all addresses.
+		if (laddr == nil || laddr.family() == syscall.AF_INET) &&
+			(raddr == nil || raddr.family() == syscall.AF_INET) &&
+			(preferIPv4 || policy.V6Only && (raddr != nil || !isWildcard(laddr))) {
 			family = syscall.AF_INET
 		}
 	}
@@ -72,10 +80,12 @@ func internetSocket(net string, laddr, raddr sockaddr, proto int, mode string, t
 			goto Error
 		}
 	}
-	fd, err = socket(net, family, proto, 0, la, ra, toAddr);
+	form := policy.Form;
+	fd, err = socket(net, family, sotype, proto, dev, policy.V6Only, la, ra, func(sa syscall.Sockaddr) Addr { return normalizeAddr(toAddr(sa), form) });
 	if err != nil {
 		goto Error
 	}
+	fd.form = form;
 	return fd, nil;
 
 Error:
@@ -0,0 +1,87 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Connection layering
+
+package net
+
+import "os"
+
+// A HandshakeConn is a Conn implemented by a protocol layer, such as
+// a security layer, that must exchange messages with its peer before
+// application data can flow.  Handshake runs that exchange; Read and
+// Write run it implicitly if it has not completed yet.  Calling
+// Handshake again after it completed returns the original result.
+type HandshakeConn interface {
+	Conn;
+	Handshake() os.Error;
+}
+
+// A WrapFunc layers a protocol on top of the connection c and
+// returns the layered connection, or an error if the layer
+// cannot be established on c.
+type WrapFunc func(c Conn) (Conn, os.Error)
+
+// WrapListener returns a Listener that applies wrap to each connection
+// accepted by l before returning it from Accept.  If wrap fails, the
+// connection is closed and Accept goes on to the next one, so that a
+// single misbehaving peer does not stop a server accepting in a loop;
+// Accept returns only the errors of l.
+//
+// Accept does not run the handshake of a HandshakeConn returned by
+// wrap, so that a slow peer cannot hold up accepting other connections;
+// the handshake runs with the first Read or Write, or when the caller
+// invokes Handshake.
+func WrapListener(l Listener, wrap WrapFunc) Listener {
+	return &wrapListener{l, wrap}
+}
+
+type wrapListener struct {
+	l	Listener;
+	wrap	WrapFunc;
+}
+
+func (l *wrapListener) Accept() (c Conn, err os.Error) {
+	for {
+		c, err = l.l.Accept();
+		if err != nil {
+			return nil, err
+		}
+		w, err := l.wrap(c);
+		if err == nil {
+			return w, nil
+		}
+		c.Close();
+	}
+	panic("unreachable");
+}
+
+func (l *wrapListener) Close() os.Error	{ return l.l.Close() }
+
+func (l *wrapListener) Addr() Addr	{ return l.l.Addr() }
+
+// DialWrap is like Dial but applies wrap to the new connection.
+// If the wrapped connection is a HandshakeConn, DialWrap runs the
+// handshake before returning, so that errors establishing the
+// layer are reported by DialWrap.  On error, the connection is closed.
+func DialWrap(net, laddr, raddr string, wrap WrapFunc) (c Conn, err os.Error) {
+	c, err = Dial(net, laddr, raddr);
+	if err != nil {
+		return nil, err
+	}
+	w, err := wrap(c);
+	if err == nil {
+		if h, ok := w.(HandshakeConn); ok {
+			if err = h.Handshake(); err != nil {
+				w.Close()
+			}
+		}
+	} else {
+		c.Close()
+	}
+	if err != nil {
+		return nil, &OpError{"dial", net + " " + raddr, nil, err}
+	}
+	return w, nil;
+}
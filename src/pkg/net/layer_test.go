@@ -0,0 +1,139 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"io";
+	"os";
+	"strings";
+	"testing";
+)
+
+// xorConn is a toy protocol layer: it exchanges a hello byte with
+// its peer and then xors all data with a fixed key.
+type xorConn struct {
+	Conn;
+	done	bool;
+	err	os.Error;
+}
+
+const xorKey = 0x5a
+
+func (c *xorConn) Handshake() os.Error {
+	if c.done {
+		return c.err
+	}
+	c.done = true;
+	var b [1]byte;
+	b[0] = 'H';
+	if _, c.err = c.Conn.Write(&b); c.err != nil {
+		return c.err
+	}
+	if _, c.err = io.ReadFull(c.Conn, &b); c.err == nil && b[0] != 'H' {
+		c.err = os.ErrorString("bad hello")
+	}
+	return c.err;
+}
+
+func (c *xorConn) Read(b []byte) (n int, err os.Error) {
+	if err = c.Handshake(); err != nil {
+		return
+	}
+	n, err = c.Conn.Read(b);
+	for i := 0; i < n; i++ {
+		b[i] ^= xorKey
+	}
+	return;
+}
+
+func (c *xorConn) Write(b []byte) (n int, err os.Error) {
+	if err = c.Handshake(); err != nil {
+		return
+	}
+	x := make([]byte, len(b));
+	for i := range b {
+		x[i] = b[i] ^ xorKey
+	}
+	return c.Conn.Write(x);
+}
+
+func wrapXor(c Conn) (Conn, os.Error)	{ return &xorConn{Conn: c}, nil }
+
+func TestWrapListener(t *testing.T) {
+	l, err := Listen("tcp", "127.0.0.1:0");
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	wl := WrapListener(l, wrapXor);
+	defer wl.Close();
+
+	// echo server
+	go func() {
+		c, err := wl.Accept();
+		if err != nil {
+			t.Errorf("Accept: %v", err);
+			return;
+		}
+		if _, ok := c.(*xorConn); !ok {
+			t.Errorf("Accept returned %T, expected *xorConn", c)
+		}
+		var buf [5]byte;
+		if _, err := io.ReadFull(c, &buf); err == nil {
+			c.Write(&buf)
+		}
+		c.Close();
+	}();
+
+	c, err := DialWrap("tcp", "", wl.Addr().String(), wrapXor);
+	if err != nil {
+		t.Fatalf("DialWrap: %v", err)
+	}
+	defer c.Close();
+	if _, err := c.Write(strings.Bytes("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	var buf [5]byte;
+	if _, err := io.ReadFull(c, &buf); err != nil {
+		t.Fatalf("ReadFull: %v", err)
+	}
+	if s := string(buf[0:len(buf)]); s != "hello" {
+		t.Errorf("read %q, expected %q", s, "hello")
+	}
+}
+
+func TestWrapListenerError(t *testing.T) {
+	l, err := Listen("tcp", "127.0.0.1:0");
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	// the wrap of the first connection fails
+	wrapped := 0;
+	wl := WrapListener(l, func(c Conn) (Conn, os.Error) {
+		wrapped++;
+		if wrapped == 1 {
+			return nil, os.ErrorString("refused")
+		}
+		return c, nil;
+	});
+	defer wl.Close();
+
+	go func() {
+		for i := 0; i < 2; i++ {
+			if c, err := Dial("tcp", "", wl.Addr().String()); err == nil {
+				c.Close()
+			}
+		}
+	}();
+
+	// Accept skips the failed connection
+	c, err := wl.Accept();
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	c.Close();
+	if wrapped != 2 {
+		t.Errorf("wrap called %d times, expected 2", wrapped)
+	}
+}
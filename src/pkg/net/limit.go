@@ -0,0 +1,158 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Socket limits
+
+package net
+
+import (
+	"os";
+	"sync";
+	"syscall";
+)
+
+// ErrSocketLimit is returned by Dial, Listen and Accept when
+// opening another socket would exceed the configured SocketLimits
+// and the limits are not configured to wait.
+var ErrSocketLimit os.Error = os.ErrorString("socket limit reached")
+
+// SocketLimits bounds the number of sockets the net package
+// keeps open at the same time.  It protects programs running
+// in environments with a small file descriptor table from
+// running out of descriptors (EMFILE).
+type SocketLimits struct {
+	// MaxOpen is the maximum number of sockets open at once,
+	// including listening sockets.  0 means no limit.
+	MaxOpen	int;
+
+	// MaxPerHost is the maximum number of connections open at
+	// once to or from a single remote host.  0 means no limit.
+	MaxPerHost	int;
+
+	// If Wait is set, opening a socket beyond a limit blocks until
+	// another socket is closed; otherwise it fails with ErrSocketLimit.
+	// Incoming connections exceeding MaxPerHost are always dropped;
+	// with Wait set, Accept then waits for the next connection.
+	Wait	bool;
+}
+
+// SetSocketLimits sets the limits enforced when sockets are created
+// by Dial and Listen or accepted by a Listener.  Sockets already
+// open count towards the new limits but are not closed.
+func SetSocketLimits(l SocketLimits) {
+	limits.mu.Lock();
+	limits.SocketLimits = l;
+	limits.wakeup();	// limits may have been raised
+	limits.mu.Unlock();
+}
+
+// OpenSockets returns the number of sockets currently
+// held open by the net package.
+func OpenSockets() int {
+	limits.mu.Lock();
+	defer limits.mu.Unlock();
+	return limits.open;
+}
+
+type socketBudget struct {
+	mu	sync.Mutex;
+	SocketLimits;
+	open	int;		// number of open sockets
+	hosts	map[string]int;	// number of open connections per remote host
+	wake	chan bool;	// closed to wake up waiters; or nil
+	waiting	chan bool;	// if not nil, told of each wait; for tests
+}
+
+var limits = &socketBudget{hosts: make(map[string]int)}
+
+// wakeup wakes all goroutines waiting for a free socket.
+// b.mu must be held.
+func (b *socketBudget) wakeup() {
+	if b.wake != nil {
+		close(b.wake);
+		b.wake = nil;
+	}
+}
+
+// wait releases b.mu, waits for the next call of wakeup,
+// and acquires b.mu again.
+func (b *socketBudget) wait() {
+	if b.wake == nil {
+		b.wake = make(chan bool)
+	}
+	c := b.wake;
+	if b.waiting != nil {
+		select {
+		case b.waiting <- true:
+		default:
+		}
+	}
+	b.mu.Unlock();
+	<-c;
+	b.mu.Lock();
+}
+
+func (b *socketBudget) full(host string) bool {
+	return b.MaxOpen > 0 && b.open >= b.MaxOpen ||
+		host != "" && b.MaxPerHost > 0 && b.hosts[host] >= b.MaxPerHost
+}
+
+// acquire reserves a socket connected to host ("" if the socket
+// is not connected).
+func (b *socketBudget) acquire(host string) os.Error {
+	b.mu.Lock();
+	defer b.mu.Unlock();
+	for b.full(host) {
+		if !b.Wait {
+			return ErrSocketLimit
+		}
+		b.wait();
+	}
+	b.open++;
+	if host != "" {
+		b.hosts[host]++
+	}
+	return nil;
+}
+
+// connect charges an already acquired unconnected socket
+// to host.  It never waits.
+func (b *socketBudget) connect(host string) os.Error {
+	b.mu.Lock();
+	defer b.mu.Unlock();
+	if b.MaxPerHost > 0 && b.hosts[host] >= b.MaxPerHost {
+		return ErrSocketLimit
+	}
+	b.hosts[host]++;
+	return nil;
+}
+
+// release returns a socket acquired for host.
+func (b *socketBudget) release(host string) {
+	b.mu.Lock();
+	b.open--;
+	if host != "" {
+		if n := b.hosts[host] - 1; n > 0 {
+			b.hosts[host] = n
+		} else {
+			b.hosts[host] = 0, false
+		}
+	}
+	b.wakeup();
+	b.mu.Unlock();
+}
+
+// sockaddrHost returns the host part of sa for the
+// purpose of per-host limits; or "" if sa is nil.
+func sockaddrHost(sa syscall.Sockaddr) string {
+	switch a := sa.(type) {
+	case *syscall.SockaddrInet4:
+		return IP(&a.Addr).String()
+	case *syscall.SockaddrInet6:
+		return IP(&a.Addr).String()
+	case *syscall.SockaddrUnix:
+		return a.Name
+	}
+	return "";
+}
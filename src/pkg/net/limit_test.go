@@ -0,0 +1,83 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import "testing"
+
+func TestSocketLimits(t *testing.T) {
+	l, err := Listen("tcp", "127.0.0.1:0");
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close();
+	addr := l.Addr().String();
+
+	SetSocketLimits(SocketLimits{MaxPerHost: 1});
+	defer SetSocketLimits(SocketLimits{});
+
+	c, err := Dial("tcp", "", addr);
+	if err != nil {
+		t.Fatalf("first Dial: %v", err)
+	}
+	if _, err := Dial("tcp", "", addr); err == nil {
+		t.Errorf("second Dial succeeded, expected %v", ErrSocketLimit)
+	} else if e, ok := err.(*OpError); !ok || e.Error != ErrSocketLimit {
+		t.Errorf("second Dial: %v, expected %v", err, ErrSocketLimit)
+	}
+	c.Close();
+
+	// closing the connection frees its slot
+	c, err = Dial("tcp", "", addr);
+	if err != nil {
+		t.Fatalf("Dial after Close: %v", err)
+	}
+	c.Close();
+}
+
+func TestSocketLimitsWait(t *testing.T) {
+	l, err := Listen("tcp", "127.0.0.1:0");
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close();
+	addr := l.Addr().String();
+
+	waiting := make(chan bool, 1);
+	limits.mu.Lock();
+	limits.waiting = waiting;
+	limits.mu.Unlock();
+	defer func() {
+		limits.mu.Lock();
+		limits.waiting = nil;
+		limits.mu.Unlock();
+	}();
+
+	SetSocketLimits(SocketLimits{MaxOpen: OpenSockets() + 1, Wait: true});
+	defer SetSocketLimits(SocketLimits{});
+
+	c, err := Dial("tcp", "", addr);
+	if err != nil {
+		t.Fatalf("first Dial: %v", err)
+	}
+	done := make(chan Conn);
+	go func() {
+		c, err := Dial("tcp", "", addr);
+		if err != nil {
+			t.Errorf("second Dial: %v", err)
+		}
+		done <- c;
+	}();
+
+	// the second Dial is waiting for a free socket once it is
+	// blocked in wait; it cannot finish before c is closed
+	<-waiting;
+	if _, ok := <-done; ok {
+		t.Errorf("second Dial did not wait for a free socket")
+	}
+	c.Close();
+	if c := <-done; c != nil {
+		c.Close()
+	}
+}
@@ -0,0 +1,391 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// In-process loopback connections
+
+package net
+
+import (
+	"io";
+	"os";
+	"sync";
+)
+
+// TCP listeners accepting in-process connections, by port;
+// see TCPListener.SetLoopback.
+var (
+	loopbackMu		sync.Mutex;
+	loopbackListeners	= make(map[int]*TCPListener);
+)
+
+// maxLoopbackQueue is the number of in-process connections
+// that may wait to be accepted; further dials use the kernel.
+const maxLoopbackQueue = 16
+
+// loopBufferSize is the number of bytes an in-process connection
+// buffers in each direction, as the socket buffers of a kernel
+// connection do; a Write blocks only once that much is unread.
+const loopBufferSize = 64 * 1024
+
+type acceptResult struct {
+	c	*TCPConn;
+	err	os.Error;
+}
+
+func isLoopbackIP(ip IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4[0] == 127
+	}
+	if len(ip) != IPv6len {
+		return false
+	}
+	for i := 0; i < IPv6len-1; i++ {
+		if ip[i] != 0 {
+			return false
+		}
+	}
+	return ip[IPv6len-1] == 1;
+}
+
+// SetLoopback sets whether connections dialed from within this
+// process to the listener's port on a loopback address, such as
+// 127.0.0.1, bypass the kernel.  If loopback is true, Dial passes
+// such connections directly to Accept through an in-memory pipe,
+// which avoids system calls for every Read and Write; this speeds
+// up tests and proxies that route traffic back into the process.
+// The listener must listen on a loopback or the unspecified address.
+//
+// Dial returns in-process connections as a Conn that is not a
+// *TCPConn, and only Accept, not AcceptTCP, returns them.  Dials
+// with an explicit local address always use the kernel, as do dials
+// while maxLoopbackQueue connections are waiting to be accepted.
+// To listen for both kinds of connections, the listener accepts
+// kernel connections in the background; at most one such
+// connection waits for a call of Accept or AcceptTCP.
+//
+// Like kernel connections, in-process connections are subject to
+// the "dial" faults installed by SetFaults, and the dialing end
+// counts against the SocketLimits until it is closed; the accepted
+// end, which has no counterpart in Dial, does not count.
+func (l *TCPListener) SetLoopback(loopback bool) os.Error {
+	if l == nil || l.fd == nil {
+		return os.EINVAL
+	}
+	addr := l.fd.laddr.(*TCPAddr);
+	if loopback && !isLoopbackIP(addr.IP) && !isZeros(addr.IP) {
+		return &OpError{"setloopback", "tcp", addr, os.EINVAL}
+	}
+
+	loopbackMu.Lock();
+	defer loopbackMu.Unlock();
+	if !loopback {
+		if loopbackListeners[addr.Port] == l {
+			loopbackListeners[addr.Port] = nil, false
+		}
+		return nil;
+	}
+	if l.loop == nil {
+		l.loop = make(chan Conn, maxLoopbackQueue);
+		l.accepted = make(chan acceptResult);
+		l.done = make(chan bool);
+		go l.acceptLoop();
+	}
+	loopbackListeners[addr.Port] = l;
+	return nil;
+}
+
+// acceptLoop accepts kernel connections for Accept and AcceptTCP
+// while l accepts in-process connections.
+func (l *TCPListener) acceptLoop() {
+	for {
+		c, err := l.acceptTCP();
+		select {
+		case l.accepted <- acceptResult{c, err}:
+		case <-l.done:
+			if c != nil {
+				c.Close()
+			}
+			return;
+		}
+	}
+}
+
+// stopLoopback stops accepting in-process connections.
+func (l *TCPListener) stopLoopback() {
+	if l.loop == nil {
+		return
+	}
+	// once l is unregistered, dialLoopback, which holds
+	// loopbackMu while it queues, cannot queue more
+	loopbackMu.Lock();
+	port := l.fd.laddr.(*TCPAddr).Port;
+	if loopbackListeners[port] == l {
+		loopbackListeners[port] = nil, false
+	}
+	loopbackMu.Unlock();
+	close(l.done);
+	// closing l.fd does not wake acceptLoop, which waits in accept;
+	// shutting the socket down makes accept fail, so that it sees
+	// l.done and returns
+	shutdown(l.fd);
+	for c, ok := <-l.loop; ok; c, ok = <-l.loop {
+		c.Close()
+	}
+}
+
+// dialLoopback returns an in-process connection to raddr,
+// or nil if there is no listener accepting them.
+func dialLoopback(raddr *TCPAddr) (c Conn, err os.Error) {
+	if raddr == nil || !isLoopbackIP(raddr.IP) {
+		return nil, nil
+	}
+	loopbackMu.Lock();
+	_, found := loopbackListeners[raddr.Port];
+	loopbackMu.Unlock();
+	if !found {
+		return nil, nil
+	}
+
+	// as socket does for kernel connections; acquire may
+	// wait, so loopbackMu must not be held
+	if f := fault("dial", raddr); f != nil && f.Error != nil {
+		return nil, f.Error
+	}
+	host := raddr.IP.String();
+	if err = limits.acquire(host); err != nil {
+		return nil, err
+	}
+
+	loopbackMu.Lock();
+	defer loopbackMu.Unlock();
+	l, found := loopbackListeners[raddr.Port];
+	if !found {
+		limits.release(host);
+		return nil, nil;
+	}
+	laddr := &TCPAddr{raddr.IP, 0};
+	pc, s := newPipeConns(laddr, raddr, normalizeAddr(raddr, l.fd.form), normalizeAddr(laddr, l.fd.form));
+	pc.host = host;
+	pc.counted = true;
+	select {
+	case l.loop <- s:
+	default:
+		limits.release(host);
+		return nil, nil;	// too many waiting connections
+	}
+	return pc, nil;
+}
+
+// A loopPipe carries the data of one direction of an in-process
+// connection.  Unlike an io.Pipe, it buffers up to loopBufferSize
+// bytes, so that, as with a kernel connection, both ends may write
+// before reading without a deadlock.
+type loopPipe struct {
+	mu			sync.Mutex;
+	buf			[]byte;		// unread data
+	rclosed, wclosed	bool;
+	readable		chan bool;	// signaled when Read may proceed
+	writable		chan bool;	// signaled when Write may proceed
+}
+
+func newLoopPipe() *loopPipe {
+	return &loopPipe{readable: make(chan bool, 1), writable: make(chan bool, 1)}
+}
+
+// wake wakes up a goroutine waiting on c, if there is one.
+func wake(c chan bool) {
+	select {
+	case c <- true:
+	default:
+	}
+}
+
+func (p *loopPipe) Read(b []byte) (n int, err os.Error) {
+	p.mu.Lock();
+	for len(p.buf) == 0 && !p.rclosed && !p.wclosed {
+		p.mu.Unlock();
+		<-p.readable;
+		p.mu.Lock();
+	}
+	switch {
+	case p.rclosed:
+		err = os.EINVAL
+	case len(p.buf) > 0:
+		n = copy(b, p.buf);
+		p.buf = p.buf[n:len(p.buf)];
+		wake(p.writable);
+	default:
+		err = os.EOF
+	}
+	if len(p.buf) > 0 || p.rclosed || p.wclosed {
+		wake(p.readable)	// pass on to other readers
+	}
+	p.mu.Unlock();
+	return;
+}
+
+func (p *loopPipe) Write(b []byte) (n int, err os.Error) {
+	p.mu.Lock();
+	for n < len(b) {
+		for len(p.buf) >= loopBufferSize && !p.rclosed && !p.wclosed {
+			p.mu.Unlock();
+			<-p.writable;
+			p.mu.Lock();
+		}
+		if p.rclosed || p.wclosed {
+			err = os.EPIPE;
+			break;
+		}
+		m := len(b) - n;
+		if m > loopBufferSize-len(p.buf) {
+			m = loopBufferSize - len(p.buf)
+		}
+		l := len(p.buf);
+		if l+m > cap(p.buf) {
+			size := 2 * (l + m);
+			if size > loopBufferSize {
+				size = loopBufferSize
+			}
+			buf := make([]byte, l, size);
+			copy(buf, p.buf);
+			p.buf = buf;
+		}
+		p.buf = p.buf[0 : l+m];
+		copy(p.buf[l:l+m], b[n:n+m]);
+		n += m;
+		wake(p.readable);
+	}
+	if len(p.buf) < loopBufferSize || p.rclosed || p.wclosed {
+		wake(p.writable)	// pass on to other writers
+	}
+	p.mu.Unlock();
+	return;
+}
+
+// closeRead closes the reading end of p; the data not yet
+// read is dropped, and writes fail with os.EPIPE.
+func (p *loopPipe) closeRead() {
+	p.mu.Lock();
+	p.rclosed = true;
+	p.buf = nil;
+	p.mu.Unlock();
+	wake(p.readable);
+	wake(p.writable);
+}
+
+// closeWrite closes the writing end of p; reads return
+// the data not yet read and then os.EOF.
+func (p *loopPipe) closeWrite() {
+	p.mu.Lock();
+	p.wclosed = true;
+	p.mu.Unlock();
+	wake(p.readable);
+	wake(p.writable);
+}
+
+// A pipeConn is one end of an in-process connection.
+type pipeConn struct {
+	r	*loopPipe;
+	w	*loopPipe;
+	rd	io.Reader;	// r, with the read timeout if any
+	wr	io.Writer;	// w, with the write timeout if any
+	laddr	Addr;
+	raddr	Addr;
+	values	Values;
+	done	*pipeDone;	// shared by both ends
+
+	mu	sync.Mutex;
+	host	string;	// remote host, for limits
+	counted	bool;	// holds a socket of limits until Close
+}
+
+// A pipeDone is the Done channel of both ends of an in-process
+// connection; the first Close of either end closes it.
+type pipeDone struct {
+	sync.Mutex;
+	c	chan bool;
+	closed	bool;
+}
+
+func (d *pipeDone) close() {
+	d.Lock();
+	if !d.closed {
+		d.closed = true;
+		close(d.c);
+	}
+	d.Unlock();
+}
+
+func newPipeConn(r, w *loopPipe, laddr, raddr Addr, done *pipeDone) *pipeConn {
+	return &pipeConn{r: r, w: w, rd: r, wr: w, laddr: laddr, raddr: raddr, done: done}
+}
+
+// newPipeConns returns the two ends of a new in-process connection:
+// c, with the addresses laddr and raddr, and s, with the addresses
+// slocal and sremote.
+func newPipeConns(laddr, raddr, slocal, sremote Addr) (c, s *pipeConn) {
+	p1, p2 := newLoopPipe(), newLoopPipe();
+	done := &pipeDone{c: make(chan bool)};
+	c = newPipeConn(p1, p2, laddr, raddr, done);
+	s = newPipeConn(p2, p1, slocal, sremote, done);
+	return;
+}
+
+func (c *pipeConn) Read(b []byte) (n int, err os.Error) {
+	n, err = c.rd.Read(b);
+	if err == io.ErrTimeout {
+		err = os.EAGAIN
+	}
+	return;
+}
+
+// Write returns n == len(b) with os.EAGAIN if it timed out while
+// the data was being written; the data is still written and must
+// not be written again.  See io.TimeoutWriter.
+func (c *pipeConn) Write(b []byte) (n int, err os.Error) {
+	n, err = c.wr.Write(b);
+	if err == io.ErrTimeout {
+		err = os.EAGAIN
+	}
+	return;
+}
+
+func (c *pipeConn) Close() os.Error {
+	c.done.close();
+	c.r.closeRead();
+	c.w.closeWrite();
+	c.mu.Lock();
+	counted := c.counted;
+	c.counted = false;
+	c.mu.Unlock();
+	if counted {
+		limits.release(c.host)
+	}
+	return nil;
+}
+
+// Done returns a channel that is closed when either end
+// of the connection is closed.
+func (c *pipeConn) Done() <-chan bool	{ return c.done.c }
+
+func (c *pipeConn) LocalAddr() Addr	{ return c.laddr }
+
+func (c *pipeConn) RemoteAddr() Addr	{ return c.raddr }
+
+func (c *pipeConn) Values() *Values	{ return &c.values }
+
+func (c *pipeConn) SetTimeout(nsec int64) os.Error {
+	c.SetReadTimeout(nsec);
+	return c.SetWriteTimeout(nsec);
+}
+
+func (c *pipeConn) SetReadTimeout(nsec int64) os.Error {
+	c.rd = io.TimeoutReader(c.r, nsec);
+	return nil;
+}
+
+func (c *pipeConn) SetWriteTimeout(nsec int64) os.Error {
+	c.wr = io.TimeoutWriter(c.w, nsec);
+	return nil;
+}
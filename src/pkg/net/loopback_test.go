@@ -0,0 +1,132 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"io";
+	"os";
+	"testing";
+)
+
+func TestLoopback(t *testing.T) {
+	l, err := ListenTCP("tcp4", &TCPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenTCP: %v", err)
+	}
+	defer l.Close();
+	if err := l.SetLoopback(true); err != nil {
+		t.Fatalf("SetLoopback: %v", err)
+	}
+
+	c, err := Dial("tcp", "", l.Addr().String());
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close();
+	if _, ok := c.(*TCPConn); ok {
+		t.Errorf("Dial returned a kernel connection")
+	}
+	s, err := l.Accept();
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	defer s.Close();
+
+	go io.WriteString(c, "hello");
+	var b [5]byte;
+	if _, err := io.ReadFull(s, b[0:5]); err != nil || string(b[0:5]) != "hello" {
+		t.Errorf("read %q, %v; expected %q", b[0:5], err, "hello")
+	}
+
+	// as with a kernel connection, both ends may write before reading
+	if _, err := io.WriteString(c, "ping"); err != nil {
+		t.Errorf("client Write: %v", err)
+	}
+	if _, err := io.WriteString(s, "pong"); err != nil {
+		t.Errorf("server Write: %v", err)
+	}
+	if _, err := io.ReadFull(s, b[0:4]); err != nil || string(b[0:4]) != "ping" {
+		t.Errorf("server read %q, %v; expected %q", b[0:4], err, "ping")
+	}
+	if _, err := io.ReadFull(c, b[0:4]); err != nil || string(b[0:4]) != "pong" {
+		t.Errorf("client read %q, %v; expected %q", b[0:4], err, "pong")
+	}
+
+	// read timeouts report os.EAGAIN like kernel connections
+	s.SetReadTimeout(1e7);
+	if n, err := s.Read(&b); n != 0 || !isEAGAIN(err) {
+		t.Errorf("Read with timeout returned %d, %v; expected 0, EAGAIN", n, err)
+	}
+
+	// with an explicit local address, Dial uses the kernel
+	c2, err := Dial("tcp", "127.0.0.1:0", l.Addr().String());
+	if err != nil {
+		t.Fatalf("Dial with local address: %v", err)
+	}
+	defer c2.Close();
+	if _, ok := c2.(*TCPConn); !ok {
+		t.Errorf("Dial with local address returned an in-process connection")
+	}
+	s2, err := l.Accept();
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	s2.Close();
+
+	// after SetLoopback(false), Dial uses the kernel again
+	l.SetLoopback(false);
+	c3, err := Dial("tcp", "", l.Addr().String());
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c3.Close();
+	if _, ok := c3.(*TCPConn); !ok {
+		t.Errorf("Dial returned an in-process connection after SetLoopback(false)")
+	}
+}
+
+func TestLoopbackLimitsAndFaults(t *testing.T) {
+	l, err := ListenTCP("tcp4", &TCPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenTCP: %v", err)
+	}
+	defer l.Close();
+	if err := l.SetLoopback(true); err != nil {
+		t.Fatalf("SetLoopback: %v", err)
+	}
+	addr := l.Addr().String();
+
+	errInjected := os.NewError("injected");
+	SetFaults([]Fault{Fault{Op: "dial", Addr: addr, Error: errInjected, Count: 1}});
+	defer SetFaults(nil);
+	if _, err := Dial("tcp", "", addr); err == nil {
+		t.Errorf("Dial succeeded despite fault")
+	} else if e, ok := err.(*OpError); !ok || e.Error != errInjected {
+		t.Errorf("Dial: %v, expected injected error", err)
+	}
+
+	SetSocketLimits(SocketLimits{MaxPerHost: 1});
+	defer SetSocketLimits(SocketLimits{});
+	c, err := Dial("tcp", "", addr);
+	if err != nil {
+		t.Fatalf("first Dial: %v", err)
+	}
+	if _, ok := c.(*TCPConn); ok {
+		t.Errorf("Dial returned a kernel connection")
+	}
+	if _, err := Dial("tcp", "", addr); err == nil {
+		t.Errorf("second Dial succeeded, expected %v", ErrSocketLimit)
+	} else if e, ok := err.(*OpError); !ok || e.Error != ErrSocketLimit {
+		t.Errorf("second Dial: %v, expected %v", err, ErrSocketLimit)
+	}
+	c.Close();
+
+	// closing the connection frees its slot
+	c, err = Dial("tcp", "", addr);
+	if err != nil {
+		t.Fatalf("Dial after Close: %v", err)
+	}
+	c.Close();
+}
@@ -0,0 +1,121 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// IPv4-mapped IPv6 addresses
+
+package net
+
+import "sync"
+
+// An IPv6 socket also carries IPv4 traffic, unless it is restricted
+// to IPv6 (IPV6_V6ONLY); the IPv4 end points then have IPv4-mapped
+// IPv6 addresses, ::ffff:a.b.c.d.  So the IP of an IPv4 peer is
+// reported as 4 bytes by an IPv4 socket but as 16 bytes by an IPv6
+// socket, and a program comparing addresses, such as an access
+// control list, must not depend on which kind of socket it got.
+
+// An AddrForm selects the form of the IPs of IPv4 end points
+// in the addresses reported by sockets.
+type AddrForm int
+
+const (
+	// RawForm reports IPs as the socket does: 4 bytes on IPv4
+	// sockets, IPv4-mapped IPv6 addresses on IPv6 sockets.
+	RawForm	AddrForm	= iota;
+
+	// IPv4Form reports the IPs of IPv4 end points as 4 bytes,
+	// on IPv6 sockets too.
+	IPv4Form;
+
+	// IPv6Form reports all IPs as 16 bytes, the IPs of IPv4
+	// end points as IPv4-mapped IPv6 addresses.
+	IPv6Form;
+)
+
+// An IPv6Policy controls how IPv6 sockets deal with IPv4.
+type IPv6Policy struct {
+	// If V6Only is set, IPv6 sockets carry IPv6 traffic only.
+	// A listener on all addresses of network "tcp" or "udp" then
+	// does not accept IPv4 connections, and sockets for IPv4
+	// addresses are IPv4 sockets.
+	V6Only	bool;
+
+	// Form is the form of the IPs in the LocalAddr and RemoteAddr
+	// of connections, the Addr of listeners, and the addresses
+	// returned by ReadFrom.
+	Form	AddrForm;
+}
+
+var ipv6Policy struct {
+	sync.Mutex;
+	IPv6Policy;
+}
+
+// SetIPv6Policy sets the policy of the TCP, UDP, and SCTP sockets
+// created by Dial and Listen from now on; existing sockets and the
+// connections accepted by existing listeners keep their policy.
+// The initial policy is the zero IPv6Policy.
+func SetIPv6Policy(p IPv6Policy) {
+	ipv6Policy.Lock();
+	ipv6Policy.IPv6Policy = p;
+	ipv6Policy.Unlock();
+}
+
+func currentIPv6Policy() IPv6Policy {
+	ipv6Policy.Lock();
+	defer ipv6Policy.Unlock();
+	return ipv6Policy.IPv6Policy;
+}
+
+// normalizeIP returns ip in the given form.
+func normalizeIP(ip IP, form AddrForm) IP {
+	switch form {
+	case IPv4Form:
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4
+		}
+	case IPv6Form:
+		if ip16 := ip.To16(); ip16 != nil {
+			return ip16
+		}
+	}
+	return ip;
+}
+
+// normalizeAddr returns a copy of addr with its IP in the given form.
+func normalizeAddr(addr Addr, form AddrForm) Addr {
+	if form == RawForm {
+		return addr
+	}
+	switch a := addr.(type) {
+	case *TCPAddr:
+		return &TCPAddr{normalizeIP(a.IP, form), a.Port}
+	case *UDPAddr:
+		return &UDPAddr{normalizeIP(a.IP, form), a.Port}
+	case *SCTPAddr:
+		return &SCTPAddr{normalizeIP(a.IP, form), a.Port}
+	}
+	return addr;
+}
+
+// isWildcard reports whether the address of a listener
+// is the address of all interfaces.
+func isWildcard(a sockaddr) bool {
+	if a == nil {
+		return true
+	}
+	var ip IP;
+	switch a := a.(type) {
+	case *TCPAddr:
+		ip = a.IP
+	case *UDPAddr:
+		ip = a.IP
+	case *SCTPAddr:
+		ip = a.IP
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return isZeros(ip);
+}
@@ -0,0 +1,82 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import "testing"
+
+type normalizeTest struct {
+	in	IP;
+	form	AddrForm;
+	out	IP;
+}
+
+var normalizeTests = []normalizeTest{
+	normalizeTest{IP{127, 0, 0, 1}, RawForm, IP{127, 0, 0, 1}},
+	normalizeTest{IPv4(127, 0, 0, 1), RawForm, IPv4(127, 0, 0, 1)},
+	normalizeTest{IPv4(127, 0, 0, 1), IPv4Form, IP{127, 0, 0, 1}},
+	normalizeTest{IP{127, 0, 0, 1}, IPv4Form, IP{127, 0, 0, 1}},
+	normalizeTest{IP{127, 0, 0, 1}, IPv6Form, IPv4(127, 0, 0, 1)},
+	normalizeTest{IPzero, IPv4Form, IPzero},
+	normalizeTest{IPzero, IPv6Form, IPzero},
+}
+
+func TestNormalizeIP(t *testing.T) {
+	for _, tt := range normalizeTests {
+		if out := normalizeIP(tt.in, tt.form); string(out) != string(tt.out) {
+			t.Errorf("normalizeIP(%v, %d) = %v; want %v", tt.in, tt.form, out, tt.out)
+		}
+	}
+}
+
+// acceptedIP returns the IP of the remote address of the first
+// connection accepted by l, which is dialed over IPv4.
+func acceptedIP(t *testing.T, l *TCPListener) IP {
+	port := l.Addr().(*TCPAddr).Port;
+	go func() {
+		c, err := Dial("tcp4", "", "127.0.0.1:"+itoa(port));
+		if err == nil {
+			c.Close()
+		}
+	}();
+	c, err := l.Accept();
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	defer c.Close();
+	return c.RemoteAddr().(*TCPAddr).IP;
+}
+
+func TestListenTCPPolicy(t *testing.T) {
+	for _, form := range []AddrForm{IPv4Form, IPv6Form} {
+		l, err := ListenTCPPolicy("tcp", &TCPAddr{nil, 0}, IPv6Policy{Form: form});
+		if err != nil {
+			t.Fatalf("ListenTCPPolicy: %v", err)
+		}
+		ip := acceptedIP(t, l);
+		want := normalizeIP(IPv4(127, 0, 0, 1), form);
+		if string(ip) != string(want) {
+			t.Errorf("form %d: RemoteAddr IP = %v (%d bytes); want %d bytes", form, ip, len(ip), len(want))
+		}
+		if lip := l.Addr().(*TCPAddr).IP; string(lip) != string(normalizeIP(lip, form)) {
+			t.Errorf("form %d: Addr IP = %v (%d bytes); not normalized", form, lip, len(lip))
+		}
+		l.Close();
+	}
+
+	if !kernelSupportsIPv6() {
+		return
+	}
+	// an IPv6-only listener on all addresses refuses IPv4 connections
+	l, err := ListenTCPPolicy("tcp", &TCPAddr{nil, 0}, IPv6Policy{V6Only: true});
+	if err != nil {
+		t.Fatalf("ListenTCPPolicy: %v", err)
+	}
+	defer l.Close();
+	port := l.Addr().(*TCPAddr).Port;
+	if c, err := Dial("tcp4", "", "127.0.0.1:"+itoa(port)); err == nil {
+		c.Close();
+		t.Errorf("IPv4 connection to IPv6-only listener succeeded");
+	}
+}
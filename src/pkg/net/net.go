@@ -11,7 +11,10 @@ package net
 //	support for raw IP sockets
 //	support for raw ethernet sockets
 
-import "os"
+import (
+	"os";
+	"syscall";
+)
 
 // Addr represents a network end point address.
 type Addr interface {
@@ -109,7 +112,9 @@ type Listener interface {
 // for the connection.
 //
 // Known networks are "tcp", "tcp4" (IPv4-only), "tcp6" (IPv6-only),
-// "udp", "udp4" (IPv4-only), and "udp6" (IPv6-only).
+// "udp", "udp4" (IPv4-only), "udp6" (IPv6-only), "sctp", "sctp4"
+// (IPv4-only), and "sctp6" (IPv6-only), as well as the networks
+// added with RegisterNetwork.
 //
 // For IP networks, addresses have the form host:port.  If host is
 // a literal IPv6 address, it must be enclosed in square brackets.
@@ -134,6 +139,14 @@ func Dial(net, laddr, raddr string) (c Conn, err os.Error) {
 				goto Error
 			}
 		}
+		if la == nil {
+			if c, err = dialLoopback(ra); c != nil {
+				return c, nil
+			}
+			if err != nil {
+				goto Error
+			}
+		}
 		return DialTCP(net, la, ra);
 	case "udp", "udp4", "upd6":
 		var la, ra *UDPAddr;
@@ -148,6 +161,19 @@ func Dial(net, laddr, raddr string) (c Conn, err os.Error) {
 			}
 		}
 		return DialUDP(net, la, ra);
+	case "sctp", "sctp4", "sctp6":
+		var la, ra *SCTPAddr;
+		if laddr != "" {
+			if la, err = ResolveSCTPAddr(laddr); err != nil {
+				goto Error
+			}
+		}
+		if raddr != "" {
+			if ra, err = ResolveSCTPAddr(raddr); err != nil {
+				goto Error
+			}
+		}
+		return DialSCTP(net, la, ra);
 	case "unix", "unixgram":
 		var la, ra *UnixAddr;
 		if raddr != "" {
@@ -162,6 +188,65 @@ func Dial(net, laddr, raddr string) (c Conn, err os.Error) {
 		}
 		return DialUnix(net, la, ra);
 	}
+	return dialRegistered(net, laddr, raddr);
+Error:
+	return nil, &OpError{"dial", net + " " + raddr, nil, err};
+}
+
+// DialDevice is like Dial but binds the connection to the network
+// interface named dev, such as "eth0", before connecting, so that
+// its packets are sent and received only through that interface
+// regardless of the routing table.  This is useful on multi-homed
+// hosts and with VPNs that route only part of the traffic.
+// If dev is empty, DialDevice is the same as Dial.
+//
+// DialDevice supports the networks "tcp", "tcp4", "tcp6",
+// "udp", "udp4", and "udp6".  Binding to an interface is only
+// supported on Linux, where it uses SO_BINDTODEVICE and usually
+// requires special privileges.  On other systems DialDevice with
+// a non-empty dev always fails with os.EINVAL; to select the
+// interface there, pass its address as laddr, which Dial binds
+// to before connecting.
+//
+// Examples:
+//	DialDevice("tcp", "eth0", "", "12.34.56.78:80")
+//	DialDevice("udp", "tun0", "10.8.0.2:0", "10.8.0.1:53")
+//
+func DialDevice(net, dev, laddr, raddr string) (c Conn, err os.Error) {
+	if dev == "" {
+		return Dial(net, laddr, raddr)
+	}
+	var fd *netFD;
+	switch net {
+	case "tcp", "tcp4", "tcp6":
+		var la, ra *TCPAddr;
+		if laddr != "" {
+			if la, err = ResolveTCPAddr(laddr); err != nil {
+				goto Error
+			}
+		}
+		if ra, err = ResolveTCPAddr(raddr); err != nil {
+			goto Error
+		}
+		if fd, err = internetSocket(net, la.toAddr(), ra.toAddr(), syscall.SOCK_STREAM, 0, "dial", dev, sockaddrToTCP); err != nil {
+			return nil, err
+		}
+		return newTCPConn(fd), nil;
+	case "udp", "udp4", "udp6":
+		var la, ra *UDPAddr;
+		if laddr != "" {
+			if la, err = ResolveUDPAddr(laddr); err != nil {
+				goto Error
+			}
+		}
+		if ra, err = ResolveUDPAddr(raddr); err != nil {
+			goto Error
+		}
+		if fd, err = internetSocket(net, la.toAddr(), ra.toAddr(), syscall.SOCK_DGRAM, 0, "dial", dev, sockaddrToUDP); err != nil {
+			return nil, err
+		}
+		return newUDPConn(fd), nil;
+	}
 	err = UnknownNetworkError(net);
 Error:
 	return nil, &OpError{"dial", net + " " + raddr, nil, err};
@@ -169,7 +254,8 @@ Error:
 
 // Listen announces on the local network address laddr.
 // The network string net must be a stream-oriented
-// network: "tcp", "tcp4", "tcp6", or "unix".
+// network: "tcp", "tcp4", "tcp6", "sctp", "sctp4", "sctp6", "unix",
+// or a network added with RegisterNetwork.
 func Listen(net, laddr string) (l Listener, err os.Error) {
 	switch net {
 	case "tcp", "tcp4", "tcp6":
@@ -184,6 +270,18 @@ func Listen(net, laddr string) (l Listener, err os.Error) {
 			return nil, err
 		}
 		return l, nil;
+	case "sctp", "sctp4", "sctp6":
+		var la *SCTPAddr;
+		if laddr != "" {
+			if la, err = ResolveSCTPAddr(laddr); err != nil {
+				return nil, err
+			}
+		}
+		l, err := ListenSCTP(net, la);
+		if err != nil {
+			return nil, err
+		}
+		return l, nil;
 	case "unix":
 		var la *UnixAddr;
 		if laddr != "" {
@@ -197,7 +295,33 @@ func Listen(net, laddr string) (l Listener, err os.Error) {
 		}
 		return l, nil;
 	}
-	return nil, UnknownNetworkError(net);
+	return listenRegistered(net, laddr);
+}
+
+// ListenAnyPort announces on an available port of the local address
+// host, which may be empty to listen on all addresses, and returns
+// the listener and the port assigned by the system.  The network string
+// net must be "tcp", "tcp4", or "tcp6".  ListenAnyPort is useful for
+// test servers, which must not depend on a fixed port being free.
+//
+// Example:
+//	l, port, err := ListenAnyPort("tcp", "127.0.0.1")
+//
+func ListenAnyPort(net, host string) (l Listener, port int, err os.Error) {
+	switch net {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, 0, UnknownNetworkError(net)
+	}
+	la, err := ResolveTCPAddr(joinHostPort(host, "0"));
+	if err != nil {
+		return nil, 0, err
+	}
+	tl, err := ListenTCP(net, la);
+	if err != nil {
+		return nil, 0, err
+	}
+	return tl, tl.Addr().(*TCPAddr).Port, nil;
 }
 
 // ListenPacket announces on the local network address laddr.
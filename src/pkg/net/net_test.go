@@ -6,7 +6,9 @@ package net
 
 import (
 	"flag";
+	"io";
 	"regexp";
+	"strings";
 	"testing";
 )
 
@@ -75,3 +77,117 @@ func TestDialError(t *testing.T) {
 		}
 	}
 }
+
+func TestTCPWriteBuffers(t *testing.T) {
+	l, err := Listen("tcp", "127.0.0.1:0");
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close();
+
+	go func() {
+		c, err := Dial("tcp", "", l.Addr().String());
+		if err != nil {
+			t.Errorf("Dial: %v", err);
+			return;
+		}
+		v := io.Buffers{strings.Bytes("one "), strings.Bytes("two "), strings.Bytes("three")};
+		if _, ok := c.(io.BuffersWriter); !ok {
+			t.Errorf("%T does not implement io.BuffersWriter", c)
+		}
+		if n, err := io.Copy(c, &v); n != 13 || err != nil {
+			t.Errorf("Copy = %d, %v; expected 13, nil", n, err)
+		}
+		c.Close();
+	}();
+
+	c, err := l.Accept();
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	defer c.Close();
+	b, err := io.ReadAll(c);
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if s := string(b); s != "one two three" {
+		t.Errorf("read %q, expected %q", s, "one two three")
+	}
+}
+
+func TestListenAnyPort(t *testing.T) {
+	// an unbound listener is assigned its port by listen
+	tl, err := ListenTCP("tcp4", nil);
+	if err != nil {
+		t.Fatalf("ListenTCP: %v", err)
+	}
+	if port := tl.Addr().(*TCPAddr).Port; port == 0 {
+		t.Errorf("ListenTCP(nil) reports port 0")
+	}
+	tl.Close();
+
+	l, port, err := ListenAnyPort("tcp", "127.0.0.1");
+	if err != nil {
+		t.Fatalf("ListenAnyPort: %v", err)
+	}
+	defer l.Close();
+	if port == 0 || l.Addr().(*TCPAddr).Port != port {
+		t.Fatalf("ListenAnyPort port = %d, Addr = %v", port, l.Addr())
+	}
+	c, err := Dial("tcp", "", "127.0.0.1:"+itoa(port));
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	c.Close();
+
+	if _, _, err := ListenAnyPort("udp", ""); err == nil {
+		t.Errorf("ListenAnyPort(udp) succeeded")
+	}
+}
+
+func TestDialDevice(t *testing.T) {
+	l, err := Listen("tcp", "127.0.0.1:0");
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close();
+
+	// without a device, DialDevice is Dial
+	c, err := DialDevice("tcp", "", "", l.Addr().String());
+	if err != nil {
+		t.Fatalf("DialDevice: %v", err)
+	}
+	c.Close();
+
+	// binding to a device that does not exist fails
+	c, err = DialDevice("tcp", "no-such-device0", "", l.Addr().String());
+	if err == nil {
+		c.Close();
+		t.Fatalf("DialDevice succeeded with unknown device");
+	}
+	if _, ok := err.(*OpError); !ok {
+		t.Errorf("DialDevice: %v (%T), expected *OpError", err, err)
+	}
+
+	c, err = DialDevice("unix", "lo", "", "/tmp/socket");
+	if err == nil {
+		c.Close();
+		t.Errorf("DialDevice succeeded with network unix");
+	}
+}
+
+func TestStartLookupHost(t *testing.T) {
+	// invalid names are rejected without sending a query
+	l := StartLookupHost("mh/astro/r70");
+	if _, _, err := l.Wait(); err == nil {
+		t.Errorf("lookup of invalid name succeeded")
+	}
+
+	// Wait returns immediately once a lookup is canceled
+	l = StartLookupHost("no-such-name.no-such-top-level-domain.");
+	l.Cancel();
+	if _, _, err := l.Wait(); err == nil {
+		t.Errorf("canceled lookup succeeded")
+	}
+	l.Cancel();	// canceling again is harmless
+}
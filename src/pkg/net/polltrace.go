@@ -0,0 +1,155 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Poll server tracing.
+
+package net
+
+import (
+	"fmt";
+	"io";
+	"once";
+	"os";
+	"sync";
+)
+
+// When tracing is on, the pollServer records its scheduling decisions
+// in a ring buffer holding the most recent events.  The trace helps to
+// diagnose stalls in the readiness machinery, in particular with poll
+// emulations such as the select-based one on Cygwin, where they are
+// hard to reproduce: a program can dump the trace when it detects a
+// stall or on request of an operator.
+
+// A PollEventKind identifies a scheduling decision of the poll server.
+type PollEventKind int
+
+const (
+	PollRegister	PollEventKind	= iota;	// fd registered for a read or write
+	PollWake;			// fd ready; its waiting reader or writer woken
+	PollDeadline;			// deadline of fd expired; its waiter woken
+	PollSpurious;			// poll reported fd, but nobody was waiting for it
+	PollTimeout;			// poll returned because of a deadline or idle sweep
+	PollRequest;			// poll returned because of a request on the pipe
+)
+
+var pollEventKinds = []string{
+	"register",
+	"wake",
+	"deadline",
+	"spurious",
+	"timeout",
+	"request",
+}
+
+func (k PollEventKind) String() string {
+	if 0 <= k && int(k) < len(pollEventKinds) {
+		return pollEventKinds[k]
+	}
+	return "event" + itoa(int(k));
+}
+
+// A PollEvent is an event recorded in the poll server trace.
+type PollEvent struct {
+	Time	int64;	// nsec since 1970
+	Kind	PollEventKind;
+	FD	int;	// file descriptor; -1 if none
+	Mode	int;	// 'r' or 'w'; 0 if none
+}
+
+func (e *PollEvent) String() string {
+	s := fmt.Sprintf("%d.%09d %s", e.Time/1e9, e.Time%1e9, e.Kind);
+	if e.FD >= 0 {
+		s += " fd=" + itoa(e.FD)
+	}
+	if e.Mode != 0 {
+		s += " mode=" + string(e.Mode)
+	}
+	return s;
+}
+
+// A pollTracer is the trace of a pollServer.
+type pollTracer struct {
+	sync.Mutex;
+	events	[]PollEvent;	// ring buffer; nil if tracing is off
+	next	int;		// index of the next event
+	full	bool;		// the buffer has wrapped around
+}
+
+// set turns tracing on, keeping the last n events,
+// or off if n <= 0, and discards the events recorded.
+func (t *pollTracer) set(n int) {
+	t.Lock();
+	t.events = nil;
+	if n > 0 {
+		t.events = make([]PollEvent, n)
+	}
+	t.next = 0;
+	t.full = false;
+	t.Unlock();
+}
+
+// list returns the events recorded, oldest first.
+func (t *pollTracer) list() []PollEvent {
+	t.Lock();
+	defer t.Unlock();
+	ev := t.events;
+	if !t.full {
+		return copyEvents(ev[0:t.next])
+	}
+	list := make([]PollEvent, len(ev));
+	n := copy(list, ev[t.next:len(ev)]);
+	copy(list[n:len(list)], ev[0:t.next]);
+	return list;
+}
+
+func copyEvents(ev []PollEvent) []PollEvent {
+	list := make([]PollEvent, len(ev));
+	copy(list, ev);
+	return list;
+}
+
+// SetPollTrace turns the tracing of the poll server on, keeping the
+// last n events, or off if n <= 0.  Events recorded earlier are
+// discarded.
+func SetPollTrace(n int) {
+	once.Do(startServer);
+	if pollserver != nil {
+		pollserver.tracer.set(n)
+	}
+}
+
+// PollTrace returns the events of the poll server trace, oldest first.
+func PollTrace() []PollEvent {
+	once.Do(startServer);
+	if pollserver == nil {
+		return nil
+	}
+	return pollserver.tracer.list();
+}
+
+// DumpPollTrace writes the events of the poll server trace to w,
+// oldest first, one per line.
+func DumpPollTrace(w io.Writer) os.Error {
+	for _, e := range PollTrace() {
+		if _, err := io.WriteString(w, e.String()+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil;
+}
+
+// trace records an event if tracing is on.
+func (s *pollServer) trace(kind PollEventKind, fd, mode int) {
+	t := &s.tracer;
+	t.Lock();
+	if ev := t.events; ev != nil {
+		ev[t.next] = PollEvent{s.Now(), kind, fd, mode};
+		t.next++;
+		if t.next == len(ev) {
+			t.next = 0;
+			t.full = true;
+		}
+	}
+	t.Unlock();
+}
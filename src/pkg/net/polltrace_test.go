@@ -0,0 +1,74 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"bytes";
+	"strings";
+	"testing";
+)
+
+// findEvent returns the index of the first event of the given kind
+// for fd in list, or -1.
+func findEvent(list []PollEvent, kind PollEventKind, fd int) int {
+	for i, e := range list {
+		if e.Kind == kind && e.FD == fd {
+			return i
+		}
+	}
+	return -1;
+}
+
+func TestPollTrace(t *testing.T) {
+	c, err := ListenUDP("udp", &UDPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	defer c.Close();
+
+	SetPollTrace(100);
+	defer SetPollTrace(0);
+	c.SetReadTimeout(1e7);	// 10ms
+	var b [10]byte;
+	if _, err := c.Read(&b); err == nil {
+		t.Fatalf("Read did not time out")
+	}
+
+	list := PollTrace();
+	fd := c.fd.fd;
+	reg := findEvent(list, PollRegister, fd);
+	dl := findEvent(list, PollDeadline, fd);
+	if reg < 0 || dl < reg {
+		t.Errorf("trace lacks register and deadline events for fd %d: %v", fd, list)
+	}
+	if reg >= 0 && list[reg].Mode != 'r' {
+		t.Errorf("register event has mode %c, expected r", list[reg].Mode)
+	}
+
+	var buf bytes.Buffer;
+	if err := DumpPollTrace(&buf); err != nil {
+		t.Errorf("DumpPollTrace: %v", err)
+	}
+	if s := buf.String(); strings.Index(s, "deadline fd="+itoa(fd)+" mode=r\n") < 0 {
+		t.Errorf("DumpPollTrace output lacks deadline event:\n%s", s)
+	}
+}
+
+func TestPollTraceRing(t *testing.T) {
+	// a private pollServer, whose trace the shared one does not touch
+	s := new(pollServer);
+	s.tracer.set(3);
+	for i := 0; i < 5; i++ {
+		s.trace(PollWake, i, 'w')
+	}
+	list := s.tracer.list();
+	if len(list) != 3 {
+		t.Fatalf("got %d events, expected 3", len(list))
+	}
+	// the ring keeps the most recent events
+	if list[2].Kind != PollWake || list[2].FD != 4 {
+		t.Errorf("last event = %v, expected wake of fd 4", &list[2])
+	}
+}
@@ -56,6 +56,8 @@ func LookupPort(network, service string) (port int, err os.Error) {
 		network = "tcp"
 	case "udp4", "udp6":
 		network = "udp"
+	case "sctp4", "sctp6":
+		network = "sctp"
 	}
 
 	if m, ok := services[network]; ok {
@@ -0,0 +1,118 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Registry of custom networks
+
+package net
+
+import (
+	"os";
+	"sync";
+)
+
+// A DialFunc connects to the remote address raddr on the network
+// net, using the local address laddr if it is not empty, as Dial does.
+type DialFunc func(net, laddr, raddr string) (Conn, os.Error)
+
+// A ListenFunc announces on the local address laddr on the
+// network net, as Listen does.
+type ListenFunc func(net, laddr string) (Listener, os.Error)
+
+// ErrNetworkRegistered is returned by RegisterNetwork for the
+// name of a network that is built in or already registered.
+var ErrNetworkRegistered os.Error = os.ErrorString("network already registered")
+
+var errNotSupported = os.ErrorString("operation not supported by network")
+
+type network struct {
+	dial	DialFunc;
+	listen	ListenFunc;
+}
+
+var (
+	networksMu	sync.Mutex;
+	networks	= make(map[string]network);
+)
+
+// isBuiltinNetwork reports whether net is one of the networks
+// implemented by this package.
+func isBuiltinNetwork(net string) bool {
+	switch net {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "sctp", "sctp4", "sctp6", "unix", "unixgram":
+		return true
+	}
+	return false;
+}
+
+// RegisterNetwork makes the network named net available to Dial and
+// Listen, which call dial and listen for it, so that packages can add
+// transports such as serial lines or Windows named pipes that programs
+// use like the built-in networks:
+//
+//	net.RegisterNetwork("npipe", dialPipe, listenPipe)
+//	c, err := net.Dial("npipe", "", `\\.\pipe\godoc`)
+//
+// Either function may be nil if the network does not support the
+// operation; Dial or Listen then fail with an *OpError.  Names of
+// built-in networks, such as "tcp", and names registered before
+// cannot be registered; RegisterNetwork returns ErrNetworkRegistered.
+// A package usually registers its networks in its init function.
+func RegisterNetwork(net string, dial DialFunc, listen ListenFunc) os.Error {
+	if isBuiltinNetwork(net) {
+		return ErrNetworkRegistered
+	}
+	networksMu.Lock();
+	defer networksMu.Unlock();
+	if _, ok := networks[net]; ok {
+		return ErrNetworkRegistered
+	}
+	networks[net] = network{dial, listen};
+	return nil;
+}
+
+// RegisteredNetworks returns the names of the networks
+// registered with RegisterNetwork, in no particular order.
+func RegisteredNetworks() []string {
+	networksMu.Lock();
+	defer networksMu.Unlock();
+	names := make([]string, len(networks));
+	i := 0;
+	for name := range networks {
+		names[i] = name;
+		i++;
+	}
+	return names;
+}
+
+// lookupNetwork returns the registered network named net.
+func lookupNetwork(net string) (n network, ok bool) {
+	networksMu.Lock();
+	n, ok = networks[net];
+	networksMu.Unlock();
+	return;
+}
+
+// dialRegistered dials raddr on the registered network net.
+func dialRegistered(net, laddr, raddr string) (c Conn, err os.Error) {
+	n, ok := lookupNetwork(net);
+	if !ok {
+		return nil, &OpError{"dial", net + " " + raddr, nil, UnknownNetworkError(net)}
+	}
+	if n.dial == nil {
+		return nil, &OpError{"dial", net + " " + raddr, nil, errNotSupported}
+	}
+	return n.dial(net, laddr, raddr);
+}
+
+// listenRegistered listens on laddr on the registered network net.
+func listenRegistered(net, laddr string) (l Listener, err os.Error) {
+	n, ok := lookupNetwork(net);
+	if !ok {
+		return nil, UnknownNetworkError(net)
+	}
+	if n.listen == nil {
+		return nil, &OpError{"listen", net + " " + laddr, nil, errNotSupported}
+	}
+	return n.listen(net, laddr);
+}
@@ -0,0 +1,104 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"io";
+	"os";
+	"testing";
+)
+
+// A testListener accepts the server ends of the connections
+// dialed on the "testpipe" network.
+type testListener struct {
+	conns	chan Conn;
+}
+
+func (l *testListener) Accept() (c Conn, err os.Error) {
+	c = <-l.conns;
+	if closed(l.conns) {
+		return nil, os.EINVAL
+	}
+	return c, nil;
+}
+
+func (l *testListener) Close() os.Error {
+	close(l.conns);
+	return nil;
+}
+
+func (l *testListener) Addr() Addr	{ return nil }
+
+var testPipeListener *testListener
+
+func listenTestPipe(net, laddr string) (Listener, os.Error) {
+	testPipeListener = &testListener{make(chan Conn, 1)};
+	return testPipeListener, nil;
+}
+
+func dialTestPipe(net, laddr, raddr string) (Conn, os.Error) {
+	if testPipeListener == nil {
+		return nil, &OpError{"dial", net + " " + raddr, nil, os.ECONNREFUSED}
+	}
+	c, s := newPipeConns(nil, nil, nil, nil);
+	testPipeListener.conns <- s;
+	return c, nil;
+}
+
+func TestRegisterNetwork(t *testing.T) {
+	if err := RegisterNetwork("testpipe", dialTestPipe, listenTestPipe); err != nil {
+		t.Fatalf("RegisterNetwork: %v", err)
+	}
+	if err := RegisterNetwork("testpipe", dialTestPipe, listenTestPipe); err != ErrNetworkRegistered {
+		t.Errorf("second RegisterNetwork: %v; expected ErrNetworkRegistered", err)
+	}
+	if err := RegisterNetwork("tcp", dialTestPipe, listenTestPipe); err != ErrNetworkRegistered {
+		t.Errorf("RegisterNetwork tcp: %v; expected ErrNetworkRegistered", err)
+	}
+
+	l, err := Listen("testpipe", "");
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close();
+	c, err := Dial("testpipe", "", "x");
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close();
+	s, err := l.Accept();
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	defer s.Close();
+
+	go io.WriteString(c, "hello");
+	var b [5]byte;
+	if _, err := io.ReadFull(s, b[0:5]); err != nil || string(b[0:5]) != "hello" {
+		t.Errorf("read %q, %v; expected %q", b[0:5], err, "hello")
+	}
+
+	found := false;
+	for _, name := range RegisteredNetworks() {
+		if name == "testpipe" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("RegisteredNetworks does not list testpipe")
+	}
+}
+
+func TestRegisterNetworkUnsupported(t *testing.T) {
+	if err := RegisterNetwork("testdialonly", dialTestPipe, nil); err != nil {
+		t.Fatalf("RegisterNetwork: %v", err)
+	}
+	if _, err := Listen("testdialonly", ""); err == nil {
+		t.Errorf("Listen on a dial-only network succeeded")
+	}
+	if _, err := Dial("testunknown", "", "x"); err == nil {
+		t.Errorf("Dial on an unknown network succeeded")
+	}
+}
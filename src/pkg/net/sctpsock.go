@@ -0,0 +1,299 @@
+// Copyright 2009 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// SCTP sockets
+
+package net
+
+import (
+	"os";
+	"syscall";
+)
+
+// ipprotoSCTP is the IP protocol number of SCTP,
+// which not every system defines in package syscall.
+const ipprotoSCTP = 132
+
+func sockaddrToSCTP(sa syscall.Sockaddr) Addr {
+	switch sa := sa.(type) {
+	case *syscall.SockaddrInet4:
+		return &SCTPAddr{&sa.Addr, sa.Port}
+	case *syscall.SockaddrInet6:
+		return &SCTPAddr{&sa.Addr, sa.Port}
+	}
+	return nil;
+}
+
+// SCTPAddr represents the address of an SCTP end point.
+type SCTPAddr struct {
+	IP	IP;
+	Port	int;
+}
+
+// Network returns the address's network name, "sctp".
+func (a *SCTPAddr) Network() string	{ return "sctp" }
+
+func (a *SCTPAddr) String() string	{ return joinHostPort(a.IP.String(), itoa(a.Port)) }
+
+func (a *SCTPAddr) family() int {
+	if a == nil || len(a.IP) <= 4 {
+		return syscall.AF_INET
+	}
+	if ip := a.IP.To4(); ip != nil {
+		return syscall.AF_INET
+	}
+	return syscall.AF_INET6;
+}
+
+func (a *SCTPAddr) sockaddr(family int) (syscall.Sockaddr, os.Error) {
+	return ipToSockaddr(family, a.IP, a.Port)
+}
+
+func (a *SCTPAddr) toAddr() sockaddr {
+	if a == nil {	// nil *SCTPAddr
+		return nil	// nil interface
+	}
+	return a;
+}
+
+// ResolveSCTPAddr parses addr as an SCTP address of the form
+// host:port and resolves domain names or port names to
+// numeric addresses.  A literal IPv6 host address must be
+// enclosed in square brackets, as in "[::]:80".
+func ResolveSCTPAddr(addr string) (*SCTPAddr, os.Error) {
+	ip, port, err := hostPortToIP("sctp", addr);
+	if err != nil {
+		return nil, err
+	}
+	return &SCTPAddr{ip, port}, nil;
+}
+
+// SCTPConn is an implementation of the Conn interface for SCTP
+// associations using the one-to-one socket style.  Each Write sends
+// a message on stream 0; WriteStream selects the stream.  Read does
+// not preserve message boundaries if p is too small to hold the
+// message, in which case the next Read returns the rest of it.
+type SCTPConn struct {
+	fd	*netFD;
+	values	Values;
+}
+
+// newSCTPConn returns the connection of fd, which
+// it closes if the socket cannot be set up.
+func newSCTPConn(fd *netFD) (*SCTPConn, os.Error) {
+	// without the events, messages carry no stream number
+	if err := setSCTPEvents(fd); err != nil {
+		fd.Close();
+		return nil, err;
+	}
+	return &SCTPConn{fd: fd}, nil;
+}
+
+func (c *SCTPConn) ok() bool	{ return c != nil && c.fd != nil }
+
+// Implementation of the Conn interface - see Conn for documentation.
+
+// Read reads data from the SCTP association.
+//
+// Read can be made to time out and return err == os.EAGAIN
+// after a fixed time limit; see SetTimeout and SetReadTimeout.
+func (c *SCTPConn) Read(b []byte) (n int, err os.Error) {
+	n, _, _, err = c.ReadStream(b);
+	return;
+}
+
+// Write sends b as a single message on stream 0.
+//
+// Write can be made to time out and return err == os.EAGAIN
+// after a fixed time limit; see SetTimeout and SetWriteTimeout.
+func (c *SCTPConn) Write(b []byte) (n int, err os.Error) {
+	return c.WriteStream(b, 0, 0)
+}
+
+// ReadStream is like Read but also returns the number of the stream
+// the message arrived on and its payload protocol identifier.
+func (c *SCTPConn) ReadStream(b []byte) (n, stream int, ppid uint32, err os.Error) {
+	if !c.ok() {
+		return 0, 0, 0, os.EINVAL
+	}
+	n, stream, ppid, err = c.fd.readSCTP(b);
+	if err != nil && err != os.EOF {
+		err = &OpError{"read", "sctp", c.fd.raddr, err}
+	}
+	return;
+}
+
+// WriteStream sends b as a single message on the given stream, with
+// the payload protocol identifier ppid.  The stream must be less than
+// the number of outgoing streams negotiated for the association.
+func (c *SCTPConn) WriteStream(b []byte, stream int, ppid uint32) (n int, err os.Error) {
+	if !c.ok() {
+		return 0, os.EINVAL
+	}
+	if stream < 0 || stream > 0xFFFF {
+		return 0, &OpError{"write", "sctp", c.fd.raddr, os.EINVAL}
+	}
+	n, err = c.fd.writeSCTP(b, stream, ppid);
+	if err != nil {
+		err = &OpError{"write", "sctp", c.fd.raddr, err}
+	}
+	return;
+}
+
+// Close closes the SCTP association.
+func (c *SCTPConn) Close() os.Error {
+	if !c.ok() {
+		return os.EINVAL
+	}
+	err := c.fd.Close();
+	c.fd = nil;
+	return err;
+}
+
+// LocalAddr returns the local network address, an *SCTPAddr.
+func (c *SCTPConn) LocalAddr() Addr {
+	if !c.ok() {
+		return nil
+	}
+	return c.fd.laddr;
+}
+
+// RemoteAddr returns the remote network address, an *SCTPAddr.
+func (c *SCTPConn) RemoteAddr() Addr {
+	if !c.ok() {
+		return nil
+	}
+	return c.fd.raddr;
+}
+
+// Values returns the values associated with the connection.
+// They remain available after the connection is closed.
+func (c *SCTPConn) Values() *Values	{ return &c.values }
+
+// SetTimeout sets the read and write deadlines associated
+// with the connection.
+func (c *SCTPConn) SetTimeout(nsec int64) os.Error {
+	if !c.ok() {
+		return os.EINVAL
+	}
+	return setTimeout(c.fd, nsec);
+}
+
+// SetReadTimeout sets the time (in nanoseconds) that
+// Read will wait for data before returning os.EAGAIN.
+// Setting nsec == 0 (the default) disables the deadline.
+func (c *SCTPConn) SetReadTimeout(nsec int64) os.Error {
+	if !c.ok() {
+		return os.EINVAL
+	}
+	return setReadTimeout(c.fd, nsec);
+}
+
+// SetWriteTimeout sets the time (in nanoseconds) that
+// Write will wait to send its data before returning os.EAGAIN.
+// Setting nsec == 0 (the default) disables the deadline.
+func (c *SCTPConn) SetWriteTimeout(nsec int64) os.Error {
+	if !c.ok() {
+		return os.EINVAL
+	}
+	return setWriteTimeout(c.fd, nsec);
+}
+
+// SetReadBuffer sets the size of the operating system's
+// receive buffer associated with the connection.
+func (c *SCTPConn) SetReadBuffer(bytes int) os.Error {
+	if !c.ok() {
+		return os.EINVAL
+	}
+	return setReadBuffer(c.fd, bytes);
+}
+
+// SetWriteBuffer sets the size of the operating system's
+// transmit buffer associated with the connection.
+func (c *SCTPConn) SetWriteBuffer(bytes int) os.Error {
+	if !c.ok() {
+		return os.EINVAL
+	}
+	return setWriteBuffer(c.fd, bytes);
+}
+
+// DialSCTP is like Dial but can only connect to SCTP networks
+// and returns an SCTPConn structure.  The association uses the
+// one-to-one socket style, which is supported on Linux only.
+func DialSCTP(net string, laddr, raddr *SCTPAddr) (c *SCTPConn, err os.Error) {
+	if raddr == nil {
+		return nil, &OpError{"dial", "sctp", nil, errMissingAddress}
+	}
+	fd, e := internetSocket(net, laddr.toAddr(), raddr.toAddr(), syscall.SOCK_STREAM, ipprotoSCTP, "dial", "", sockaddrToSCTP);
+	if e != nil {
+		return nil, e
+	}
+	if c, err = newSCTPConn(fd); err != nil {
+		return nil, &OpError{"dial", "sctp", raddr, err}
+	}
+	return c, nil;
+}
+
+// SCTPListener is an SCTP network listener.
+// Clients should typically use variables of type Listener
+// instead of assuming SCTP.
+type SCTPListener struct {
+	fd *netFD;
+}
+
+// ListenSCTP announces on the SCTP address laddr and returns an SCTP
+// listener.  Net must be "sctp", "sctp4", or "sctp6".  If laddr is
+// nil or has a port of 0, it means to listen on some available port.
+// The caller can use l.Addr() to retrieve the chosen address.
+func ListenSCTP(net string, laddr *SCTPAddr) (l *SCTPListener, err os.Error) {
+	fd, err := internetSocket(net, laddr.toAddr(), nil, syscall.SOCK_STREAM, ipprotoSCTP, "listen", "", sockaddrToSCTP);
+	if err != nil {
+		return nil, err
+	}
+	errno := syscall.Listen(fd.fd, listenBacklog());
+	if errno != 0 {
+		fd.Close();
+		return nil, &OpError{"listen", "sctp", laddr, os.Errno(errno)};
+	}
+	updateLocalAddr(fd, sockaddrToSCTP);
+	return &SCTPListener{fd}, nil;
+}
+
+// AcceptSCTP accepts the next incoming association and returns
+// the new connection.
+func (l *SCTPListener) AcceptSCTP() (c *SCTPConn, err os.Error) {
+	if l == nil || l.fd == nil || l.fd.fd < 0 {
+		return nil, os.EINVAL
+	}
+	fd, err := l.fd.accept(sockaddrToSCTP);
+	if err != nil {
+		return nil, err
+	}
+	if c, err = newSCTPConn(fd); err != nil {
+		return nil, &OpError{"accept", "sctp", l.fd.laddr, err}
+	}
+	return c, nil;
+}
+
+// Accept implements the Accept method in the Listener interface;
+// it waits for the next association and returns a generic Conn.
+func (l *SCTPListener) Accept() (c Conn, err os.Error) {
+	c1, err := l.AcceptSCTP();
+	if err != nil {
+		return nil, err
+	}
+	return c1, nil;
+}
+
+// Close stops listening on the SCTP address.
+// Already accepted associations are not closed.
+func (l *SCTPListener) Close() os.Error {
+	if l == nil || l.fd == nil {
+		return os.EINVAL
+	}
+	return l.fd.Close();
+}
+
+// Addr returns the listener's network address, an *SCTPAddr.
+func (l *SCTPListener) Addr() Addr	{ return l.fd.laddr }
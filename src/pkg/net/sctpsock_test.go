@@ -0,0 +1,51 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"strings";
+	"syscall";
+	"testing";
+)
+
+func TestSCTPStreams(t *testing.T) {
+	if syscall.OS != "linux" {
+		return
+	}
+	l, err := ListenSCTP("sctp4", &SCTPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		// the kernel may have no SCTP support
+		t.Logf("skipping test: ListenSCTP: %v", err);
+		return;
+	}
+	defer l.Close();
+
+	done := make(chan bool);
+	go func() {
+		c, err := l.AcceptSCTP();
+		if err != nil {
+			t.Errorf("AcceptSCTP: %v", err);
+			done <- true;
+			return;
+		}
+		var buf [64]byte;
+		n, stream, ppid, err := c.ReadStream(&buf);
+		if err != nil || string(buf[0:n]) != "hello" || stream != 1 || ppid != 42 {
+			t.Errorf("ReadStream = %q, stream %d, ppid %d, %v; want \"hello\", 1, 42, nil", buf[0:n], stream, ppid, err)
+		}
+		c.Close();
+		done <- true;
+	}();
+
+	c, err := DialSCTP("sctp4", nil, l.Addr().(*SCTPAddr));
+	if err != nil {
+		t.Fatalf("DialSCTP: %v", err)
+	}
+	defer c.Close();
+	if _, err := c.WriteStream(strings.Bytes("hello"), 1, 42); err != nil {
+		t.Fatalf("WriteStream: %v", err)
+	}
+	<-done;
+}
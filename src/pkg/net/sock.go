@@ -20,13 +20,26 @@ func boolint(b bool) int {
 	return 0;
 }
 
-// Generic socket creation.
-func socket(net string, f, p, t int, la, ra syscall.Sockaddr, toAddr func(syscall.Sockaddr) Addr) (fd *netFD, err os.Error) {
+// Generic socket creation.  If v6only is set, the IPv6 socket
+// is restricted to IPv6 traffic; see IPv6Policy.
+func socket(net string, f, p, t int, dev string, v6only bool, la, ra syscall.Sockaddr, toAddr func(syscall.Sockaddr) Addr) (fd *netFD, err os.Error) {
+	if ra != nil {
+		if f := fault("dial", toAddr(ra)); f != nil && f.Error != nil {
+			return nil, f.Error
+		}
+	}
+
+	host := sockaddrHost(ra);
+	if err = limits.acquire(host); err != nil {
+		return nil, err
+	}
+
 	// See ../syscall/exec.go for description of ForkLock.
 	syscall.ForkLock.RLock();
 	s, e := syscall.Socket(f, p, t);
 	if e != 0 {
 		syscall.ForkLock.RUnlock();
+		limits.release(host);
 		return nil, os.Errno(e);
 	}
 	syscall.CloseOnExec(s);
@@ -35,10 +48,27 @@ func socket(net string, f, p, t int, la, ra syscall.Sockaddr, toAddr func(syscal
 	// Allow reuse of recently-used addresses.
 	syscall.SetsockoptInt(s, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1);
 
+	if v6only && f == syscall.AF_INET6 {
+		if err = setV6Only(s); err != nil {
+			syscall.Close(s);
+			limits.release(host);
+			return nil, err;
+		}
+	}
+
+	if dev != "" {
+		if err = bindToDevice(s, dev); err != nil {
+			syscall.Close(s);
+			limits.release(host);
+			return nil, err;
+		}
+	}
+
 	if la != nil {
 		e = syscall.Bind(s, la);
 		if e != 0 {
 			syscall.Close(s);
+			limits.release(host);
 			return nil, os.Errno(e);
 		}
 	}
@@ -47,6 +77,7 @@ func socket(net string, f, p, t int, la, ra syscall.Sockaddr, toAddr func(syscal
 		e = syscall.Connect(s, ra);
 		if e != 0 {
 			syscall.Close(s);
+			limits.release(host);
 			return nil, os.Errno(e);
 		}
 	}
@@ -59,16 +90,33 @@ func socket(net string, f, p, t int, la, ra syscall.Sockaddr, toAddr func(syscal
 	fd, err = newFD(s, f, p, net, laddr, raddr);
 	if err != nil {
 		syscall.Close(s);
+		limits.release(host);
 		return nil, err;
 	}
+	fd.host = host;
 
 	return fd, nil;
 }
 
+// updateLocalAddr rereads the local address of fd.  The system assigns
+// the port of a socket bound to port 0, or not bound at all, only when
+// it is first needed; for a stream socket, that is when it starts
+// listening.
+func updateLocalAddr(fd *netFD, toAddr func(syscall.Sockaddr) Addr) {
+	if sa, e := syscall.Getsockname(fd.fd); e == 0 {
+		fd.laddr = normalizeAddr(toAddr(sa), fd.form)
+	}
+}
+
 func setsockoptInt(fd, level, opt int, value int) os.Error {
 	return os.NewSyscallError("setsockopt", syscall.SetsockoptInt(fd, level, opt, value))
 }
 
+func getsockoptInt(fd, level, opt int) (int, os.Error) {
+	v, e := syscall.GetsockoptInt(fd, level, opt);
+	return v, os.NewSyscallError("getsockopt", e);
+}
+
 func setsockoptNsec(fd, level, opt int, nsec int64) os.Error {
 	var tv = syscall.NsecToTimeval(nsec);
 	return os.NewSyscallError("setsockopt", syscall.SetsockoptTimeval(fd, level, opt, &tv));
@@ -103,11 +151,6 @@ func setReuseAddr(fd *netFD, reuse bool) os.Error {
 	return setsockoptInt(fd.fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, boolint(reuse))
 }
 
-func bindToDevice(fd *netFD, dev string) os.Error {
-	// TODO(rsc): call setsockopt with null-terminated string pointer
-	return os.EINVAL
-}
-
 func setDontRoute(fd *netFD, dontroute bool) os.Error {
 	return setsockoptInt(fd.fd, syscall.SOL_SOCKET, syscall.SO_DONTROUTE, boolint(dontroute))
 }
@@ -0,0 +1,65 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Socket options for Darwin
+
+package net
+
+import (
+	"os";
+	"syscall";
+)
+
+// Socket option, shutdown modes, and receive flag not (yet) provided by package syscall.
+const (
+	_IPV6_V6ONLY	= 0x1b;
+	_SHUT_WR	= 1;
+	_SHUT_RDWR	= 2;
+	_MSG_PEEK	= 0x2;
+)
+
+func setDontFragment(fd *netFD, dontfrag bool) os.Error {
+	// TODO: Darwin has no socket option to set the
+	// Don't Fragment bit on UDP sockets.
+	return os.EINVAL
+}
+
+func pathMTU(fd *netFD) (int, os.Error) {
+	// TODO: Darwin does not report the path MTU.
+	return 0, os.EINVAL
+}
+
+func bindToDevice(fd int, dev string) os.Error {
+	// Darwin has no socket option to bind a socket to an
+	// interface; DialDevice documents that callers must pass
+	// the interface's address as laddr instead.
+	return os.EINVAL
+}
+
+// setV6Only restricts the IPv6 socket fd to IPv6 traffic.
+func setV6Only(fd int) os.Error {
+	return os.NewSyscallError("setsockopt", syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, _IPV6_V6ONLY, 1))
+}
+
+func shutdown(fd *netFD) os.Error {
+	return os.NewSyscallError("shutdown", syscall.Shutdown(fd.fd, _SHUT_RDWR))
+}
+
+// shutdownWrite shuts fd down for writing only.
+func shutdownWrite(fd *netFD) os.Error {
+	return os.NewSyscallError("shutdown", syscall.Shutdown(fd.fd, _SHUT_WR))
+}
+
+// peek reads the next byte of fd without consuming it.
+// It returns 0 bytes and no error at end of file.
+func peek(fd *netFD) (n int, errno int) {
+	var b [1]byte;
+	n, _, errno = syscall.Recvfrom(fd.fd, &b, _MSG_PEEK);
+	return;
+}
+
+func setSCTPEvents(fd *netFD) os.Error {
+	// TODO: Darwin has no SCTP in the kernel.
+	return os.EINVAL
+}
@@ -0,0 +1,79 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Socket options for Linux
+
+package net
+
+import (
+	"os";
+	"syscall";
+)
+
+// Socket options, shutdown modes, and receive flags not (yet) provided by package syscall.
+const (
+	_IP_MTU			= 0xe;
+	_IPV6_MTU_DISCOVER	= 0x17;
+	_IPV6_MTU		= 0x18;
+	_IPV6_V6ONLY		= 0x1a;
+	_SO_BINDTODEVICE	= 0x19;
+	_SHUT_WR		= 1;
+	_SHUT_RDWR		= 2;
+	_MSG_PEEK		= 0x2;
+)
+
+func setDontFragment(fd *netFD, dontfrag bool) os.Error {
+	// IP_PMTUDISC_DO sets the Don't Fragment bit on all outgoing
+	// packets; IP_PMTUDISC_DONT fragments packets if necessary.
+	// The IPv6 values are the same.
+	mode := syscall.IP_PMTUDISC_DONT;
+	if dontfrag {
+		mode = syscall.IP_PMTUDISC_DO
+	}
+	if fd.family == syscall.AF_INET6 {
+		return setsockoptInt(fd.fd, syscall.IPPROTO_IPV6, _IPV6_MTU_DISCOVER, mode)
+	}
+	return setsockoptInt(fd.fd, syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, mode);
+}
+
+func pathMTU(fd *netFD) (int, os.Error) {
+	if fd.family == syscall.AF_INET6 {
+		return getsockoptInt(fd.fd, syscall.IPPROTO_IPV6, _IPV6_MTU)
+	}
+	return getsockoptInt(fd.fd, syscall.IPPROTO_IP, _IP_MTU);
+}
+
+func bindToDevice(fd int, dev string) os.Error {
+	// Binding to a device requires CAP_NET_RAW.
+	return os.NewSyscallError("setsockopt", syscall.SetsockoptString(fd, syscall.SOL_SOCKET, _SO_BINDTODEVICE, dev))
+}
+
+// setV6Only restricts the IPv6 socket fd to IPv6 traffic.
+func setV6Only(fd int) os.Error {
+	return os.NewSyscallError("setsockopt", syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, _IPV6_V6ONLY, 1))
+}
+
+func shutdown(fd *netFD) os.Error {
+	return os.NewSyscallError("shutdown", syscall.Shutdown(fd.fd, _SHUT_RDWR))
+}
+
+// shutdownWrite shuts fd down for writing only.
+func shutdownWrite(fd *netFD) os.Error {
+	return os.NewSyscallError("shutdown", syscall.Shutdown(fd.fd, _SHUT_WR))
+}
+
+// peek reads the next byte of fd without consuming it.
+// It returns 0 bytes and no error at end of file.
+func peek(fd *netFD) (n int, errno int) {
+	var b [1]byte;
+	n, _, errno = syscall.Recvfrom(fd.fd, &b, _MSG_PEEK);
+	return;
+}
+
+func setSCTPEvents(fd *netFD) os.Error {
+	// Subscribe to the sctp_data_io_event only, the first
+	// field of struct sctp_event_subscribe, so that received
+	// messages carry their stream number.
+	return os.NewSyscallError("setsockopt", syscall.SetsockoptString(fd.fd, syscall.SOL_SCTP, syscall.SCTP_EVENTS, "\x01"))
+}
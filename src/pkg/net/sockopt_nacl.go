@@ -0,0 +1,43 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"os";
+	"syscall";
+)
+
+func setDontFragment(fd *netFD, dontfrag bool) os.Error {
+	return os.NewSyscallError("networking", syscall.ENACL)
+}
+
+func pathMTU(fd *netFD) (int, os.Error) {
+	return 0, os.NewSyscallError("networking", syscall.ENACL)
+}
+
+func bindToDevice(fd int, dev string) os.Error {
+	return os.NewSyscallError("networking", syscall.ENACL)
+}
+
+func setV6Only(fd int) os.Error {
+	return os.NewSyscallError("networking", syscall.ENACL)
+}
+
+func shutdown(fd *netFD) os.Error {
+	return os.NewSyscallError("networking", syscall.ENACL)
+}
+
+func shutdownWrite(fd *netFD) os.Error {
+	return os.NewSyscallError("networking", syscall.ENACL)
+}
+
+func peek(fd *netFD) (n int, errno int) {
+	// Not reached: the pollster reports no events.
+	return 0, syscall.EAGAIN
+}
+
+func setSCTPEvents(fd *netFD) os.Error {
+	return os.NewSyscallError("networking", syscall.ENACL)
+}
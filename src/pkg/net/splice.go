@@ -0,0 +1,126 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Connection splicing
+
+package net
+
+import (
+	"io";
+	"os";
+	"sync";
+	"syscall";
+)
+
+// Splice copies data between the connections a and b in both
+// directions until both directions have ended: the data read from a
+// is written to b, and the data read from b is written to a.
+//
+// A direction ends when its source reaches EOF; then the writing side
+// of its destination is shut down, so that the peer of the destination
+// sees EOF, too, while the other direction goes on.  Connections that
+// cannot be shut down for writing only, such as in-process ones, are
+// closed instead.  A direction also ends if a read or write fails;
+// then both connections are shut down, which ends the other direction.
+//
+// Splice returns the number of bytes copied from a to b and from b to
+// a, and the first error encountered; reaching EOF is not an error.
+// It does not close a or b.
+//
+// Where the system supports it, currently on Linux, the data between
+// two TCP or Unix stream connections is moved with splice(2) through
+// a pipe, without being copied to and from user space.  Otherwise, or
+// while faults are installed with SetFaults, the data is copied in
+// user space with a buffer for each direction.
+func Splice(a, b Conn) (ab, ba int64, err os.Error) {
+	s := new(splicer);
+	done := make(chan bool);
+	go func() {
+		ab = s.copy(b, a);
+		done <- true;
+	}();
+	ba = s.copy(a, b);
+	<-done;
+	return ab, ba, s.err;
+}
+
+// A splicer records the first error of the directions of a Splice.
+type splicer struct {
+	mu	sync.Mutex;
+	err	os.Error;
+}
+
+// copy copies the data from src to dst until EOF or an error
+// and returns the number of bytes copied.
+func (s *splicer) copy(dst, src Conn) int64 {
+	n, err := spliceCopy(dst, src);
+	if err != nil {
+		s.mu.Lock();
+		if s.err == nil {
+			s.err = err
+		}
+		s.mu.Unlock();
+		shutdownConn(src);
+		shutdownConn(dst);
+		return n;
+	}
+	closeWrite(dst);
+	return n;
+}
+
+// spliceCopy copies from src to dst in the kernel if possible
+// and in user space otherwise.
+func spliceCopy(dst Conn, src io.Reader) (int64, os.Error) {
+	if c, ok := src.(Conn); ok {
+		if d, s := streamFD(dst), streamFD(c); d != nil && s != nil && !faultsInstalled() {
+			if n, err, handled := spliceFD(d, s); handled {
+				return n, err
+			}
+		}
+	}
+	return io.Copy(writerOnly{dst}, src);
+}
+
+// A writerOnly hides all methods of its Writer but Write, so that
+// io.Copy to it does not call the ReadFrom method that uses spliceCopy.
+type writerOnly struct {
+	io.Writer;
+}
+
+// streamFD returns the socket of the stream connection c,
+// or nil if c is not a TCP or Unix stream connection.
+func streamFD(c Conn) *netFD {
+	switch c := c.(type) {
+	case *TCPConn:
+		if c.ok() {
+			return c.fd
+		}
+	case *UnixConn:
+		if c.ok() && c.fd.proto == syscall.SOCK_STREAM {
+			return c.fd
+		}
+	}
+	return nil;
+}
+
+// faultsInstalled reports whether faults are installed with SetFaults;
+// the kernel path would bypass them.
+func faultsInstalled() bool	{ return faults.installed }
+
+// closeWrite shuts c down for writing, so that its peer reads EOF.
+// Connections other than sockets are closed instead.
+func closeWrite(c Conn) {
+	switch c := c.(type) {
+	case *TCPConn:
+		if c.ok() {
+			shutdownWrite(c.fd)
+		}
+	case *UnixConn:
+		if c.ok() {
+			shutdownWrite(c.fd)
+		}
+	default:
+		c.Close()
+	}
+}
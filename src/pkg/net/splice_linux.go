@@ -0,0 +1,108 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Splicing sockets via splice(2).
+
+package net
+
+import (
+	"os";
+	"syscall";
+)
+
+// Splice flags not (yet) provided by package syscall.
+const (
+	_SPLICE_F_MOVE		= 0x1;
+	_SPLICE_F_NONBLOCK	= 0x2;
+)
+
+// Maximum number of bytes moved by a single splice system call;
+// the default capacity of a pipe.
+const maxSpliceSize = 64 << 10
+
+// splice1 moves up to n bytes from rfd to wfd, one of which
+// must be a pipe, without blocking.  It calls the system
+// directly since syscall.Splice drops the error number on
+// 32-bit systems.
+func splice1(rfd, wfd, n int) (int, int) {
+	r, _, e := syscall.Syscall6(syscall.SYS_SPLICE, uintptr(rfd), 0, uintptr(wfd), 0, uintptr(n), _SPLICE_F_MOVE|_SPLICE_F_NONBLOCK);
+	if e != 0 {
+		return 0, int(e)
+	}
+	return int(r), 0;
+}
+
+// spliceFD moves the data from the socket src to the socket dst
+// through a pipe until src reaches EOF or an error occurs.  If
+// the system cannot splice the sockets, handled is false and
+// nothing has been moved.
+func spliceFD(dst, src *netFD) (n int64, err os.Error, handled bool) {
+	var p [2]int;
+	if e := syscall.Pipe(p[0:2]); e != 0 {
+		return 0, nil, false
+	}
+	defer syscall.Close(p[0]);
+	defer syscall.Close(p[1]);
+
+	src.rio.Lock();
+	defer src.rio.Unlock();
+	dst.wio.Lock();
+	defer dst.wio.Unlock();
+
+	// the read deadline is set anew after each successful splice only,
+	// so that WaitRead can expire it while the source is idle
+	rearm := true;
+	for {
+		// socket to pipe
+		if rearm {
+			if src.rdeadline_delta > 0 {
+				src.rdeadline = pollserver.Now() + src.rdeadline_delta
+			} else {
+				src.rdeadline = 0
+			}
+			rearm = false;
+		}
+		nr, e := splice1(src.fd, p[1], maxSpliceSize);
+		if e == syscall.EAGAIN && src.rdeadline >= 0 {
+			pollserver.WaitRead(src);
+			continue;
+		}
+		if (e == syscall.EINVAL || e == syscall.ENOSYS) && n == 0 {
+			return 0, nil, false	// not supported for these sockets
+		}
+		if e != 0 {
+			return n, &OpError{"splice", src.net, src.raddr, os.Errno(e)}, true
+		}
+		if nr == 0 {
+			return n, nil, true	// EOF
+		}
+		src.touch();
+		rearm = true;
+
+		// pipe to socket
+		if dst.wdeadline_delta > 0 {
+			dst.wdeadline = pollserver.Now() + dst.wdeadline_delta
+		} else {
+			dst.wdeadline = 0
+		}
+		for nr > 0 {
+			nw, e := splice1(p[0], dst.fd, nr);
+			if nw > 0 {
+				nr -= nw;
+				n += int64(nw);
+				dst.touch();
+				continue;
+			}
+			if e == syscall.EAGAIN && dst.wdeadline >= 0 {
+				pollserver.WaitWrite(dst);
+				continue;
+			}
+			if e == 0 {
+				e = syscall.EPIPE
+			}
+			return n, &OpError{"splice", dst.net, dst.raddr, os.Errno(e)}, true;
+		}
+	}
+	panic("unreachable");
+}
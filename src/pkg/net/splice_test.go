@@ -0,0 +1,141 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"io";
+	"os";
+	"strings";
+	"testing";
+)
+
+// tcpPair returns the two ends of a TCP connection accepted from l.
+func tcpPair(t *testing.T, l Listener) (client, server Conn) {
+	client, err := Dial("tcp", "", l.Addr().String());
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	server, err = l.Accept();
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	return;
+}
+
+type spliceResult struct {
+	ab, ba	int64;
+	err	os.Error;
+}
+
+func TestSplice(t *testing.T) {
+	l, err := Listen("tcp", "127.0.0.1:0");
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close();
+	c1, s1 := tcpPair(t, l);
+	c2, s2 := tcpPair(t, l);
+
+	done := make(chan spliceResult);
+	go func() {
+		ab, ba, err := Splice(s1, s2);
+		done <- spliceResult{ab, ba, err};
+	}();
+
+	msg1 := strings.Bytes("hello from the first client");
+	msg2 := strings.Bytes("and hello from the second");
+	c1.Write(msg1);
+	b := make([]byte, len(msg1));
+	if _, err := io.ReadFull(c2, b); err != nil || string(b) != string(msg1) {
+		t.Errorf("ReadFull = %q, %v; want %q", b, err, msg1)
+	}
+
+	// the second client still writes after the first has finished
+	shutdownWrite(c1.(*TCPConn).fd);
+	if n, err := c2.Read(b); n != 0 || err != os.EOF {
+		t.Errorf("Read after shutdown = %d, %v; want 0, os.EOF", n, err)
+	}
+	c2.Write(msg2);
+	c2.Close();
+	b, err = io.ReadAll(c1);
+	if err != nil || string(b) != string(msg2) {
+		t.Errorf("ReadAll = %q, %v; want %q", b, err, msg2)
+	}
+	c1.Close();
+
+	r := <-done;
+	if r.ab != int64(len(msg1)) || r.ba != int64(len(msg2)) || r.err != nil {
+		t.Errorf("Splice = %d, %d, %v; want %d, %d, nil", r.ab, r.ba, r.err, len(msg1), len(msg2))
+	}
+	s1.Close();
+	s2.Close();
+}
+
+// isTimeout reports whether err is the error of an operation that timed out.
+func isTimeout(err os.Error) bool {
+	if e, ok := err.(*OpError); ok {
+		err = e.Error
+	}
+	return isEAGAIN(err);
+}
+
+func TestSpliceReadTimeout(t *testing.T) {
+	l, err := Listen("tcp", "127.0.0.1:0");
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close();
+	c1, s1 := tcpPair(t, l);
+	c2, s2 := tcpPair(t, l);
+	defer c1.Close();
+	defer c2.Close();
+	defer s1.Close();
+	defer s2.Close();
+
+	// neither client sends anything; the read timeout of s1 ends the splice
+	s1.SetReadTimeout(1e8);	// 100ms
+	done := make(chan bool);
+	var r spliceResult;
+	go func() {
+		r.ab, r.ba, r.err = Splice(s1, s2);
+		done <- true;
+	}();
+	if !isDone(done) {
+		t.Fatalf("Splice did not time out")
+	}
+	if !isTimeout(r.err) {
+		t.Errorf("Splice error = %v, want a timeout", r.err)
+	}
+}
+
+func TestCopyTCP(t *testing.T) {
+	l, err := Listen("tcp", "127.0.0.1:0");
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close();
+	c1, s1 := tcpPair(t, l);
+	c2, s2 := tcpPair(t, l);
+	defer s1.Close();
+	defer c2.Close();
+
+	if _, ok := s2.(io.ReaderFrom); !ok {
+		t.Fatalf("TCPConn is not an io.ReaderFrom")
+	}
+	msg := strings.Bytes("copied from one connection to another");
+	go func() {
+		c1.Write(msg);
+		c1.Close();
+	}();
+	n, err := io.Copy(s2, s1);
+	if n != int64(len(msg)) || err != nil {
+		t.Errorf("Copy = %d, %v; want %d, nil", n, err, len(msg))
+	}
+	s2.Close();
+	b, err := io.ReadAll(c2);
+	if err != nil || string(b) != string(msg) {
+		t.Errorf("ReadAll = %q, %v; want %q", b, err, msg)
+	}
+}
@@ -7,6 +7,7 @@
 package net
 
 import (
+	"io";
 	"os";
 	"syscall";
 )
@@ -68,11 +69,12 @@ func ResolveTCPAddr(addr string) (*TCPAddr, os.Error) {
 // TCPConn is an implementation of the Conn interface
 // for TCP network connections.
 type TCPConn struct {
-	fd *netFD;
+	fd	*netFD;
+	values	Values;
 }
 
 func newTCPConn(fd *netFD) *TCPConn {
-	c := &TCPConn{fd};
+	c := &TCPConn{fd: fd};
 	setsockoptInt(fd.fd, syscall.IPPROTO_TCP, syscall.TCP_NODELAY, 1);
 	return c;
 }
@@ -103,6 +105,32 @@ func (c *TCPConn) Write(b []byte) (n int, err os.Error) {
 	return c.fd.Write(b);
 }
 
+// WriteBuffers writes the buffers in v to the TCP connection,
+// using a single writev system call where possible.  It implements
+// the io.BuffersWriter interface, so that writing io.Buffers to the
+// connection avoids a system call per buffer.
+//
+// WriteBuffers can be made to time out like Write.
+func (c *TCPConn) WriteBuffers(v io.Buffers) (n int64, err os.Error) {
+	if !c.ok() {
+		return 0, os.EINVAL
+	}
+	return c.fd.writeBuffers(v);
+}
+
+// ReadFrom reads data from r until EOF or an error and writes it to
+// the TCP connection.  It implements the io.ReaderFrom interface, so
+// that io.Copy from a TCP or Unix stream connection moves the data in
+// the kernel where Splice would.
+//
+// ReadFrom can be made to time out like Write.
+func (c *TCPConn) ReadFrom(r io.Reader) (n int64, err os.Error) {
+	if !c.ok() {
+		return 0, os.EINVAL
+	}
+	return spliceCopy(c, r);
+}
+
 // Close closes the TCP connection.
 func (c *TCPConn) Close() os.Error {
 	if !c.ok() {
@@ -129,6 +157,20 @@ func (c *TCPConn) RemoteAddr() Addr {
 	return c.fd.raddr;
 }
 
+// Values returns the values associated with the connection.
+// They remain available after the connection is closed.
+func (c *TCPConn) Values() *Values	{ return &c.values }
+
+// Done returns a channel that is closed when the connection is
+// torn down: closed, shut down after an idle timeout, or closed or
+// reset by the peer.  See DoneConn.
+func (c *TCPConn) Done() <-chan bool {
+	if !c.ok() {
+		return closedDone
+	}
+	return c.fd.done();
+}
+
 // SetTimeout sets the read and write deadlines associated
 // with the connection.
 func (c *TCPConn) SetTimeout(nsec int64) os.Error {
@@ -205,13 +247,25 @@ func (c *TCPConn) SetKeepAlive(keepalive bool) os.Error {
 	return setKeepAlive(c.fd, keepalive);
 }
 
+// SetIdleTimeout sets the time (in nanoseconds) after which the
+// connection is shut down if no data has been read or written.
+// Once the connection has been shut down, Read returns os.EOF and
+// Write fails; the connection must still be closed.
+// Setting nsec == 0 (the default) disables the timeout.
+func (c *TCPConn) SetIdleTimeout(nsec int64) os.Error {
+	if !c.ok() {
+		return os.EINVAL
+	}
+	return setIdleTimeout(c.fd, nsec);
+}
+
 // DialTCP is like Dial but can only connect to TCP networks
 // and returns a TCPConn structure.
 func DialTCP(net string, laddr, raddr *TCPAddr) (c *TCPConn, err os.Error) {
 	if raddr == nil {
 		return nil, &OpError{"dial", "tcp", nil, errMissingAddress}
 	}
-	fd, e := internetSocket(net, laddr.toAddr(), raddr.toAddr(), syscall.SOCK_STREAM, "dial", sockaddrToTCP);
+	fd, e := internetSocket(net, laddr.toAddr(), raddr.toAddr(), syscall.SOCK_STREAM, 0, "dial", "", sockaddrToTCP);
 	if e != nil {
 		return nil, e
 	}
@@ -222,23 +276,38 @@ func DialTCP(net string, laddr, raddr *TCPAddr) (c *TCPConn, err os.Error) {
 // Clients should typically use variables of type Listener
 // instead of assuming TCP.
 type TCPListener struct {
-	fd *netFD;
+	fd	*netFD;
+	idle	int64;	// idle timeout for accepted connections
+
+	// in-process connections; see loopback.go
+	loop		chan Conn;		// waiting in-process connections; nil if not enabled
+	accepted	chan acceptResult;	// kernel connections accepted in the background
+	done		chan bool;		// closed when the listener is closed
 }
 
 // ListenTCP announces on the TCP address laddr and returns a TCP listener.
 // Net must be "tcp", "tcp4", or "tcp6".
-// If laddr has a port of 0, it means to listen on some available port.
-// The caller can use l.Addr() to retrieve the chosen address.
+// If laddr is nil or has a port of 0, it means to listen on some
+// available port.  The caller can use l.Addr() to retrieve the chosen
+// address, including the port assigned by the system.
 func ListenTCP(net string, laddr *TCPAddr) (l *TCPListener, err os.Error) {
-	fd, err := internetSocket(net, laddr.toAddr(), nil, syscall.SOCK_STREAM, "listen", sockaddrToTCP);
+	return ListenTCPPolicy(net, laddr, currentIPv6Policy())
+}
+
+// ListenTCPPolicy is like ListenTCP but applies the IPv6Policy p,
+// instead of the one set by SetIPv6Policy, to the listener and
+// the connections it accepts.
+func ListenTCPPolicy(net string, laddr *TCPAddr, p IPv6Policy) (l *TCPListener, err os.Error) {
+	fd, err := policySocket(net, laddr.toAddr(), nil, syscall.SOCK_STREAM, 0, "listen", "", sockaddrToTCP, p);
 	if err != nil {
 		return nil, err
 	}
 	errno := syscall.Listen(fd.fd, listenBacklog());
 	if errno != 0 {
-		syscall.Close(fd.fd);
+		fd.Close();
 		return nil, &OpError{"listen", "tcp", laddr, os.Errno(errno)};
 	}
+	updateLocalAddr(fd, sockaddrToTCP);
 	l = new(TCPListener);
 	l.fd = fd;
 	return l, nil;
@@ -247,6 +316,17 @@ func ListenTCP(net string, laddr *TCPAddr) (l *TCPListener, err os.Error) {
 // AcceptTCP accepts the next incoming call and returns the new connection
 // and the remote address.
 func (l *TCPListener) AcceptTCP() (c *TCPConn, err os.Error) {
+	if l == nil || l.fd == nil || l.fd.fd < 0 {
+		return nil, os.EINVAL
+	}
+	if l.accepted != nil {
+		r := <-l.accepted;
+		return r.c, r.err;
+	}
+	return l.acceptTCP();
+}
+
+func (l *TCPListener) acceptTCP() (c *TCPConn, err os.Error) {
 	if l == nil || l.fd == nil || l.fd.fd < 0 {
 		return nil, os.EINVAL
 	}
@@ -254,12 +334,26 @@ func (l *TCPListener) AcceptTCP() (c *TCPConn, err os.Error) {
 	if err != nil {
 		return nil, err
 	}
+	if l.idle > 0 {
+		setIdleTimeout(fd, l.idle)
+	}
 	return newTCPConn(fd), nil;
 }
 
 // Accept implements the Accept method in the Listener interface;
 // it waits for the next call and returns a generic Conn.
 func (l *TCPListener) Accept() (c Conn, err os.Error) {
+	if l != nil && l.fd != nil && l.fd.fd >= 0 && l.loop != nil {
+		select {
+		case c = <-l.loop:
+			return c, nil
+		case r := <-l.accepted:
+			if r.err != nil {
+				return nil, r.err
+			}
+			return r.c, nil;
+		}
+	}
 	c1, err := l.AcceptTCP();
 	if err != nil {
 		return nil, err
@@ -273,8 +367,19 @@ func (l *TCPListener) Close() os.Error {
 	if l == nil || l.fd == nil {
 		return os.EINVAL
 	}
+	l.stopLoopback();
 	return l.fd.Close();
 }
 
+// SetIdleTimeout sets the idle timeout of connections accepted
+// by l from now on; see TCPConn.SetIdleTimeout.
+func (l *TCPListener) SetIdleTimeout(nsec int64) os.Error {
+	if l == nil || l.fd == nil || nsec < 0 {
+		return os.EINVAL
+	}
+	l.idle = nsec;
+	return nil;
+}
+
 // Addr returns the listener's network address, a *TCPAddr.
 func (l *TCPListener) Addr() Addr	{ return l.fd.laddr }
@@ -5,6 +5,8 @@
 package net
 
 import (
+	"os";
+	"strings";
 	"testing";
 	"time";
 )
@@ -36,3 +38,78 @@ func TestTimeoutTCP(t *testing.T) {
 	// timeouts and this is the timeout test.
 	testTimeout(t, "tcp", "74.125.19.99:80")
 }
+
+func TestIdleTimeout(t *testing.T) {
+	l, err := ListenTCP("tcp", &TCPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenTCP: %v", err)
+	}
+	defer l.Close();
+	l.SetIdleTimeout(1e8);	// 100ms
+
+	// a client that connects but never sends anything
+	c, err := Dial("tcp", "", l.Addr().String());
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close();
+
+	s, err := l.Accept();
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	defer s.Close();
+	t0 := time.Nanoseconds();
+	var b [100]byte;
+	n, err := s.Read(&b);
+	t1 := time.Nanoseconds();
+	if n != 0 || err != os.EOF {
+		t.Errorf("Read on idle connection returned %d, %v; expected 0, os.EOF", n, err)
+	}
+	if t1-t0 < 0.5e8 || t1-t0 > 1.5e9 {
+		t.Errorf("Read on idle connection took %f seconds, expected 0.1", float64(t1-t0)/1e9)
+	}
+}
+
+func TestTimeoutReadFromUDP(t *testing.T) {
+	c, err := ListenUDP("udp4", &UDPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	defer c.Close();
+	var b [100]byte;
+
+	// connection read timeout
+	c.SetReadTimeout(1e8);	// 100ms
+	t0 := time.Nanoseconds();
+	n, _, err := c.ReadFromUDP(&b);
+	t1 := time.Nanoseconds();
+	if n != 0 || !isEAGAIN(err) {
+		t.Errorf("ReadFromUDP did not return 0, EAGAIN: %v, %v", n, err)
+	}
+	if t1-t0 < 0.5e8 || t1-t0 > 1.5e8 {
+		t.Errorf("ReadFromUDP took %f seconds, expected 0.1", float64(t1-t0)/1e9)
+	}
+
+	// per-call timeout overrides the connection timeout
+	c.SetReadTimeout(1e10);	// 10s
+	t0 = time.Nanoseconds();
+	n, _, err = c.ReadFromUDPTimeout(&b, 1e8);
+	t1 = time.Nanoseconds();
+	if n != 0 || !isEAGAIN(err) {
+		t.Errorf("ReadFromUDPTimeout did not return 0, EAGAIN: %v, %v", n, err)
+	}
+	if t1-t0 < 0.5e8 || t1-t0 > 1.5e8 {
+		t.Errorf("ReadFromUDPTimeout took %f seconds, expected 0.1", float64(t1-t0)/1e9)
+	}
+
+	// a waiting packet is returned before the deadline
+	laddr := c.LocalAddr().(*UDPAddr);
+	if _, err := c.WriteToUDPTimeout(strings.Bytes("hello"), laddr, 1e8); err != nil {
+		t.Fatalf("WriteToUDPTimeout: %v", err)
+	}
+	n, addr, err := c.ReadFromUDPTimeout(&b, 1e8);
+	if err != nil || string(b[0:n]) != "hello" || addr == nil || addr.Port != laddr.Port {
+		t.Errorf("ReadFromUDPTimeout = %d, %v, %v; expected 5, %v, nil", n, addr, err, laddr)
+	}
+}
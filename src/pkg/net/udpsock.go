@@ -68,10 +68,11 @@ func ResolveUDPAddr(addr string) (*UDPAddr, os.Error) {
 // UDPConn is the implementation of the Conn and PacketConn
 // interfaces for UDP network connections.
 type UDPConn struct {
-	fd *netFD;
+	fd	*netFD;
+	values	Values;
 }
 
-func newUDPConn(fd *netFD) *UDPConn	{ return &UDPConn{fd} }
+func newUDPConn(fd *netFD) *UDPConn	{ return &UDPConn{fd: fd} }
 
 func (c *UDPConn) ok() bool	{ return c != nil && c.fd != nil }
 
@@ -127,6 +128,10 @@ func (c *UDPConn) RemoteAddr() Addr {
 	return c.fd.raddr;
 }
 
+// Values returns the values associated with the connection.
+// They remain available after the connection is closed.
+func (c *UDPConn) Values() *Values	{ return &c.values }
+
 // SetTimeout sets the read and write deadlines associated
 // with the connection.
 func (c *UDPConn) SetTimeout(nsec int64) os.Error {
@@ -176,6 +181,27 @@ func (c *UDPConn) SetWriteBuffer(bytes int) os.Error {
 	return setWriteBuffer(c.fd, bytes);
 }
 
+// SetDontFragment sets whether the Don't Fragment bit is set on
+// outgoing packets.  If dontfrag is true, the operating system
+// performs path MTU discovery and packets larger than the path MTU
+// are rejected with an error instead of being fragmented; see PathMTU.
+func (c *UDPConn) SetDontFragment(dontfrag bool) os.Error {
+	if !c.ok() {
+		return os.EINVAL
+	}
+	return setDontFragment(c.fd, dontfrag);
+}
+
+// PathMTU returns the path MTU currently known for the remote
+// address of a connected UDP connection, that is, the size of the
+// largest IP packet that can be sent without fragmentation.
+func (c *UDPConn) PathMTU() (int, os.Error) {
+	if !c.ok() {
+		return 0, os.EINVAL
+	}
+	return pathMTU(c.fd);
+}
+
 // UDP-specific methods.
 
 // ReadFromUDP reads a UDP packet from c, copying the payload into b.
@@ -185,18 +211,25 @@ func (c *UDPConn) SetWriteBuffer(bytes int) os.Error {
 // ReadFromUDP can be made to time out and return err == os.EAGAIN
 // after a fixed time limit; see SetTimeout and SetReadTimeout.
 func (c *UDPConn) ReadFromUDP(b []byte) (n int, addr *UDPAddr, err os.Error) {
+	return c.ReadFromUDPTimeout(b, -1)
+}
+
+// ReadFromUDPTimeout is like ReadFromUDP but waits at most nsec
+// nanoseconds for a packet before returning err == os.EAGAIN,
+// regardless of the read timeout of c.  Setting nsec == 0 waits
+// without a deadline; nsec < 0 uses the read timeout of c.
+// This lets a datagram server bound each iteration of its receive
+// loop without changing the timeout shared with other callers.
+func (c *UDPConn) ReadFromUDPTimeout(b []byte, nsec int64) (n int, addr *UDPAddr, err os.Error) {
 	if !c.ok() {
 		return 0, nil, os.EINVAL
 	}
-	n, sa, errno := syscall.Recvfrom(c.fd.fd, b, 0);
-	if errno != 0 {
-		err = os.Errno(errno)
-	}
+	n, sa, err := c.fd.readFrom(b, nsec);
 	switch sa := sa.(type) {
 	case *syscall.SockaddrInet4:
-		addr = &UDPAddr{&sa.Addr, sa.Port}
+		addr = &UDPAddr{normalizeIP(&sa.Addr, c.fd.form), sa.Port}
 	case *syscall.SockaddrInet6:
-		addr = &UDPAddr{&sa.Addr, sa.Port}
+		addr = &UDPAddr{normalizeIP(&sa.Addr, c.fd.form), sa.Port}
 	}
 	return;
 }
@@ -221,6 +254,14 @@ func (c *UDPConn) ReadFrom(b []byte) (n int, addr Addr, err os.Error) {
 // after a fixed time limit; see SetTimeout and SetWriteTimeout.
 // On packet-oriented connections such as UDP, write timeouts are rare.
 func (c *UDPConn) WriteToUDP(b []byte, addr *UDPAddr) (n int, err os.Error) {
+	return c.WriteToUDPTimeout(b, addr, -1)
+}
+
+// WriteToUDPTimeout is like WriteToUDP but waits at most nsec
+// nanoseconds to send the packet before returning err == os.EAGAIN,
+// regardless of the write timeout of c.  Setting nsec == 0 waits
+// without a deadline; nsec < 0 uses the write timeout of c.
+func (c *UDPConn) WriteToUDPTimeout(b []byte, addr *UDPAddr, nsec int64) (n int, err os.Error) {
 	if !c.ok() {
 		return 0, os.EINVAL
 	}
@@ -228,10 +269,7 @@ func (c *UDPConn) WriteToUDP(b []byte, addr *UDPAddr) (n int, err os.Error) {
 	if err != nil {
 		return 0, err
 	}
-	if errno := syscall.Sendto(c.fd.fd, b, 0, sa); errno != 0 {
-		return 0, os.Errno(errno)
-	}
-	return len(b), nil;
+	return c.fd.writeTo(b, sa, nsec);
 }
 
 // WriteTo writes a UDP packet with payload b to addr via c.
@@ -250,6 +288,81 @@ func (c *UDPConn) WriteTo(b []byte, addr Addr) (n int, err os.Error) {
 	return c.WriteToUDP(b, a);
 }
 
+// A UDPMessage describes one datagram of a batch.
+type UDPMessage struct {
+	Buf	[]byte;		// payload
+	N	int;		// number of bytes received into Buf
+	Addr	*UDPAddr;	// destination or source address
+}
+
+// WriteBatch sends the datagrams described by msgs, moving several of
+// them per system call (sendmmsg) where the system supports it.  Each
+// datagram is sent to its Addr; if the Addr of every message is nil,
+// they are sent to the connected peer.  WriteBatch returns the number
+// of datagrams sent, which is less than len(msgs) only if err != nil.
+//
+// WriteBatch can be made to time out and return err == os.EAGAIN
+// after a fixed time limit; see SetTimeout and SetWriteTimeout.
+func (c *UDPConn) WriteBatch(msgs []UDPMessage) (n int, err os.Error) {
+	if !c.ok() {
+		return 0, os.EINVAL
+	}
+	p := make([][]byte, len(msgs));
+	var to []syscall.Sockaddr;
+	for i, m := range msgs {
+		p[i] = m.Buf;
+		if m.Addr == nil {
+			if to != nil {
+				return 0, &OpError{"writebatch", "udp", nil, os.EINVAL}
+			}
+			continue;
+		}
+		if to == nil {
+			if i > 0 {
+				return 0, &OpError{"writebatch", "udp", m.Addr, os.EINVAL}
+			}
+			to = make([]syscall.Sockaddr, len(msgs));
+		}
+		if to[i], err = m.Addr.sockaddr(c.fd.family); err != nil {
+			return 0, err
+		}
+	}
+	return c.fd.writeBatch(p, to);
+}
+
+// ReadBatch receives datagrams into the Buf fields of msgs, moving
+// several of them per system call (recvmmsg) where the system supports
+// it.  It waits for at least one datagram and returns the number n of
+// datagrams received; for each of msgs[0:n], it sets N to the length
+// of the payload and Addr to the source address.
+//
+// ReadBatch can be made to time out and return err == os.EAGAIN
+// after a fixed time limit; see SetTimeout and SetReadTimeout.
+func (c *UDPConn) ReadBatch(msgs []UDPMessage) (n int, err os.Error) {
+	if !c.ok() {
+		return 0, os.EINVAL
+	}
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+	p := make([][]byte, len(msgs));
+	for i, m := range msgs {
+		p[i] = m.Buf
+	}
+	n, lens, from, err := c.fd.readBatch(p);
+	for i := 0; i < n; i++ {
+		msgs[i].N = lens[i];
+		msgs[i].Addr = nil;
+		switch sa := from[i].(type) {
+		case *syscall.SockaddrInet4:
+			msgs[i].Addr = &UDPAddr{normalizeIP(&sa.Addr, c.fd.form), sa.Port}
+		case *syscall.SockaddrInet6:
+			msgs[i].Addr = &UDPAddr{normalizeIP(&sa.Addr, c.fd.form), sa.Port}
+		}
+	}
+	return;
+}
+
 // DialUDP connects to the remote address raddr on the network net,
 // which must be "udp", "udp4", or "udp6".  If laddr is not nil, it is used
 // as the local address for the connection.
@@ -262,7 +375,7 @@ func DialUDP(net string, laddr, raddr *UDPAddr) (c *UDPConn, err os.Error) {
 	if raddr == nil {
 		return nil, &OpError{"dial", "udp", nil, errMissingAddress}
 	}
-	fd, e := internetSocket(net, laddr.toAddr(), raddr.toAddr(), syscall.SOCK_DGRAM, "dial", sockaddrToUDP);
+	fd, e := internetSocket(net, laddr.toAddr(), raddr.toAddr(), syscall.SOCK_DGRAM, 0, "dial", "", sockaddrToUDP);
 	if e != nil {
 		return nil, e
 	}
@@ -282,7 +395,7 @@ func ListenUDP(net string, laddr *UDPAddr) (c *UDPConn, err os.Error) {
 	if laddr == nil {
 		return nil, &OpError{"listen", "udp", nil, errMissingAddress}
 	}
-	fd, e := internetSocket(net, laddr.toAddr(), nil, syscall.SOCK_DGRAM, "dial", sockaddrToUDP);
+	fd, e := internetSocket(net, laddr.toAddr(), nil, syscall.SOCK_DGRAM, 0, "dial", "", sockaddrToUDP);
 	if e != nil {
 		return nil, e
 	}
@@ -0,0 +1,99 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"strings";
+	"syscall";
+	"testing";
+)
+
+func TestPathMTU(t *testing.T) {
+	if syscall.OS != "linux" {
+		return
+	}
+	l, err := ListenUDP("udp4", &UDPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	defer l.Close();
+
+	c, err := DialUDP("udp4", nil, l.LocalAddr().(*UDPAddr));
+	if err != nil {
+		t.Fatalf("DialUDP: %v", err)
+	}
+	defer c.Close();
+
+	if err := c.SetDontFragment(true); err != nil {
+		t.Fatalf("SetDontFragment: %v", err)
+	}
+	mtu, err := c.PathMTU();
+	if err != nil {
+		t.Fatalf("PathMTU: %v", err)
+	}
+	if mtu < 576 {
+		t.Errorf("PathMTU = %d, expected at least 576", mtu)
+	}
+	if err := c.SetDontFragment(false); err != nil {
+		t.Errorf("SetDontFragment(false): %v", err)
+	}
+}
+
+func TestUDPBatch(t *testing.T) {
+	if syscall.OS != "linux" && syscall.OS != "darwin" {
+		return
+	}
+	l, err := ListenUDP("udp4", &UDPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	defer l.Close();
+
+	c, err := ListenUDP("udp4", &UDPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	defer c.Close();
+
+	laddr := l.LocalAddr().(*UDPAddr);
+	payloads := []string{"one", "two", "three"};
+	out := make([]UDPMessage, len(payloads));
+	for i, s := range payloads {
+		out[i] = UDPMessage{Buf: strings.Bytes(s), Addr: laddr}
+	}
+	n, err := c.WriteBatch(out);
+	if n != len(out) || err != nil {
+		t.Fatalf("WriteBatch = %d, %v; expected %d, nil", n, err, len(out))
+	}
+
+	l.SetReadTimeout(1e9);
+	in := make([]UDPMessage, 8);
+	for i := range in {
+		in[i].Buf = make([]byte, 16)
+	}
+	caddr := c.LocalAddr().(*UDPAddr);
+	got := 0;
+	for got < len(payloads) {
+		n, err := l.ReadBatch(in);
+		if err != nil {
+			t.Fatalf("ReadBatch: %v", err)
+		}
+		for _, m := range in[0:n] {
+			if s := string(m.Buf[0:m.N]); s != payloads[got] {
+				t.Errorf("datagram %d = %q, expected %q", got, s, payloads[got])
+			}
+			if m.Addr == nil || m.Addr.Port != caddr.Port {
+				t.Errorf("datagram %d from %v, expected %v", got, m.Addr, caddr)
+			}
+			got++;
+		}
+	}
+
+	// mixing addressed and unaddressed messages is an error
+	out[1].Addr = nil;
+	if _, err := c.WriteBatch(out); err == nil {
+		t.Errorf("WriteBatch with missing address succeeded")
+	}
+}
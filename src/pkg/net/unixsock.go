@@ -7,6 +7,7 @@
 package net
 
 import (
+	"io";
 	"os";
 	"syscall";
 )
@@ -51,7 +52,7 @@ func unixSocket(net string, laddr, raddr *UnixAddr, mode string) (fd *netFD, err
 	if proto != syscall.SOCK_STREAM {
 		f = sockaddrToUnixgram
 	}
-	fd, err = socket(net, syscall.AF_UNIX, proto, 0, la, ra, f);
+	fd, err = socket(net, syscall.AF_UNIX, proto, 0, "", false, la, ra, f);
 	if err != nil {
 		goto Error
 	}
@@ -124,10 +125,11 @@ func ResolveUnixAddr(net, addr string) (*UnixAddr, os.Error) {
 // UnixConn is an implementation of the Conn interface
 // for connections to Unix domain sockets.
 type UnixConn struct {
-	fd *netFD;
+	fd	*netFD;
+	values	Values;
 }
 
-func newUnixConn(fd *netFD) *UnixConn	{ return &UnixConn{fd} }
+func newUnixConn(fd *netFD) *UnixConn	{ return &UnixConn{fd: fd} }
 
 func (c *UnixConn) ok() bool	{ return c != nil && c.fd != nil }
 
@@ -155,6 +157,16 @@ func (c *UnixConn) Write(b []byte) (n int, err os.Error) {
 	return c.fd.Write(b);
 }
 
+// WriteBuffers writes the buffers in v to the Unix domain connection,
+// using a single writev system call where possible.  It implements
+// the io.BuffersWriter interface.
+func (c *UnixConn) WriteBuffers(v io.Buffers) (n int64, err os.Error) {
+	if !c.ok() {
+		return 0, os.EINVAL
+	}
+	return c.fd.writeBuffers(v);
+}
+
 // Close closes the Unix domain connection.
 func (c *UnixConn) Close() os.Error {
 	if !c.ok() {
@@ -184,6 +196,20 @@ func (c *UnixConn) RemoteAddr() Addr {
 	return c.fd.raddr;
 }
 
+// Values returns the values associated with the connection.
+// They remain available after the connection is closed.
+func (c *UnixConn) Values() *Values	{ return &c.values }
+
+// Done returns a channel that is closed when the connection is
+// torn down: closed, shut down after an idle timeout, or closed or
+// reset by the peer.  See DoneConn.
+func (c *UnixConn) Done() <-chan bool {
+	if !c.ok() {
+		return closedDone
+	}
+	return c.fd.done();
+}
+
 // SetTimeout sets the read and write deadlines associated
 // with the connection.
 func (c *UnixConn) SetTimeout(nsec int64) os.Error {
@@ -233,6 +259,18 @@ func (c *UnixConn) SetWriteBuffer(bytes int) os.Error {
 	return setWriteBuffer(c.fd, bytes);
 }
 
+// SetIdleTimeout sets the time (in nanoseconds) after which the
+// connection is shut down if no data has been read or written.
+// Once the connection has been shut down, Read returns os.EOF and
+// Write fails; the connection must still be closed.
+// Setting nsec == 0 (the default) disables the timeout.
+func (c *UnixConn) SetIdleTimeout(nsec int64) os.Error {
+	if !c.ok() {
+		return os.EINVAL
+	}
+	return setIdleTimeout(c.fd, nsec);
+}
+
 // ReadFromUnix reads a packet from c, copying the payload into b.
 // It returns the number of bytes copied into b and the return address
 // that was on the packet.
@@ -320,6 +358,7 @@ func DialUnix(net string, laddr, raddr *UnixAddr) (c *UnixConn, err os.Error) {
 type UnixListener struct {
 	fd	*netFD;
 	path	string;
+	idle	int64;	// idle timeout for accepted connections
 }
 
 // ListenUnix announces on the Unix domain socket laddr and returns a Unix listener.
@@ -340,10 +379,10 @@ func ListenUnix(net string, laddr *UnixAddr) (l *UnixListener, err os.Error) {
 	}
 	e1 := syscall.Listen(fd.fd, 8);	// listenBacklog());
 	if e1 != 0 {
-		syscall.Close(fd.fd);
+		fd.Close();
 		return nil, &OpError{"listen", "unix", laddr, os.Errno(e1)};
 	}
-	return &UnixListener{fd, laddr.Name}, nil;
+	return &UnixListener{fd, laddr.Name, 0}, nil;
 }
 
 // AcceptUnix accepts the next incoming call and returns the new connection
@@ -356,6 +395,9 @@ func (l *UnixListener) AcceptUnix() (c *UnixConn, err os.Error) {
 	if e != nil {
 		return nil, e
 	}
+	if l.idle > 0 {
+		setIdleTimeout(fd, l.idle)
+	}
 	c = newUnixConn(fd);
 	return c, nil;
 }
@@ -395,6 +437,16 @@ func (l *UnixListener) Close() os.Error {
 	return err;
 }
 
+// SetIdleTimeout sets the idle timeout of connections accepted
+// by l from now on; see UnixConn.SetIdleTimeout.
+func (l *UnixListener) SetIdleTimeout(nsec int64) os.Error {
+	if l == nil || l.fd == nil || nsec < 0 {
+		return os.EINVAL
+	}
+	l.idle = nsec;
+	return nil;
+}
+
 // Addr returns the listener's network address.
 func (l *UnixListener) Addr() Addr	{ return l.fd.laddr }
 
@@ -0,0 +1,67 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Per-connection values
+
+package net
+
+import "sync"
+
+// Values holds values associated with a connection, keyed by
+// arbitrary comparable keys.  It lets layers built on a connection,
+// such as logging wrappers, security layers and proxies, pass
+// per-connection metadata to each other without global maps keyed
+// by connections.  To avoid collisions, a package should use keys of
+// an unexported type it defines.  Values is safe for concurrent use;
+// its zero value is empty and ready to use.
+type Values struct {
+	mu	sync.Mutex;
+	m	map[interface{}]interface{};
+}
+
+// Set associates value with key, replacing any value set before.
+func (v *Values) Set(key, value interface{}) {
+	v.mu.Lock();
+	if v.m == nil {
+		v.m = make(map[interface{}]interface{})
+	}
+	v.m[key] = value;
+	v.mu.Unlock();
+}
+
+// Get returns the value associated with key; ok reports
+// whether there is one.
+func (v *Values) Get(key interface{}) (value interface{}, ok bool) {
+	v.mu.Lock();
+	value, ok = v.m[key];
+	v.mu.Unlock();
+	return;
+}
+
+// Delete removes the value associated with key, if any.
+func (v *Values) Delete(key interface{}) {
+	v.mu.Lock();
+	if v.m != nil {
+		v.m[key] = nil, false
+	}
+	v.mu.Unlock();
+}
+
+// A ValueConn is a Conn with associated values.  The connections
+// of this package implement ValueConn.  A layer wrapping another
+// connection should implement ValueConn too, by returning the Values
+// of the wrapped connection, so that all layers share the same values.
+type ValueConn interface {
+	Conn;
+	Values() *Values;
+}
+
+// ConnValues returns the values associated with c, or nil
+// if c does not implement ValueConn.
+func ConnValues(c Conn) *Values {
+	if vc, ok := c.(ValueConn); ok {
+		return vc.Values()
+	}
+	return nil;
+}
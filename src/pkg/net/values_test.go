@@ -0,0 +1,44 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import "testing"
+
+type testKey int
+
+func TestConnValues(t *testing.T) {
+	l, err := ListenTCP("tcp", &TCPAddr{IPv4(127, 0, 0, 1), 0});
+	if err != nil {
+		t.Fatalf("ListenTCP: %v", err)
+	}
+	defer l.Close();
+	c, err := Dial("tcp", "", l.Addr().String());
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	v := ConnValues(c);
+	if v == nil {
+		t.Fatalf("ConnValues(%T) = nil", c)
+	}
+	if _, ok := v.Get(testKey(1)); ok {
+		t.Errorf("Get on empty Values succeeded")
+	}
+	v.Set(testKey(1), "user");
+	v.Set(testKey(2), 42);
+	c.Close();
+
+	// values outlive the connection
+	if x, ok := ConnValues(c).Get(testKey(1)); !ok || x.(string) != "user" {
+		t.Errorf("Get(1) = %v, %v; want user, true", x, ok)
+	}
+	v.Delete(testKey(1));
+	if _, ok := v.Get(testKey(1)); ok {
+		t.Errorf("Get(1) after Delete succeeded")
+	}
+	if x, ok := v.Get(testKey(2)); !ok || x.(int) != 42 {
+		t.Errorf("Get(2) = %v, %v; want 42, true", x, ok)
+	}
+}
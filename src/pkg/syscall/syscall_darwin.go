@@ -156,6 +156,7 @@ func Sleep(ns int64) (errno int) {
 //sys	connect(s int, addr uintptr, addrlen _Socklen) (errno int)
 //sys	socket(domain int, typ int, proto int) (fd int, errno int)
 //sys	setsockopt(s int, level int, name int, val uintptr, vallen int) (errno int)
+//sys	getsockopt(s int, level int, name int, val uintptr, vallen *_Socklen) (errno int)
 //sys	getpeername(fd int, rsa *RawSockaddrAny, addrlen *_Socklen) (errno int)
 //sys	getsockname(fd int, rsa *RawSockaddrAny, addrlen *_Socklen) (errno int)
 
@@ -333,6 +334,84 @@ func SetsockoptInt(fd, level, opt int, value int) (errno int) {
 	return setsockopt(fd, level, opt, uintptr(unsafe.Pointer(&n)), 4);
 }
 
+func GetsockoptInt(fd, level, opt int) (value, errno int) {
+	var n int32;
+	var len _Socklen = 4;
+	errno = getsockopt(fd, level, opt, uintptr(unsafe.Pointer(&n)), &len);
+	return int(n), errno;
+}
+
+func Writev(fd int, p [][]byte) (n int, errno int) {
+	if len(p) == 0 {
+		return 0, 0
+	}
+	iov := make([]Iovec, len(p));
+	for i, b := range p {
+		if len(b) > 0 {
+			iov[i].Base = &b[0]
+		}
+		iov[i].SetLen(len(b));
+	}
+	return writev(fd, &iov[0], len(iov));
+}
+
+// Protection and flags for Mmap.
+const (
+	PROT_NONE	= 0x0;
+	PROT_READ	= 0x1;
+	PROT_WRITE	= 0x2;
+	PROT_EXEC	= 0x4;
+	MAP_SHARED	= 0x1;
+	MAP_PRIVATE	= 0x2;
+)
+
+// Mmap maps length bytes of the file fd, starting at offset, which
+// must be a multiple of the page size, and returns them as a slice.
+// The slice must be released with Munmap; it must not be used after that.
+func Mmap(fd int, offset int64, length int, prot int, flags int) (data []byte, errno int) {
+	if length <= 0 {
+		return nil, EINVAL
+	}
+	addr, errno := mmap(0, uintptr(length), prot, flags, fd, offset);
+	if errno != 0 {
+		return nil, errno
+	}
+	h := (*sliceHeader)(unsafe.Pointer(&data));
+	h.Data = addr;
+	h.Len = length;
+	h.Cap = length;
+	return data, 0;
+}
+
+// Munmap releases a slice returned by Mmap.
+func Munmap(data []byte) (errno int) {
+	if len(data) == 0 {
+		return EINVAL
+	}
+	_, _, e1 := Syscall(SYS_MUNMAP, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), 0);
+	return int(e1);
+}
+
+// Darwin has no sendmmsg and recvmmsg system calls.
+
+func Recvmmsg(fd int, p [][]byte, flags int) (n int, lens []int, from []Sockaddr, errno int) {
+	return 0, nil, nil, ENOSYS
+}
+
+func Sendmmsg(fd int, p [][]byte, to []Sockaddr, flags int) (n int, errno int) {
+	return 0, ENOSYS
+}
+
+// TODO: SCTP messages on Darwin, which has no SCTP in the kernel.
+
+func SctpSendmsg(fd int, p []byte, stream int, ppid uint32, flags int) (n int, errno int) {
+	return 0, ENOSYS
+}
+
+func SctpRecvmsg(fd int, p []byte, flags int) (n, stream int, ppid uint32, errno int) {
+	return 0, 0, 0, ENOSYS
+}
+
 func SetsockoptTimeval(fd, level, opt int, tv *Timeval) (errno int) {
 	return setsockopt(fd, level, opt, uintptr(unsafe.Pointer(tv)), unsafe.Sizeof(*tv))
 }
@@ -543,6 +622,7 @@ func SysctlUint32(name string) (value uint32, errno int) {
 //sys	Setsid() (pid int, errno int)
 //sys	Settimeofday(tp *Timeval) (errno int)
 //sys	Setuid(uid int) (errno int)
+//sys	Shutdown(s int, how int) (errno int)
 //sys	Stat(path string, stat *Stat_t) (errno int) = SYS_STAT64
 //sys	Statfs(path string, stat *Statfs_t) (errno int) = SYS_STATFS64
 //sys	Symlink(path string, link string) (errno int)
@@ -555,6 +635,7 @@ func SysctlUint32(name string) (value uint32, errno int) {
 //sys	Write(fd int, p []byte) (n int, errno int)
 //sys	read(fd int, buf *byte, nbuf int) (n int, errno int)
 //sys	write(fd int, buf *byte, nbuf int) (n int, errno int)
+//sys	writev(fd int, iov *Iovec, niov int) (n int, errno int)
 
 
 /*
@@ -579,7 +660,6 @@ func SysctlUint32(name string) (value uint32, errno int) {
 // Select
 // Sigsuspend
 // Readv
-// Writev
 // Nfssvc
 // Getfh
 // Quotactl
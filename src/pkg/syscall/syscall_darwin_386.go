@@ -23,6 +23,13 @@ func NsecToTimeval(nsec int64) (tv Timeval) {
 	return;
 }
 
+func (iov *Iovec) SetLen(length int)	{ iov.Len = uint32(length) }
+
+// TODO: the 64-bit offset makes mmap a seven-argument system call on 386.
+func mmap(addr uintptr, length uintptr, prot int, flags int, fd int, offset int64) (xaddr uintptr, errno int) {
+	return 0, ENOSYS
+}
+
 //sys	gettimeofday(tp *Timeval) (sec int32, usec int32, errno int)
 func Gettimeofday(tv *Timeval) (errno int) {
 	// The tv passed to gettimeofday must be non-nil
@@ -23,6 +23,13 @@ func NsecToTimeval(nsec int64) (tv Timeval) {
 	return;
 }
 
+func (iov *Iovec) SetLen(length int)	{ iov.Len = uint64(length) }
+
+func mmap(addr uintptr, length uintptr, prot int, flags int, fd int, offset int64) (xaddr uintptr, errno int) {
+	r0, _, e1 := Syscall6(SYS_MMAP, addr, length, uintptr(prot), uintptr(flags), uintptr(fd), uintptr(offset));
+	return r0, int(e1);
+}
+
 //sys	gettimeofday(tp *Timeval) (sec int64, usec int32, errno int)
 func Gettimeofday(tv *Timeval) (errno int) {
 	// The tv passed to gettimeofday must be non-nil
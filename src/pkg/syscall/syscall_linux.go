@@ -357,6 +357,69 @@ func SetsockoptInt(fd, level, opt int, value int) (errno int) {
 	return setsockopt(fd, level, opt, uintptr(unsafe.Pointer(&n)), 4);
 }
 
+func GetsockoptInt(fd, level, opt int) (value, errno int) {
+	var n int32;
+	var len _Socklen = 4;
+	errno = getsockopt(fd, level, opt, uintptr(unsafe.Pointer(&n)), &len);
+	return int(n), errno;
+}
+
+func Writev(fd int, p [][]byte) (n int, errno int) {
+	if len(p) == 0 {
+		return 0, 0
+	}
+	iov := make([]Iovec, len(p));
+	for i, b := range p {
+		if len(b) > 0 {
+			iov[i].Base = &b[0]
+		}
+		iov[i].SetLen(len(b));
+	}
+	return writev(fd, &iov[0], len(iov));
+}
+
+// Protection and flags for Mmap.
+const (
+	PROT_NONE	= 0x0;
+	PROT_READ	= 0x1;
+	PROT_WRITE	= 0x2;
+	PROT_EXEC	= 0x4;
+	MAP_SHARED	= 0x1;
+	MAP_PRIVATE	= 0x2;
+)
+
+// Mmap maps length bytes of the file fd, starting at offset, which
+// must be a multiple of the page size, and returns them as a slice.
+// The slice must be released with Munmap; it must not be used after that.
+func Mmap(fd int, offset int64, length int, prot int, flags int) (data []byte, errno int) {
+	if length <= 0 {
+		return nil, EINVAL
+	}
+	addr, errno := mmap(0, uintptr(length), prot, flags, fd, offset);
+	if errno != 0 {
+		return nil, errno
+	}
+	h := (*sliceHeader)(unsafe.Pointer(&data));
+	h.Data = addr;
+	h.Len = length;
+	h.Cap = length;
+	return data, 0;
+}
+
+// Munmap releases a slice returned by Mmap.
+func Munmap(data []byte) (errno int) {
+	if len(data) == 0 {
+		return EINVAL
+	}
+	_, _, e1 := Syscall(SYS_MUNMAP, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), 0);
+	return int(e1);
+}
+
+func SetsockoptString(fd, level, opt int, s string) (errno int) {
+	p := StringByteSlice(s);
+	return setsockopt(fd, level, opt, uintptr(unsafe.Pointer(&p[0])), len(p));
+}
+
 func SetsockoptTimeval(fd, level, opt int, tv *Timeval) (errno int) {
 	return setsockopt(fd, level, opt, uintptr(unsafe.Pointer(tv)), unsafe.Sizeof(*tv))
 }
@@ -383,6 +446,161 @@ func Sendto(fd int, p []byte, flags int, to Sockaddr) (errno int) {
 	return sendto(fd, p, flags, ptr, n);
 }
 
+// newMmsghdrs returns the message headers for sending or receiving
+// the datagrams p with sendmmsg or recvmmsg.
+func newMmsghdrs(p [][]byte) []_Mmsghdr {
+	msgs := make([]_Mmsghdr, len(p));
+	iov := make([]Iovec, len(p));
+	for i, b := range p {
+		if len(b) > 0 {
+			iov[i].Base = &b[0]
+		}
+		iov[i].SetLen(len(b));
+		msgs[i].Hdr.Iov = &iov[i];
+		msgs[i].Hdr.SetIovlen(1);
+	}
+	return msgs;
+}
+
+// Recvmmsg receives up to len(p) datagrams with a single system call,
+// storing the payload of the i'th datagram in p[i].  It returns the
+// number of datagrams received, their lengths, and their source
+// addresses.  Recvmmsg requires Linux 2.6.33 or later; older kernels
+// return ENOSYS.
+func Recvmmsg(fd int, p [][]byte, flags int) (n int, lens []int, from []Sockaddr, errno int) {
+	if len(p) == 0 {
+		return
+	}
+	msgs := newMmsghdrs(p);
+	rsa := make([]RawSockaddrAny, len(p));
+	for i := range msgs {
+		msgs[i].Hdr.Name = (*byte)(unsafe.Pointer(&rsa[i]));
+		msgs[i].Hdr.Namelen = SizeofSockaddrAny;
+	}
+	r0, _, e1 := Syscall6(_SYS_RECVMMSG, uintptr(fd), uintptr(unsafe.Pointer(&msgs[0])), uintptr(len(msgs)), uintptr(flags), 0, 0);
+	if errno = int(e1); errno != 0 {
+		return
+	}
+	n = int(r0);
+	lens = make([]int, n);
+	from = make([]Sockaddr, n);
+	for i := 0; i < n; i++ {
+		lens[i] = int(msgs[i].Len);
+		from[i], _ = anyToSockaddr(&rsa[i]);	// nil if unknown
+	}
+	return;
+}
+
+// Sendmmsg sends the datagrams p with a single system call.  If to
+// is not nil, the i'th datagram is sent to to[i]; otherwise all are
+// sent to the connected peer.  It returns the number of datagrams sent.
+// Sendmmsg requires Linux 3.0 or later; older kernels return ENOSYS.
+func Sendmmsg(fd int, p [][]byte, to []Sockaddr, flags int) (n int, errno int) {
+	if len(p) == 0 {
+		return
+	}
+	msgs := newMmsghdrs(p);
+	if to != nil {
+		for i := range msgs {
+			ptr, l, err := to[i].sockaddr();
+			if err != 0 {
+				return 0, err
+			}
+			msgs[i].Hdr.Name = (*byte)(unsafe.Pointer(ptr));
+			msgs[i].Hdr.Namelen = uint32(l);
+		}
+	}
+	r0, _, e1 := Syscall6(_SYS_SENDMMSG, uintptr(fd), uintptr(unsafe.Pointer(&msgs[0])), uintptr(len(msgs)), uintptr(flags), 0, 0);
+	return int(r0), int(e1);
+}
+
+// SCTP socket options and ancillary data (see linux/sctp.h).
+const (
+	SOL_SCTP		= 132;
+	SCTP_SNDRCV		= 1;	// ancillary data type of struct sctp_sndrcvinfo
+	SCTP_EVENTS		= 11;
+	sizeofSctpSndrcvinfo	= 0x20;
+)
+
+// sctpSndrcvinfo is struct sctp_sndrcvinfo.
+type sctpSndrcvinfo struct {
+	Stream		uint16;
+	Ssn		uint16;
+	Flags		uint16;
+	Pad		uint16;
+	Ppid		uint32;
+	Context		uint32;
+	Timetolive	uint32;
+	Tsn		uint32;
+	Cumtsn		uint32;
+	AssocId		int32;
+}
+
+func cmsgAlign(n int) int	{ return (n + sizeofPtr - 1) &^ (sizeofPtr - 1) }
+
+// cmsgLen returns the length of a control message with n bytes of data.
+func cmsgLen(n int) int	{ return cmsgAlign(SizeofCmsghdr) + n }
+
+// SctpSendmsg sends p on the SCTP socket fd as a single message on the
+// given stream, with the payload protocol identifier ppid.
+func SctpSendmsg(fd int, p []byte, stream int, ppid uint32, flags int) (n int, errno int) {
+	var iov Iovec;
+	if len(p) > 0 {
+		iov.Base = &p[0]
+	}
+	iov.SetLen(len(p));
+	control := make([]byte, cmsgAlign(cmsgLen(sizeofSctpSndrcvinfo)));
+	h := (*Cmsghdr)(unsafe.Pointer(&control[0]));
+	h.Level = SOL_SCTP;
+	h.Type = SCTP_SNDRCV;
+	h.SetLen(cmsgLen(sizeofSctpSndrcvinfo));
+	info := (*sctpSndrcvinfo)(unsafe.Pointer(&control[cmsgLen(0)]));
+	info.Stream = uint16(stream);
+	info.Ppid = ppid;
+	var msg Msghdr;
+	msg.Iov = &iov;
+	msg.SetIovlen(1);
+	msg.Control = &control[0];
+	msg.SetControllen(len(control));
+	return sendmsg(fd, &msg, flags);
+}
+
+// SctpRecvmsg receives a message, or the next part of it, from the
+// SCTP socket fd into p.  It returns the stream number and payload
+// protocol identifier of the message; they are only reported if the
+// socket subscribed to them with the SCTP_EVENTS option, and are
+// zero otherwise.
+func SctpRecvmsg(fd int, p []byte, flags int) (n, stream int, ppid uint32, errno int) {
+	var iov Iovec;
+	if len(p) > 0 {
+		iov.Base = &p[0]
+	}
+	iov.SetLen(len(p));
+	control := make([]byte, cmsgAlign(cmsgLen(sizeofSctpSndrcvinfo)));
+	var msg Msghdr;
+	msg.Iov = &iov;
+	msg.SetIovlen(1);
+	msg.Control = &control[0];
+	msg.SetControllen(len(control));
+	if n, errno = recvmsg(fd, &msg, flags); errno != 0 {
+		return
+	}
+	clen := int(msg.Controllen);
+	for off := 0; off+cmsgLen(0) <= clen; {
+		h := (*Cmsghdr)(unsafe.Pointer(&control[off]));
+		l := int(h.Len);
+		if l < cmsgLen(0) || off+l > clen {
+			break
+		}
+		if h.Level == SOL_SCTP && h.Type == SCTP_SNDRCV && l >= cmsgLen(sizeofSctpSndrcvinfo) {
+			info := (*sctpSndrcvinfo)(unsafe.Pointer(&control[off+cmsgLen(0)]));
+			stream, ppid = int(info.Stream), info.Ppid;
+		}
+		off += cmsgAlign(l);
+	}
+	return;
+}
+
 //sys	ptrace(request int, pid int, addr uintptr, data uintptr) (errno int)
 
 // See bytes.Copy.
@@ -621,6 +839,7 @@ func PtraceDetach(pid int) (errno int)	{ return ptrace(PTRACE_DETACH, pid, 0, 0)
 //sys	exitThread(code int) (errno int) = SYS_EXIT
 //sys	read(fd int, p *byte, np int) (n int, errno int)
 //sys	write(fd int, p *byte, np int) (n int, errno int)
+//sys	writev(fd int, iov *Iovec, niov int) (n int, errno int)
 
 /*
  * Unimplemented
@@ -775,5 +994,4 @@ func PtraceDetach(pid int) (errno int)	{ return ptrace(PTRACE_DETACH, pid, 0, 0)
 // Vmsplice
 // Vserver
 // Waitid
-// Writev
 // _Sysctl
@@ -25,6 +25,31 @@ func NsecToTimeval(nsec int64) (tv Timeval) {
 	return;
 }
 
+func (iov *Iovec) SetLen(length int)	{ iov.Len = uint32(length) }
+
+// On 386, the six-argument mmap system call would need Syscall7;
+// the old mmap system call takes its arguments in memory instead.
+func mmap(addr uintptr, length uintptr, prot int, flags int, fd int, offset int64) (xaddr uintptr, errno int) {
+	if offset != int64(uint32(offset)) {
+		return 0, EINVAL
+	}
+	args := [6]uint32{uint32(addr), uint32(length), uint32(prot), uint32(flags), uint32(fd), uint32(offset)};
+	r0, _, e1 := Syscall(SYS_MMAP, uintptr(unsafe.Pointer(&args[0])), 0, 0);
+	return r0, int(e1);
+}
+
+func (msghdr *Msghdr) SetIovlen(length int)	{ msghdr.Iovlen = uint32(length) }
+
+func (msghdr *Msghdr) SetControllen(length int)	{ msghdr.Controllen = uint32(length) }
+
+func (cmsg *Cmsghdr) SetLen(length int)	{ cmsg.Len = uint32(length) }
+
+// System calls added after zsysnum_linux_386.go was generated.
+const (
+	_SYS_RECVMMSG	= 337;
+	_SYS_SENDMMSG	= 345;
+)
+
 // 64-bit file system and 32-bit uid calls
 // (386 default is 32-bit file system and 16-bit uid).
 //sys	Chown(path string, uid int, gid int) (errno int) = SYS_CHOWN32
@@ -122,6 +147,11 @@ func setsockopt(s int, level int, name int, val uintptr, vallen int) (errno int)
 	return;
 }
 
+func getsockopt(s int, level int, name int, val uintptr, vallen *_Socklen) (errno int) {
+	_, errno = socketcall(_GETSOCKOPT, uintptr(s), uintptr(level), uintptr(name), uintptr(val), uintptr(unsafe.Pointer(vallen)), 0);
+	return;
+}
+
 func recvfrom(s int, p []byte, flags int, from *RawSockaddrAny, fromlen *_Socklen) (n int, errno int) {
 	var base uintptr;
 	if len(p) > 0 {
@@ -140,11 +170,26 @@ func sendto(s int, p []byte, flags int, to uintptr, addrlen _Socklen) (errno int
 	return;
 }
 
+func recvmsg(s int, msg *Msghdr, flags int) (n int, errno int) {
+	n, errno = socketcall(_RECVMSG, uintptr(s), uintptr(unsafe.Pointer(msg)), uintptr(flags), 0, 0, 0);
+	return;
+}
+
+func sendmsg(s int, msg *Msghdr, flags int) (n int, errno int) {
+	n, errno = socketcall(_SENDMSG, uintptr(s), uintptr(unsafe.Pointer(msg)), uintptr(flags), 0, 0, 0);
+	return;
+}
+
 func Listen(s int, n int) (errno int) {
 	_, errno = socketcall(_LISTEN, uintptr(s), uintptr(n), 0, 0, 0, 0);
 	return;
 }
 
+func Shutdown(s, how int) (errno int) {
+	_, errno = socketcall(_SHUTDOWN, uintptr(s), uintptr(how), 0, 0, 0, 0);
+	return;
+}
+
 func (r *PtraceRegs) PC() uint64	{ return uint64(uint32(r.Eip)) }
 
 func (r *PtraceRegs) SetPC(pc uint64)	{ r.Eip = int32(pc) }
@@ -36,11 +36,14 @@ package syscall
 //sys	getgroups(n int, list *_Gid_t) (nn int, errno int)
 //sys	setgroups(n int, list *_Gid_t) (errno int)
 //sys	setsockopt(s int, level int, name int, val uintptr, vallen int) (errno int)
+//sys	getsockopt(s int, level int, name int, val uintptr, vallen *_Socklen) (errno int)
 //sys	socket(domain int, typ int, proto int) (fd int, errno int)
 //sys	getpeername(fd int, rsa *RawSockaddrAny, addrlen *_Socklen) (errno int)
 //sys	getsockname(fd int, rsa *RawSockaddrAny, addrlen *_Socklen) (errno int)
 //sys	recvfrom(fd int, p []byte, flags int, from *RawSockaddrAny, fromlen *_Socklen) (n int, errno int)
 //sys	sendto(s int, buf []byte, flags int, to uintptr, addrlen _Socklen) (errno int)
+//sys	recvmsg(s int, msg *Msghdr, flags int) (n int, errno int)
+//sys	sendmsg(s int, msg *Msghdr, flags int) (n int, errno int)
 
 func Getpagesize() int	{ return 4096 }
 
@@ -61,6 +64,25 @@ func NsecToTimeval(nsec int64) (tv Timeval) {
 	return;
 }
 
+func (iov *Iovec) SetLen(length int)	{ iov.Len = uint64(length) }
+
+func mmap(addr uintptr, length uintptr, prot int, flags int, fd int, offset int64) (xaddr uintptr, errno int) {
+	r0, _, e1 := Syscall6(SYS_MMAP, addr, length, uintptr(prot), uintptr(flags), uintptr(fd), uintptr(offset));
+	return r0, int(e1);
+}
+
+func (msghdr *Msghdr) SetIovlen(length int)	{ msghdr.Iovlen = uint64(length) }
+
+func (msghdr *Msghdr) SetControllen(length int)	{ msghdr.Controllen = uint64(length) }
+
+func (cmsg *Cmsghdr) SetLen(length int)	{ cmsg.Len = uint64(length) }
+
+// System calls added after zsysnum_linux_amd64.go was generated.
+const (
+	_SYS_RECVMMSG	= 299;
+	_SYS_SENDMMSG	= 307;
+)
+
 func (r *PtraceRegs) PC() uint64	{ return r.Rip }
 
 func (r *PtraceRegs) SetPC(pc uint64)	{ r.Rip = pc }
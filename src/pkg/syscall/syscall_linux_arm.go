@@ -21,17 +21,74 @@ func NsecToTimeval(nsec int64) (tv Timeval) {
 	return;
 }
 
+// On arm, mmap2 takes the offset in units of 4096 bytes.
+func mmap(addr uintptr, length uintptr, prot int, flags int, fd int, offset int64) (xaddr uintptr, errno int) {
+	page := offset / 4096;
+	if offset%4096 != 0 || page != int64(uint32(page)) {
+		return 0, EINVAL
+	}
+	r0, _, e1 := Syscall6(SYS_MMAP2, addr, length, uintptr(prot), uintptr(flags), uintptr(fd), uintptr(page));
+	return r0, int(e1);
+}
+
+// Iovec, Msghdr, and _Mmsghdr are missing from ztypes_linux_arm.go.
+type Iovec struct {
+	Base	*byte;
+	Len	uint32;
+}
+
+func (iov *Iovec) SetLen(length int)	{ iov.Len = uint32(length) }
+
+type Msghdr struct {
+	Name		*byte;
+	Namelen		uint32;
+	Iov		*Iovec;
+	Iovlen		uint32;
+	Control		*byte;
+	Controllen	uint32;
+	Flags		int32;
+}
+
+type _Mmsghdr struct {
+	Hdr	Msghdr;
+	Len	uint32;
+}
+
+func (msghdr *Msghdr) SetIovlen(length int)	{ msghdr.Iovlen = uint32(length) }
+
+// Cmsghdr is missing from ztypes_linux_arm.go.
+type Cmsghdr struct {
+	Len	uint32;
+	Level	int32;
+	Type	int32;
+}
+
+const SizeofCmsghdr = 0xc
+
+func (msghdr *Msghdr) SetControllen(length int)	{ msghdr.Controllen = uint32(length) }
+
+func (cmsg *Cmsghdr) SetLen(length int)	{ cmsg.Len = uint32(length) }
+
+// System calls added after zsysnum_linux_arm.go was generated.
+const (
+	_SYS_RECVMMSG	= (SYS_SYSCALL_BASE + 365);
+	_SYS_SENDMMSG	= (SYS_SYSCALL_BASE + 374);
+)
+
 //sys	accept(s int, rsa *RawSockaddrAny, addrlen *_Socklen) (fd int, errno int)
 //sys	bind(s int, addr uintptr, addrlen _Socklen) (errno int)
 //sys	connect(s int, addr uintptr, addrlen _Socklen) (errno int)
 //sys	getgroups(n int, list *_Gid_t) (nn int, errno int) = SYS_GETGROUPS32
 //sys	setgroups(n int, list *_Gid_t) (errno int) = SYS_SETGROUPS32
 //sys	setsockopt(s int, level int, name int, val uintptr, vallen int) (errno int)
+//sys	getsockopt(s int, level int, name int, val uintptr, vallen *_Socklen) (errno int)
 //sys	socket(domain int, typ int, proto int) (fd int, errno int)
 //sys	getpeername(fd int, rsa *RawSockaddrAny, addrlen *_Socklen) (errno int)
 //sys	getsockname(fd int, rsa *RawSockaddrAny, addrlen *_Socklen) (errno int)
 //sys	recvfrom(fd int, p []byte, flags int, from *RawSockaddrAny, fromlen *_Socklen) (n int, errno int)
 //sys	sendto(s int, buf []byte, flags int, to uintptr, addrlen _Socklen) (errno int)
+//sys	recvmsg(s int, msg *Msghdr, flags int) (n int, errno int)
+//sys	sendmsg(s int, msg *Msghdr, flags int) (n int, errno int)
 
 //sys	Chown(path string, uid int, gid int) (errno int)
 //sys	Fchown(fd int, uid int, gid int) (errno int)
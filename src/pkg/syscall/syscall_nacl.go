@@ -263,10 +263,45 @@ func SetsockoptInt(fd, level, opt int, value int) (errno int) {
 	return ENACL
 }
 
+func GetsockoptInt(fd, level, opt int) (value, errno int) {
+	return 0, ENACL
+}
+
 func SetsockoptTimeval(fd, level, opt int, tv *Timeval) (errno int) {
 	return ENACL
 }
 
+func Writev(fd int, p [][]byte) (n int, errno int)	{ return 0, ENACL }
+
+// Protection and flags for Mmap not in ztypes_nacl_386.go.
+const (
+	PROT_NONE	= 0x0;
+	PROT_EXEC	= 0x4;
+	MAP_PRIVATE	= 0x2;
+)
+
+func Mmap(fd int, offset int64, length int, prot int, flags int) (data []byte, errno int) {
+	return nil, ENACL
+}
+
+func Munmap(data []byte) (errno int)	{ return ENACL }
+
+func Recvmmsg(fd int, p [][]byte, flags int) (n int, lens []int, from []Sockaddr, errno int) {
+	return 0, nil, nil, ENACL
+}
+
+func Sendmmsg(fd int, p [][]byte, to []Sockaddr, flags int) (n int, errno int) {
+	return 0, ENACL
+}
+
+func SctpSendmsg(fd int, p []byte, stream int, ppid uint32, flags int) (n int, errno int) {
+	return 0, ENACL
+}
+
+func SctpRecvmsg(fd int, p []byte, flags int) (n, stream int, ppid uint32, errno int) {
+	return 0, 0, 0, ENACL
+}
+
 type Linger struct {
 	Onoff	int32;
 	Linger	int32;
@@ -108,6 +108,15 @@ typedef struct iovec $Iovec;
 typedef struct msghdr $Msghdr;
 typedef struct cmsghdr $Cmsghdr;
 
+// struct mmsghdr, used by sendmmsg and recvmmsg,
+// is missing from older C libraries.
+struct my_mmsghdr {
+	struct msghdr msg_hdr;
+	unsigned int msg_len;
+};
+
+typedef struct my_mmsghdr $_Mmsghdr;
+
 enum {
 	$SizeofSockaddrInet4 = sizeof(struct sockaddr_in),
 	$SizeofSockaddrInet6 = sizeof(struct sockaddr_in6),
@@ -65,6 +65,12 @@ func setsockopt(s int, level int, name int, val uintptr, vallen int) (errno int)
 	return;
 }
 
+func getsockopt(s int, level int, name int, val uintptr, vallen *_Socklen) (errno int) {
+	_, _, e1 := Syscall6(SYS_GETSOCKOPT, uintptr(s), uintptr(level), uintptr(name), uintptr(val), uintptr(unsafe.Pointer(vallen)), 0);
+	errno = int(e1);
+	return;
+}
+
 func getpeername(fd int, rsa *RawSockaddrAny, addrlen *_Socklen) (errno int) {
 	_, _, e1 := Syscall(SYS_GETPEERNAME, uintptr(fd), uintptr(unsafe.Pointer(rsa)), uintptr(unsafe.Pointer(addrlen)));
 	errno = int(e1);
@@ -587,6 +593,12 @@ func Setuid(uid int) (errno int) {
 	return;
 }
 
+func Shutdown(s int, how int) (errno int) {
+	_, _, e1 := Syscall(SYS_SHUTDOWN, uintptr(s), uintptr(how), 0);
+	errno = int(e1);
+	return;
+}
+
 func Stat(path string, stat *Stat_t) (errno int) {
 	_, _, e1 := Syscall(SYS_STAT64, uintptr(unsafe.Pointer(StringBytePtr(path))), uintptr(unsafe.Pointer(stat)), 0);
 	errno = int(e1);
@@ -666,6 +678,13 @@ func write(fd int, buf *byte, nbuf int) (n int, errno int) {
 	return;
 }
 
+func writev(fd int, iov *Iovec, niov int) (n int, errno int) {
+	r0, _, e1 := Syscall(SYS_WRITEV, uintptr(fd), uintptr(unsafe.Pointer(iov)), uintptr(niov));
+	n = int(r0);
+	errno = int(e1);
+	return;
+}
+
 func gettimeofday(tp *Timeval) (sec int64, usec int32, errno int) {
 	r0, r1, e1 := Syscall(SYS_GETTIMEOFDAY, uintptr(unsafe.Pointer(tp)), 0, 0);
 	sec = int64(r0);
@@ -580,6 +580,13 @@ func write(fd int, p *byte, np int) (n int, errno int) {
 	return;
 }
 
+func writev(fd int, iov *Iovec, niov int) (n int, errno int) {
+	r0, _, e1 := Syscall(SYS_WRITEV, uintptr(fd), uintptr(unsafe.Pointer(iov)), uintptr(niov));
+	n = int(r0);
+	errno = int(e1);
+	return;
+}
+
 func Chown(path string, uid int, gid int) (errno int) {
 	_, _, e1 := Syscall(SYS_CHOWN32, uintptr(unsafe.Pointer(StringBytePtr(path))), uintptr(uid), uintptr(gid));
 	errno = int(e1);
@@ -580,6 +580,13 @@ func write(fd int, p *byte, np int) (n int, errno int) {
 	return;
 }
 
+func writev(fd int, iov *Iovec, niov int) (n int, errno int) {
+	r0, _, e1 := Syscall(SYS_WRITEV, uintptr(fd), uintptr(unsafe.Pointer(iov)), uintptr(niov));
+	n = int(r0);
+	errno = int(e1);
+	return;
+}
+
 func accept(s int, rsa *RawSockaddrAny, addrlen *_Socklen) (fd int, errno int) {
 	r0, _, e1 := Syscall(SYS_ACCEPT, uintptr(s), uintptr(unsafe.Pointer(rsa)), uintptr(unsafe.Pointer(addrlen)));
 	fd = int(r0);
@@ -618,6 +625,12 @@ func setsockopt(s int, level int, name int, val uintptr, vallen int) (errno int)
 	return;
 }
 
+func getsockopt(s int, level int, name int, val uintptr, vallen *_Socklen) (errno int) {
+	_, _, e1 := Syscall6(SYS_GETSOCKOPT, uintptr(s), uintptr(level), uintptr(name), uintptr(val), uintptr(unsafe.Pointer(vallen)), 0);
+	errno = int(e1);
+	return;
+}
+
 func socket(domain int, typ int, proto int) (fd int, errno int) {
 	r0, _, e1 := Syscall(SYS_SOCKET, uintptr(domain), uintptr(typ), uintptr(proto));
 	fd = int(r0);
@@ -796,3 +809,17 @@ func sendto(s int, buf []byte, flags int, to uintptr, addrlen _Socklen) (errno i
 	errno = int(e1);
 	return;
 }
+
+func recvmsg(s int, msg *Msghdr, flags int) (n int, errno int) {
+	r0, _, e1 := Syscall(SYS_RECVMSG, uintptr(s), uintptr(unsafe.Pointer(msg)), uintptr(flags));
+	n = int(r0);
+	errno = int(e1);
+	return;
+}
+
+func sendmsg(s int, msg *Msghdr, flags int) (n int, errno int) {
+	r0, _, e1 := Syscall(SYS_SENDMSG, uintptr(s), uintptr(unsafe.Pointer(msg)), uintptr(flags));
+	n = int(r0);
+	errno = int(e1);
+	return;
+}
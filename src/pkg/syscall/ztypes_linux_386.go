@@ -217,6 +217,11 @@ type Cmsghdr struct {
 	Type	int32;
 }
 
+type _Mmsghdr struct {
+	Hdr	Msghdr;
+	Len	uint32;
+}
+
 type PtraceRegs struct {
 	Ebx		int32;
 	Ecx		int32;
@@ -219,6 +219,12 @@ type Cmsghdr struct {
 	Type	int32;
 }
 
+type _Mmsghdr struct {
+	Hdr	Msghdr;
+	Len	uint32;
+	Pad0	[4]byte;
+}
+
 type PtraceRegs struct {
 	R15		uint64;
 	R14		uint64;
@@ -0,0 +1,266 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iotest
+
+import (
+	"bytes";
+	"fmt";
+	"io";
+	"os";
+	"rand";
+	"testing";
+)
+
+// The Check functions test that an implementation of io.Reader or
+// io.Writer follows the conventions its callers rely on:
+//
+//	Read and Write never report more bytes than requested;
+//	a Read or Write of zero bytes does not consume or lose data;
+//	a Write of fewer bytes than requested returns an error;
+//	at the end of the data, Read returns os.EOF, and keeps doing so;
+//	the data arrives intact regardless of the sizes of the calls.
+//
+// The tests report failures with t.Errorf. They are deterministic:
+// the data and the sizes of the calls are pseudo-random, with fixed
+// seeds.
+
+// checkSizes are the sizes of the data the Check functions transfer.
+var checkSizes = []int{0, 1, 7, 64, 1000, 4099}
+
+// errAfter is the error returned by the readers of CheckFilter.
+var errAfter = os.NewError("iotest: error after data")
+
+// checkData returns n bytes of pseudo-random data.
+func checkData(n int) []byte {
+	rnd := rand.New(rand.NewSource(int64(n)));
+	data := make([]byte, n);
+	for i := range data {
+		data[i] = byte(rnd.Int())
+	}
+	return data;
+}
+
+// readChunks reads from r until an error, with reads of random
+// sizes up to max bytes, and returns the data and the error.
+// It reports reads that return more bytes than requested and
+// readers that make no progress.
+func readChunks(t *testing.T, name string, r io.Reader, rnd *rand.Rand, max int) ([]byte, os.Error) {
+	var buf bytes.Buffer;
+	p := make([]byte, max);
+	empty := 0;
+	for {
+		m := rnd.Intn(max + 1);
+		n, err := r.Read(p[0:m]);
+		if n < 0 || n > m {
+			t.Errorf("%s: Read of %d bytes returned %d", name, m, n);
+			return buf.Bytes(), err;
+		}
+		buf.Write(p[0:n]);
+		if err != nil {
+			return buf.Bytes(), err
+		}
+		if n > 0 || m == 0 {
+			empty = 0;
+			continue;
+		}
+		empty++;
+		if empty > 100 {
+			t.Errorf("%s: Read makes no progress", name);
+			return buf.Bytes(), nil;
+		}
+	}
+	panic("unreachable");
+}
+
+// checkEOF checks that a reader at the end of its data returns os.EOF
+// and keeps doing so.
+func checkEOF(t *testing.T, name string, r io.Reader) {
+	var p [10]byte;
+	for i := 0; i < 2; i++ {
+		if n, err := r.Read(&p); n != 0 || err != os.EOF {
+			t.Errorf("%s: Read after EOF = %d, %v; want 0, os.EOF", name, n, err)
+		}
+	}
+}
+
+// CheckReader tests the Reader returned by newReader, which must read
+// the given data.
+func CheckReader(t *testing.T, newReader func(data []byte) io.Reader) {
+	rnd := rand.New(rand.NewSource(1));
+	for _, size := range checkSizes {
+		data := checkData(size);
+
+		// a Read of zero bytes comes first and must not consume data
+		name := fmt.Sprintf("%d bytes, zero-byte read", size);
+		r := newReader(data);
+		if n, err := r.Read(nil); n != 0 || err != nil && err != os.EOF {
+			t.Errorf("%s: Read = %d, %v; want 0, nil", name, n, err)
+		}
+		got, err := readChunks(t, name, r, rnd, 1);
+		if err != os.EOF {
+			t.Errorf("%s: Read: %v", name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: got %d bytes, not the %d bytes of data", name, len(got), size)
+		}
+		checkEOF(t, name, r);
+
+		// reads of random sizes
+		for _, max := range []int{16, 1000, 2*size + 1} {
+			name := fmt.Sprintf("%d bytes, reads up to %d bytes", size, max);
+			r := newReader(data);
+			got, err := readChunks(t, name, r, rnd, max);
+			if err != os.EOF {
+				t.Errorf("%s: Read: %v", name, err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("%s: got %d bytes, not the %d bytes of data", name, len(got), size)
+			}
+			checkEOF(t, name, r);
+		}
+	}
+}
+
+// writeChunks writes data to w with writes of random sizes up to max
+// bytes, interspersed with writes of zero bytes, and reports violations
+// of the Writer conventions. It returns the first error.
+func writeChunks(t *testing.T, name string, w io.Writer, data []byte, rnd *rand.Rand, max int) os.Error {
+	for len(data) > 0 {
+		if n, err := w.Write(data[0:0]); n != 0 || err != nil {
+			t.Errorf("%s: Write of zero bytes = %d, %v; want 0, nil", name, n, err);
+			return err;
+		}
+		m := rnd.Intn(max) + 1;
+		if m > len(data) {
+			m = len(data)
+		}
+		n, err := w.Write(data[0:m]);
+		if n < 0 || n > m {
+			t.Errorf("%s: Write of %d bytes returned %d", name, m, n)
+		}
+		if err != nil {
+			return err
+		}
+		if n != m {
+			t.Errorf("%s: Write of %d bytes returned %d without error", name, m, n);
+			return io.ErrShortWrite;
+		}
+		data = data[m:len(data)];
+	}
+	return nil;
+}
+
+// CheckWriter tests the Writer returned by newWriter, which must
+// accept any amount of data. After the test has written its data,
+// it calls written to get the data the Writer received.
+func CheckWriter(t *testing.T, newWriter func() (w io.Writer, written func() []byte)) {
+	rnd := rand.New(rand.NewSource(1));
+	for _, size := range checkSizes {
+		data := checkData(size);
+		for _, max := range []int{1, 16, 1000, size + 1} {
+			name := fmt.Sprintf("%d bytes, writes up to %d bytes", size, max);
+			w, written := newWriter();
+			if err := writeChunks(t, name, w, data, rnd, max); err != nil {
+				t.Errorf("%s: Write: %v", name, err);
+				continue;
+			}
+			if got := written(); !bytes.Equal(got, data) {
+				t.Errorf("%s: wrote %d bytes, not the %d bytes of data", name, len(got), size)
+			}
+		}
+	}
+}
+
+// CheckFilter tests the Reader returned by newReader, which must pass
+// on the data of the Reader r unchanged, such as a buffering Reader.
+// The Readers r return short reads, return their final error with the
+// last data, and fail with an error after a part of their data, which
+// the Reader of newReader must return after passing on that data.
+func CheckFilter(t *testing.T, newReader func(r io.Reader) io.Reader) {
+	type source struct {
+		name	string;
+		wrap	func(io.Reader) io.Reader;
+	}
+	sources := []source{
+		source{"plain", func(r io.Reader) io.Reader { return r }},
+		source{"OneByteReader", OneByteReader},
+		source{"HalfReader", HalfReader},
+		source{"DataErrReader", DataErrReader},
+	};
+	rnd := rand.New(rand.NewSource(1));
+	for _, size := range checkSizes {
+		data := checkData(size);
+		for _, s := range sources {
+			name := fmt.Sprintf("%d bytes, %s", size, s.name);
+			r := newReader(s.wrap(bytes.NewBuffer(data)));
+			got, err := readChunks(t, name, r, rnd, 100);
+			if err != os.EOF {
+				t.Errorf("%s: Read: %v", name, err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("%s: got %d bytes, not the %d bytes of data", name, len(got), size)
+			}
+			checkEOF(t, name, r);
+		}
+
+		// an error after half of the data
+		name := fmt.Sprintf("%d bytes, error after %d bytes", size, size/2);
+		r := newReader(ErrReader(bytes.NewBuffer(data), int64(size/2), errAfter));
+		got, err := readChunks(t, name, r, rnd, 100);
+		if err != errAfter {
+			t.Errorf("%s: Read: %v; want %v", name, err, errAfter)
+		}
+		if !bytes.Equal(got, data[0:size/2]) {
+			t.Errorf("%s: got %d bytes, not the %d bytes of data", name, len(got), size/2)
+		}
+	}
+}
+
+// CheckPipe tests the connected Reader and Writer returned by newPipe,
+// whose Writer blocks until the data is read, such as io.Pipe: data
+// written in calls of any size must arrive intact, closing the Writer
+// must make the Reader return os.EOF, and closing the Reader must make
+// Writes fail.
+func CheckPipe(t *testing.T, newPipe func() (io.ReadCloser, io.WriteCloser)) {
+	rnd := rand.New(rand.NewSource(1));
+	for _, size := range checkSizes {
+		data := checkData(size);
+		for _, max := range []int{1, 100, size + 1} {
+			name := fmt.Sprintf("%d bytes, calls up to %d bytes", size, max);
+			r, w := newPipe();
+			done := make(chan bool);
+			wrnd := rand.New(rand.NewSource(int64(max)));
+			go func() {
+				if err := writeChunks(t, name, w, data, wrnd, max); err != nil {
+					t.Errorf("%s: Write: %v", name, err)
+				}
+				if err := w.Close(); err != nil {
+					t.Errorf("%s: Close of Writer: %v", name, err)
+				}
+				done <- true;
+			}();
+			got, err := readChunks(t, name, r, rnd, max);
+			if err != os.EOF {
+				t.Errorf("%s: Read: %v", name, err)
+			}
+			<-done;
+			if !bytes.Equal(got, data) {
+				t.Errorf("%s: got %d bytes, not the %d bytes of data", name, len(got), size)
+			}
+			checkEOF(t, name, r);
+			r.Close();
+		}
+	}
+
+	// closing the Reader makes Writes fail
+	r, w := newPipe();
+	if err := r.Close(); err != nil {
+		t.Errorf("Close of Reader: %v", err)
+	}
+	if n, err := w.Write(checkData(10)); err == nil {
+		t.Errorf("Write after Close of Reader = %d, nil; want error", n)
+	}
+	w.Close();
+}
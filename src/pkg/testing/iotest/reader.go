@@ -68,3 +68,30 @@ func (r *dataErrReader) Read(p []byte) (n int, err os.Error) {
 	}
 	return;
 }
+
+// ErrReader returns a Reader that reads the first n bytes
+// from r and then returns err.
+func ErrReader(r io.Reader, n int64, err os.Error) io.Reader {
+	return &errReader{r, n, err}
+}
+
+type errReader struct {
+	r	io.Reader;
+	n	int64;
+	err	os.Error;
+}
+
+func (r *errReader) Read(p []byte) (n int, err os.Error) {
+	if r.n <= 0 {
+		return 0, r.err
+	}
+	if int64(len(p)) > r.n {
+		p = p[0:int(r.n)]
+	}
+	n, err = r.r.Read(p);
+	r.n -= int64(n);
+	if err == nil && r.n <= 0 {
+		err = r.err
+	}
+	return;
+}